@@ -0,0 +1,81 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/**
+ * Built-in analytics sinks.
+ * @enum {string}
+ */
+export const AnalyticsSinkType = {
+  // POSTs batches of events as JSON to the `url` of the sink.
+  HTTP_COLLECTOR: 'httpCollector',
+  // Pushes events to `window.dataLayer`, e.g. for Google Tag Manager.
+  DATA_LAYER: 'dataLayer',
+};
+
+/**
+ * A SwG event, as sent to analytics sinks. Properties:
+ * - eventType - the name of the `AnalyticsEvent`, e.g. "IMPRESSION_OFFERS".
+ * - originator - the name of the `EventOriginator`, e.g. "SWG_CLIENT".
+ * - isFromUserAction - whether the reader's action caused the event.
+ * - flow - the subscription flow of the event, e.g. "subscribe", if any.
+ * - sku - the SKU the event is about, if any.
+ * - transactionId - the ID of the reader's transaction, which is shared by
+ *   the events of the page.
+ * - labels - the experiments and labels of the page.
+ * - timestamp - when the event happened, in milliseconds since the epoch.
+ *
+ * @typedef {{
+ *   eventType: string,
+ *   originator: string,
+ *   isFromUserAction: boolean,
+ *   flow: ?string,
+ *   sku: ?string,
+ *   transactionId: ?string,
+ *   labels: !Array<string>,
+ *   timestamp: number,
+ * }}
+ */
+export let AnalyticsSinkEvent;
+
+/**
+ * Receives the SwG events of the page, e.g. to forward them to the
+ * publisher's analytics pipeline. Publishers may supply their own
+ * implementation via the `analyticsSinks` config property.
+ *
+ * Errors thrown by a sink are logged, and don't affect the other sinks.
+ * @interface
+ */
+export class AnalyticsSink {
+  /**
+   * @param {!AnalyticsSinkEvent} unusedEvent
+   * @return {void|!Promise}
+   */
+  send(unusedEvent) {}
+}
+
+/**
+ * A built-in sink, e.g. `{type: 'httpCollector', url: '/collect'}`.
+ * Properties:
+ * - type - the `AnalyticsSinkType` of the sink.
+ * - url - the URL the HTTP collector sends batches to. Required for HTTP
+ *   collectors.
+ *
+ * @typedef {{
+ *   type: !AnalyticsSinkType,
+ *   url: (string|undefined),
+ * }}
+ */
+export let AnalyticsSinkConfig;
@@ -36,11 +36,37 @@ export class BasicSubscriptions {
    *   isPartOfType: (string|!Array<string>),
    *   isPartOfProductId: string,
    *   autoPromptType: (AutoPromptType|undefined),
+   *   autoPromptRules: (!Array<!AutoPromptRule>|undefined),
+   *   autoPromptTriggers: (!AutoPromptTriggers|undefined),
+   *   autoPromptFrequencyCaps: (!Array<!AutoPromptFrequencyCap>|undefined),
+   *   campaign: (string|undefined),
+   *   trackNavigation: (boolean|undefined),
    *   clientOptions: (ClientOptions|undefined),
    * }=} params
    */
   init(params) {}
 
+  /**
+   * Switches to the article of the current page after a client-side
+   * navigation in a single page application, whose markup must already
+   * describe the new article. Cancels the pending auto prompt, closes the
+   * open dialogs, fetches the entitlements of the new article, and then shows
+   * the auto prompt of the last setupAndShowAutoPrompt call again, subject to
+   * its rules and frequency caps. Setting trackNavigation in init calls it
+   * automatically on navigations made through the History API.
+   * @return {?}
+   */
+  navigate() {}
+
+  /**
+   * Tears down the runtime: cancels the pending auto prompt, closes the open
+   * dialogs, removes the iframes and the listeners added to the page, and
+   * uninstalls the `SWG_BASIC` global. Other calls are rejected afterwards,
+   * and a new runtime can be installed.
+   * @return {!Promise}
+   */
+  destroy() {}
+
   /**
    * Set the entitlement check callback.
    * @param {function(!Promise<!EntitlementsDef>)} callback
@@ -70,10 +96,20 @@ export class BasicSubscriptions {
    * autoPromptType specifies which type of prompt should be displayed (see
    * AutoPromptType below). The alwaysShow parameter is an option to force show
    * the prompt, regardless of any display rules. This parameter is intended for
-   * preview purposes.
+   * preview purposes. The autoPromptRules parameter replaces the targeting
+   * rules configured for the publication (see AutoPromptRule below), and the
+   * autoPromptTriggers parameter overrides its display triggers (see
+   * AutoPromptTriggers below). The autoPromptFrequencyCaps parameter replaces
+   * the frequency caps configured for the publication (see
+   * AutoPromptFrequencyCap below), and campaign identifies the campaign the
+   * prompt belongs to, for the caps of that campaign.
    * @param {{
    *   autoPromptType: (!AutoPromptType|undefined),
    *   alwaysShow: (boolean|undefined),
+   *   autoPromptRules: (!Array<!AutoPromptRule>|undefined),
+   *   autoPromptTriggers: (!AutoPromptTriggers|undefined),
+   *   autoPromptFrequencyCaps: (!Array<!AutoPromptFrequencyCap>|undefined),
+   *   campaign: (string|undefined),
    * }} options
    * @returns {!Promise}
    */
@@ -103,6 +139,104 @@ export const AutoPromptType = {
   SUBSCRIPTION_LARGE: 'subscription_large',
 };
 
+/**
+ * The signals an auto prompt targeting rule can check.
+ * - SESSION_PAGEVIEWS: Pages viewed in the current browser session, including
+ *   this one.
+ * - ARTICLE_COUNT: Pages viewed in the past week, including this one.
+ * - TIME_ON_PAGE: Seconds since the runtime started on this page.
+ * - REFERRER: Host of the referring page. An empty string matches direct
+ *   visits.
+ * - PROPENSITY_BUCKET: Bucketed propensity to subscribe, between 1 and 20.
+ * - DEVICE_CLASS: One of "mobile", "tablet" or "desktop".
+ * - DAY_PART: Local hour of the day, between 0 and 23.
+ * @enum {string}
+ */
+export const AutoPromptRuleType = {
+  SESSION_PAGEVIEWS: 'sessionPageviews',
+  ARTICLE_COUNT: 'articleCount',
+  TIME_ON_PAGE: 'timeOnPage',
+  REFERRER: 'referrer',
+  PROPENSITY_BUCKET: 'propensityBucket',
+  DEVICE_CLASS: 'deviceClass',
+  DAY_PART: 'dayPart',
+};
+
+/**
+ * A targeting rule the auto prompt must satisfy to be displayed.
+ * Properties:
+ * - id: Optional. Identifies the rule when it blocks the prompt. Defaults to
+ *   the type.
+ * - type: Required. The signal checked by the rule.
+ * - min: Lowest allowed value of a numeric signal, inclusive.
+ * - max: Highest allowed value of a numeric signal, inclusive. For DAY_PART,
+ *   a max lower than min wraps around midnight.
+ * - values: Allowed values of REFERRER and DEVICE_CLASS. Referrer hosts also
+ *   match their subdomains.
+ *
+ * @typedef {{
+ *   id: (string|undefined),
+ *   type: !AutoPromptRuleType,
+ *   min: (number|undefined),
+ *   max: (number|undefined),
+ *   values: (!Array<string>|undefined),
+ * }}
+ */
+export let AutoPromptRule;
+
+/**
+ * When to display an eligible auto prompt. After the display delay, the prompt
+ * is displayed as soon as any of the engagement triggers fires, or right away
+ * if none is set.
+ * Properties:
+ * - displayDelaySeconds: Delay before the triggers are checked.
+ * - scrollDepthPercent: Fires once the reader scrolled this percentage of the
+ *   page.
+ * - visibleElementSelector: Fires once the element matching this selector
+ *   enters the viewport. Ignored if no element matches it.
+ * - exitIntent: Fires when the pointer leaves the window through its top.
+ * - idleSeconds: Fires once the reader was inactive for this long.
+ * - nthArticle: Only displays the prompt from this page view of the session
+ *   on.
+ *
+ * @typedef {{
+ *   displayDelaySeconds: (number|undefined),
+ *   scrollDepthPercent: (number|undefined),
+ *   visibleElementSelector: (string|undefined),
+ *   exitIntent: (boolean|undefined),
+ *   idleSeconds: (number|undefined),
+ *   nthArticle: (number|undefined),
+ * }}
+ */
+export let AutoPromptTriggers;
+
+/**
+ * A cap on the impressions or dismissals of auto prompts. The prompt isn't
+ * displayed once the cap is reached.
+ * Properties:
+ * - id: Optional. Identifies the cap when it blocks the prompt.
+ * - event: Required. Either "impression" or "dismissal".
+ * - max: Required. Number of events reaching the cap.
+ * - windowSeconds: Optional. Length of the rolling window the events are
+ *   counted in, e.g. 86400 for a day. Counts all the events ever recorded if
+ *   unset. Since only the last 50 events of the past 30 days are kept, caps
+ *   with a longer window or a higher max are ignored.
+ * - autoPromptType: Optional. Counts the events of this prompt type. Defaults
+ *   to the type of the displayed prompt.
+ * - campaign: Optional. Counts the events of the prompts of this campaign
+ *   instead of a prompt type.
+ *
+ * @typedef {{
+ *   id: (string|undefined),
+ *   event: string,
+ *   max: number,
+ *   windowSeconds: (number|undefined),
+ *   autoPromptType: (!AutoPromptType|undefined),
+ *   campaign: (string|undefined),
+ * }}
+ */
+export let AutoPromptFrequencyCap;
+
 /**
  * Options for configuring all client UI.
  * Properties:
@@ -45,6 +45,34 @@ export const FilterResult = {
  */
 export let ClientEvent;
 
+/**
+ * Options of a listener or filterer.
+ * Properties:
+ * - eventTypes: Optional. Only the events of these types are passed to it.
+ * - eventOriginators: Optional. Only the events from these originators are
+ *   passed to it.
+ * - priority: Optional. Listeners and filterers with a higher priority are
+ *   called first. Defaults to 0. Those with the same priority are called in
+ *   the order they were registered in.
+ *
+ *  @typedef {{
+ *    eventTypes: (!Array<!AnalyticsEventDef>|undefined),
+ *    eventOriginators: (!Array<!EventOriginatorDef>|undefined),
+ *    priority: (number|undefined),
+ * }}
+ */
+export let ClientEventListenerOptions;
+
+/**
+ * The registration of a listener or filterer. Calling `unsubscribe` removes
+ * it, including from the events being processed.
+ *
+ *  @typedef {{
+ *    unsubscribe: function(),
+ * }}
+ */
+export let ClientEventSubscription;
+
 /* eslint-disable no-unused-vars */
 /**
  * @interface
@@ -52,10 +80,14 @@ export let ClientEvent;
 export class ClientEventManagerApi {
   /**
    * Call this function to log an event. The registered listeners will be
-   * invoked unless the event is filtered.
-   * @param {!function(!ClientEvent)} listener
+   * invoked unless the event is filtered, in priority order. A listener can
+   * return a promise, which the event's processing waits for. The listeners
+   * after it are still called right away, without waiting for it.
+   * @param {!function(!ClientEvent):(!Promise|undefined)} listener
+   * @param {!ClientEventListenerOptions=} options
+   * @return {!ClientEventSubscription}
    */
-  registerEventListener(listener) {}
+  registerEventListener(listener, options) {}
 
   /**
    * Register a filterer for events if you need to potentially prevent the
@@ -63,8 +95,10 @@ export class ClientEventManagerApi {
    * FilterResult.CANCEL_EVENT to prevent listeners from hearing about the
    * event.
    * @param {!function(!ClientEvent):FilterResult} filterer
+   * @param {!ClientEventListenerOptions=} options
+   * @return {!ClientEventSubscription}
    */
-  registerEventFilterer(filterer) {}
+  registerEventFilterer(filterer, options) {}
 
   /**
    * Call this function to log an event.  It will immediately throw an error if
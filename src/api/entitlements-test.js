@@ -85,6 +85,19 @@ describes.realWin('Entitlements', {}, () => {
     });
   });
 
+  describe('clone', () => {
+    it('keeps the stale flag and source', () => {
+      expect(entitlements.isStale).to.be.false;
+      expect(entitlements.source).to.be.null;
+      entitlements.isStale = true;
+      entitlements.source = 'cache-offline';
+
+      const clone = entitlements.clone();
+      expect(clone.isStale).to.be.true;
+      expect(clone.source).to.equal('cache-offline');
+    });
+  });
+
   describe('consume', () => {
     it('executes consume handler', () => {
       const consumeHandlerSpy = sandbox.spy();
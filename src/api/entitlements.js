@@ -25,6 +25,12 @@ export const GOOGLE_METERING_SOURCE = 'google:metering';
 /** Source for privileged entitlements. */
 export const PRIVILEGED_SOURCE = 'privileged';
 
+/**
+ * Source of entitlements served from the offline snapshot because the server
+ * couldn't be reached. See `Entitlements.source`.
+ */
+export const CACHE_OFFLINE_SOURCE = 'cache-offline';
+
 /** Subscription token for dev mode entitlements. */
 export const DEV_MODE_TOKEN = 'GOOGLE_DEV_MODE_TOKEN';
 
@@ -62,6 +68,18 @@ export class Entitlements {
     this.isReadyToPay = isReadyToPay || false;
     /** @const {?string} */
     this.decryptedDocumentKey = decryptedDocumentKey || null;
+    /**
+     * Whether these entitlements were served from the offline snapshot
+     * ("cache-offline") because the server couldn't be reached.
+     * @type {boolean}
+     */
+    this.isStale = false;
+    /**
+     * Where the entitlements were served from, if not from the server, e.g.
+     * `CACHE_OFFLINE_SOURCE`.
+     * @type {?string}
+     */
+    this.source = null;
 
     /** @private @const {?string} */
     this.product_ = currentProduct;
@@ -75,7 +93,7 @@ export class Entitlements {
    * @return {!Entitlements}
    */
   clone() {
-    return new Entitlements(
+    const entitlements = new Entitlements(
       this.service,
       this.raw,
       this.entitlements.map((ent) => ent.clone()),
@@ -85,6 +103,9 @@ export class Entitlements {
       this.isReadyToPay,
       this.decryptedDocumentKey
     );
+    entitlements.isStale = this.isStale;
+    entitlements.source = this.source;
+    return entitlements;
   }
 
   /**
@@ -0,0 +1,77 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/**
+ * Built-in storage backends.
+ * @enum {string}
+ */
+export const StorageBackendType = {
+  // Window.sessionStorage. Cleared when the tab is closed.
+  SESSION_STORAGE: 'sessionStorage',
+  // Window.localStorage. Persists across tabs and sessions.
+  LOCAL_STORAGE: 'localStorage',
+  // An IndexedDB object store. Shared across tabs. In session chains, it's
+  // cleared when a new browser session starts, like session cookies.
+  INDEXED_DB: 'indexedDB',
+  // First-party cookies on the publisher's domain.
+  COOKIE: 'cookie',
+  // In-memory map. Lost on page unload.
+  MEMORY: 'memory',
+};
+
+/**
+ * Storage backend used by the runtime to persist its state. Publishers may
+ * supply their own implementation via the `storageBackends` config property.
+ *
+ * Every method may either return synchronously or return a promise. A method
+ * that throws or rejects marks the operation as failed, and the runtime falls
+ * back to the next backend in the configured chain.
+ * @interface
+ */
+export class StorageBackend {
+  /**
+   * @param {string} unusedKey
+   * @return {?string|!Promise<?string>}
+   */
+  getItem(unusedKey) {}
+
+  /**
+   * @param {string} unusedKey
+   * @param {string} unusedValue
+   * @return {void|!Promise}
+   */
+  setItem(unusedKey, unusedValue) {}
+
+  /**
+   * @param {string} unusedKey
+   * @return {void|!Promise}
+   */
+  removeItem(unusedKey) {}
+}
+
+/**
+ * Ordered chains of storage backends. The `session` chain stores state that
+ * should only last for the current session, such as cached entitlements. The
+ * `local` chain stores state that should persist, such as the user token and
+ * auto prompt frequency caps. An in-memory backend is always appended to
+ * each chain as the last resort.
+ *
+ * @typedef {{
+ *   session: (!Array<!StorageBackendType|!StorageBackend>|undefined),
+ *   local: (!Array<!StorageBackendType|!StorageBackend>|undefined),
+ * }}
+ */
+export let StorageBackendsConfig;
@@ -14,6 +14,10 @@
  * limitations under the License.
  */
 
+import {
+  AnalyticsSink as AnalyticsSinkDef,
+  AnalyticsSinkConfig as AnalyticsSinkConfigDef,
+} from './analytics-sink';
 import {ClientEventManagerApi as ClientEventManagerApiDef} from './client-event-manager-api';
 import {
   DeferredAccountCreationRequest,
@@ -23,6 +27,7 @@ import {Entitlements as EntitlementsDef} from './entitlements';
 import {LoggerApi as LoggerApiDef} from './logger-api';
 import {Offer as OfferDef} from './offer';
 import {PropensityApi as PropensityApiDef} from './propensity-api';
+import {StorageBackendsConfig as StorageBackendsConfigDef} from './storage-backend';
 import {SubscribeResponse as SubscribeResponseDef} from './subscribe-response';
 
 /* eslint-disable no-unused-vars */
@@ -64,6 +69,40 @@ export class Subscriptions {
    */
   clear() {}
 
+  /**
+   * Switches to the article of the current page after a client-side
+   * navigation in a single page application. The page config is resolved
+   * again from the markup of the page, which must already describe the new
+   * article. Open dialogs are closed, and the entitlements are fetched again
+   * if they were fetched for the previous article or the new one is locked.
+   * See the `trackNavigation` config to detect navigations automatically.
+   * @return {?}
+   */
+  navigate() {}
+
+  /**
+   * Tears down the runtime: closes the open dialogs, removes the iframes and
+   * the listeners added to the page, and uninstalls the `SWG` global. Other
+   * calls are rejected afterwards, and a new runtime can be installed.
+   * @return {!Promise}
+   */
+  destroy() {}
+
+  /**
+   * Creates a runtime for another publication of the page, e.g. to show the
+   * teasers of several publications on an aggregator page. The new runtime
+   * has its own entitlements, storage, client config and analytics. It
+   * shares the dialogs of the page, so only one runtime shows a dialog at a
+   * time. It's configured with the config passed to `configure` so far, and
+   * destroyed along with this runtime. The new runtime can't be initialized,
+   * navigate or create runtimes: create another runtime for the new article
+   * instead. Its flows should open in popups, since results returned by a
+   * redirect are handled by this runtime.
+   * @param {!RuntimeOptions} options
+   * @return {!Subscriptions}
+   */
+  createRuntime(options) {}
+
   /**
    * @param {!GetEntitlementsParamsExternalDef=} params
    * @return {!Promise<!EntitlementsDef>}
@@ -87,11 +126,13 @@ export class Subscriptions {
   getOffers(options) {}
 
   /**
-   * Starts the Offers flow.
+   * Starts the Offers flow. The returned promise is resolved with the outcome
+   * of the flow, including the purchase started from it.
    * @param {!OffersRequest=} options
-   * @return {?}
+   * @param {!FlowOptions=} flowOptions
+   * @return {!Promise<!FlowOutcome>}
    */
-  showOffers(options) {}
+  showOffers(options, flowOptions) {}
 
   /**
    * Starts the Offers flow for a subscription update.
@@ -121,10 +162,13 @@ export class Subscriptions {
    * to the publisher. These options are based on the SKUs defined in the Play
    * console for a given publication.
    * Each SKU has Amount, Period, SKUId and other attributes.
+   * The returned promise is resolved with the outcome of the flow, including
+   * the contribution started from it.
    * @param {!OffersRequest=} options
-   * @return {?}
+   * @param {!FlowOptions=} flowOptions
+   * @return {!Promise<!FlowOutcome>}
    */
-  showContributionOptions(options) {}
+  showContributionOptions(options, flowOptions) {}
 
   /**
    * Set the callback for the native subscribe request. Setting this callback
@@ -142,11 +186,13 @@ export class Subscriptions {
   setOnSubscribeResponse(callback) {}
 
   /**
-   * Starts subscription purchase flow.
+   * Starts subscription purchase flow. The returned promise is resolved with
+   * the outcome of the purchase.
    * @param {string} sku
-   * @return {?}
+   * @param {!FlowOptions=} flowOptions
+   * @return {!Promise<!FlowOutcome>}
    */
-  subscribe(sku) {}
+  subscribe(sku, flowOptions) {}
 
   /**
    * Starts subscription purchase flow.
@@ -170,11 +216,13 @@ export class Subscriptions {
   setOnPaymentResponse(callback) {}
 
   /**
-   * Starts contributions purchase flow.
+   * Starts contributions purchase flow. The returned promise is resolved with
+   * the outcome of the contribution.
    * @param {string|SubscriptionRequest} skuOrSubscriptionRequest
-   * @return {?}
+   * @param {!FlowOptions=} flowOptions
+   * @return {!Promise<!FlowOutcome>}
    */
-  contribute(skuOrSubscriptionRequest) {}
+  contribute(skuOrSubscriptionRequest, flowOptions) {}
 
   /**
    * Starts the deferred account creation flow.
@@ -216,17 +264,20 @@ export class Subscriptions {
 
   /**
    * @param {!Promise} accountPromise Publisher's promise to lookup account.
+   * @param {!FlowOptions=} flowOptions
    * @return {!Promise}
    */
-  waitForSubscriptionLookup(accountPromise) {}
+  waitForSubscriptionLookup(accountPromise, flowOptions) {}
 
   /**
    * Starts the Account linking flow.
    * TODO(dparikh): decide if it's only exposed for testing or PROD purposes.
+   * The returned promise is resolved with the outcome of the flow.
    * @param {{ampReaderId: (string|undefined)}=} params
-   * @return {?}
+   * @param {!FlowOptions=} flowOptions
+   * @return {!Promise<!FlowOutcome>}
    */
-  linkAccount(params) {}
+  linkAccount(params, flowOptions) {}
 
   /**
    * Notifies the client that a flow has been started. The name of the flow
@@ -257,6 +308,51 @@ export class Subscriptions {
    */
   setOnFlowCanceled(callback) {}
 
+  /**
+   * Subscribes a handler to an event. Unlike the `setOn...` methods, which
+   * replace the callback set before, any number of handlers can be subscribed
+   * to an event. The handlers are called with the same argument as the
+   * matching callback, e.g. a promise of the entitlements for the
+   * "entitlements" event.
+   *
+   * An event triggered before any handler is subscribed is passed to the
+   * first handlers subscribed. The last "entitlements" event is also passed
+   * to the handlers subscribed later.
+   *
+   * @param {!SubscriptionEvent} event
+   * @param {function(?)} handler
+   */
+  on(event, handler) {}
+
+  /**
+   * Unsubscribes a handler subscribed with `on`.
+   * @param {!SubscriptionEvent} event
+   * @param {function(?)} handler
+   */
+  off(event, handler) {}
+
+  /**
+   * Returns the flows in progress, such as a purchase waiting for the
+   * publisher to create the account. See also the "flowstatechange" event.
+   * @return {!Promise<!Array<!ActiveFlow>>}
+   */
+  getActiveFlows() {}
+
+  /**
+   * Exports what the runtime recorded as JSON, for support requests. See the
+   * `enableDiagnostics` config.
+   * @return {!Promise<string>}
+   */
+  getDiagnostics() {}
+
+  /**
+   * Returns the latest duration of each timed operation, such as fetching the
+   * entitlements, in milliseconds. The timings are also added to the
+   * performance timeline as "swg:" measures.
+   * @return {!Promise<!Object<string, number>>}
+   */
+  getPerformanceTimings() {}
+
   /**
    * Starts the save subscriptions flow.
    * @param {!SaveSubscriptionRequestCallback} requestCallback
@@ -387,6 +483,28 @@ export const SubscriptionFlows = {
   SHOW_LOGIN_PROMPT: 'showLoginPrompt',
   SHOW_LOGIN_NOTIFICATION: 'showLoginNotification',
   SHOW_METER_TOAST: 'showMeterToast',
+  WAIT_FOR_SUBSCRIPTION_LOOKUP: 'waitForSubscriptionLookup',
+};
+
+/**
+ * Events that can be subscribed to with `on`. Each event, except
+ * "flowstatechange" and "error", matches one of the `setOn...` callbacks.
+ * @enum {string}
+ */
+export const SubscriptionEvent = {
+  ENTITLEMENTS: 'entitlements',
+  NATIVE_SUBSCRIBE_REQUEST: 'nativeSubscribeRequest',
+  PAYMENT_RESPONSE: 'paymentResponse',
+  LOGIN_REQUEST: 'loginRequest',
+  LINK_PROGRESS: 'linkProgress',
+  LINK_COMPLETE: 'linkComplete',
+  FLOW_STARTED: 'flowStarted',
+  FLOW_CANCELED: 'flowCanceled',
+  // A flow changed state. Handlers are called with a `FlowStateChange`.
+  FLOW_STATE_CHANGE: 'flowstatechange',
+  // An error that no promise is rejected with, e.g. one reported by the
+  // entitlements server. Handlers are called with a `SwgError`.
+  ERROR: 'error',
 };
 
 /**
@@ -403,16 +521,105 @@ export const SubscriptionFlows = {
  * - enablePropensity - If true events from the logger api are sent to the
  *   propensity server.  Note events from the legacy propensity endpoint are
  *   always sent.
+ * - storageBackends - chains of storage backends used to persist the
+ *   runtime's state, tried in order until one succeeds. Each entry is either
+ *   a `StorageBackendType` or a publisher-supplied `StorageBackend`. Defaults
+ *   to session storage for session state and local storage for persistent
+ *   state, falling back to memory.
+ * - entitlementsCachePolicy - how cached entitlements are used. See
+ *   `EntitlementsCacheMode`. Defaults to only using positive, non-expired
+ *   cached entitlements.
+ * - trackNavigation - if set to true, client-side navigations made through the
+ *   History API call `navigate` automatically. Defaults to false.
+ * - enableDiagnostics - if set to true, the runtime records the events,
+ *   requests, client config and flows of the page, without tokens, so they
+ *   can be exported with `getDiagnostics`. Defaults to false.
+ * - consent - the consent given by the reader, for publishers that manage
+ *   consent themselves. See `ConsentState`. Defaults to the consent read from
+ *   the IAB TCF and GPP consent management platforms of the page, if any.
+ * - googleAnalyticsEvents - the names of the GA4 events sent to gtag.js or
+ *   the Google Tag Manager data layer, keyed by `AnalyticsEvent` name, e.g.
+ *   `{'IMPRESSION_PAYWALL': 'view_promotion'}`. A null name doesn't send the
+ *   event. Defaults to the GA4 recommended ecommerce events for the offers,
+ *   checkout and purchase events. Their items only have the SKU as
+ *   `item_id`, without price and currency, which SwG doesn't know.
+ * - analyticsSinks - sinks the SwG events of the page are sent to, e.g. to
+ *   forward them to the publisher's analytics pipeline. Each entry is either
+ *   the `AnalyticsSinkConfig` of a built-in sink or a publisher-supplied
+ *   `AnalyticsSink`. Events are sent as `AnalyticsSinkEvent`s.
  * @typedef {{
  *   experiments: (!Array<string>|undefined),
  *   windowOpenMode: (!WindowOpenMode|undefined),
  *   analyticsMode: (!AnalyticsMode|undefined),
  *   enableSwgAnalytics: (boolean|undefined),
  *   enablePropensity: (boolean|undefined),
+ *   storageBackends: (!StorageBackendsConfigDef|undefined),
+ *   entitlementsCachePolicy: (!EntitlementsCachePolicy|undefined),
+ *   trackNavigation: (boolean|undefined),
+ *   enableDiagnostics: (boolean|undefined),
+ *   consent: (!ConsentState|undefined),
+ *   googleAnalyticsEvents: (!Object<string, ?string>|undefined),
+ *   analyticsSinks: (!Array<!AnalyticsSinkConfigDef|!AnalyticsSinkDef>|undefined),
  * }}
  */
 export let Config;
 
+/**
+ * The purposes the reader can consent to.
+ * @enum {string}
+ */
+export const ConsentPurpose = {
+  // Storing the runtime's state on the device beyond the session. Without
+  // consent, the state is only kept for the session, and not in cookies.
+  STORAGE: 'storage',
+  // Analytics that can identify the reader, e.g. through the cookies of the
+  // SwG analytics service. Without consent, only aggregate analytics are
+  // sent.
+  ANALYTICS: 'analytics',
+  // Reading advertising cookies, and propensity. Without consent, no
+  // propensity requests are made.
+  PERSONALIZATION: 'personalization',
+};
+
+/**
+ * Whether the reader consented to each `ConsentPurpose`, e.g.
+ * `{storage: true, analytics: false}`. Purposes that aren't listed are
+ * denied.
+ * @typedef {!Object<!ConsentPurpose, boolean>}
+ */
+export let ConsentState;
+
+/**
+ * Properties:
+ * - publicationId - the publication of the runtime.
+ * - productId - the product of the content shown by the runtime, e.g.
+ *   "publication:label". Defaults to the publication ID.
+ *
+ * @typedef {{
+ *   publicationId: string,
+ *   productId: (string|undefined),
+ * }}
+ */
+export let RuntimeOptions;
+
+/**
+ * Properties:
+ * - mode - the caching mode. Defaults to "default".
+ * - negativeTtlSeconds - how long negative results are cached in the
+ *   "cache-negative" mode. Defaults to 300 seconds.
+ * - offlineGracePeriodSeconds - how long the last positive entitlements
+ *   keep granting access, counted from when the server issued them, while
+ *   the server can't be reached or fails. These entitlements are marked
+ *   with `isStale` and the "cache-offline" `source`. Disabled by default.
+ *
+ * @typedef {{
+ *   mode: (!EntitlementsCacheMode|undefined),
+ *   negativeTtlSeconds: (number|undefined),
+ *   offlineGracePeriodSeconds: (number|undefined),
+ * }}
+ */
+export let EntitlementsCachePolicy;
+
 /**
  * Params for GetEntitlements requests to SwG Client.
  * swg-js constructs objects of this type, but publisher JS won't.
@@ -502,6 +709,24 @@ export const WindowOpenMode = {
   REDIRECT: 'redirect',
 };
 
+/**
+ * @enum {string}
+ */
+export const EntitlementsCacheMode = {
+  // Positive, non-expired cached entitlements are used. Anything else is
+  // fetched from the server.
+  DEFAULT: 'default',
+  // Any cached result, positive or negative, is used immediately and then
+  // revalidated in the background. The entitlements response callback is
+  // called again if the revalidated result is different.
+  STALE_WHILE_REVALIDATE: 'stale-while-revalidate',
+  // Like the default mode, but negative results are cached as well, for
+  // `negativeTtlSeconds`.
+  CACHE_NEGATIVE: 'cache-negative',
+  // Entitlements are always fetched from the server.
+  NETWORK_ONLY: 'network-only',
+};
+
 /**
  * @enum {string}
  */
@@ -564,6 +789,134 @@ export let OffersRequest;
  */
 export let LoginRequest;
 
+/**
+ * Options of the flows started by the publisher.
+ *
+ * Properties:
+ * - signal - aborts the flow once aborted. An aborted flow is closed and its
+ *   promise is rejected with an AbortError, or resolved with an "aborted"
+ *   error outcome once the flow started. The payment sheet, once open, is
+ *   managed by Google Pay and stays open.
+ * - timeoutMs - aborts the flow if it hasn't started after this time, e.g.
+ *   while the offers load or the account lookup runs. Once the flow started,
+ *   the interaction of the user with it isn't timed.
+ * - onOutcome - called once with the outcome of the flow, as well as the
+ *   promise of the flows that return one. See `FlowOutcome`.
+ *
+ * @typedef {{
+ *   signal: (!AbortSignal|undefined),
+ *   timeoutMs: (number|undefined),
+ *   onOutcome: (function(!FlowOutcome)|undefined),
+ * }}
+ */
+export let FlowOptions;
+
+/**
+ * @enum {string}
+ */
+export const FlowOutcomeType = {
+  // The user purchased a subscription or made a contribution.
+  PURCHASED: 'purchased',
+  // The user linked their publisher account.
+  LINKED: 'linked',
+  // The user closed the flow.
+  CANCELED: 'canceled',
+  // The user said they're already subscribed. The login request callback is
+  // called as well.
+  ALREADY_SUBSCRIBED: 'already-subscribed',
+  ERROR: 'error',
+};
+
+/**
+ * @enum {string}
+ */
+export const FlowErrorCode = {
+  // The flow couldn't be started.
+  START_FAILED: 'start-failed',
+  // The payment failed.
+  PAYMENT_FAILED: 'payment-failed',
+  // The flow wasn't started, since it conflicts with a flow in progress, e.g.
+  // with a purchase.
+  FLOW_CONFLICT: 'flow-conflict',
+  // The flow was aborted through its flow options.
+  ABORTED: 'aborted',
+};
+
+/**
+ * Properties:
+ * - type - the outcome of the flow.
+ * - flow - the flow the outcome came from. See `SubscriptionFlows`. For
+ *   instance, the purchase started from the offers flow is the "subscribe"
+ *   flow.
+ * - response - the purchase. Only set for the "purchased" outcome.
+ * - linkRequested - whether the user asked to link their account. Only set for
+ *   the "already-subscribed" outcome.
+ * - code - the error. Only set for the "error" outcome.
+ * - error - the `SwgError` the flow failed with, if any. Only set for the
+ *   "error" outcome.
+ *
+ * Flows completed in a redirect, rather than a popup, report their outcome
+ * through the callbacks only, on the page the user returns to. Once the
+ * runtime is destroyed, the promises of the flows in progress are resolved
+ * with an "aborted" error, and `onOutcome` isn't called.
+ *
+ * @typedef {{
+ *   type: !FlowOutcomeType,
+ *   flow: string,
+ *   response: (!SubscribeResponseDef|undefined),
+ *   linkRequested: (boolean|undefined),
+ *   code: (!FlowErrorCode|undefined),
+ *   error: (!../utils/errors.SwgError|undefined),
+ * }}
+ */
+export let FlowOutcome;
+
+/**
+ * The states of the flows. A flow is idle until it starts, and becomes idle
+ * again once done, canceled or failed.
+ * @enum {string}
+ */
+export const FlowState = {
+  IDLE: 'idle',
+  // The flow's dialog, or popup, is shown.
+  DIALOG_OPEN: 'dialog-open',
+  // The payment sheet is shown.
+  PAY_STARTED: 'pay-started',
+  // The payment is verified, and the account is being created.
+  PAY_COMPLETE: 'pay-complete',
+  // The publisher created the account, see `SubscribeResponse.complete`.
+  ACCOUNT_CREATED: 'account-created',
+  DONE: 'done',
+  CANCELED: 'canceled',
+  FAILED: 'failed',
+};
+
+/**
+ * Properties:
+ * - flow - the flow, see `SubscriptionFlows`.
+ * - state - the state of the flow.
+ *
+ * @typedef {{
+ *   flow: string,
+ *   state: !FlowState,
+ * }}
+ */
+export let ActiveFlow;
+
+/**
+ * Properties:
+ * - flow - the flow, see `SubscriptionFlows`.
+ * - state - the new state of the flow.
+ * - previousState - the state of the flow before the change.
+ *
+ * @typedef {{
+ *   flow: string,
+ *   state: !FlowState,
+ *   previousState: !FlowState,
+ * }}
+ */
+export let FlowStateChange;
+
 /**
  * Properties:
  * - one and only one of "token" or "authCode"
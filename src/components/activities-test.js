@@ -16,6 +16,7 @@
 
 import {ActivityIframePort, ActivityPorts} from './activities';
 import {
+  ActivityMode,
   ActivityResult,
   ActivityIframePort as WebActivityIframePort,
   ActivityPort as WebActivityPort,
@@ -27,6 +28,7 @@ import {ClientEventManager} from '../runtime/client-event-manager';
 import {Dialog} from '../components/dialog';
 import {GlobalDoc} from '../model/doc';
 import {PageConfig} from '../model/page-config';
+import {PerformanceTimings} from '../utils/performance';
 import {tick} from '../../test/tick';
 
 const publicationId = 'PUB_ID';
@@ -34,6 +36,7 @@ const publicationId = 'PUB_ID';
 describes.realWin('Activity Components', {}, (env) => {
   let win, iframe, url, dialog, doc, deps, pageConfig, analytics, activityPorts;
   let eventManager;
+  let performanceTimings;
 
   beforeEach(() => {
     url = '/hello';
@@ -43,6 +46,7 @@ describes.realWin('Activity Components', {}, (env) => {
     iframe = dialog.getElement();
     doc.getBody().appendChild(iframe);
     eventManager = new ClientEventManager(Promise.resolve());
+    performanceTimings = new PerformanceTimings(win);
 
     pageConfig = new PageConfig(publicationId, false);
     deps = {
@@ -50,6 +54,8 @@ describes.realWin('Activity Components', {}, (env) => {
       pageConfig: () => pageConfig,
       doc: () => doc,
       eventManager: () => eventManager,
+      consentManager: () => ({hasConsent: () => true}),
+      performanceTimings: () => performanceTimings,
     };
     activityPorts = new ActivityPorts(deps);
     deps['activities'] = () => activityPorts;
@@ -221,6 +227,57 @@ describes.realWin('Activity Components', {}, (env) => {
         expect(result.data).to.equal('test');
       });
 
+      it('should keep the namespace out of the request IDs', () => {
+        const openStub = sandbox
+          .stub(WebActivityPorts.prototype, 'open')
+          .returns({targetWin: null});
+        const onResultStub = sandbox.stub(
+          WebActivityPorts.prototype,
+          'onResult'
+        );
+        activityPorts = new ActivityPorts(deps, 'pub2');
+
+        activityPorts.open('swg-link', '/someUrl', '_top', {});
+        activityPorts.onResult('swg-link', () => {});
+
+        expect(openStub).to.be.calledWith('swg-link', '/someUrl', '_top');
+        expect(onResultStub).to.be.calledWith('swg-link');
+      });
+
+      it('should leave redirect results to the runtime without a namespace', () => {
+        const handlers = [];
+        sandbox
+          .stub(WebActivityPorts.prototype, 'onResult')
+          .callsFake((requestId, handler) => handlers.push(handler));
+        const redirectPort = new WebActivityPort();
+        redirectPort.getMode = () => ActivityMode.REDIRECT;
+        const popupPort = new WebActivityPort();
+        popupPort.getMode = () => ActivityMode.POPUP;
+        const pageCallback = sandbox.spy();
+        const createdCallback = sandbox.spy();
+        activityPorts.onResult('swg-link', pageCallback);
+        new ActivityPorts(deps, 'pub2').onResult('swg-link', createdCallback);
+
+        for (const handler of handlers) {
+          handler(redirectPort);
+          handler(popupPort);
+        }
+
+        expect(pageCallback).to.be.calledTwice;
+        expect(createdCallback).to.be.calledOnce;
+      });
+
+      it('should leave redirect errors to the runtime without a namespace', () => {
+        const onRedirectErrorStub = sandbox.stub(
+          WebActivityPorts.prototype,
+          'onRedirectError'
+        );
+
+        new ActivityPorts(deps, 'pub2').onRedirectError(() => {});
+
+        expect(onRedirectErrorStub).to.not.be.called;
+      });
+
       it('must delegate onRedirectError', () => {
         let actualHandler;
         sandbox
@@ -19,9 +19,11 @@ import {
   deserialize,
   getLabel,
 } from '../proto/api_messages';
+import {PerformanceTiming} from '../utils/performance';
 
 const {
   ActivityIframePort: WebActivityIframePort,
+  ActivityMode,
   ActivityPorts: WebActivityPorts,
 } = require('web-activities/activity-ports');
 
@@ -260,13 +262,23 @@ export class ActivityIframePort {
 export class ActivityPorts {
   /**
    * @param {!../runtime/deps.DepsDef} deps
+   * @param {string=} namespace Set for the runtimes created for other
+   *     publications of the page. Results returned by a redirect are
+   *     delivered to every runtime of the page, so only the page's own
+   *     runtime, without a namespace, handles them.
    */
-  constructor(deps) {
+  constructor(deps, namespace = '') {
     /** @private @const {!../runtime/deps.DepsDef} */
     this.deps_ = deps;
 
     /** @private @const {!web-activities/activity-ports.ActivityPorts} */
     this.activityPorts_ = new WebActivityPorts(deps.win());
+
+    /** @private @const {!../utils/performance.PerformanceTimings} */
+    this.performanceTimings_ = deps.performanceTimings();
+
+    /** @private @const {string} */
+    this.namespace_ = namespace;
   }
 
   /**
@@ -314,7 +326,13 @@ export class ActivityPorts {
     if (addDefaultArguments) {
       args = this.addDefaultArguments(args);
     }
-    return this.openActivityIframePort_(iframe, url, args);
+    const endTiming = this.performanceTimings_.start(
+      PerformanceTiming.IFRAME_READY
+    );
+    const portPromise = this.openActivityIframePort_(iframe, url, args);
+    // Iframes that fail to open aren't timed.
+    portPromise.then((port) => port.whenReady()).then(endTiming, () => {});
+    return portPromise;
   }
 
   /**
@@ -383,6 +401,9 @@ export class ActivityPorts {
    */
   onResult(requestId, callback) {
     this.activityPorts_.onResult(requestId, (port) => {
+      if (this.namespace_ && port.getMode() == ActivityMode.REDIRECT) {
+        return;
+      }
       callback(new ActivityPortDeprecated(port));
     });
   }
@@ -391,6 +412,9 @@ export class ActivityPorts {
    * @param {function(!Error)} handler
    */
   onRedirectError(handler) {
+    if (this.namespace_) {
+      return;
+    }
     this.activityPorts_.onRedirectError(handler);
   }
 
@@ -400,4 +424,12 @@ export class ActivityPorts {
   getOriginalWebActivityPorts() {
     return this.activityPorts_;
   }
+
+  /**
+   * @return {!../utils/performance.PerformanceTimings} The timings of the
+   *     runtime that opens the activities.
+   */
+  getPerformanceTimings() {
+    return this.performanceTimings_;
+  }
 }
@@ -150,6 +150,37 @@ describes.realWin('DialogManager', {}, (env) => {
     expect(graypaneStubs.destroy).to.not.be.called;
   });
 
+  it('should call the open callbacks', async () => {
+    const callback = sandbox.spy();
+    dialogManager.onOpen(callback);
+
+    await dialogManager.openView(initView);
+    await dialogManager.openView(initView);
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should call the close callbacks', async () => {
+    const callback = sandbox.spy();
+    dialogManager.onClose(callback);
+    await dialogManager.openView(initView);
+    expect(callback).to.not.be.called;
+
+    dialogManager.completeAll();
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should remove the callbacks', async () => {
+    const openCallback = sandbox.spy();
+    const closeCallback = sandbox.spy();
+    dialogManager.onOpen(openCallback)();
+    dialogManager.onClose(closeCallback)();
+
+    await dialogManager.openView(initView);
+    dialogManager.completeAll();
+    expect(openCallback).to.not.be.called;
+    expect(closeCallback).to.not.be.called;
+  });
+
   it('should catch view error', async () => {
     const view = {
       whenComplete: () =>
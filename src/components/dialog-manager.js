@@ -44,6 +44,12 @@ export class DialogManager {
     /** @private {?Window} */
     this.popupWin_ = null;
 
+    /** @private @const {!Array<function()>} */
+    this.openCallbacks_ = [];
+
+    /** @private @const {!Array<function()>} */
+    this.closeCallbacks_ = [];
+
     this.popupGraypane_.getElement().addEventListener('click', () => {
       if (this.popupWin_) {
         try {
@@ -70,6 +76,7 @@ export class DialogManager {
         dialogConfig
       );
       this.openPromise_ = this.dialog_.open(hidden);
+      this.openCallbacks_.forEach((callback) => callback());
     }
     return this.openPromise_;
   }
@@ -132,11 +139,28 @@ export class DialogManager {
     return this.dialog_;
   }
 
+  /**
+   * @param {function()} callback Called whenever the dialog is opened.
+   * @return {function()} Removes the callback.
+   */
+  onOpen(callback) {
+    return addCallback(this.openCallbacks_, callback);
+  }
+
+  /**
+   * @param {function()} callback Called whenever the dialog is closed.
+   * @return {function()} Removes the callback.
+   */
+  onClose(callback) {
+    return addCallback(this.closeCallbacks_, callback);
+  }
+
   /** @private */
   close_() {
     this.dialog_.close();
     this.dialog_ = null;
     this.openPromise_ = null;
+    this.closeCallbacks_.forEach((callback) => callback());
   }
 
   /**
@@ -161,3 +185,18 @@ export class DialogManager {
     }
   }
 }
+
+/**
+ * @param {!Array<function()>} callbacks
+ * @param {function()} callback
+ * @return {function()} Removes the callback.
+ */
+function addCallback(callbacks, callback) {
+  callbacks.push(callback);
+  return () => {
+    const index = callbacks.indexOf(callback);
+    if (index != -1) {
+      callbacks.splice(index, 1);
+    }
+  };
+}
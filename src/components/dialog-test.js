@@ -16,6 +16,7 @@
 
 import {Dialog} from './dialog';
 import {GlobalDoc} from '../model/doc';
+import {PerformanceTiming, PerformanceTimings} from '../utils/performance';
 import {computedStyle, getStyle} from '../utils/style';
 
 const NO_ANIMATE = false;
@@ -30,6 +31,7 @@ describes.realWin('Dialog', {}, (env) => {
   let graypaneStubs;
   let view;
   let element;
+  let performanceTimings;
   const documentHeight = 100;
 
   beforeEach(() => {
@@ -38,12 +40,14 @@ describes.realWin('Dialog', {}, (env) => {
     globalDoc = new GlobalDoc(win);
 
     element = doc.createElement('div');
+    performanceTimings = new PerformanceTimings(win);
     view = {
       getElement: () => element,
       init: (dialog) => Promise.resolve(dialog),
       resized: () => {},
       shouldFadeBody: () => true,
       hasLoadingIndicator: () => false,
+      getPerformanceTimings: () => performanceTimings,
     };
   });
 
@@ -153,6 +157,16 @@ describes.realWin('Dialog', {}, (env) => {
       );
     });
 
+    it('should time its opening in the timings of the view', async () => {
+      const openedDialog = await dialog.open();
+      await openedDialog.openView(view);
+      await openedDialog.resizeView(view, 99, NO_ANIMATE);
+
+      expect(performanceTimings.getDurations()).to.have.property(
+        PerformanceTiming.DIALOG_FIRST_RESIZE
+      );
+    });
+
     it('should return null if passed wrong view', async () => {
       const wrongView = {};
       expect(dialog.resizeView(wrongView)).to.be.null;
@@ -18,6 +18,7 @@ import {CSS as DIALOG_CSS} from '../../build/css/ui/ui.css';
 import {FriendlyIframe} from './friendly-iframe';
 import {Graypane} from './graypane';
 import {LoadingView} from '../ui/loading-view';
+import {PerformanceTiming, startTiming} from '../utils/performance';
 import {
   createElement,
   injectStyleSheet,
@@ -163,6 +164,13 @@ export class Dialog {
     /** @private {boolean} */
     this.hidden_ = false;
 
+    /**
+     * Ends the timing of the dialog's opening, once first resized. The
+     * duration is recorded in the timings of the view's runtime.
+     * @private {?function(?../utils/performance.PerformanceTimings)}
+     */
+    this.endOpenTiming_ = null;
+
     /** @private {?./view.View} */
     this.previousProgressView_ = null;
 
@@ -198,6 +206,11 @@ export class Dialog {
       throw new Error('already opened');
     }
 
+    this.endOpenTiming_ = startTiming(
+      this.doc_.getWin(),
+      PerformanceTiming.DIALOG_FIRST_RESIZE
+    );
+
     // Attach.
     this.doc_.getBody().appendChild(iframe.getElement()); // Fires onload.
 
@@ -461,6 +474,10 @@ export class Dialog {
     if (this.view_ != view) {
       return null;
     }
+    if (this.endOpenTiming_) {
+      this.endOpenTiming_(view.getPerformanceTimings());
+      this.endOpenTiming_ = null;
+    }
     const newHeight = this.getMaxAllowedHeight_(height);
 
     // Uniquely identify this animation.
@@ -64,4 +64,14 @@ export class View {
    * @abstract
    */
   hasLoadingIndicator() {}
+
+  /**
+   * Returns the timings of the runtime that shows the view. The runtimes of a
+   * page share the dialog, so the dialog times its opening for the view.
+   * @return {?../utils/performance.PerformanceTimings}
+   */
+  getPerformanceTimings() {
+    // Not timed by default. Override if needed.
+    return null;
+  }
 }
@@ -20,19 +20,45 @@
 export class AutoPromptConfig {
   /**
    * @param {number|undefined} maxImpressionsPerWeek
+   * @param {number|undefined} displayDelaySeconds
+   * @param {number|undefined} backoffSeconds
+   * @param {number|undefined} maxDismissalsPerWeek
+   * @param {number|undefined} maxDismissalsResultingHideSeconds
+   * @param {!Array<!../api/basic-subscriptions.AutoPromptRule>=} rules
+   * @param {!../api/basic-subscriptions.AutoPromptTriggers=} triggers The
+   *     display triggers other than the delay.
+   * @param {!Array<!../api/basic-subscriptions.AutoPromptFrequencyCap>=} frequencyCaps
    */
   constructor(
     maxImpressionsPerWeek,
     displayDelaySeconds,
     backoffSeconds,
     maxDismissalsPerWeek,
-    maxDismissalsResultingHideSeconds
+    maxDismissalsResultingHideSeconds,
+    rules,
+    triggers,
+    frequencyCaps
   ) {
     /** @const {number|undefined} */
     this.maxImpressionsPerWeek = maxImpressionsPerWeek;
 
+    /**
+     * Targeting rules the auto prompt must satisfy to be displayed.
+     * @const {!Array<!../api/basic-subscriptions.AutoPromptRule>|undefined}
+     */
+    this.rules = rules;
+
+    /**
+     * Caps on impressions and dismissals, in addition to the weekly ones.
+     * @const {!Array<!../api/basic-subscriptions.AutoPromptFrequencyCap>|undefined}
+     */
+    this.frequencyCaps = frequencyCaps;
+
     /** @const {!ClientDisplayTrigger} */
-    this.clientDisplayTrigger = new ClientDisplayTrigger(displayDelaySeconds);
+    this.clientDisplayTrigger = new ClientDisplayTrigger(
+      displayDelaySeconds,
+      triggers
+    );
 
     /** @const {!ExplicitDismissalConfig} */
     this.explicitDismissalConfig = new ExplicitDismissalConfig(
@@ -49,10 +75,26 @@ export class AutoPromptConfig {
 export class ClientDisplayTrigger {
   /**
    * @param {number|undefined} displayDelaySeconds
+   * @param {!../api/basic-subscriptions.AutoPromptTriggers=} triggers
    */
-  constructor(displayDelaySeconds) {
+  constructor(displayDelaySeconds, triggers = {}) {
     /** @const {number|undefined} */
     this.displayDelaySeconds = displayDelaySeconds;
+
+    /** @const {number|undefined} */
+    this.scrollDepthPercent = triggers.scrollDepthPercent;
+
+    /** @const {string|undefined} */
+    this.visibleElementSelector = triggers.visibleElementSelector;
+
+    /** @const {boolean|undefined} */
+    this.exitIntent = triggers.exitIntent;
+
+    /** @const {number|undefined} */
+    this.idleSeconds = triggers.idleSeconds;
+
+    /** @const {number|undefined} */
+    this.nthArticle = triggers.nthArticle;
   }
 }
 
@@ -363,6 +363,8 @@ describe('AnalyticsRequest', () => {
     eventparams.setOldTransactionId('');
     eventparams.setIsUserRegistered(false);
     eventparams.setSubscriptionFlow('');
+    eventparams.setPrice(0);
+    eventparams.setCurrency('');
     analyticsrequest.setParams(eventparams);
 
     let analyticsrequestDeserialized;
@@ -652,6 +654,8 @@ describe('EventParams', () => {
     eventparams.setOldTransactionId('');
     eventparams.setIsUserRegistered(false);
     eventparams.setSubscriptionFlow('');
+    eventparams.setPrice(0);
+    eventparams.setCurrency('');
 
     let eventparamsDeserialized;
 
@@ -677,6 +681,10 @@ describe('EventParams', () => {
         eventparams.getIsUserRegistered());
     expect(eventparamsDeserialized.getSubscriptionFlow()).to.deep.equal(
         eventparams.getSubscriptionFlow());
+    expect(eventparamsDeserialized.getPrice()).to.deep.equal(
+        eventparams.getPrice());
+    expect(eventparamsDeserialized.getCurrency()).to.deep.equal(
+        eventparams.getCurrency());
 
     // Verify includeLabel true
     // Verify serialized arrays.
@@ -700,6 +708,10 @@ describe('EventParams', () => {
         eventparams.getIsUserRegistered());
     expect(eventparamsDeserialized.getSubscriptionFlow()).to.deep.equal(
         eventparams.getSubscriptionFlow());
+    expect(eventparamsDeserialized.getPrice()).to.deep.equal(
+        eventparams.getPrice());
+    expect(eventparamsDeserialized.getCurrency()).to.deep.equal(
+        eventparams.getCurrency());
 
     // Verify includeLabel false
     // Verify serialized arrays.
@@ -722,6 +734,10 @@ describe('EventParams', () => {
         eventparams.getIsUserRegistered());
     expect(eventparamsDeserialized.getSubscriptionFlow()).to.deep.equal(
         eventparams.getSubscriptionFlow());
+    expect(eventparamsDeserialized.getPrice()).to.deep.equal(
+        eventparams.getPrice());
+    expect(eventparamsDeserialized.getCurrency()).to.deep.equal(
+        eventparams.getCurrency());
   });
 });
 
@@ -129,6 +129,7 @@ const AnalyticsEvent = {
   ACTION_REGWALL_ALREADY_OPTED_IN_CLICK: 1055,
   ACTION_NEWSLETTER_OPT_IN_BUTTON_CLICK: 1056,
   ACTION_NEWSLETTER_ALREADY_OPTED_IN_CLICK: 1057,
+  ACTION_FLOW_ABORTED: 1058,
   EVENT_PAYMENT_FAILED: 2000,
   EVENT_REGWALL_OPT_IN_FAILED: 2001,
   EVENT_NEWSLETTER_OPT_IN_FAILED: 2002,
@@ -1180,6 +1181,12 @@ class EventParams {
 
     /** @private {?string} */
     this.subscriptionFlow_ = data[6 + base] == null ? null : data[6 + base];
+
+    /** @private {?number} */
+    this.price_ = data[7 + base] == null ? null : data[7 + base];
+
+    /** @private {?string} */
+    this.currency_ = data[8 + base] == null ? null : data[8 + base];
   }
 
   /**
@@ -1280,6 +1287,34 @@ class EventParams {
     this.subscriptionFlow_ = value;
   }
 
+  /**
+   * @return {?number}
+   */
+  getPrice() {
+    return this.price_;
+  }
+
+  /**
+   * @param {number} value
+   */
+  setPrice(value) {
+    this.price_ = value;
+  }
+
+  /**
+   * @return {?string}
+   */
+  getCurrency() {
+    return this.currency_;
+  }
+
+  /**
+   * @param {string} value
+   */
+  setCurrency(value) {
+    this.currency_ = value;
+  }
+
   /**
    * @param {boolean=} includeLabel
    * @return {!Array<?>}
@@ -1294,6 +1329,8 @@ class EventParams {
         this.oldTransactionId_, // field 5 - old_transaction_id
         this.isUserRegistered_, // field 6 - is_user_registered
         this.subscriptionFlow_, // field 7 - subscription_flow
+        this.price_, // field 8 - price
+        this.currency_, // field 9 - currency
     ];
     if (includeLabel) {
       arr.unshift(this.label());
@@ -0,0 +1,287 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsEvent, AnalyticsRequest} from '../proto/api_messages';
+import {AnalyticsQueue} from './analytics-queue';
+import {PageConfig} from '../model/page-config';
+import {tick} from '../../test/tick';
+
+const STORAGE_KEY = 'analyticsqueue';
+
+describes.sandboxed('AnalyticsQueue', {}, () => {
+  let clock;
+  let win;
+  let listeners;
+  let stored;
+  let deps;
+  let fetcher;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    listeners = {};
+    const addEventListener = (type, callback) => (listeners[type] = callback);
+    const removeEventListener = (type) => delete listeners[type];
+    win = {
+      document: {
+        visibilityState: 'visible',
+        addEventListener,
+        removeEventListener,
+      },
+      addEventListener,
+      removeEventListener,
+      setTimeout: (fn, delay) => setTimeout(fn, delay),
+      clearTimeout: (id) => clearTimeout(id),
+    };
+    stored = {};
+    const storage = {
+      reload: (key) => Promise.resolve(stored[key] || null),
+      set: (key, value) => {
+        stored[key] = value;
+        return Promise.resolve();
+      },
+      remove: (key) => {
+        delete stored[key];
+        return Promise.resolve();
+      },
+    };
+    const pageConfig = new PageConfig('pub1:label1');
+    deps = {
+      win: () => win,
+      storage: () => storage,
+      pageConfig: () => pageConfig,
+    };
+    fetcher = {
+      sendPost: sandbox.stub().resolves({}),
+      sendBeacon: sandbox.spy(),
+    };
+  });
+
+  /**
+   * @param {!AnalyticsEvent=} eventType
+   * @return {!AnalyticsRequest}
+   */
+  function createRequest(eventType = AnalyticsEvent.IMPRESSION_PAYWALL) {
+    const request = new AnalyticsRequest();
+    request.setEvent(eventType);
+    return request;
+  }
+
+  /**
+   * @param {number} count
+   * @param {number=} time
+   * @param {?string=} owner
+   * @return {string}
+   */
+  function createStoredQueue(count, time = Date.now(), owner = null) {
+    const entries = [];
+    for (let i = 0; i < count; i++) {
+      entries.push({
+        'id': `${owner || 'id'}${i}`,
+        'time': time,
+        'data': ['', null, i],
+        'owner': owner,
+      });
+    }
+    return JSON.stringify(entries);
+  }
+
+  async function waitForBatch() {
+    clock.tick(1000);
+    await tick(10);
+  }
+
+  it('should send the requests in batches', async () => {
+    const queue = new AnalyticsQueue(deps, fetcher);
+    queue.enqueue(createRequest(AnalyticsEvent.IMPRESSION_PAYWALL));
+    queue.enqueue(createRequest(AnalyticsEvent.IMPRESSION_OFFERS));
+    await tick(10);
+    expect(fetcher.sendPost).to.not.be.called;
+
+    await waitForBatch();
+
+    expect(fetcher.sendPost).to.be.calledTwice;
+    const [url, request] = fetcher.sendPost.args[1];
+    expect(url).to.contain('/publication/pub1/clientlogs');
+    expect(request.getEvent()).to.equal(AnalyticsEvent.IMPRESSION_OFFERS);
+    await queue.whenPersisted();
+    expect(stored[STORAGE_KEY]).to.be.undefined;
+  });
+
+  it('should send the ID of each request', async () => {
+    const queue = new AnalyticsQueue(deps, fetcher);
+    queue.enqueue(createRequest());
+    await queue.whenPersisted();
+    const {id} = JSON.parse(stored[STORAGE_KEY])[0];
+
+    await waitForBatch();
+
+    expect(fetcher.sendPost.args[0][0]).to.match(
+      new RegExp(`/clientlogs\\?eventId=${id}$`)
+    );
+  });
+
+  it('should send full batches right away', async () => {
+    const queue = new AnalyticsQueue(deps, fetcher);
+    for (let i = 0; i < 20; i++) {
+      queue.enqueue(createRequest());
+    }
+    await tick(10);
+
+    expect(fetcher.sendPost).to.have.callCount(20);
+  });
+
+  it('should persist the queue', async () => {
+    const queue = new AnalyticsQueue(deps, fetcher);
+    const request = createRequest();
+    queue.enqueue(request);
+    await queue.whenPersisted();
+
+    const entries = JSON.parse(stored[STORAGE_KEY]);
+    expect(entries).to.have.length(1);
+    expect(entries[0].id).to.exist;
+    expect(entries[0].data).to.deep.equal(request.toArray());
+  });
+
+  it('should send failed requests again on the next page view', async () => {
+    fetcher.sendPost.rejects(new Error('offline'));
+    const queue = new AnalyticsQueue(deps, fetcher);
+    queue.enqueue(createRequest());
+    await waitForBatch();
+    await waitForBatch();
+
+    expect(fetcher.sendPost).to.be.calledOnce;
+    await queue.whenPersisted();
+    expect(JSON.parse(stored[STORAGE_KEY])).to.have.length(1);
+
+    fetcher.sendPost.resolves({});
+    new AnalyticsQueue(deps, fetcher);
+    await tick(10);
+    await waitForBatch();
+
+    expect(fetcher.sendPost).to.be.calledTwice;
+  });
+
+  it('should keep the requests of other tabs', async () => {
+    stored[STORAGE_KEY] = createStoredQueue(2, Date.now(), 'tab');
+    const queue = new AnalyticsQueue(deps, fetcher);
+    queue.enqueue(createRequest());
+    await queue.whenPersisted();
+
+    const entries = JSON.parse(stored[STORAGE_KEY]);
+    expect(entries.map((entry) => entry.id)).to.include.members([
+      'tab0',
+      'tab1',
+    ]);
+    expect(entries).to.have.length(3);
+    expect(entries[2].owner).to.be.a('string').and.not.equal('tab');
+
+    await waitForBatch();
+
+    expect(fetcher.sendPost).to.be.calledOnce;
+    await queue.whenPersisted();
+    expect(stored[STORAGE_KEY]).to.equal(createStoredQueue(2, 0, 'tab'));
+  });
+
+  it('should send the requests of other tabs once orphaned', async () => {
+    stored[STORAGE_KEY] = createStoredQueue(2, 0, 'tab');
+    clock.tick(60 * 60 * 1000);
+
+    const queue = new AnalyticsQueue(deps, fetcher);
+    await tick(10);
+    await waitForBatch();
+
+    expect(fetcher.sendPost).to.be.calledTwice;
+    await queue.whenPersisted();
+    expect(stored[STORAGE_KEY]).to.be.undefined;
+  });
+
+  it('should update the queue under a lock', async () => {
+    win.navigator = {
+      locks: {request: sandbox.spy((name, callback) => callback())},
+    };
+    const queue = new AnalyticsQueue(deps, fetcher);
+    queue.enqueue(createRequest());
+    await queue.whenPersisted();
+
+    expect(win.navigator.locks.request).to.be.calledTwice;
+    expect(win.navigator.locks.request).to.always.be.calledWith(
+      'subscribe.google.com:analyticsqueue'
+    );
+  });
+
+  it('should send the persisted requests once', async () => {
+    const entries = JSON.parse(createStoredQueue(2));
+    stored[STORAGE_KEY] = JSON.stringify(entries.concat(entries));
+
+    new AnalyticsQueue(deps, fetcher);
+    await tick(10);
+    await waitForBatch();
+
+    expect(fetcher.sendPost).to.be.calledTwice;
+  });
+
+  it('should drop requests that are too old', async () => {
+    clock.tick(2 * 24 * 60 * 60 * 1000);
+    stored[STORAGE_KEY] = createStoredQueue(2, 0);
+
+    new AnalyticsQueue(deps, fetcher);
+    await tick(10);
+    await waitForBatch();
+
+    expect(fetcher.sendPost).to.not.be.called;
+  });
+
+  it('should keep the newest requests', async () => {
+    stored[STORAGE_KEY] = createStoredQueue(105);
+
+    new AnalyticsQueue(deps, fetcher);
+    await tick(10);
+    listeners['pagehide']();
+
+    expect(fetcher.sendBeacon).to.have.callCount(100);
+    expect(fetcher.sendBeacon.args[0][1].getEvent()).to.equal(5);
+  });
+
+  it('should send the pending requests with beacons once hidden', async () => {
+    const queue = new AnalyticsQueue(deps, fetcher);
+    queue.enqueue(createRequest());
+    queue.enqueue(createRequest());
+    await tick(10);
+
+    listeners['visibilitychange']();
+    expect(fetcher.sendBeacon).to.not.be.called;
+
+    win.document.visibilityState = 'hidden';
+    listeners['visibilitychange']();
+    expect(fetcher.sendBeacon).to.be.calledTwice;
+
+    await waitForBatch();
+    expect(fetcher.sendPost).to.not.be.called;
+    await queue.whenPersisted();
+    expect(stored[STORAGE_KEY]).to.be.undefined;
+  });
+
+  it('should stop listening to the page once destroyed', async () => {
+    const queue = new AnalyticsQueue(deps, fetcher);
+    queue.enqueue(createRequest());
+
+    queue.destroy();
+    await waitForBatch();
+
+    expect(listeners).to.be.empty;
+    expect(fetcher.sendPost).to.not.be.called;
+  });
+});
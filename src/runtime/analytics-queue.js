@@ -0,0 +1,438 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsRequest} from '../proto/api_messages';
+import {addQueryParam} from '../utils/url';
+import {getUuid} from '../utils/string';
+import {serviceUrl} from './services';
+import {tryParseJson} from '../utils/json';
+
+/** @const {string} */
+const STORAGE_KEY = 'analyticsqueue';
+
+/**
+ * Name of the Web Lock that the tabs take to update the persisted queue.
+ * @const {string}
+ */
+const LOCK_NAME = 'subscribe.google.com:analyticsqueue';
+
+/**
+ * The number of requests kept. The oldest are dropped first.
+ * @const {number}
+ */
+const MAX_ENTRIES = 100;
+
+/**
+ * Requests older than this are dropped, e.g. when the reader comes back
+ * days later.
+ * @const {number}
+ */
+const MAX_AGE_MS = 24 * 60 * 60 * 1000;
+
+/**
+ * Requests of other tabs older than this are sent by the next page view,
+ * since their tab would have sent them already, unless it crashed.
+ * @const {number}
+ */
+const ORPHAN_AGE_MS = 60 * 60 * 1000;
+
+/** @const {number} */
+const MAX_BATCH_SIZE = 20;
+
+/**
+ * How long requests are batched before being sent.
+ * @const {number}
+ */
+const BATCH_DELAY_MS = 1000;
+
+/**
+ * A queued request. The request is kept serialized, since the analytics
+ * context it refers to keeps changing.
+ * @typedef {{
+ *   id: string,
+ *   time: number,
+ *   data: !Array<*>,
+ * }}
+ */
+let QueueEntry;
+
+/**
+ * Queues analytics requests, and sends them to the SwG analytics service in
+ * batches. The queue is persisted, so that requests survive slow networks
+ * and redirects. The requests left are sent with beacons once the page is
+ * hidden, and the requests that failed are sent again on the next page view.
+ * The service takes one request at a time, so a batch is sent as one request
+ * per event, at the same time. Each request is sent with its ID, so that the
+ * service can drop the ones sent twice.
+ *
+ * The tabs of the page share the persisted queue. Each tab only sends the
+ * requests it owns, and updates the queue under a Web Lock where supported,
+ * so that it doesn't drop the requests of the other tabs.
+ */
+export class AnalyticsQueue {
+  /**
+   * @param {!./deps.DepsDef} deps
+   * @param {!./fetcher.Fetcher} fetcher
+   * @param {!../utils/retry-policy.RetryPolicy=} retryPolicy
+   */
+  constructor(deps, fetcher, retryPolicy) {
+    /** @private @const {!./deps.DepsDef} */
+    this.deps_ = deps;
+
+    /** @private @const {!Window} */
+    this.win_ = deps.win();
+
+    /** @private @const {!./fetcher.Fetcher} */
+    this.fetcher_ = fetcher;
+
+    /** @private @const {!../utils/retry-policy.RetryPolicy|undefined} */
+    this.retryPolicy_ = retryPolicy;
+
+    /**
+     * Identifies the entries that this tab owns in the persisted queue.
+     * @private @const {string}
+     */
+    this.owner_ = getUuid();
+
+    /**
+     * The entries owned by this tab.
+     * @private {!Array<!QueueEntry>}
+     */
+    this.entries_ = [];
+
+    /**
+     * The entries that failed, which are persisted without owner so that
+     * the next page view sends them again.
+     * @private {!Array<!QueueEntry>}
+     */
+    this.releasedEntries_ = [];
+
+    /**
+     * IDs of the entries being sent.
+     * @private @const {!Object<string, boolean>}
+     */
+    this.sending_ = {};
+
+    /** @private {?number} */
+    this.timeout_ = null;
+
+    /** @private {!Promise} */
+    this.lastPersist_ = Promise.resolve();
+
+    /** @private @const {function()} */
+    this.onPageHide_ = () => this.flushWithBeacons_();
+
+    /** @private @const {function()} */
+    this.onVisibilityChange_ = () => {
+      if (this.win_.document.visibilityState == 'hidden') {
+        this.flushWithBeacons_();
+      }
+    };
+
+    this.win_.addEventListener('pagehide', this.onPageHide_);
+    this.win_.document.addEventListener(
+      'visibilitychange',
+      this.onVisibilityChange_
+    );
+
+    /**
+     * Resolved once the entries persisted by previous page views are loaded.
+     * @private @const {!Promise}
+     */
+    this.loaded_ = this.load_();
+  }
+
+  /**
+   * @param {!AnalyticsRequest} request
+   */
+  enqueue(request) {
+    this.entries_.push({
+      id: getUuid(),
+      time: Date.now(),
+      data: request.toArray(),
+    });
+    this.trim_();
+    this.persist_();
+    if (this.getPendingEntries_().length >= MAX_BATCH_SIZE) {
+      this.flush();
+    } else {
+      this.scheduleFlush_();
+    }
+  }
+
+  /**
+   * Sends a batch of the queued requests.
+   * @return {!Promise}
+   */
+  flush() {
+    this.cancelScheduledFlush_();
+    return this.loaded_.then(() => {
+      const batch = this.getPendingEntries_().slice(0, MAX_BATCH_SIZE);
+      if (!batch.length) {
+        return;
+      }
+      for (const entry of batch) {
+        this.sending_[entry.id] = true;
+      }
+      return Promise.all(
+        batch.map((entry) =>
+          this.fetcher_
+            .sendPost(
+              this.getUrl_(entry),
+              new AnalyticsRequest(entry.data),
+              this.retryPolicy_
+            )
+            .then(() => true, () => false)
+        )
+      ).then((results) => {
+        batch.forEach((entry, index) => {
+          delete this.sending_[entry.id];
+          if (!results[index] && this.entries_.includes(entry)) {
+            this.releasedEntries_.push(entry);
+          }
+        });
+        this.remove_(batch.map((entry) => entry.id));
+        if (this.getPendingEntries_().length) {
+          this.scheduleFlush_();
+        }
+      });
+    });
+  }
+
+  /**
+   * @return {!Promise} Resolved once the queue is persisted.
+   */
+  whenPersisted() {
+    return this.lastPersist_;
+  }
+
+  /**
+   * Stops listening to the page. The queued requests stay persisted.
+   */
+  destroy() {
+    this.cancelScheduledFlush_();
+    this.win_.removeEventListener('pagehide', this.onPageHide_);
+    this.win_.document.removeEventListener(
+      'visibilitychange',
+      this.onVisibilityChange_
+    );
+  }
+
+  /**
+   * Sends the pending requests with beacons, which survive the page.
+   * @private
+   */
+  flushWithBeacons_() {
+    this.cancelScheduledFlush_();
+    const entries = this.getPendingEntries_();
+    for (const entry of entries) {
+      this.fetcher_.sendBeacon(
+        this.getUrl_(entry),
+        new AnalyticsRequest(entry.data),
+        this.retryPolicy_
+      );
+    }
+    this.remove_(entries.map((entry) => entry.id));
+  }
+
+  /**
+   * Takes over the persisted entries that no other tab owns.
+   * @return {!Promise}
+   * @private
+   */
+  load_() {
+    return this.update_((stored) => {
+      const ids = this.entries_.map((entry) => entry.id);
+      const loaded = [];
+      const kept = [];
+      for (const entry of stored) {
+        // Entries are identified by ID, so that none is sent twice.
+        if (ids.includes(entry['id'])) {
+          continue;
+        }
+        ids.push(entry['id']);
+        if (entry['owner'] && Date.now() - entry['time'] < ORPHAN_AGE_MS) {
+          kept.push(entry);
+        } else {
+          loaded.push({
+            id: entry['id'],
+            time: entry['time'],
+            data: entry['data'],
+          });
+        }
+      }
+      this.entries_ = loaded.concat(this.entries_);
+      this.trim_();
+      if (this.entries_.length) {
+        this.scheduleFlush_();
+      }
+      return kept;
+    });
+  }
+
+  /**
+   * Drops the requests that are too old, and the oldest requests if there
+   * are too many.
+   * @private
+   */
+  trim_() {
+    const now = Date.now();
+    this.entries_ = this.entries_
+      .filter((entry) => now - entry.time < MAX_AGE_MS)
+      .slice(-MAX_ENTRIES);
+  }
+
+  /**
+   * @param {!Array<string>} ids
+   * @private
+   */
+  remove_(ids) {
+    if (!ids.length) {
+      return;
+    }
+    this.entries_ = this.entries_.filter((entry) => !ids.includes(entry.id));
+    this.persist_();
+  }
+
+  /**
+   * Persists the queue, once the entries of previous page views are loaded
+   * so that they aren't overwritten.
+   * @private
+   */
+  persist_() {
+    this.lastPersist_ = this.loaded_.then(() =>
+      this.update_((stored) => stored)
+    );
+  }
+
+  /**
+   * Replaces the entries of this tab in the persisted queue, under a lock
+   * so that the other tabs don't update it in the meantime.
+   * @param {function(!Array<!Object>):!Array<!Object>} getOtherEntries
+   *     Returns the persisted entries to keep, from those that this tab
+   *     doesn't own.
+   * @return {!Promise}
+   * @private
+   */
+  update_(getOtherEntries) {
+    return this.withLock_(() => {
+      const storage = this.deps_.storage();
+      return storage
+        .reload(STORAGE_KEY, /* useLocalStorage */ true)
+        .then((value) => {
+          const parsed = (value && tryParseJson(value)) || [];
+          const stored = (Array.isArray(parsed) ? parsed : []).filter(
+            (entry) => entry && entry['owner'] != this.owner_
+          );
+          const otherEntries = getOtherEntries(stored);
+          const otherIds = otherEntries.map((entry) => entry['id']);
+          // The entries that another tab took over are its own now.
+          this.entries_ = this.entries_.filter(
+            (entry) => !otherIds.includes(entry.id)
+          );
+          const now = Date.now();
+          const entries = otherEntries
+            .concat(
+              this.releasedEntries_.map((entry) => toStoredEntry(entry, null)),
+              this.entries_.map((entry) => toStoredEntry(entry, this.owner_))
+            )
+            .filter((entry) => now - entry['time'] < MAX_AGE_MS)
+            .sort((a, b) => a['time'] - b['time'])
+            .slice(-MAX_ENTRIES);
+          this.releasedEntries_ = [];
+          if (!entries.length) {
+            return storage.remove(STORAGE_KEY, /* useLocalStorage */ true);
+          }
+          return storage.set(
+            STORAGE_KEY,
+            JSON.stringify(entries),
+            /* useLocalStorage */ true
+          );
+        });
+    });
+  }
+
+  /**
+   * Runs the callback under the Web Lock of the queue, where supported.
+   * @param {function():!Promise} callback
+   * @return {!Promise}
+   * @private
+   */
+  withLock_(callback) {
+    const locks = this.win_.navigator && this.win_.navigator.locks;
+    if (!locks) {
+      return callback();
+    }
+    return locks.request(LOCK_NAME, callback);
+  }
+
+  /**
+   * @return {!Array<!QueueEntry>} The entries that aren't being sent.
+   * @private
+   */
+  getPendingEntries_() {
+    return this.entries_.filter((entry) => !this.sending_[entry.id]);
+  }
+
+  /** @private */
+  scheduleFlush_() {
+    if (this.timeout_ === null) {
+      this.timeout_ = this.win_.setTimeout(() => {
+        this.timeout_ = null;
+        this.flush();
+      }, BATCH_DELAY_MS);
+    }
+  }
+
+  /** @private */
+  cancelScheduledFlush_() {
+    if (this.timeout_ !== null) {
+      this.win_.clearTimeout(this.timeout_);
+      this.timeout_ = null;
+    }
+  }
+
+  /**
+   * The request has no field for the ID, so it's sent in the URL.
+   * @param {!QueueEntry} entry
+   * @return {string}
+   * @private
+   */
+  getUrl_(entry) {
+    const pubId = encodeURIComponent(
+      this.deps_.pageConfig().getPublicationId()
+    );
+    return addQueryParam(
+      serviceUrl('/publication/' + pubId + '/clientlogs'),
+      'eventId',
+      entry.id
+    );
+  }
+}
+
+/**
+ * @param {!QueueEntry} entry
+ * @param {?string} owner
+ * @return {!Object}
+ */
+function toStoredEntry(entry, owner) {
+  return {
+    'id': entry.id,
+    'time': entry.time,
+    'data': entry.data,
+    'owner': owner,
+  };
+}
@@ -21,11 +21,13 @@ import {
   EventParams,
   FinishedLoggingResponse,
 } from '../proto/api_messages';
+import {AnalyticsQueue} from './analytics-queue';
 import {AnalyticsService} from './analytics-service';
 import {ClientEventManager} from './client-event-manager';
 import {ConfiguredRuntime} from './runtime';
 import {ExperimentFlags} from './experiment-flags';
 import {PageConfig} from '../model/page-config';
+import {RetriedRequest} from './retry-policies';
 import {XhrFetcher} from './fetcher';
 import {feUrl} from './services';
 import {getStyle} from '../utils/style';
@@ -354,6 +356,26 @@ describes.realWin('AnalyticsService', {}, (env) => {
       expect(loggedErrors.length).to.equal(1);
     });
 
+    it('should release pending logs once destroyed', async () => {
+      sandbox.stub(activityIframePort, 'execute').callsFake(() => {});
+      sandbox.stub(activityIframePort, 'disconnect');
+      eventManagerCallback({
+        eventType: AnalyticsEvent.IMPRESSION_PAYWALL,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        isFromUserAction: true,
+        additionalParameters: null,
+      });
+      await analyticsService.lastAction_;
+      const loggingPromise = analyticsService.getLoggingPromise();
+
+      analyticsService.destroy();
+
+      expect(await loggingPromise).to.be.false;
+      expect(await analyticsService.getLoggingPromise()).to.be.true;
+      expect(analyticsService.getElement().parentNode).to.be.null;
+      expect(activityIframePort.disconnect).to.be.calledOnce;
+    });
+
     it('should report error with log', async function () {
       const err = 'Fake error';
       eventManagerCallback({
@@ -582,6 +604,20 @@ describes.realWin('AnalyticsService', {}, (env) => {
       testOriginator(EventOriginator.SHOWCASE_CLIENT, false);
     });
 
+    it('should not log retries of its own requests', () => {
+      const eventType = AnalyticsEvent.EVENT_CUSTOM;
+      event.additionalParameters = {
+        'retry': {'request': RetriedRequest.CLIENT_LOGS},
+      };
+      testOriginator(EventOriginator.SWG_CLIENT, false, eventType);
+
+      event.additionalParameters = {
+        'retry': {'request': RetriedRequest.ENTITLEMENTS},
+      };
+      testOriginator(EventOriginator.SWG_CLIENT, true, eventType);
+      event.additionalParameters = {};
+    });
+
     it('should not log publisher events by default', () => {
       testOriginator(EventOriginator.SWG_CLIENT, true);
       testOriginator(EventOriginator.SWG_SERVER, true);
@@ -610,6 +646,88 @@ describes.realWin('AnalyticsService', {}, (env) => {
     });
   });
 
+  describe('Consent', () => {
+    it('should only send aggregate events without consent', () => {
+      const sendAnonymousPostStub = sandbox.stub(
+        XhrFetcher.prototype,
+        'sendAnonymousPost'
+      );
+      runtime.configure({consent: {storage: true}});
+      event.additionalParameters = new EventParams();
+
+      analyticsService.handleClientEvent_(event);
+
+      expect(activityPorts.openIframe).to.not.be.called;
+      expect(eventsLoggedToService).to.be.empty;
+      expect(sendAnonymousPostStub).to.be.calledOnce;
+      const [url, request] = sendAnonymousPostStub.args[0];
+      expect(url).to.contain('/publication/pub1/clientlogs');
+      expect(request.getEvent()).to.equal(event.eventType);
+      expect(request.getContext().getClientVersion()).to.exist;
+      expect(request.getContext().getTransactionId()).to.be.null;
+      expect(request.getContext().getUrl()).to.be.null;
+      expect(request.getParams()).to.be.null;
+      event.additionalParameters = {};
+    });
+
+    it('should open the service iframe with consent', async () => {
+      runtime.configure({consent: {analytics: true}});
+      sandbox.stub(activityIframePort, 'on');
+      const executeStub = sandbox.stub(activityIframePort, 'execute');
+
+      analyticsService.handleClientEvent_(event);
+      await analyticsService.lastAction_;
+
+      expect(activityPorts.openIframe).to.be.calledOnce;
+      expect(executeStub).to.be.calledOnce;
+    });
+  });
+
+  describe('Durable analytics queue', () => {
+    beforeEach(() => {
+      setExperimentsStringForTesting(ExperimentFlags.DURABLE_ANALYTICS_QUEUE);
+      // The window of the test lost its prototype when it was copied.
+      sandbox.stub(runtime, 'win').returns(env.win.document.defaultView);
+    });
+
+    it('should queue the requests instead of opening the iframe', () => {
+      const enqueueStub = sandbox.stub(AnalyticsQueue.prototype, 'enqueue');
+
+      analyticsService.handleClientEvent_(event);
+
+      expect(activityPorts.openIframe).to.not.be.called;
+      expect(enqueueStub).to.be.calledOnce;
+      const request = enqueueStub.args[0][0];
+      expect(request.getEvent()).to.equal(event.eventType);
+      expect(request.getContext().getUrl()).to.equal(URL);
+      expect(request.getContext().getLabelList()).to.include(
+        ExperimentFlags.DURABLE_ANALYTICS_QUEUE
+      );
+    });
+
+    it('should wait for the queue to be persisted', async () => {
+      sandbox.stub(AnalyticsQueue.prototype, 'enqueue');
+      const whenPersistedStub = sandbox
+        .stub(AnalyticsQueue.prototype, 'whenPersisted')
+        .resolves();
+
+      analyticsService.handleClientEvent_(event);
+
+      expect(await analyticsService.getLoggingPromise()).to.be.true;
+      expect(whenPersistedStub).to.be.calledOnce;
+    });
+
+    it('should destroy the queue', () => {
+      sandbox.stub(AnalyticsQueue.prototype, 'enqueue');
+      const destroyStub = sandbox.stub(AnalyticsQueue.prototype, 'destroy');
+      analyticsService.handleClientEvent_(event);
+
+      analyticsService.destroy();
+
+      expect(destroyStub).to.be.calledOnce;
+    });
+  });
+
   describe('EventParams', () => {
     it('should ignore additionalParameters', () => {
       const logRequest = analyticsService.createLogRequest_(event);
@@ -623,4 +741,38 @@ describes.realWin('AnalyticsService', {}, (env) => {
       event.additionalParameters = {};
     });
   });
+
+  describe('Performance timings', () => {
+    it('should add the timings to the context', () => {
+      analyticsService.addLabels(['L1']);
+      runtime.performanceTimings().record('entitlements', 212.4);
+      runtime.performanceTimings().record('clientConfig', 98.6);
+
+      const logRequest = analyticsService.createLogRequest_(event);
+
+      expect(logRequest.getContext().getLabelList()).to.deep.equal([
+        'L1',
+        'swg-timing:entitlements=212',
+        'swg-timing:clientConfig=99',
+      ]);
+    });
+
+    it('should log the latest durations of the timings', () => {
+      runtime.performanceTimings().record('entitlements', 212);
+      analyticsService.createLogRequest_(event);
+      runtime.performanceTimings().record('entitlements', 150);
+
+      const logRequest = analyticsService.createLogRequest_(event);
+
+      expect(logRequest.getContext().getLabelList()).to.deep.equal([
+        'swg-timing:entitlements=150',
+      ]);
+    });
+
+    it('should not add timings to the context without any', () => {
+      const logRequest = analyticsService.createLogRequest_(event);
+
+      expect(logRequest.getContext().getLabelList()).to.deep.equal([]);
+    });
+  });
 });
@@ -23,13 +23,20 @@ import {
   EventParams,
   FinishedLoggingResponse,
 } from '../proto/api_messages';
+import {AnalyticsQueue} from './analytics-queue';
 import {ClientEventManager} from './client-event-manager';
+import {ConsentPurpose} from '../api/subscriptions';
 import {ExperimentFlags} from './experiment-flags';
-import {createElement} from '../utils/dom';
+import {
+  RetriedRequest,
+  createRetryPolicy,
+  isRetryEventOf,
+} from './retry-policies';
+import {createElement, removeElement} from '../utils/dom';
 import {feUrl} from './services';
 import {getCanonicalUrl} from '../utils/url';
 import {getOnExperiments, isExperimentOn} from './experiments';
-import {getSwgTransactionId, getUuid} from '../utils/string';
+import {getSwgTransactionId, getUuid, startsWith} from '../utils/string';
 import {log} from '../utils/log';
 import {parseQueryString, parseUrl} from '../utils/url';
 import {serviceUrl} from './services';
@@ -51,6 +58,13 @@ const iframeStyles = {
 // most 100 ms.
 const MAX_FIRST_WAIT = 500;
 const MAX_WAIT = 200;
+
+/**
+ * Prefixes the labels of the performance timings, since `AnalyticsContext` has
+ * no field for them, e.g. "swg-timing:entitlements=212".
+ * @const {string}
+ */
+const TIMING_LABEL_PREFIX = 'swg-timing:';
 // If we logged and rapidly redirected, we will add a short delay in case
 // a message hasn't been transmitted yet.
 const TIMEOUT_ERROR = 'AnalyticsService timed out waiting for a response';
@@ -70,11 +84,16 @@ export class AnalyticsService {
   /**
    * @param {!./deps.DepsDef} deps
    * @param {!./fetcher.Fetcher} fetcher
+   * @param {!../utils/retry-policy.RetryPolicy=} retryPolicy
    */
-  constructor(deps, fetcher) {
+  constructor(deps, fetcher, retryPolicy) {
     /** @private @const {!./fetcher.Fetcher} */
     this.fetcher_ = fetcher;
 
+    /** @private @const {!../utils/retry-policy.RetryPolicy} */
+    this.retryPolicy_ =
+      retryPolicy || createRetryPolicy(deps, RetriedRequest.CLIENT_LOGS);
+
     /** @private @const {!../model/doc.Doc} */
     this.doc_ = deps.doc();
 
@@ -137,6 +156,13 @@ export class AnalyticsService {
     this.getTimestamp_ = () => {
       return toTimestamp(Date.now());
     };
+
+    /**
+     * The queue of requests, created on the first request logged with the
+     * durable analytics queue experiment.
+     * @private {?AnalyticsQueue}
+     */
+    this.queue_ = null;
   }
 
   /**
@@ -307,6 +333,30 @@ export class AnalyticsService {
     this.doc_.getBody().removeChild(this.getElement());
   }
 
+  /**
+   * Closes the connection to the service iframe and removes it. Callers
+   * waiting for pending logs are released.
+   */
+  destroy() {
+    this.loggingBroken_ = true;
+    if (this.timeout_ !== null) {
+      clearTimeout(this.timeout_);
+      this.timeout_ = null;
+    }
+    if (this.loggingResolver_) {
+      this.loggingResolver_(false);
+      this.promiseToLog_ = null;
+      this.loggingResolver_ = null;
+    }
+    if (this.serviceReady_) {
+      this.serviceReady_.then((port) => port.disconnect(), () => {});
+    }
+    if (this.queue_) {
+      this.queue_.destroy();
+    }
+    removeElement(this.iframe_);
+  }
+
   /**
    * @return {!AnalyticsContext}
    */
@@ -325,6 +375,7 @@ export class AnalyticsService {
     // Update the of the analytics context to the current time.
     // This needs to be current for log analysis.
     this.context_.setClientTimestamp(this.getTimestamp_());
+    this.updateTimingLabels_();
     const request = new AnalyticsRequest();
     request.setEvent(/** @type {!AnalyticsEvent} */ (event.eventType));
     request.setContext(this.context_);
@@ -335,6 +386,46 @@ export class AnalyticsService {
     return request;
   }
 
+  /**
+   * Replaces the timing labels of the analytics context with the latest
+   * duration of each timing, in milliseconds.
+   * @private
+   */
+  updateTimingLabels_() {
+    const labels = this.context_
+      .getLabelList()
+      .filter((label) => !startsWith(label, TIMING_LABEL_PREFIX));
+    const durations = this.deps_.performanceTimings().getDurations();
+    for (const timing in durations) {
+      labels.push(
+        `${TIMING_LABEL_PREFIX}${timing}=${Math.round(durations[timing])}`
+      );
+    }
+    this.context_.setLabelList(labels);
+  }
+
+  /**
+   * Creates a request for readers who didn't consent to analytics. Only the
+   * event is sent, without the context and the parameters that could
+   * identify the reader.
+   * @param {!../api/client-event-manager-api.ClientEvent} event
+   * @return {!AnalyticsRequest}
+   * @private
+   */
+  createAggregateLogRequest_(event) {
+    const meta = new AnalyticsEventMeta();
+    meta.setEventOriginator(event.eventOriginator);
+    meta.setIsFromUserAction(!!event.isFromUserAction);
+    const context = new AnalyticsContext();
+    context.setClientVersion('SwG $internalRuntimeVersion$');
+    context.setClientTimestamp(this.getTimestamp_());
+    const request = new AnalyticsRequest();
+    request.setEvent(/** @type {!AnalyticsEvent} */ (event.eventType));
+    request.setContext(context);
+    request.setMeta(meta);
+    return request;
+  }
+
   /**
    * @return {boolean}
    */
@@ -377,6 +468,10 @@ export class AnalyticsService {
       return;
     }
 
+    if (isRetryEventOf(event, RetriedRequest.CLIENT_LOGS)) {
+      return;
+    }
+
     if (
       ClientEventManager.isPublisherEvent(event) &&
       !this.shouldLogPublisherEvents_() &&
@@ -384,6 +479,23 @@ export class AnalyticsService {
     ) {
       return;
     }
+    if (!this.deps_.consentManager().hasConsent(ConsentPurpose.ANALYTICS)) {
+      // Beacons always send cookies, so the aggregate request is posted
+      // without them.
+      this.fetcher_.sendAnonymousPost(
+        this.getClientLogsUrl_(),
+        this.createAggregateLogRequest_(event),
+        this.retryPolicy_
+      );
+      return;
+    }
+    const win = this.doc_.getWin();
+    if (isExperimentOn(win, ExperimentFlags.DURABLE_ANALYTICS_QUEUE)) {
+      // The iframe isn't opened, so the experiments are added here.
+      this.addLabels(getOnExperiments(win));
+      this.getQueue_().enqueue(this.createLogRequest_(event));
+      return;
+    }
     // Register we sent a log, the port will call this.afterLogging_ when done.
     this.unfinishedLogs_++;
     this.lastAction_ = this.start().then((port) => {
@@ -438,6 +550,10 @@ export class AnalyticsService {
    * @return {!Promise}
    */
   getLoggingPromise() {
+    if (this.queue_) {
+      // The requests left are sent with beacons once the page is hidden.
+      return this.queue_.whenPersisted().then(() => true, () => false);
+    }
     if (this.unfinishedLogs_ === 0 || this.loggingBroken_) {
       return Promise.resolve(true);
     }
@@ -461,16 +577,42 @@ export class AnalyticsService {
     return this.promiseToLog_;
   }
 
+  /**
+   * @return {!AnalyticsQueue}
+   * @private
+   */
+  getQueue_() {
+    if (!this.queue_) {
+      this.queue_ = new AnalyticsQueue(
+        this.deps_,
+        this.fetcher_,
+        this.retryPolicy_
+      );
+    }
+    return this.queue_;
+  }
+
   /**
    * A beacon is a rapid fire browser request that does not wait for a response
    * from the server.  It is guaranteed to go out before the page redirects.
    * @param {!AnalyticsRequest} analyticsRequest
    */
   sendBeacon_(analyticsRequest) {
+    this.fetcher_.sendBeacon(
+      this.getClientLogsUrl_(),
+      analyticsRequest,
+      this.retryPolicy_
+    );
+  }
+
+  /**
+   * @return {string}
+   * @private
+   */
+  getClientLogsUrl_() {
     const pubId = encodeURIComponent(
       this.deps_.pageConfig().getPublicationId()
     );
-    const url = serviceUrl('/publication/' + pubId + '/clientlogs');
-    this.fetcher_.sendBeacon(url, analyticsRequest);
+    return serviceUrl('/publication/' + pubId + '/clientlogs');
   }
 }
@@ -0,0 +1,130 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {
+  AnalyticsEvent,
+  EventOriginator,
+  EventParams,
+} from '../proto/api_messages';
+import {ConfiguredRuntime} from './runtime';
+import {DataLayerSink} from './analytics-sinks';
+import {PageConfig} from '../model/page-config';
+import {SubscriptionFlows} from '../api/subscriptions';
+
+describes.realWin('AnalyticsSinkManager', {}, (env) => {
+  let runtime;
+  let eventManager;
+  let sink;
+
+  beforeEach(async () => {
+    sink = {send: sandbox.spy()};
+    runtime = new ConfiguredRuntime(env.win, new PageConfig('pub1:label1'));
+    runtime.configure({analyticsSinks: [sink]});
+    eventManager = runtime.eventManager();
+    sandbox.stub(self.console, 'log');
+    // Skips the page load event.
+    await eventManager.lastAction_;
+    sink.send.resetHistory();
+  });
+
+  it('should send normalized events to the sinks', async () => {
+    runtime.analytics().setTransactionId('transaction1');
+    runtime.analytics().addLabels(['label1']);
+    const eventParams = new EventParams();
+    eventParams.setSku('sku1');
+    eventParams.setSubscriptionFlow(SubscriptionFlows.SUBSCRIBE);
+
+    eventManager.logSwgEvent(
+      AnalyticsEvent.ACTION_PAYMENT_COMPLETE,
+      true,
+      eventParams
+    );
+    await eventManager.lastAction_;
+
+    const event = sink.send.lastCall.args[0];
+    expect(event).to.deep.include({
+      'eventType': 'ACTION_PAYMENT_COMPLETE',
+      'originator': 'SWG_CLIENT',
+      'isFromUserAction': true,
+      'flow': SubscriptionFlows.SUBSCRIBE,
+      'sku': 'sku1',
+      'transactionId': 'transaction1',
+    });
+    expect(event.labels).to.include('label1');
+    expect(event.timestamp).to.be.a('number');
+  });
+
+  it('should not send events without consent to analytics', async () => {
+    runtime.configure({consent: {storage: true, analytics: false}});
+
+    eventManager.logSwgEvent(AnalyticsEvent.IMPRESSION_PAYWALL);
+    await eventManager.lastAction_;
+    expect(sink.send).to.not.be.called;
+
+    runtime.configure({consent: {analytics: true}});
+    eventManager.logSwgEvent(AnalyticsEvent.IMPRESSION_PAYWALL);
+    await eventManager.lastAction_;
+    expect(sink.send).to.be.calledOnce;
+  });
+
+  it('should leave out the parameters of publisher events', async () => {
+    eventManager.logEvent({
+      eventType: AnalyticsEvent.IMPRESSION_PAYWALL,
+      eventOriginator: EventOriginator.PUBLISHER_CLIENT,
+      isFromUserAction: false,
+      additionalParameters: {'email': 'reader@example.com'},
+    });
+    await eventManager.lastAction_;
+
+    const event = sink.send.lastCall.args[0];
+    expect(event.flow).to.be.null;
+    expect(event.sku).to.be.null;
+    expect(JSON.stringify(event)).to.not.contain('reader@example.com');
+  });
+
+  it('should keep sending events to the other sinks', async () => {
+    const failingSink = {send: sandbox.stub().throws(new Error('broken'))};
+    const rejectingSink = {send: sandbox.stub().rejects(new Error('broken'))};
+    runtime.configure({analyticsSinks: [failingSink, rejectingSink, sink]});
+
+    eventManager.logSwgEvent(AnalyticsEvent.IMPRESSION_OFFERS);
+    await eventManager.lastAction_;
+
+    expect(failingSink.send).to.be.called;
+    expect(rejectingSink.send).to.be.called;
+    expect(sink.send).to.be.called;
+  });
+
+  it('should create the built-in sinks', async () => {
+    const sendSpy = sandbox.spy(DataLayerSink.prototype, 'send');
+    runtime.configure({analyticsSinks: [{type: 'dataLayer'}]});
+
+    eventManager.logSwgEvent(AnalyticsEvent.IMPRESSION_OFFERS);
+    await eventManager.lastAction_;
+
+    expect(sendSpy).to.be.called;
+    expect(sink.send).to.not.be.called;
+  });
+
+  it('should stop sending events once destroyed', async () => {
+    runtime.analyticsSinkManager_.destroy();
+
+    eventManager.logSwgEvent(AnalyticsEvent.IMPRESSION_OFFERS);
+    await eventManager.lastAction_;
+
+    expect(sink.send).to.not.be.called;
+  });
+});
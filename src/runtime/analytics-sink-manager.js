@@ -0,0 +1,120 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {
+  AnalyticsEvent,
+  EventOriginator,
+  EventParams,
+} from '../proto/api_messages';
+import {ConsentPurpose} from '../api/subscriptions';
+import {createAnalyticsSink} from './analytics-sinks';
+import {getEnumName, isFunction} from '../utils/types';
+import {log} from '../utils/log';
+
+/**
+ * Sends the SwG events of the page to the analytics sinks set with the
+ * `analyticsSinks` config, normalized into `AnalyticsSinkEvent`s. Events are
+ * only sent once the reader consents to analytics.
+ */
+export class AnalyticsSinkManager {
+  /**
+   * @param {!./deps.DepsDef} deps
+   */
+  constructor(deps) {
+    /** @private @const {!./deps.DepsDef} */
+    this.deps_ = deps;
+
+    /** @private {!Array<!../api/analytics-sink.AnalyticsSink>} */
+    this.sinks_ = [];
+
+    this.update();
+
+    deps
+      .eventManager()
+      .registerEventListener((event) => this.handleClientEvent_(event));
+  }
+
+  /**
+   * Replaces the sinks with the ones of the `analyticsSinks` config, e.g.
+   * after it was set.
+   */
+  update() {
+    this.destroy();
+    const win = this.deps_.win();
+    this.sinks_ = (this.deps_.config().analyticsSinks || []).map((sink) =>
+      isFunction(sink.send) ? sink : createAnalyticsSink(win, sink)
+    );
+  }
+
+  /**
+   * Stops the built-in sinks, which send the events they batched.
+   */
+  destroy() {
+    for (const sink of this.sinks_) {
+      if (isFunction(sink.destroy)) {
+        sink.destroy();
+      }
+    }
+    this.sinks_ = [];
+  }
+
+  /**
+   * @param {!../api/client-event-manager-api.ClientEvent} event
+   * @private
+   */
+  handleClientEvent_(event) {
+    if (
+      !this.sinks_.length ||
+      !this.deps_.consentManager().hasConsent(ConsentPurpose.ANALYTICS)
+    ) {
+      return;
+    }
+    const sinkEvent = this.normalize_(event);
+    for (const sink of this.sinks_) {
+      // A failing sink shouldn't keep the others from getting the event.
+      try {
+        Promise.resolve(sink.send(sinkEvent)).catch(log);
+      } catch (e) {
+        log(e);
+      }
+    }
+  }
+
+  /**
+   * @param {!../api/client-event-manager-api.ClientEvent} event
+   * @return {!../api/analytics-sink.AnalyticsSinkEvent}
+   * @private
+   */
+  normalize_(event) {
+    const context = this.deps_.analytics().getContext();
+    // additionalParameters isn't strongly typed, and publisher events may
+    // have any parameters.
+    const params =
+      event.additionalParameters instanceof EventParams
+        ? event.additionalParameters
+        : null;
+    return {
+      'eventType': String(getEnumName(AnalyticsEvent, event.eventType)),
+      'originator': String(getEnumName(EventOriginator, event.eventOriginator)),
+      'isFromUserAction': !!event.isFromUserAction,
+      'flow': (params && params.getSubscriptionFlow()) || null,
+      'sku': (params && params.getSku()) || context.getSku() || null,
+      'transactionId': context.getTransactionId() || null,
+      'labels': (context.getLabelList() || []).slice(),
+      'timestamp': Date.now(),
+    };
+  }
+}
@@ -0,0 +1,163 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsSinkType} from '../api/analytics-sink';
+import {
+  DataLayerSink,
+  HttpCollectorSink,
+  createAnalyticsSink,
+  isValidAnalyticsSink,
+} from './analytics-sinks';
+import {Xhr} from '../utils/xhr';
+
+const URL = 'https://collector.example/swg';
+
+describes.sandboxed('analytics sinks', {}, () => {
+  let clock;
+  let win;
+  let listeners;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    listeners = {};
+    const addEventListener = (type, callback) => (listeners[type] = callback);
+    const removeEventListener = (type) => delete listeners[type];
+    win = {
+      document: {
+        visibilityState: 'visible',
+        addEventListener,
+        removeEventListener,
+      },
+      navigator: {sendBeacon: sandbox.stub().returns(true)},
+      addEventListener,
+      removeEventListener,
+      setTimeout: (fn, delay) => setTimeout(fn, delay),
+      clearTimeout: (id) => clearTimeout(id),
+    };
+  });
+
+  /**
+   * @param {string} eventType
+   * @return {!../api/analytics-sink.AnalyticsSinkEvent}
+   */
+  function createEvent(eventType) {
+    return {
+      eventType,
+      originator: 'SWG_CLIENT',
+      isFromUserAction: false,
+      flow: null,
+      sku: null,
+      transactionId: 'transaction1',
+      labels: [],
+      timestamp: 0,
+    };
+  }
+
+  describe('HttpCollectorSink', () => {
+    let fetchStub;
+    let sink;
+
+    beforeEach(() => {
+      fetchStub = sandbox.stub(Xhr.prototype, 'fetch').resolves({});
+      sink = new HttpCollectorSink(win, URL);
+    });
+
+    it('should send the events in batches', () => {
+      sink.send(createEvent('IMPRESSION_OFFERS'));
+      sink.send(createEvent('ACTION_OFFER_SELECTED'));
+      expect(fetchStub).to.not.be.called;
+
+      clock.tick(1000);
+
+      expect(fetchStub).to.be.calledOnce;
+      const [url, init] = fetchStub.args[0];
+      expect(url).to.equal(URL);
+      expect(init.method).to.equal('POST');
+      expect(init.credentials).to.equal('omit');
+      expect(JSON.parse(init.body)).to.deep.equal({
+        'events': [
+          createEvent('IMPRESSION_OFFERS'),
+          createEvent('ACTION_OFFER_SELECTED'),
+        ],
+      });
+    });
+
+    it('should send full batches right away', () => {
+      for (let i = 0; i < 20; i++) {
+        sink.send(createEvent('IMPRESSION_OFFERS'));
+      }
+
+      expect(fetchStub).to.be.calledOnce;
+      clock.tick(1000);
+      expect(fetchStub).to.be.calledOnce;
+    });
+
+    it('should send the events with a beacon once the page is hidden', () => {
+      sink.send(createEvent('IMPRESSION_OFFERS'));
+
+      listeners['pagehide']();
+
+      expect(win.navigator.sendBeacon).to.be.calledOnceWith(URL);
+      const body = win.navigator.sendBeacon.args[0][1];
+      expect(JSON.parse(body).events).to.have.length(1);
+      clock.tick(1000);
+      expect(fetchStub).to.not.be.called;
+    });
+
+    it('should send the events once destroyed', () => {
+      sink.send(createEvent('IMPRESSION_OFFERS'));
+
+      sink.destroy();
+
+      expect(fetchStub).to.be.calledOnce;
+      expect(listeners).to.be.empty;
+    });
+  });
+
+  describe('DataLayerSink', () => {
+    it('should push the events to the data layer', () => {
+      const sink = new DataLayerSink(win);
+      const event = createEvent('IMPRESSION_OFFERS');
+
+      sink.send(event);
+
+      expect(win.dataLayer).to.deep.equal([
+        {'event': 'swg_event', 'swg': event},
+      ]);
+    });
+  });
+
+  it('should create the built-in sinks', () => {
+    expect(
+      createAnalyticsSink(win, {
+        type: AnalyticsSinkType.HTTP_COLLECTOR,
+        url: URL,
+      })
+    ).to.be.instanceOf(HttpCollectorSink);
+    expect(
+      createAnalyticsSink(win, {type: AnalyticsSinkType.DATA_LAYER})
+    ).to.be.instanceOf(DataLayerSink);
+  });
+
+  it('should validate sinks', () => {
+    expect(isValidAnalyticsSink({type: 'dataLayer'})).to.be.true;
+    expect(isValidAnalyticsSink({type: 'httpCollector', url: URL})).to.be.true;
+    expect(isValidAnalyticsSink({send: () => {}})).to.be.true;
+    expect(isValidAnalyticsSink({type: 'httpCollector'})).to.be.false;
+    expect(isValidAnalyticsSink({type: 'warehouse'})).to.be.false;
+    expect(isValidAnalyticsSink('dataLayer')).to.be.false;
+  });
+});
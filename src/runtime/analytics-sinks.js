@@ -0,0 +1,214 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsSinkType} from '../api/analytics-sink';
+import {Xhr} from '../utils/xhr';
+import {isEnumValue, isFunction, isObject} from '../utils/types';
+import {log} from '../utils/log';
+
+/** @const {number} */
+const MAX_BATCH_SIZE = 20;
+
+/**
+ * How long events are batched before being sent.
+ * @const {number}
+ */
+const BATCH_DELAY_MS = 1000;
+
+/**
+ * Sends batches of events to an HTTP collector, as a JSON object with an
+ * `events` array. The events left are sent with a beacon once the page is
+ * hidden.
+ * @implements {../api/analytics-sink.AnalyticsSink}
+ */
+export class HttpCollectorSink {
+  /**
+   * @param {!Window} win
+   * @param {string} url
+   */
+  constructor(win, url) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {string} */
+    this.url_ = url;
+
+    /** @private @const {!Xhr} */
+    this.xhr_ = new Xhr(win);
+
+    /** @private {!Array<!../api/analytics-sink.AnalyticsSinkEvent>} */
+    this.events_ = [];
+
+    /** @private {?number} */
+    this.timeout_ = null;
+
+    /** @private @const {function()} */
+    this.onPageHide_ = () => this.flushWithBeacon_();
+
+    /** @private @const {function()} */
+    this.onVisibilityChange_ = () => {
+      if (this.win_.document.visibilityState == 'hidden') {
+        this.flushWithBeacon_();
+      }
+    };
+
+    this.win_.addEventListener('pagehide', this.onPageHide_);
+    this.win_.document.addEventListener(
+      'visibilitychange',
+      this.onVisibilityChange_
+    );
+  }
+
+  /** @override */
+  send(event) {
+    this.events_.push(event);
+    if (this.events_.length >= MAX_BATCH_SIZE) {
+      this.flush();
+    } else if (this.timeout_ === null) {
+      this.timeout_ = this.win_.setTimeout(() => {
+        this.timeout_ = null;
+        this.flush();
+      }, BATCH_DELAY_MS);
+    }
+  }
+
+  /**
+   * Sends the batched events.
+   * @return {!Promise}
+   */
+  flush() {
+    const body = this.takeBatch_();
+    if (!body) {
+      return Promise.resolve();
+    }
+    const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
+      method: 'POST',
+      headers: {'Content-Type': 'application/json'},
+      credentials: 'omit',
+      body,
+    });
+    return this.xhr_.fetch(this.url_, init).then(
+      () => {},
+      (reason) => log('Failed to send analytics events: ' + reason)
+    );
+  }
+
+  /**
+   * Stops listening to the page, and sends the batched events.
+   */
+  destroy() {
+    this.win_.removeEventListener('pagehide', this.onPageHide_);
+    this.win_.document.removeEventListener(
+      'visibilitychange',
+      this.onVisibilityChange_
+    );
+    this.flush();
+  }
+
+  /**
+   * Sends the batched events with a beacon, which survives the page.
+   * @private
+   */
+  flushWithBeacon_() {
+    const navigator = this.win_.navigator;
+    if (!navigator || !navigator.sendBeacon) {
+      this.flush();
+      return;
+    }
+    const body = this.takeBatch_();
+    // A string body is sent as plain text, so that the beacon doesn't need
+    // a CORS preflight.
+    if (body && navigator.sendBeacon(this.url_, body) === false) {
+      log('Failed to send analytics events with a beacon');
+    }
+  }
+
+  /**
+   * @return {?string} The batched events, as a JSON body, or null if there
+   *     are none.
+   * @private
+   */
+  takeBatch_() {
+    if (this.timeout_ !== null) {
+      this.win_.clearTimeout(this.timeout_);
+      this.timeout_ = null;
+    }
+    if (!this.events_.length) {
+      return null;
+    }
+    const events = this.events_;
+    this.events_ = [];
+    return JSON.stringify({'events': events});
+  }
+}
+
+/**
+ * Pushes each event to `window.dataLayer`, as a `swg_event` event with the
+ * event in its `swg` property.
+ * @implements {../api/analytics-sink.AnalyticsSink}
+ */
+export class DataLayerSink {
+  /**
+   * @param {!Window} win
+   */
+  constructor(win) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+  }
+
+  /** @override */
+  send(event) {
+    // The data layer may be created after SwG, e.g. by Google Tag Manager.
+    this.win_.dataLayer = this.win_.dataLayer || [];
+    this.win_.dataLayer.push({'event': 'swg_event', 'swg': event});
+  }
+}
+
+/**
+ * Creates a built-in analytics sink.
+ * @param {!Window} win
+ * @param {!../api/analytics-sink.AnalyticsSinkConfig} config
+ * @return {!../api/analytics-sink.AnalyticsSink}
+ */
+export function createAnalyticsSink(win, config) {
+  switch (config.type) {
+    case AnalyticsSinkType.HTTP_COLLECTOR:
+      return new HttpCollectorSink(win, /** @type {string} */ (config.url));
+    case AnalyticsSinkType.DATA_LAYER:
+      return new DataLayerSink(win);
+    default:
+      throw new Error('Unknown analytics sink: ' + config.type);
+  }
+}
+
+/**
+ * Whether the value is the config of a built-in sink or a publisher-supplied
+ * sink implementing the `AnalyticsSink` interface.
+ * @param {*} value
+ * @return {boolean}
+ */
+export function isValidAnalyticsSink(value) {
+  if (!isObject(value)) {
+    return false;
+  }
+  if (isFunction(value.send)) {
+    return true;
+  }
+  if (value.type == AnalyticsSinkType.HTTP_COLLECTOR) {
+    return typeof value.url == 'string' && !!value.url;
+  }
+  return isEnumValue(AnalyticsSinkType, value.type);
+}
@@ -0,0 +1,163 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {
+  AutoPromptHistory,
+  HistoryEvent,
+  RETENTION_MILLIS,
+  getCampaignScope,
+  getTypeScope,
+} from './auto-prompt-history';
+import {AutoPromptType} from '../api/basic-subscriptions';
+
+const NOW = 1615416442000;
+const SCOPE = getTypeScope(AutoPromptType.CONTRIBUTION);
+
+describes.realWin('AutoPromptHistory', {}, () => {
+  let history;
+
+  beforeEach(() => {
+    history = new AutoPromptHistory();
+  });
+
+  it('should count events within a window', () => {
+    history.record([SCOPE], HistoryEvent.IMPRESSION, NOW - 5000);
+    history.record([SCOPE], HistoryEvent.IMPRESSION, NOW - 1000);
+
+    expect(history.count(SCOPE, HistoryEvent.IMPRESSION, 2000, NOW)).to.equal(
+      1
+    );
+    expect(
+      history.count(SCOPE, HistoryEvent.IMPRESSION, undefined, NOW)
+    ).to.equal(2);
+    expect(history.count(SCOPE, HistoryEvent.DISMISSAL, 2000, NOW)).to.equal(
+      0
+    );
+    expect(history.count('t:other', HistoryEvent.IMPRESSION, 2000, NOW)).to
+      .equal(0);
+  });
+
+  it('should record events in each scope', () => {
+    const campaignScope = getCampaignScope('spring');
+    history.record([SCOPE, campaignScope], HistoryEvent.DISMISSAL, NOW);
+
+    expect(history.getLast(SCOPE, HistoryEvent.DISMISSAL)).to.equal(NOW);
+    expect(history.getLast(campaignScope, HistoryEvent.DISMISSAL)).to.equal(
+      NOW
+    );
+    expect(history.getLast(SCOPE, HistoryEvent.IMPRESSION)).to.be.null;
+  });
+
+  it('should return the last event', () => {
+    history.record([SCOPE], HistoryEvent.DISMISSAL, NOW);
+    history.record([SCOPE], HistoryEvent.DISMISSAL, NOW - 1000);
+
+    expect(history.getLast(SCOPE, HistoryEvent.DISMISSAL)).to.equal(NOW);
+  });
+
+  it('should serialize and parse the history', () => {
+    history.record([SCOPE], HistoryEvent.IMPRESSION, NOW);
+
+    const value = history.serialize(NOW);
+
+    expect(JSON.parse(value)).to.deep.equal({
+      'v': 1,
+      's': {'t:contribution': {'i': {'t': [NOW], 'n': 1}}},
+    });
+    expect(
+      AutoPromptHistory.parse(value).count(
+        SCOPE,
+        HistoryEvent.IMPRESSION,
+        1000,
+        NOW
+      )
+    ).to.equal(1);
+  });
+
+  it('should drop old timestamps but keep the lifetime count', () => {
+    history.record(
+      [SCOPE],
+      HistoryEvent.IMPRESSION,
+      NOW - RETENTION_MILLIS - 1
+    );
+    for (let i = 0; i < 60; i++) {
+      history.record([SCOPE], HistoryEvent.IMPRESSION, NOW - i);
+    }
+
+    const parsed = AutoPromptHistory.parse(history.serialize(NOW));
+
+    expect(
+      parsed.count(SCOPE, HistoryEvent.IMPRESSION, RETENTION_MILLIS * 2, NOW)
+    ).to.equal(50);
+    expect(parsed.count(SCOPE, HistoryEvent.IMPRESSION, undefined, NOW)).to
+      .equal(61);
+  });
+
+  it('should parse missing, invalid and unknown histories as empty', () => {
+    [
+      null,
+      '',
+      'not json',
+      '{"v":2,"s":{"t:contribution":{"i":{"t":[],"n":1}}}}',
+      '{"v":1}',
+    ].forEach((value) => {
+      expect(
+        AutoPromptHistory.parse(value).count(
+          SCOPE,
+          HistoryEvent.IMPRESSION,
+          undefined,
+          NOW
+        )
+      ).to.equal(0);
+    });
+  });
+
+  it('should only make unknown versions read-only', () => {
+    expect(AutoPromptHistory.parse('{"v":2,"s":{}}').isReadOnly()).to.be.true;
+    expect(AutoPromptHistory.parse('{"s":{}}').isReadOnly()).to.be.false;
+    expect(AutoPromptHistory.parse('{"v":1,"s":{}}').isReadOnly()).to.be.false;
+    expect(AutoPromptHistory.parse('not json').isReadOnly()).to.be.false;
+    expect(AutoPromptHistory.parse(null).isReadOnly()).to.be.false;
+  });
+
+  it('should migrate legacy events to every prompt type', () => {
+    history.migrate(`${NOW - 2000},${NOW - 1000}`, `${NOW - 1000}`);
+
+    [
+      AutoPromptType.CONTRIBUTION,
+      AutoPromptType.CONTRIBUTION_LARGE,
+      AutoPromptType.SUBSCRIPTION,
+      AutoPromptType.SUBSCRIPTION_LARGE,
+    ].forEach((type) => {
+      const scope = getTypeScope(type);
+      expect(
+        history.count(scope, HistoryEvent.IMPRESSION, undefined, NOW)
+      ).to.equal(2);
+      expect(history.getLast(scope, HistoryEvent.DISMISSAL)).to.equal(
+        NOW - 1000
+      );
+    });
+  });
+
+  it('should only have scopes for prompt types with a history', () => {
+    expect(getTypeScope(AutoPromptType.SUBSCRIPTION)).to.equal(
+      't:subscription'
+    );
+    expect(getTypeScope(AutoPromptType.NONE)).to.be.null;
+    expect(getTypeScope(undefined)).to.be.null;
+    expect(getTypeScope('unknown')).to.be.null;
+  });
+});
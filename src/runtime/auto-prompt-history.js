@@ -0,0 +1,261 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AutoPromptType} from '../api/basic-subscriptions';
+import {isObject} from '../utils/types';
+import {tryParseJson} from '../utils/json';
+
+/** Version of the stored format. */
+const FORMAT_VERSION = 1;
+
+/**
+ * How long event timestamps are kept. Caps can't have longer windows, since
+ * they would undercount.
+ */
+export const RETENTION_MILLIS = 2592000000; // 30 days
+
+/**
+ * Most timestamps kept per scope and event. Caps with a window can't have a
+ * higher maximum, since they would never be reached.
+ */
+export const MAX_TIMESTAMPS = 50;
+
+/** Separator of the timestamps in the legacy format. */
+const LEGACY_DELIMITER = ',';
+
+/**
+ * Events counted by frequency caps.
+ * @enum {string}
+ */
+export const HistoryEvent = {
+  IMPRESSION: 'i',
+  DISMISSAL: 'd',
+};
+
+/**
+ * Timestamps of the recent events, in ascending order, and the lifetime count
+ * of events.
+ * @typedef {{
+ *   t: !Array<number>,
+ *   n: number,
+ * }}
+ */
+let EventLog;
+
+/**
+ * Impressions and dismissals of auto prompts, by scope. A scope is either an
+ * auto prompt type or a campaign.
+ *
+ * Stored as JSON: `{"v": version, "s": {scope: {event: {"t": [...], "n": n}}}}`.
+ */
+export class AutoPromptHistory {
+  /**
+   * @param {!Object<string, !Object<!HistoryEvent, !EventLog>>=} scopes
+   * @param {boolean=} readOnly
+   */
+  constructor(scopes = {}, readOnly = false) {
+    /** @private @const {!Object<string, !Object<!HistoryEvent, !EventLog>>} */
+    this.scopes_ = scopes;
+
+    /** @private @const {boolean} */
+    this.readOnly_ = readOnly;
+  }
+
+  /**
+   * Parses a stored history. Missing, invalid and unknown versions of the
+   * history result in an empty one. Unknown versions, e.g. written by a newer
+   * runtime, result in a read-only one, so that they aren't overwritten.
+   * @param {?string} value
+   * @return {!AutoPromptHistory}
+   */
+  static parse(value) {
+    const data = value ? tryParseJson(value) : null;
+    if (!isObject(data) || typeof data['v'] != 'number') {
+      return new AutoPromptHistory();
+    }
+    if (data['v'] !== FORMAT_VERSION) {
+      return new AutoPromptHistory({}, /* readOnly */ true);
+    }
+    return new AutoPromptHistory(isObject(data['s']) ? data['s'] : {});
+  }
+
+  /**
+   * Whether the stored history has an unknown version, and mustn't be
+   * overwritten.
+   * @return {boolean}
+   */
+  isReadOnly() {
+    return this.readOnly_;
+  }
+
+  /**
+   * Adds events stored in the legacy format, comma separated timestamps shared
+   * by all the prompt types. Since their prompt type is unknown, they count
+   * toward the caps of every type.
+   * @param {?string} impressions
+   * @param {?string} dismissals
+   */
+  migrate(impressions, dismissals) {
+    Object.values(AutoPromptType).forEach((type) => {
+      const scope = getTypeScope(type);
+      if (!scope) {
+        return;
+      }
+      this.addLegacyEvents_(scope, HistoryEvent.IMPRESSION, impressions);
+      this.addLegacyEvents_(scope, HistoryEvent.DISMISSAL, dismissals);
+    });
+  }
+
+  /**
+   * @param {string} scope
+   * @param {!HistoryEvent} event
+   * @param {?string} value
+   * @private
+   */
+  addLegacyEvents_(scope, event, value) {
+    if (!value) {
+      return;
+    }
+    value
+      .split(LEGACY_DELIMITER)
+      .map((timestamp) => parseInt(timestamp, 10))
+      .filter((timestamp) => !isNaN(timestamp))
+      .forEach((timestamp) => this.record([scope], event, timestamp));
+  }
+
+  /**
+   * Records an event in each of the scopes.
+   * @param {!Array<string>} scopes
+   * @param {!HistoryEvent} event
+   * @param {number} timestamp
+   */
+  record(scopes, event, timestamp) {
+    scopes.forEach((scope) => {
+      const log = this.getLog_(scope, event, /* create */ true);
+      log['t'].push(timestamp);
+      log['t'].sort((a, b) => a - b);
+      log['n']++;
+    });
+  }
+
+  /**
+   * Counts the events of a scope within the window ending now.
+   * @param {string} scope
+   * @param {!HistoryEvent} event
+   * @param {number|undefined} windowMillis The lifetime count if undefined.
+   * @param {number} now
+   * @return {number}
+   */
+  count(scope, event, windowMillis, now) {
+    const log = this.getLog_(scope, event);
+    if (!log) {
+      return 0;
+    }
+    if (windowMillis === undefined) {
+      return log['n'];
+    }
+    const recent = log['t'].filter(
+      (timestamp) => now - timestamp <= windowMillis
+    );
+    return recent.length;
+  }
+
+  /**
+   * Returns the time of the last event of a scope, or null if there's none.
+   * @param {string} scope
+   * @param {!HistoryEvent} event
+   * @return {?number}
+   */
+  getLast(scope, event) {
+    const log = this.getLog_(scope, event);
+    return log && log['t'].length ? log['t'][log['t'].length - 1] : null;
+  }
+
+  /**
+   * Serializes the history for storage, dropping the timestamps that are no
+   * longer needed.
+   * @param {number} now
+   * @return {string}
+   */
+  serialize(now) {
+    Object.keys(this.scopes_).forEach((scope) => {
+      Object.keys(this.scopes_[scope]).forEach((event) => {
+        const log = this.scopes_[scope][event];
+        log['t'] = log['t']
+          .filter((timestamp) => now - timestamp <= RETENTION_MILLIS)
+          .slice(-MAX_TIMESTAMPS);
+      });
+    });
+    return JSON.stringify({'v': FORMAT_VERSION, 's': this.scopes_});
+  }
+
+  /**
+   * @param {string} scope
+   * @param {!HistoryEvent} event
+   * @param {boolean=} create
+   * @return {?EventLog}
+   * @private
+   */
+  getLog_(scope, event, create = false) {
+    if (!this.scopes_[scope]) {
+      if (!create) {
+        return null;
+      }
+      this.scopes_[scope] = {};
+    }
+    if (!this.scopes_[scope][event]) {
+      if (!create) {
+        return null;
+      }
+      this.scopes_[scope][event] = {'t': [], 'n': 0};
+    }
+    return this.scopes_[scope][event];
+  }
+}
+
+/**
+ * Returns the scope of an auto prompt type, or null for types without history,
+ * which the legacy events aren't migrated to.
+ * @param {!AutoPromptType|undefined} autoPromptType
+ * @return {?string}
+ */
+export function getTypeScope(autoPromptType) {
+  if (!isAutoPromptType(autoPromptType)) {
+    return null;
+  }
+  return 't:' + autoPromptType;
+}
+
+/**
+ * Whether the value is an auto prompt type with a history, i.e. any type but
+ * NONE.
+ * @param {*} value
+ * @return {boolean}
+ */
+function isAutoPromptType(value) {
+  return (
+    value !== AutoPromptType.NONE &&
+    Object.values(AutoPromptType).includes(value)
+  );
+}
+
+/**
+ * @param {string} campaign
+ * @return {string}
+ */
+export function getCampaignScope(campaign) {
+  return 'c:' + campaign;
+}
@@ -16,8 +16,9 @@
 
 import {AnalyticsEvent, EventOriginator} from '../proto/api_messages';
 import {AutoPromptConfig, UiPredicates} from '../model/auto-prompt-config';
+import {AutoPromptHistory, HistoryEvent} from './auto-prompt-history';
 import {AutoPromptManager} from './auto-prompt-manager';
-import {AutoPromptType} from '../api/basic-subscriptions';
+import {AutoPromptRuleType, AutoPromptType} from '../api/basic-subscriptions';
 import {ClientConfig} from '../model/client-config';
 import {ClientConfigManager} from './client-config-manager';
 import {ClientEventManager} from './client-event-manager';
@@ -30,8 +31,11 @@ import {PageConfig} from '../model/page-config';
 import {Storage} from './storage';
 import {tick} from '../../test/tick';
 
+const STORAGE_KEY_HISTORY = 'autoprompthistory';
 const STORAGE_KEY_IMPRESSIONS = 'autopromptimp';
 const STORAGE_KEY_DISMISSALS = 'autopromptdismiss';
+const STORAGE_KEY_ARTICLES = 'autopromptarticles';
+const STORAGE_KEY_SESSION_PAGEVIEWS = 'autopromptsessionpv';
 const CURRENT_TIME = 1615416442; // GMT: Wednesday, March 10, 2021 10:47:22 PM
 
 describes.realWin('AutoPromptManager', {}, (env) => {
@@ -90,6 +94,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     sandbox.stub(MiniPromptApi.prototype, 'init');
     autoPromptManager = new AutoPromptManager(deps);
     autoPromptManager.autoPromptDisplayed_ = true;
+    autoPromptManager.displayedPromptScopes_ = ['t:contribution'];
 
     miniPromptApiMock = sandbox.mock(autoPromptManager.miniPromptAPI_);
     alternatePromptSpy = sandbox.spy();
@@ -102,21 +107,83 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     miniPromptApiMock.verify();
   });
 
+  /**
+   * Expects the history to be loaded, without events in the legacy format.
+   * @param {?string} value
+   */
+  function expectHistory(value) {
+    storageMock
+      .expects('get')
+      .withExactArgs(STORAGE_KEY_HISTORY, /* useLocalStorage */ true)
+      .returns(Promise.resolve(value))
+      .once();
+    expectLegacyEvents(null, null);
+  }
+
+  /**
+   * Expects events in the legacy format to be loaded and migrated to the
+   * history.
+   * @param {?string} storedImpressions
+   * @param {?string} storedDismissals
+   */
+  function expectLegacyEvents(storedImpressions, storedDismissals) {
+    storageMock
+      .expects('get')
+      .withExactArgs(STORAGE_KEY_HISTORY, /* useLocalStorage */ true)
+      .returns(Promise.resolve(null))
+      .once();
+    expectLegacyEvents(storedImpressions, storedDismissals);
+    if (storedImpressions === null && storedDismissals === null) {
+      return;
+    }
+    storageMock
+      .expects('set')
+      .withExactArgs(STORAGE_KEY_HISTORY, sandbox.match.string, true)
+      .once();
+    storageMock
+      .expects('remove')
+      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
+      .once();
+    storageMock
+      .expects('remove')
+      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
+      .once();
+  }
+
+  /**
+   * @param {!Object<string, !Array<number>>} impressions By scope.
+   * @param {!Object<string, !Array<number>>=} dismissals By scope.
+   * @return {string}
+   */
+  function serializeHistory(impressions, dismissals = {}) {
+    const history = new AutoPromptHistory();
+    for (const scope in impressions) {
+      impressions[scope].forEach((timestamp) =>
+        history.record([scope], HistoryEvent.IMPRESSION, timestamp)
+      );
+    }
+    for (const scope in dismissals) {
+      dismissals[scope].forEach((timestamp) =>
+        history.record([scope], HistoryEvent.DISMISSAL, timestamp)
+      );
+    }
+    return history.serialize(CURRENT_TIME);
+  }
+
   it('should be listening for events from the events manager', () => {
     expect(eventManagerCallback).to.not.be.null;
   });
 
   it('should locally store contribution impressions when contribution impression events are fired', async () => {
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectHistory(null);
     storageMock
       .expects('set')
       .withExactArgs(
-        STORAGE_KEY_IMPRESSIONS,
-        CURRENT_TIME.toString(),
+        STORAGE_KEY_HISTORY,
+        JSON.stringify({
+          'v': 1,
+          's': {'t:contribution': {'i': {'t': [CURRENT_TIME], 'n': 1}}},
+        }),
         /* useLocalStorage */ true
       )
       .returns(Promise.resolve())
@@ -131,16 +198,18 @@ describes.realWin('AutoPromptManager', {}, (env) => {
   });
 
   it('should locally store subscription impressions when subscription impression events are fired', async () => {
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectHistory(null);
     storageMock
       .expects('set')
       .withExactArgs(
-        STORAGE_KEY_IMPRESSIONS,
-        CURRENT_TIME.toString(),
+        STORAGE_KEY_HISTORY,
+        JSON.stringify({
+          'v': 1,
+          's': {
+            't:contribution': {'i': {'t': [CURRENT_TIME], 'n': 1}},
+            't:subscription': {'i': {'t': [CURRENT_TIME], 'n': 1}},
+          },
+        }),
         /* useLocalStorage */ true
       )
       .returns(Promise.resolve())
@@ -155,16 +224,15 @@ describes.realWin('AutoPromptManager', {}, (env) => {
   });
 
   it('should locally store contribution dismissals when contribution dismissal events are fired', async () => {
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectHistory(null);
     storageMock
       .expects('set')
       .withExactArgs(
-        STORAGE_KEY_DISMISSALS,
-        CURRENT_TIME.toString(),
+        STORAGE_KEY_HISTORY,
+        JSON.stringify({
+          'v': 1,
+          's': {'t:contribution': {'d': {'t': [CURRENT_TIME], 'n': 1}}},
+        }),
         /* useLocalStorage */ true
       )
       .returns(Promise.resolve())
@@ -179,16 +247,18 @@ describes.realWin('AutoPromptManager', {}, (env) => {
   });
 
   it('should locally store subscription dismissals when subscription dismissal events are fired', async () => {
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectHistory(null);
     storageMock
       .expects('set')
       .withExactArgs(
-        STORAGE_KEY_DISMISSALS,
-        CURRENT_TIME.toString(),
+        STORAGE_KEY_HISTORY,
+        JSON.stringify({
+          'v': 1,
+          's': {
+            't:contribution': {'d': {'t': [CURRENT_TIME], 'n': 1}},
+            't:subscription': {'d': {'t': [CURRENT_TIME], 'n': 1}},
+          },
+        }),
         /* useLocalStorage */ true
       )
       .returns(Promise.resolve())
@@ -202,6 +272,17 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     });
   });
 
+  it('should not store the offers closed by the publisher as dismissals', async () => {
+    storageMock.expects('set').never();
+
+    await eventManagerCallback({
+      eventType: AnalyticsEvent.ACTION_SUBSCRIPTION_OFFERS_CLOSED,
+      eventOriginator: EventOriginator.SWG_CLIENT,
+      isFromUserAction: false,
+      additionalParameters: null,
+    });
+  });
+
   it('should not store events when an impression or dismissal was fired for a paygated article', async () => {
     sandbox.stub(pageConfig, 'isLocked').returns(true);
     storageMock.expects('get').never();
@@ -222,6 +303,39 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     });
   });
 
+  it('should only migrate the legacy events once', async () => {
+    const storage = deps.storage();
+    await storage.set(
+      STORAGE_KEY_IMPRESSIONS,
+      String(CURRENT_TIME - 1000),
+      /* useLocalStorage */ true
+    );
+    const event = {
+      eventType: AnalyticsEvent.IMPRESSION_SWG_CONTRIBUTION_MINI_PROMPT,
+      eventOriginator: EventOriginator.UNKNOWN_CLIENT,
+      isFromUserAction: null,
+      additionalParameters: null,
+    };
+
+    await Promise.all([
+      eventManagerCallback(event),
+      eventManagerCallback(event),
+    ]);
+
+    const history = AutoPromptHistory.parse(
+      await storage.get(STORAGE_KEY_HISTORY, /* useLocalStorage */ true)
+    );
+    expect(
+      history.count(
+        't:contribution',
+        HistoryEvent.IMPRESSION,
+        /* windowMillis */ undefined,
+        CURRENT_TIME
+      )
+    ).to.equal(3);
+    await storage.remove(STORAGE_KEY_HISTORY, /* useLocalStorage */ true);
+  });
+
   it('should ignore irrelevant events', async () => {
     storageMock.expects('get').never();
     storageMock.expects('set').never();
@@ -382,16 +496,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     // Two stored impressions.
     const storedImpressions =
       (CURRENT_TIME + 1).toString() + ',' + CURRENT_TIME.toString();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedImpressions))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectLegacyEvents(storedImpressions, null);
     miniPromptApiMock.expects('create').never();
 
     await autoPromptManager.showAutoPrompt({
@@ -416,16 +521,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
       .once();
     // One stored impression.
     const storedImpressions = CURRENT_TIME.toString();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedImpressions))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectLegacyEvents(storedImpressions, null);
     miniPromptApiMock.expects('create').once();
 
     await autoPromptManager.showAutoPrompt({
@@ -448,16 +544,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
       .expects('getClientConfig')
       .returns(Promise.resolve(clientConfig))
       .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectLegacyEvents(null, null);
     miniPromptApiMock.expects('create').never();
 
     await autoPromptManager.showAutoPrompt({
@@ -486,16 +573,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     const twoWeeksInMs = 1209600000;
     const storedImpressions =
       (CURRENT_TIME - twoWeeksInMs).toString() + ',' + CURRENT_TIME.toString();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedImpressions))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(null))
-      .once();
+    expectLegacyEvents(storedImpressions, null);
     miniPromptApiMock.expects('create').once();
 
     await autoPromptManager.showAutoPrompt({
@@ -527,16 +605,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     // One stored impression from 10ms ago and one dismissal from 5ms ago.
     const storedImpressions = (CURRENT_TIME - 10).toString();
     const storedDismissals = (CURRENT_TIME - 5).toString();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedImpressions))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedDismissals))
-      .once();
+    expectLegacyEvents(storedImpressions, storedDismissals);
     miniPromptApiMock.expects('create').never();
 
     await autoPromptManager.showAutoPrompt({
@@ -568,16 +637,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     // One stored impression from 20s ago and one dismissal from 11s ago.
     const storedImpressions = (CURRENT_TIME - 20000).toString();
     const storedDismissals = (CURRENT_TIME - 11000).toString();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedImpressions))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedDismissals))
-      .once();
+    expectLegacyEvents(storedImpressions, storedDismissals);
     miniPromptApiMock.expects('create').once();
 
     await autoPromptManager.showAutoPrompt({
@@ -609,16 +669,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     // One stored impression from 20s ago and one dismissal from 6s ago.
     const storedImpressions = (CURRENT_TIME - 20000).toString();
     const storedDismissals = (CURRENT_TIME - 6000).toString();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedImpressions))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedDismissals))
-      .once();
+    expectLegacyEvents(storedImpressions, storedDismissals);
     miniPromptApiMock.expects('create').never();
 
     await autoPromptManager.showAutoPrompt({
@@ -650,16 +701,7 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     // One stored impression from 20s ago and one dismissal from 6s ago.
     const storedImpressions = (CURRENT_TIME - 20000).toString();
     const storedDismissals = (CURRENT_TIME - 6000).toString();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedImpressions))
-      .once();
-    storageMock
-      .expects('get')
-      .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
-      .returns(Promise.resolve(storedDismissals))
-      .once();
+    expectLegacyEvents(storedImpressions, storedDismissals);
     miniPromptApiMock.expects('create').once();
 
     await autoPromptManager.showAutoPrompt({
@@ -799,4 +841,487 @@ describes.realWin('AutoPromptManager', {}, (env) => {
     });
     expect(alternatePromptSpy).to.not.be.called;
   });
+
+  describe('rules', () => {
+    let logEventSpy;
+
+    beforeEach(() => {
+      logEventSpy = sandbox.spy(eventManager, 'logEvent');
+      entitlementsManagerMock
+        .expects('getEntitlements')
+        .returns(Promise.resolve(new Entitlements()))
+        .once();
+    });
+
+    function expectClientConfig(rules) {
+      const autoPromptConfig = new AutoPromptConfig(
+        /* maxImpressionsPerWeek */ undefined,
+        /* displayDelaySeconds */ 0,
+        /* backoffSeconds */ undefined,
+        /* maxDismissalsPerWeek */ undefined,
+        /* maxDismissalsResultingHideSeconds */ undefined,
+        rules
+      );
+      clientConfigManagerMock
+        .expects('getClientConfig')
+        .returns(Promise.resolve(new ClientConfig({autoPromptConfig})))
+        .once();
+    }
+
+    async function showAutoPrompt(autoPromptRules) {
+      await autoPromptManager.showAutoPrompt({
+        autoPromptType: AutoPromptType.CONTRIBUTION,
+        alwaysShow: false,
+        displayLargePromptFn: alternatePromptSpy,
+        autoPromptRules,
+      });
+      await tick(10);
+    }
+
+    it('should not display the mini prompt if a rule of the config blocks it', async () => {
+      expectClientConfig([
+        {id: 'tablets', type: AutoPromptRuleType.DEVICE_CLASS, values: []},
+      ]);
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt();
+
+      expect(logEventSpy).to.be.calledWithExactly({
+        eventType: AnalyticsEvent.EVENT_CUSTOM,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        isFromUserAction: false,
+        additionalParameters: {
+          'autoPromptBlocked': {'rule': 'tablets', 'type': 'deviceClass'},
+        },
+      });
+    });
+
+    it('should display the mini prompt if the rules of the config are satisfied', async () => {
+      expectClientConfig([
+        {type: AutoPromptRuleType.TIME_ON_PAGE, min: 0},
+        {type: AutoPromptRuleType.DAY_PART, min: 0, max: 23},
+      ]);
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt();
+
+      expect(logEventSpy).to.not.be.called;
+    });
+
+    it('should replace the rules of the config with the publisher rules', async () => {
+      expectClientConfig([{type: AutoPromptRuleType.DEVICE_CLASS, values: []}]);
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt([{type: AutoPromptRuleType.TIME_ON_PAGE, min: 0}]);
+    });
+
+    it('should ignore invalid rules', async () => {
+      expectClientConfig([{type: 'unknown'}]);
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt();
+    });
+
+    it('should count page views for page view rules', async () => {
+      expectClientConfig([
+        {type: AutoPromptRuleType.SESSION_PAGEVIEWS, min: 3},
+        {type: AutoPromptRuleType.ARTICLE_COUNT, max: 1},
+      ]);
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_SESSION_PAGEVIEWS, false)
+        .returns(Promise.resolve('2'))
+        .once();
+      storageMock
+        .expects('set')
+        .withExactArgs(STORAGE_KEY_SESSION_PAGEVIEWS, '3', false)
+        .once();
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_ARTICLES, true)
+        .returns(Promise.resolve(null))
+        .once();
+      storageMock
+        .expects('set')
+        .withExactArgs(STORAGE_KEY_ARTICLES, CURRENT_TIME.toString(), true)
+        .once();
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt();
+    });
+
+    it('should block propensity rules without a propensity module', async () => {
+      expectClientConfig([
+        {type: AutoPromptRuleType.PROPENSITY_BUCKET, min: 10},
+      ]);
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt();
+
+      expect(logEventSpy).to.be.calledOnce;
+    });
+
+    it('should check the propensity bucket', async () => {
+      const propensity = {
+        getPropensity: () =>
+          Promise.resolve({
+            header: {ok: true},
+            body: {scores: [{score: {value: 12, bucketed: true}}]},
+          }),
+      };
+      autoPromptManager = new AutoPromptManager(deps, () =>
+        Promise.resolve(propensity)
+      );
+      miniPromptApiMock = sandbox.mock(autoPromptManager.miniPromptAPI_);
+      expectClientConfig([
+        {type: AutoPromptRuleType.PROPENSITY_BUCKET, min: 10},
+      ]);
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt();
+    });
+  });
+
+  describe('display triggers', () => {
+    beforeEach(() => {
+      entitlementsManagerMock
+        .expects('getEntitlements')
+        .returns(Promise.resolve(new Entitlements()))
+        .once();
+      clientConfigManagerMock
+        .expects('getClientConfig')
+        .returns(
+          Promise.resolve(
+            new ClientConfig({autoPromptConfig: new AutoPromptConfig()})
+          )
+        )
+        .once();
+    });
+
+    async function showAutoPrompt(autoPromptTriggers) {
+      await autoPromptManager.showAutoPrompt({
+        autoPromptType: AutoPromptType.CONTRIBUTION,
+        alwaysShow: false,
+        displayLargePromptFn: alternatePromptSpy,
+        autoPromptTriggers,
+      });
+      await tick(10);
+    }
+
+    function exitPage() {
+      win.document.dispatchEvent(new MouseEvent('mouseout', {clientY: 0}));
+    }
+
+    it('should wait for the triggers before displaying the prompt', async () => {
+      const createStub = sandbox.stub(
+        autoPromptManager.miniPromptAPI_,
+        'create'
+      );
+
+      await showAutoPrompt({exitIntent: true});
+      expect(createStub).to.not.be.called;
+
+      exitPage();
+      expect(createStub).to.be.calledOnce;
+    });
+
+    it('should not display the prompt if its triggers are cancelled', async () => {
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt({exitIntent: true});
+      autoPromptManager.cancelDisplayTrigger();
+      exitPage();
+    });
+
+    it('should cancel the triggers and remove the mini prompt once destroyed', async () => {
+      miniPromptApiMock.expects('create').never();
+      miniPromptApiMock.expects('destroy').once();
+
+      await showAutoPrompt({exitIntent: true});
+      autoPromptManager.destroy();
+      exitPage();
+    });
+
+    it('should forget the displayed prompt once reset', async () => {
+      miniPromptApiMock.expects('create').never();
+      miniPromptApiMock.expects('destroy').once();
+      storageMock.expects('get').never();
+
+      await showAutoPrompt({exitIntent: true});
+      autoPromptManager.reset();
+      exitPage();
+      await eventManagerCallback({
+        eventType: AnalyticsEvent.IMPRESSION_SWG_CONTRIBUTION_MINI_PROMPT,
+        eventOriginator: EventOriginator.UNKNOWN_CLIENT,
+        isFromUserAction: null,
+        additionalParameters: null,
+      });
+    });
+
+    it('should not display the prompt before the nth article of the session', async () => {
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_SESSION_PAGEVIEWS, false)
+        .returns(Promise.resolve('1'))
+        .once();
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_ARTICLES, true)
+        .returns(Promise.resolve(null))
+        .once();
+      storageMock.expects('set').twice();
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt({nthArticle: 3});
+    });
+
+    it('should display the prompt from the nth article of the session', async () => {
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_SESSION_PAGEVIEWS, false)
+        .returns(Promise.resolve('2'))
+        .once();
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_ARTICLES, true)
+        .returns(Promise.resolve(null))
+        .once();
+      storageMock.expects('set').twice();
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt({nthArticle: 3});
+    });
+  });
+
+  describe('frequency caps', () => {
+    let logEventSpy;
+
+    beforeEach(() => {
+      logEventSpy = sandbox.spy(eventManager, 'logEvent');
+      entitlementsManagerMock
+        .expects('getEntitlements')
+        .returns(Promise.resolve(new Entitlements()))
+        .once();
+    });
+
+    function expectClientConfig(frequencyCaps) {
+      const autoPromptConfig = new AutoPromptConfig(
+        /* maxImpressionsPerWeek */ undefined,
+        /* displayDelaySeconds */ 0,
+        /* backoffSeconds */ undefined,
+        /* maxDismissalsPerWeek */ undefined,
+        /* maxDismissalsResultingHideSeconds */ undefined,
+        /* rules */ undefined,
+        /* triggers */ undefined,
+        frequencyCaps
+      );
+      clientConfigManagerMock
+        .expects('getClientConfig')
+        .returns(Promise.resolve(new ClientConfig({autoPromptConfig})))
+        .once();
+    }
+
+    async function showAutoPrompt(params) {
+      await autoPromptManager.showAutoPrompt(
+        Object.assign(
+          {
+            autoPromptType: AutoPromptType.CONTRIBUTION,
+            alwaysShow: false,
+            displayLargePromptFn: alternatePromptSpy,
+          },
+          params
+        )
+      );
+      await tick(10);
+    }
+
+    it('should not display the prompt once its type is over the cap', async () => {
+      expectClientConfig([
+        {id: 'daily', event: 'impression', max: 2, windowSeconds: 86400},
+      ]);
+      expectHistory(
+        serializeHistory({
+          't:contribution': [CURRENT_TIME - 1000, CURRENT_TIME],
+        })
+      );
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt();
+
+      expect(logEventSpy).to.be.calledWithExactly({
+        eventType: AnalyticsEvent.EVENT_CUSTOM,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        isFromUserAction: false,
+        additionalParameters: {
+          'autoPromptBlocked': {'rule': 'daily', 'type': 'frequencyCap'},
+        },
+      });
+    });
+
+    it('should not count the events of other prompt types', async () => {
+      expectClientConfig([{event: 'impression', max: 1}]);
+      expectHistory(serializeHistory({'t:subscription': [CURRENT_TIME]}));
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt();
+    });
+
+    it('should cap subscription prompts with explicit frequency caps', async () => {
+      expectClientConfig([{event: 'dismissal', max: 1}]);
+      expectHistory(serializeHistory({}, {'t:subscription': [CURRENT_TIME]}));
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt({autoPromptType: AutoPromptType.SUBSCRIPTION});
+    });
+
+    it('should count lifetime events without a window', async () => {
+      const monthAgo = CURRENT_TIME - 2592000000;
+      expectClientConfig([{event: 'impression', max: 2}]);
+      expectHistory(
+        serializeHistory({'t:contribution': [monthAgo, CURRENT_TIME]})
+      );
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt();
+    });
+
+    it('should cap the events of a campaign', async () => {
+      expectClientConfig([
+        {event: 'impression', max: 1, campaign: 'spring'},
+        {event: 'impression', max: 1, campaign: 'summer'},
+      ]);
+      expectHistory(serializeHistory({'c:summer': [CURRENT_TIME]}));
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt({campaign: 'spring'});
+
+      expect(logEventSpy).to.be.calledOnce;
+    });
+
+    it('should replace the caps of the config with the publisher caps', async () => {
+      expectClientConfig([{event: 'impression', max: 1}]);
+      expectHistory(serializeHistory({'t:contribution': [CURRENT_TIME]}));
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt({
+        autoPromptFrequencyCaps: [{event: 'impression', max: 2}],
+      });
+    });
+
+    it('should not load the history without caps', async () => {
+      expectClientConfig([{event: 'unknown', max: 1}]);
+      storageMock.expects('get').never();
+      miniPromptApiMock.expects('create').once();
+
+      await showAutoPrompt();
+    });
+
+    it('should record events in the scopes of the displayed prompt', async () => {
+      expectClientConfig();
+      miniPromptApiMock.expects('create').once();
+      await showAutoPrompt({campaign: 'spring'});
+      expectHistory(null);
+      storageMock
+        .expects('set')
+        .withExactArgs(
+          STORAGE_KEY_HISTORY,
+          serializeHistory({
+            't:contribution': [CURRENT_TIME],
+            'c:spring': [CURRENT_TIME],
+          }),
+          /* useLocalStorage */ true
+        )
+        .once();
+
+      await eventManagerCallback({
+        eventType: AnalyticsEvent.IMPRESSION_SWG_CONTRIBUTION_MINI_PROMPT,
+        eventOriginator: EventOriginator.UNKNOWN_CLIENT,
+        isFromUserAction: null,
+        additionalParameters: null,
+      });
+    });
+
+    it('should not overwrite a history of an unknown version', async () => {
+      expectClientConfig();
+      miniPromptApiMock.expects('create').once();
+      await showAutoPrompt();
+      expectHistory('{"v":2,"s":{}}');
+      storageMock.expects('set').never();
+
+      await eventManagerCallback({
+        eventType: AnalyticsEvent.IMPRESSION_SWG_CONTRIBUTION_MINI_PROMPT,
+        eventOriginator: EventOriginator.UNKNOWN_CLIENT,
+        isFromUserAction: null,
+        additionalParameters: null,
+      });
+    });
+
+    it('should record manually triggered prompts in the scope of their type', async () => {
+      expectClientConfig();
+      miniPromptApiMock.expects('create').once();
+      await showAutoPrompt();
+      expectHistory(null);
+      storageMock
+        .expects('set')
+        .withExactArgs(
+          STORAGE_KEY_HISTORY,
+          serializeHistory({
+            't:contribution': [CURRENT_TIME],
+            't:subscription_large': [CURRENT_TIME],
+          }),
+          /* useLocalStorage */ true
+        )
+        .once();
+
+      await eventManagerCallback({
+        eventType: AnalyticsEvent.IMPRESSION_OFFERS,
+        eventOriginator: EventOriginator.UNKNOWN_CLIENT,
+        isFromUserAction: null,
+        additionalParameters: null,
+      });
+    });
+
+    it('should migrate events stored in the legacy format', async () => {
+      expectClientConfig([{event: 'impression', max: 2}]);
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_HISTORY, /* useLocalStorage */ true)
+        .returns(
+          Promise.resolve(serializeHistory({'t:contribution': [CURRENT_TIME]}))
+        )
+        .once();
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
+        .returns(Promise.resolve((CURRENT_TIME - 1000).toString()))
+        .once();
+      storageMock
+        .expects('get')
+        .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
+        .returns(Promise.resolve(null))
+        .once();
+      const migrated = new AutoPromptHistory();
+      migrated.record(['t:contribution'], HistoryEvent.IMPRESSION, CURRENT_TIME);
+      migrated.migrate((CURRENT_TIME - 1000).toString(), null);
+      storageMock
+        .expects('set')
+        .withExactArgs(
+          STORAGE_KEY_HISTORY,
+          migrated.serialize(CURRENT_TIME),
+          /* useLocalStorage */ true
+        )
+        .once();
+      storageMock
+        .expects('remove')
+        .withExactArgs(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true)
+        .once();
+      storageMock
+        .expects('remove')
+        .withExactArgs(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true)
+        .once();
+      miniPromptApiMock.expects('create').never();
+
+      await showAutoPrompt();
+    });
+  });
 });
@@ -14,17 +14,81 @@
  * limitations under the License.
  */
 
-import {AnalyticsEvent} from '../proto/api_messages';
-import {AutoPromptType} from '../api/basic-subscriptions';
+import {AnalyticsEvent, EventOriginator} from '../proto/api_messages';
+import {
+  AutoPromptHistory,
+  HistoryEvent,
+  getCampaignScope,
+  getTypeScope,
+} from './auto-prompt-history';
+import {AutoPromptRuleType, AutoPromptType} from '../api/basic-subscriptions';
+import {DisplayTrigger} from './display-trigger';
 import {MiniPromptApi} from './mini-prompt-api';
-import {assert} from '../utils/log';
+import {assert, debugLog} from '../utils/log';
+import {
+  findBlockingRule,
+  frequencyCapsToRules,
+  getDeviceClass,
+  getFrequencyCapRules,
+  getPropensityBucket,
+  getValidRules,
+} from './auto-prompt-rules';
+import {parseUrl} from '../utils/url';
 
+/**
+ * Types of the prompts whose impressions are recorded, by event.
+ * @const {!Object<!AnalyticsEvent, !AutoPromptType>}
+ */
+const IMPRESSION_PROMPT_TYPES = {
+  [AnalyticsEvent.IMPRESSION_SWG_CONTRIBUTION_MINI_PROMPT]:
+    AutoPromptType.CONTRIBUTION,
+  [AnalyticsEvent.IMPRESSION_SWG_SUBSCRIPTION_MINI_PROMPT]:
+    AutoPromptType.SUBSCRIPTION,
+  [AnalyticsEvent.IMPRESSION_CONTRIBUTION_OFFERS]:
+    AutoPromptType.CONTRIBUTION_LARGE,
+  [AnalyticsEvent.IMPRESSION_OFFERS]: AutoPromptType.SUBSCRIPTION_LARGE,
+};
+
+/**
+ * Types of the prompts whose dismissals are recorded, by event.
+ * @const {!Object<!AnalyticsEvent, !AutoPromptType>}
+ */
+const DISMISSAL_PROMPT_TYPES = {
+  [AnalyticsEvent.ACTION_SWG_CONTRIBUTION_MINI_PROMPT_CLOSE]:
+    AutoPromptType.CONTRIBUTION,
+  [AnalyticsEvent.ACTION_SWG_SUBSCRIPTION_MINI_PROMPT_CLOSE]:
+    AutoPromptType.SUBSCRIPTION,
+  [AnalyticsEvent.ACTION_CONTRIBUTION_OFFERS_CLOSED]:
+    AutoPromptType.CONTRIBUTION_LARGE,
+  [AnalyticsEvent.ACTION_SUBSCRIPTION_OFFERS_CLOSED]:
+    AutoPromptType.SUBSCRIPTION_LARGE,
+};
+
+const STORAGE_KEY_HISTORY = 'autoprompthistory';
+// Legacy storage of the impressions and dismissals, migrated to the history.
 const STORAGE_KEY_IMPRESSIONS = 'autopromptimp';
 const STORAGE_KEY_DISMISSALS = 'autopromptdismiss';
+const STORAGE_KEY_ARTICLES = 'autopromptarticles';
+const STORAGE_KEY_SESSION_PAGEVIEWS = 'autopromptsessionpv';
 const STORAGE_DELIMITER = ',';
 const WEEK_IN_MILLIS = 604800000;
 const SECOND_IN_MILLIS = 1000;
 
+/**
+ * Options of an auto prompt. See `setupAndShowAutoPrompt` in
+ * `../api/basic-subscriptions.js`.
+ * @typedef {{
+ *   autoPromptType: (AutoPromptType|undefined),
+ *   alwaysShow: (boolean|undefined),
+ *   displayLargePromptFn: (function()|undefined),
+ *   autoPromptRules: (!Array<!../api/basic-subscriptions.AutoPromptRule>|undefined),
+ *   autoPromptTriggers: (!../api/basic-subscriptions.AutoPromptTriggers|undefined),
+ *   autoPromptFrequencyCaps: (!Array<!../api/basic-subscriptions.AutoPromptFrequencyCap>|undefined),
+ *   campaign: (string|undefined),
+ * }}
+ */
+export let AutoPromptParams;
+
 /**
  * Manages the display of subscription/contribution prompts automatically
  * displayed to the user.
@@ -32,13 +96,21 @@ const SECOND_IN_MILLIS = 1000;
 export class AutoPromptManager {
   /**
    * @param {!./deps.DepsDef} deps
+   * @param {function():!Promise<?../api/propensity-api.PropensityApi>=} getPropensityModule
+   *     Provides the propensity score to the PROPENSITY_BUCKET rules.
    */
-  constructor(deps) {
+  constructor(deps, getPropensityModule) {
     /** @private @const {!./deps.DepsDef} */
     this.deps_ = deps;
 
-    /** @private @const {!../model/page-config.PageConfig} */
-    this.pageConfig_ = deps.pageConfig();
+    /** @private @const {?function():!Promise<?../api/propensity-api.PropensityApi>} */
+    this.getPropensityModule_ = getPropensityModule || null;
+
+    /** @private @const {number} */
+    this.startTime_ = Date.now();
+
+    /** @private {?Promise<!Array<number>>} */
+    this.pageviewsPromise_ = null;
 
     /** @private @const {!./entitlements-manager.EntitlementsManager} */
     this.entitlementsManager_ = deps.entitlementsManager();
@@ -63,6 +135,22 @@ export class AutoPromptManager {
 
     /** @private {boolean} */
     this.autoPromptDisplayed_ = false;
+
+    /**
+     * History scopes of the displayed auto prompt, whose impressions and
+     * dismissals count toward their frequency caps.
+     * @private {!Array<string>}
+     */
+    this.displayedPromptScopes_ = [];
+
+    /**
+     * The last load of the history.
+     * @private {?Promise<!AutoPromptHistory>}
+     */
+    this.historyPromise_ = null;
+
+    /** @private {?DisplayTrigger} */
+    this.displayTrigger_ = null;
   }
 
   /**
@@ -82,13 +170,11 @@ export class AutoPromptManager {
    *   - alwaysShow == true, used for demo purposes, OR
    *   - There is no active entitlement found AND
    *   - The user had not reached the maximum impressions allowed, as specified
-   *     by the publisher
+   *     by the publisher AND
+   *   - The targeting rules of the publication, or the autoPromptRules
+   *     overriding them, are satisfied
    * A prompt may not be displayed if the appropriate criteria are not met.
-   * @param {{
-   *   autoPromptType: (AutoPromptType|undefined),
-   *   alwaysShow: (boolean|undefined),
-   *   displayLargePromptFn: (function()|undefined),
-   * }} params
+   * @param {!AutoPromptParams} params
    * @return {!Promise}
    */
   showAutoPrompt(params) {
@@ -114,18 +200,16 @@ export class AutoPromptManager {
    * configuration, entitlement state, and options specified in params.
    * @param {!../model/client-config.ClientConfig|undefined} clientConfig
    * @param {!../api/entitlements.Entitlements} entitlements
-   * @param {{
-   *   autoPromptType: (AutoPromptType|undefined),
-   *   alwaysShow: (boolean|undefined),
-   *   displayLargePromptFn: (function()|undefined),
-   * }} params
+   * @param {!AutoPromptParams} params
    * @return {!Promise}
    */
   showAutoPrompt_(clientConfig, entitlements, params) {
     return this.shouldShowAutoPrompt_(
       clientConfig,
       entitlements,
-      params.autoPromptType
+      params.autoPromptType,
+      params.autoPromptRules,
+      params.autoPromptFrequencyCaps
     ).then((shouldShowAutoPrompt) => {
       if (!shouldShowAutoPrompt) {
         if (
@@ -136,10 +220,72 @@ export class AutoPromptManager {
         }
         return;
       }
-      this.deps_.win().setTimeout(() => {
-        this.autoPromptDisplayed_ = true;
-        this.showPrompt_(params.autoPromptType, params.displayLargePromptFn);
-      }, (clientConfig?.autoPromptConfig.clientDisplayTrigger.displayDelaySeconds || 0) * SECOND_IN_MILLIS);
+      // The publisher's triggers override the ones of the publication.
+      const triggers = Object.assign(
+        {},
+        clientConfig?.autoPromptConfig.clientDisplayTrigger,
+        params.autoPromptTriggers
+      );
+      if (triggers.nthArticle === undefined) {
+        this.startDisplayTrigger_(triggers, params);
+        return;
+      }
+      return this.countPageview_().then((counts) => {
+        if (counts[0] >= triggers.nthArticle) {
+          this.startDisplayTrigger_(triggers, params);
+        }
+      });
+    });
+  }
+
+  /**
+   * Cancels the display of an auto prompt still waiting for its triggers, for
+   * example when the reader navigates to another page of a single page
+   * application.
+   */
+  cancelDisplayTrigger() {
+    if (this.displayTrigger_) {
+      this.displayTrigger_.cancel();
+      this.displayTrigger_ = null;
+    }
+  }
+
+  /**
+   * Forgets the displayed auto prompt before the next article of a single
+   * page application: cancels the pending prompt, removes the mini prompt, and
+   * counts the page view of the next article.
+   */
+  reset() {
+    this.cancelDisplayTrigger();
+    this.miniPromptAPI_.destroy();
+    this.autoPromptDisplayed_ = false;
+    this.displayedPromptScopes_ = [];
+    this.pageviewsPromise_ = null;
+  }
+
+  /**
+   * Cancels the pending auto prompt and removes the mini prompt, if displayed.
+   */
+  destroy() {
+    this.cancelDisplayTrigger();
+    this.miniPromptAPI_.destroy();
+  }
+
+  /**
+   * Displays the prompt once its triggers fire. Replaces any prompt still
+   * waiting for its triggers.
+   * @param {!../api/basic-subscriptions.AutoPromptTriggers} triggers
+   * @param {!AutoPromptParams} params
+   * @private
+   */
+  startDisplayTrigger_(triggers, params) {
+    this.cancelDisplayTrigger();
+    this.displayTrigger_ = new DisplayTrigger(this.deps_.win(), triggers);
+    this.displayTrigger_.start(() => {
+      this.displayTrigger_ = null;
+      this.autoPromptDisplayed_ = true;
+      this.displayedPromptScopes_ = getScopes(params);
+      this.showPrompt_(params.autoPromptType, params.displayLargePromptFn);
     });
   }
 
@@ -149,9 +295,17 @@ export class AutoPromptManager {
    * @param {!../model/client-config.ClientConfig|undefined} clientConfig
    * @param {!../api/entitlements.Entitlements} entitlements
    * @param {!AutoPromptType|undefined} autoPromptType
+   * @param {!Array<!../api/basic-subscriptions.AutoPromptRule>=} autoPromptRules
+   * @param {!Array<!../api/basic-subscriptions.AutoPromptFrequencyCap>=} frequencyCaps
    * @returns {!Promise<boolean>}
    */
-  shouldShowAutoPrompt_(clientConfig, entitlements, autoPromptType) {
+  shouldShowAutoPrompt_(
+    clientConfig,
+    entitlements,
+    autoPromptType,
+    autoPromptRules,
+    frequencyCaps
+  ) {
     // If false publication predicate was returned in the response, don't show
     // the prompt.
     if (
@@ -175,16 +329,59 @@ export class AutoPromptManager {
     }
 
     // The auto prompt is only for non-paygated content.
-    if (this.pageConfig_.isLocked()) {
+    if (this.deps_.pageConfig().isLocked()) {
       return Promise.resolve(false);
     }
 
-    // Don't cap subscription prompts.
+    // The publisher's rules replace the ones of the publication.
+    const rules = getValidRules(
+      autoPromptRules ||
+        (clientConfig && clientConfig.autoPromptConfig
+          ? clientConfig.autoPromptConfig.rules
+          : undefined)
+    );
+    if (rules.length == 0) {
+      return this.checkFrequencyCaps_(
+        clientConfig,
+        autoPromptType,
+        frequencyCaps
+      );
+    }
+    return this.getSignals_(rules).then((signals) => {
+      const blockingRule = findBlockingRule(rules, signals);
+      if (blockingRule) {
+        this.logBlockingRule_(blockingRule);
+        return false;
+      }
+      return this.checkFrequencyCaps_(
+        clientConfig,
+        autoPromptType,
+        frequencyCaps
+      );
+    });
+  }
+
+  /**
+   * Determines whether the frequency caps of the auto prompt allow it to be
+   * shown.
+   * @param {!../model/client-config.ClientConfig|undefined} clientConfig
+   * @param {!AutoPromptType} autoPromptType
+   * @param {!Array<!../api/basic-subscriptions.AutoPromptFrequencyCap>=} frequencyCaps
+   *     Replaces the frequency caps of the publication.
+   * @return {!Promise<boolean>}
+   */
+  checkFrequencyCaps_(clientConfig, autoPromptType, frequencyCaps) {
+    const rules = frequencyCapsToRules(
+      frequencyCaps || clientConfig?.autoPromptConfig?.frequencyCaps,
+      autoPromptType
+    );
+
+    // Only cap subscription prompts with explicit frequency caps.
     if (
       autoPromptType === AutoPromptType.SUBSCRIPTION ||
       autoPromptType === AutoPromptType.SUBSCRIPTION_LARGE
     ) {
-      return Promise.resolve(true);
+      return this.findBlockingCap_(rules);
     }
 
     // If no auto prompt config was returned in the response, don't show
@@ -199,57 +396,135 @@ export class AutoPromptManager {
       autoPromptConfig = clientConfig.autoPromptConfig;
     }
 
-    // Fetched config returned no maximum cap.
-    if (autoPromptConfig.maxImpressionsPerWeek === undefined) {
+    // The weekly caps only apply if the fetched config has a maximum number of
+    // impressions.
+    if (autoPromptConfig.maxImpressionsPerWeek !== undefined) {
+      rules.unshift(...getFrequencyCapRules(autoPromptConfig, autoPromptType));
+    }
+    return this.findBlockingCap_(rules);
+  }
+
+  /**
+   * Checks the frequency cap rules against the stored history of impressions
+   * and dismissals. Resolves with false if a rule blocks the prompt.
+   * @param {!Array<!./auto-prompt-rules.Rule>} rules
+   * @return {!Promise<boolean>}
+   */
+  findBlockingCap_(rules) {
+    if (rules.length == 0) {
       return Promise.resolve(true);
     }
+    return this.loadHistory_().then((history) => {
+      const blockingRule = findBlockingRule(rules, {now: Date.now(), history});
+      if (blockingRule) {
+        this.logBlockingRule_(blockingRule);
+        return false;
+      }
+      return true;
+    });
+  }
 
-    // See if we should display the auto prompt based on the config and logged
-    // events.
-    return Promise.all([this.getImpressions_(), this.getDismissals_()]).then(
-      (values) => {
-        const impressions = values[0];
-        const dismissals = values[1];
+  /**
+   * Loads the signals needed by the targeting rules.
+   * @param {!Array<!../api/basic-subscriptions.AutoPromptRule>} rules
+   * @return {!Promise<!./auto-prompt-rules.AutoPromptSignals>}
+   */
+  getSignals_(rules) {
+    const types = rules.map((rule) => rule.type);
+    const win = this.deps_.win();
+    const signals = {now: Date.now()};
+    const promises = [];
+    if (
+      types.includes(AutoPromptRuleType.SESSION_PAGEVIEWS) ||
+      types.includes(AutoPromptRuleType.ARTICLE_COUNT)
+    ) {
+      promises.push(
+        this.countPageview_().then((counts) => {
+          signals[AutoPromptRuleType.SESSION_PAGEVIEWS] = counts[0];
+          signals[AutoPromptRuleType.ARTICLE_COUNT] = counts[1];
+        })
+      );
+    }
+    if (types.includes(AutoPromptRuleType.PROPENSITY_BUCKET)) {
+      promises.push(
+        this.getPropensityBucket_().then((bucket) => {
+          signals[AutoPromptRuleType.PROPENSITY_BUCKET] = bucket;
+        })
+      );
+    }
+    signals[AutoPromptRuleType.TIME_ON_PAGE] =
+      (signals.now - this.startTime_) / SECOND_IN_MILLIS;
+    const referrer = win.document.referrer;
+    signals[AutoPromptRuleType.REFERRER] = referrer
+      ? parseUrl(referrer).hostname
+      : '';
+    signals[AutoPromptRuleType.DEVICE_CLASS] = getDeviceClass(win);
+    signals[AutoPromptRuleType.DAY_PART] = new Date(signals.now).getHours();
+    return Promise.all(promises).then(() => signals);
+  }
 
-        // If the user has reached the maxDismissalsPerWeek, and
-        // maxDismissalsResultingHideSeconds has not yet passed, don't show the
-        // prompt.
-        if (
-          autoPromptConfig.explicitDismissalConfig.maxDismissalsPerWeek !==
-            undefined &&
-          dismissals.length >=
-            autoPromptConfig.explicitDismissalConfig.maxDismissalsPerWeek &&
-          Date.now() - dismissals[dismissals.length - 1] <
-            (autoPromptConfig.explicitDismissalConfig
-              .maxDismissalsResultingHideSeconds || 0) *
-              SECOND_IN_MILLIS
-        ) {
-          return false;
-        }
+  /**
+   * Counts this page view once, and returns the number of page views in the
+   * current session and in the past week.
+   * @return {!Promise<!Array<number>>}
+   */
+  countPageview_() {
+    if (!this.pageviewsPromise_) {
+      this.pageviewsPromise_ = Promise.all([
+        this.storage_.get(
+          STORAGE_KEY_SESSION_PAGEVIEWS,
+          /* useLocalStorage */ false
+        ),
+        this.getEvent_(STORAGE_KEY_ARTICLES),
+      ]).then((values) => {
+        const sessionPageviews = (parseInt(values[0], 10) || 0) + 1;
+        const articles = values[1];
+        articles.push(Date.now());
+        this.storage_.set(
+          STORAGE_KEY_SESSION_PAGEVIEWS,
+          String(sessionPageviews),
+          /* useLocalStorage */ false
+        );
+        this.storage_.set(
+          STORAGE_KEY_ARTICLES,
+          this.arrayToStoredValue_(articles),
+          /* useLocalStorage */ true
+        );
+        return [sessionPageviews, articles.length];
+      });
+    }
+    return this.pageviewsPromise_;
+  }
 
-        // If the user has previously dismissed the prompt, and backoffSeconds has
-        // not yet passed, don't show the prompt.
-        if (
-          autoPromptConfig.explicitDismissalConfig.backoffSeconds !==
-            undefined &&
-          dismissals.length > 0 &&
-          Date.now() - dismissals[dismissals.length - 1] <
-            autoPromptConfig.explicitDismissalConfig.backoffSeconds *
-              SECOND_IN_MILLIS
-        ) {
-          return false;
-        }
+  /**
+   * Returns the propensity bucket of the user, or null if it isn't available.
+   * @return {!Promise<?number>}
+   */
+  getPropensityBucket_() {
+    if (!this.getPropensityModule_) {
+      return Promise.resolve(null);
+    }
+    return this.getPropensityModule_()
+      .then((propensity) => propensity && propensity.getPropensity())
+      .then(getPropensityBucket)
+      .catch(() => null);
+  }
 
-        // If the user has reached maxImpressionsPerWeek, don't show the prompt.
-        if (
-          autoPromptConfig.maxImpressionsPerWeek !== undefined &&
-          impressions.length >= autoPromptConfig.maxImpressionsPerWeek
-        ) {
-          return false;
-        }
-        return true;
-      }
-    );
+  /**
+   * Reports the rule that blocked the auto prompt.
+   * @param {!./auto-prompt-rules.Rule} rule
+   */
+  logBlockingRule_(rule) {
+    const id = rule.id || rule.type;
+    debugLog(`[swg.js:autoPrompt] Blocked by rule "${id}"`);
+    this.deps_.eventManager().logEvent({
+      eventType: AnalyticsEvent.EVENT_CUSTOM,
+      eventOriginator: EventOriginator.SWG_CLIENT,
+      isFromUserAction: false,
+      additionalParameters: {
+        'autoPromptBlocked': {'rule': id, 'type': rule.type},
+      },
+    });
   }
 
   /**
@@ -282,7 +557,7 @@ export class AutoPromptManager {
    * @returns {boolean}
    */
   shouldShowLockedContentPrompt_(entitlements) {
-    return this.pageConfig_.isLocked() && !entitlements.enablesThis();
+    return this.deps_.pageConfig().isLocked() && !entitlements.enablesThis();
   }
 
   /**
@@ -294,70 +569,105 @@ export class AutoPromptManager {
    */
   handleClientEvent_(event) {
     // Impressions and dimissals of forced (for paygated) or manually triggered
-    // prompts do not count toward the frequency caps.
-    if (!this.autoPromptDisplayed_ || this.pageConfig_.isLocked()) {
+    // prompts do not count toward the frequency caps, until an auto prompt has
+    // been displayed.
+    if (!this.autoPromptDisplayed_ || this.deps_.pageConfig().isLocked()) {
       return Promise.resolve();
     }
 
-    if (
-      event.eventType ===
-        AnalyticsEvent.IMPRESSION_SWG_CONTRIBUTION_MINI_PROMPT ||
-      event.eventType ===
-        AnalyticsEvent.IMPRESSION_SWG_SUBSCRIPTION_MINI_PROMPT ||
-      event.eventType === AnalyticsEvent.IMPRESSION_OFFERS ||
-      event.eventType === AnalyticsEvent.IMPRESSION_CONTRIBUTION_OFFERS
-    ) {
-      return this.storeEvent_(STORAGE_KEY_IMPRESSIONS);
+    if (event.eventType in IMPRESSION_PROMPT_TYPES) {
+      return this.recordEvent_(
+        HistoryEvent.IMPRESSION,
+        IMPRESSION_PROMPT_TYPES[event.eventType]
+      );
     }
 
+    // Prompts closed by the publisher aren't dismissed.
     if (
-      event.eventType ===
-        AnalyticsEvent.ACTION_SWG_CONTRIBUTION_MINI_PROMPT_CLOSE ||
-      event.eventType ===
-        AnalyticsEvent.ACTION_SWG_SUBSCRIPTION_MINI_PROMPT_CLOSE ||
-      event.eventType === AnalyticsEvent.ACTION_CONTRIBUTION_OFFERS_CLOSED ||
-      event.eventType === AnalyticsEvent.ACTION_SUBSCRIPTION_OFFERS_CLOSED
+      event.eventType in DISMISSAL_PROMPT_TYPES &&
+      event.isFromUserAction !== false
     ) {
-      return this.storeEvent_(STORAGE_KEY_DISMISSALS);
+      return this.recordEvent_(
+        HistoryEvent.DISMISSAL,
+        DISMISSAL_PROMPT_TYPES[event.eventType]
+      );
     }
 
     return Promise.resolve();
   }
 
   /**
-   * Stores the current time to local storage, under the storageKey provided.
-   * Removes times older than a week in the process.
-   * @param {string} storageKey
+   * Records an event in the history, in the scopes of the displayed auto
+   * prompt and in the scope of the type of the prompt the event belongs to,
+   * which differs for the prompts triggered manually afterwards.
+   * @param {!HistoryEvent} event
+   * @param {!AutoPromptType} autoPromptType
+   * @return {!Promise}
    */
-  storeEvent_(storageKey) {
-    return this.storage_
-      .get(storageKey, /* useLocalStorage */ true)
-      .then((value) => {
-        const dateValues = this.filterOldValues_(
-          this.storedValueToDateArray_(value)
-        );
-        dateValues.push(Date.now());
-        const valueToStore = this.arrayToStoredValue_(dateValues);
-        this.storage_.set(storageKey, valueToStore, /* useLocalStorage */ true);
-      });
+  recordEvent_(event, autoPromptType) {
+    const scopes = this.displayedPromptScopes_.slice();
+    const typeScope = getTypeScope(autoPromptType);
+    if (!scopes.includes(typeScope)) {
+      scopes.push(typeScope);
+    }
+    return this.loadHistory_().then((history) => {
+      history.record(scopes, event, Date.now());
+      this.saveHistory_(history);
+    });
   }
 
   /**
-   * Retrieves the locally stored impressions of the auto prompt, within a week
-   * of the current time.
-   * @return {!Promise<!Array<number>>}
+   * Loads the history of impressions and dismissals, migrating the events
+   * stored in the legacy format.
+   * @return {!Promise<!AutoPromptHistory>}
    */
-  getImpressions_() {
-    return this.getEvent_(STORAGE_KEY_IMPRESSIONS);
+  loadHistory_() {
+    // Loads wait for the previous ones, so that the events in the legacy
+    // format are only migrated once.
+    const load = () =>
+      Promise.all([
+        this.storage_.get(STORAGE_KEY_HISTORY, /* useLocalStorage */ true),
+        this.storage_.get(STORAGE_KEY_IMPRESSIONS, /* useLocalStorage */ true),
+        this.storage_.get(STORAGE_KEY_DISMISSALS, /* useLocalStorage */ true),
+      ]).then((values) => {
+        const history = AutoPromptHistory.parse(values[0]);
+        if (
+          !history.isReadOnly() &&
+          (values[1] !== null || values[2] !== null)
+        ) {
+          history.migrate(values[1], values[2]);
+          this.saveHistory_(history);
+          this.storage_.remove(
+            STORAGE_KEY_IMPRESSIONS,
+            /* useLocalStorage */ true
+          );
+          this.storage_.remove(
+            STORAGE_KEY_DISMISSALS,
+            /* useLocalStorage */ true
+          );
+        }
+        return history;
+      });
+    this.historyPromise_ = this.historyPromise_
+      ? this.historyPromise_.then(load, load)
+      : load();
+    return this.historyPromise_;
   }
 
   /**
-   * Retrieves the locally stored dismissals of the auto prompt, within a week
-   * of the current time.
-   * @return {!Promise<!Array<number>>}
+   * Saves the history, unless it has an unknown version. The events recorded
+   * since then only count for the current page.
+   * @param {!AutoPromptHistory} history
    */
-  getDismissals_() {
-    return this.getEvent_(STORAGE_KEY_DISMISSALS);
+  saveHistory_(history) {
+    if (history.isReadOnly()) {
+      return;
+    }
+    this.storage_.set(
+      STORAGE_KEY_HISTORY,
+      history.serialize(Date.now()),
+      /* useLocalStorage */ true
+    );
   }
 
   /**
@@ -418,3 +728,20 @@ export class AutoPromptManager {
     return dateArray.slice(sliceIndex);
   }
 }
+
+/**
+ * Returns the history scopes of an auto prompt.
+ * @param {!AutoPromptParams} params
+ * @return {!Array<string>}
+ */
+function getScopes(params) {
+  const scopes = [];
+  const typeScope = getTypeScope(params.autoPromptType);
+  if (typeScope) {
+    scopes.push(typeScope);
+  }
+  if (params.campaign) {
+    scopes.push(getCampaignScope(params.campaign));
+  }
+  return scopes;
+}
@@ -0,0 +1,428 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AutoPromptConfig} from '../model/auto-prompt-config';
+import {
+  AutoPromptHistory,
+  HistoryEvent,
+  MAX_TIMESTAMPS,
+  RETENTION_MILLIS,
+} from './auto-prompt-history';
+import {AutoPromptRuleType, AutoPromptType} from '../api/basic-subscriptions';
+import {
+  FrequencyCapRuleType,
+  findBlockingRule,
+  frequencyCapsToRules,
+  getDeviceClass,
+  getFrequencyCapRules,
+  getPropensityBucket,
+  getValidRules,
+} from './auto-prompt-rules';
+
+const NOW = 1615416442000;
+
+describes.realWin('auto prompt rules', {}, () => {
+  describe('findBlockingRule', () => {
+    function isBlocked(rule, signals) {
+      return !!findBlockingRule([rule], Object.assign({now: NOW}, signals));
+    }
+
+    it('should return the first blocking rule', () => {
+      const rules = [
+        {type: AutoPromptRuleType.SESSION_PAGEVIEWS, min: 1},
+        {id: 'returning', type: AutoPromptRuleType.ARTICLE_COUNT, min: 3},
+        {type: AutoPromptRuleType.TIME_ON_PAGE, min: 30},
+      ];
+
+      const rule = findBlockingRule(rules, {
+        now: NOW,
+        sessionPageviews: 2,
+        articleCount: 2,
+        timeOnPage: 10,
+      });
+
+      expect(rule).to.equal(rules[1]);
+    });
+
+    it('should return null if no rule blocks', () => {
+      expect(findBlockingRule([], {now: NOW})).to.be.null;
+      expect(
+        findBlockingRule([{type: AutoPromptRuleType.SESSION_PAGEVIEWS}], {
+          now: NOW,
+          sessionPageviews: 1,
+        })
+      ).to.be.null;
+    });
+
+    it('should check numeric ranges inclusively', () => {
+      const rule = {type: AutoPromptRuleType.ARTICLE_COUNT, min: 2, max: 4};
+
+      expect(isBlocked(rule, {articleCount: 1})).to.be.true;
+      expect(isBlocked(rule, {articleCount: 2})).to.be.false;
+      expect(isBlocked(rule, {articleCount: 4})).to.be.false;
+      expect(isBlocked(rule, {articleCount: 5})).to.be.true;
+    });
+
+    it('should block on unavailable signals', () => {
+      const rule = {type: AutoPromptRuleType.PROPENSITY_BUCKET, min: 1};
+
+      expect(isBlocked(rule, {propensityBucket: null})).to.be.true;
+      expect(isBlocked(rule, {})).to.be.true;
+    });
+
+    it('should match referrer hosts and their subdomains', () => {
+      const rule = {
+        type: AutoPromptRuleType.REFERRER,
+        values: ['google.com'],
+      };
+
+      expect(isBlocked(rule, {referrer: 'google.com'})).to.be.false;
+      expect(isBlocked(rule, {referrer: 'news.google.com'})).to.be.false;
+      expect(isBlocked(rule, {referrer: 'notgoogle.com'})).to.be.true;
+      expect(isBlocked(rule, {referrer: ''})).to.be.true;
+    });
+
+    it('should match direct visits with an empty referrer', () => {
+      const rule = {type: AutoPromptRuleType.REFERRER, values: ['']};
+
+      expect(isBlocked(rule, {referrer: ''})).to.be.false;
+      expect(isBlocked(rule, {referrer: 'example.com'})).to.be.true;
+    });
+
+    it('should match device classes', () => {
+      const rule = {
+        type: AutoPromptRuleType.DEVICE_CLASS,
+        values: ['mobile', 'tablet'],
+      };
+
+      expect(isBlocked(rule, {deviceClass: 'mobile'})).to.be.false;
+      expect(isBlocked(rule, {deviceClass: 'desktop'})).to.be.true;
+    });
+
+    it('should check day parts', () => {
+      const rule = {type: AutoPromptRuleType.DAY_PART, min: 8, max: 18};
+
+      expect(isBlocked(rule, {dayPart: 8})).to.be.false;
+      expect(isBlocked(rule, {dayPart: 20})).to.be.true;
+    });
+
+    it('should wrap day parts around midnight', () => {
+      const rule = {type: AutoPromptRuleType.DAY_PART, min: 22, max: 6};
+
+      expect(isBlocked(rule, {dayPart: 23})).to.be.false;
+      expect(isBlocked(rule, {dayPart: 3})).to.be.false;
+      expect(isBlocked(rule, {dayPart: 12})).to.be.true;
+    });
+  });
+
+  /**
+   * @param {!HistoryEvent} event
+   * @param {!Array<number>} timestamps
+   * @param {string=} scope
+   * @return {!AutoPromptHistory}
+   */
+  function historyOf(event, timestamps, scope = 't:contribution') {
+    const history = new AutoPromptHistory();
+    timestamps.forEach((timestamp) =>
+      history.record([scope], event, timestamp)
+    );
+    return history;
+  }
+
+  describe('getFrequencyCapRules', () => {
+    function getRules(autoPromptConfig) {
+      return getFrequencyCapRules(
+        autoPromptConfig,
+        AutoPromptType.CONTRIBUTION
+      );
+    }
+
+    function dismissedAt(timestamps) {
+      return {now: NOW, history: historyOf(HistoryEvent.DISMISSAL, timestamps)};
+    }
+
+    it('should convert the frequency caps of the config', () => {
+      const rules = getRules(new AutoPromptConfig(2, 0, 10, 3, 60));
+
+      expect(rules).to.deep.equal([
+        {
+          type: FrequencyCapRuleType.MAX_DISMISSALS,
+          max: 3,
+          seconds: 60,
+          scope: 't:contribution',
+        },
+        {
+          type: FrequencyCapRuleType.DISMISSAL_BACKOFF,
+          seconds: 10,
+          scope: 't:contribution',
+        },
+        {
+          type: FrequencyCapRuleType.MAX_IMPRESSIONS,
+          max: 2,
+          scope: 't:contribution',
+        },
+      ]);
+    });
+
+    it('should skip caps that are not configured', () => {
+      expect(getRules(new AutoPromptConfig())).to.be.empty;
+    });
+
+    it('should block dismissals until the hide duration passed', () => {
+      const rules = getRules(
+        new AutoPromptConfig(undefined, 0, undefined, 2, 10)
+      );
+
+      expect(
+        findBlockingRule(rules, dismissedAt([NOW - 20000, NOW - 5000]))
+      ).to.equal(rules[0]);
+      expect(findBlockingRule(rules, dismissedAt([NOW - 20000, NOW - 15000])))
+        .to.be.null;
+      expect(findBlockingRule(rules, dismissedAt([NOW]))).to.be.null;
+    });
+
+    it('should back off after dismissals', () => {
+      const rules = getRules(new AutoPromptConfig(undefined, 0, 10));
+
+      expect(findBlockingRule(rules, dismissedAt([NOW - 5000]))).to.equal(
+        rules[0]
+      );
+      expect(findBlockingRule(rules, dismissedAt([NOW - 10000]))).to.be.null;
+      expect(findBlockingRule(rules, dismissedAt([]))).to.be.null;
+    });
+
+    it('should cap impressions', () => {
+      const rules = getRules(new AutoPromptConfig(2));
+
+      expect(
+        findBlockingRule(rules, {
+          now: NOW,
+          history: historyOf(HistoryEvent.IMPRESSION, [NOW, NOW]),
+        })
+      ).to.equal(rules[0]);
+      expect(
+        findBlockingRule(rules, {
+          now: NOW,
+          history: historyOf(HistoryEvent.IMPRESSION, [NOW]),
+        })
+      ).to.be.null;
+    });
+
+    it('should only count the events of the prompt type', () => {
+      const rules = getRules(new AutoPromptConfig(1));
+
+      expect(
+        findBlockingRule(rules, {
+          now: NOW,
+          history: historyOf(HistoryEvent.IMPRESSION, [NOW], 't:subscription'),
+        })
+      ).to.be.null;
+    });
+  });
+
+  describe('frequencyCapsToRules', () => {
+    it('should convert frequency caps', () => {
+      const rules = frequencyCapsToRules(
+        [
+          {id: 'daily', event: 'impression', max: 3, windowSeconds: 86400},
+          {
+            event: 'dismissal',
+            max: 1,
+            autoPromptType: AutoPromptType.SUBSCRIPTION,
+          },
+          {event: 'impression', max: 5, campaign: 'spring'},
+        ],
+        AutoPromptType.CONTRIBUTION
+      );
+
+      expect(rules).to.deep.equal([
+        {
+          id: 'daily',
+          type: FrequencyCapRuleType.MAX_EVENTS,
+          max: 3,
+          seconds: 86400,
+          event: HistoryEvent.IMPRESSION,
+          scope: 't:contribution',
+        },
+        {
+          id: undefined,
+          type: FrequencyCapRuleType.MAX_EVENTS,
+          max: 1,
+          seconds: undefined,
+          event: HistoryEvent.DISMISSAL,
+          scope: 't:subscription',
+        },
+        {
+          id: undefined,
+          type: FrequencyCapRuleType.MAX_EVENTS,
+          max: 5,
+          seconds: undefined,
+          event: HistoryEvent.IMPRESSION,
+          scope: 'c:spring',
+        },
+      ]);
+    });
+
+    it('should drop invalid caps', () => {
+      expect(
+        frequencyCapsToRules(
+          [null, {event: 'click', max: 1}, {event: 'impression'}],
+          AutoPromptType.CONTRIBUTION
+        )
+      ).to.be.empty;
+      expect(frequencyCapsToRules(undefined, AutoPromptType.CONTRIBUTION)).to
+        .be.empty;
+    });
+
+    it('should drop caps without a prompt type', () => {
+      expect(
+        frequencyCapsToRules(
+          [
+            {event: 'impression', max: 1},
+            {event: 'impression', max: 1, autoPromptType: 'unknown'},
+          ],
+          undefined
+        )
+      ).to.be.empty;
+    });
+
+    it('should drop caps the history can not count', () => {
+      const month = RETENTION_MILLIS / 1000;
+
+      expect(
+        frequencyCapsToRules(
+          [
+            {event: 'impression', max: 1, windowSeconds: month + 1},
+            {event: 'impression', max: MAX_TIMESTAMPS + 1, windowSeconds: 60},
+          ],
+          AutoPromptType.CONTRIBUTION
+        )
+      ).to.be.empty;
+      expect(
+        frequencyCapsToRules(
+          [
+            {event: 'impression', max: MAX_TIMESTAMPS, windowSeconds: month},
+            {event: 'impression', max: MAX_TIMESTAMPS + 1},
+          ],
+          AutoPromptType.CONTRIBUTION
+        )
+      ).to.have.length(2);
+    });
+
+    it('should count events within the window', () => {
+      const rules = frequencyCapsToRules(
+        [{event: 'impression', max: 2, windowSeconds: 60}],
+        AutoPromptType.CONTRIBUTION
+      );
+      const history = historyOf(HistoryEvent.IMPRESSION, [
+        NOW - 90000,
+        NOW - 30000,
+      ]);
+
+      expect(findBlockingRule(rules, {now: NOW, history})).to.be.null;
+      history.record(['t:contribution'], HistoryEvent.IMPRESSION, NOW);
+      expect(findBlockingRule(rules, {now: NOW, history})).to.equal(rules[0]);
+    });
+
+    it('should count lifetime events without a window', () => {
+      const rules = frequencyCapsToRules(
+        [{event: 'dismissal', max: 2}],
+        AutoPromptType.CONTRIBUTION
+      );
+      const stored = historyOf(HistoryEvent.DISMISSAL, [
+        NOW - 2 * RETENTION_MILLIS,
+        NOW,
+      ]).serialize(NOW);
+
+      // The old dismissal is still counted once its timestamp is dropped.
+      const history = AutoPromptHistory.parse(stored);
+      expect(findBlockingRule(rules, {now: NOW, history})).to.equal(rules[0]);
+    });
+  });
+
+  describe('getValidRules', () => {
+    it('should drop invalid rules', () => {
+      const rule = {type: AutoPromptRuleType.DEVICE_CLASS, values: ['mobile']};
+
+      expect(
+        getValidRules([rule, {type: 'unknown'}, null, 'dayPart'])
+      ).to.deep.equal([rule]);
+    });
+
+    it('should ignore values that are not arrays', () => {
+      expect(getValidRules(undefined)).to.be.empty;
+      expect(getValidRules({type: AutoPromptRuleType.DAY_PART})).to.be.empty;
+    });
+  });
+
+  describe('getPropensityBucket', () => {
+    function score(value, bucketed) {
+      return {
+        header: {ok: true},
+        body: {scores: [{product: 'pub1', score: {value, bucketed}}]},
+      };
+    }
+
+    it('should return bucketed scores', () => {
+      expect(getPropensityBucket(score(7, true))).to.equal(7);
+    });
+
+    it('should bucket raw scores', () => {
+      expect(getPropensityBucket(score(1, false))).to.equal(1);
+      expect(getPropensityBucket(score(42, false))).to.equal(9);
+      expect(getPropensityBucket(score(100, false))).to.equal(20);
+    });
+
+    it('should put raw scores of 0 in the first bucket', () => {
+      expect(getPropensityBucket(score(0, false))).to.equal(1);
+    });
+
+    it('should return null without a score', () => {
+      expect(getPropensityBucket(null)).to.be.null;
+      expect(
+        getPropensityBucket({header: {ok: false}, body: {error: 'Error'}})
+      ).to.be.null;
+    });
+  });
+
+  describe('getDeviceClass', () => {
+    function withUserAgent(userAgent) {
+      return {navigator: {userAgent}};
+    }
+
+    it('should classify devices', () => {
+      expect(
+        getDeviceClass(withUserAgent('Mozilla/5.0 (iPhone) Mobile/15E148'))
+      ).to.equal('mobile');
+      expect(
+        getDeviceClass(
+          withUserAgent('Mozilla/5.0 (Linux; Android 12) Mobile Safari')
+        )
+      ).to.equal('mobile');
+      expect(
+        getDeviceClass(
+          withUserAgent('Mozilla/5.0 (Linux; Android 12; SM-X700) Safari')
+        )
+      ).to.equal('tablet');
+      expect(
+        getDeviceClass(withUserAgent('Mozilla/5.0 (iPad; CPU OS 15_0)'))
+      ).to.equal('tablet');
+      expect(
+        getDeviceClass(withUserAgent('Mozilla/5.0 (X11; Linux x86_64)'))
+      ).to.equal('desktop');
+    });
+  });
+});
@@ -0,0 +1,317 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AutoPromptRuleType} from '../api/basic-subscriptions';
+import {
+  HistoryEvent,
+  MAX_TIMESTAMPS,
+  RETENTION_MILLIS,
+  getCampaignScope,
+  getTypeScope,
+} from './auto-prompt-history';
+import {isObject} from '../utils/types';
+import {warn} from '../utils/log';
+
+const SECOND_IN_MILLIS = 1000;
+const WEEK_IN_MILLIS = 604800000;
+
+/**
+ * Rules derived from frequency caps. They count the events of their `scope`
+ * in the auto prompt history.
+ * - MAX_DISMISSALS: Blocks once `max` dismissals happened in the past week,
+ *   until `seconds` have passed since the last one.
+ * - DISMISSAL_BACKOFF: Blocks until `seconds` have passed since the last
+ *   dismissal.
+ * - MAX_IMPRESSIONS: Blocks once `max` impressions happened in the past week.
+ * - MAX_EVENTS: Blocks once `max` events of the `event` type happened in the
+ *   past `seconds`, or ever if `seconds` is undefined.
+ * @enum {string}
+ */
+export const FrequencyCapRuleType = {
+  MAX_DISMISSALS: 'maxDismissalsPerWeek',
+  DISMISSAL_BACKOFF: 'dismissalBackoff',
+  MAX_IMPRESSIONS: 'maxImpressionsPerWeek',
+  MAX_EVENTS: 'frequencyCap',
+};
+
+/**
+ * A targeting rule, or a frequency cap rule whose `seconds` property holds
+ * its duration.
+ * @typedef {{
+ *   id: (string|undefined),
+ *   type: (!AutoPromptRuleType|!FrequencyCapRuleType),
+ *   min: (number|undefined),
+ *   max: (number|undefined),
+ *   values: (!Array<string>|undefined),
+ *   seconds: (number|undefined),
+ *   scope: (string|undefined),
+ *   event: (!HistoryEvent|undefined),
+ * }}
+ */
+export let Rule;
+
+/**
+ * Values the rules are evaluated against. Signals read from storage or the
+ * network are only set when a rule needs them. A null signal could not be
+ * determined, and blocks the rules that need it.
+ * @typedef {{
+ *   now: number,
+ *   history: (!./auto-prompt-history.AutoPromptHistory|undefined),
+ *   sessionPageviews: (?number|undefined),
+ *   articleCount: (?number|undefined),
+ *   timeOnPage: (?number|undefined),
+ *   referrer: (?string|undefined),
+ *   propensityBucket: (?number|undefined),
+ *   deviceClass: (?string|undefined),
+ *   dayPart: (?number|undefined),
+ * }}
+ */
+export let AutoPromptSignals;
+
+/**
+ * Returns the frequency cap rules of an AutoPromptConfig, in the order they
+ * are checked. The caps of the config apply to the events of the displayed
+ * prompt type.
+ * @param {!../model/auto-prompt-config.AutoPromptConfig} autoPromptConfig
+ * @param {!../api/basic-subscriptions.AutoPromptType} autoPromptType
+ * @return {!Array<!Rule>}
+ */
+export function getFrequencyCapRules(autoPromptConfig, autoPromptType) {
+  const {
+    backoffSeconds,
+    maxDismissalsPerWeek,
+    maxDismissalsResultingHideSeconds,
+  } = autoPromptConfig.explicitDismissalConfig;
+  const scope = getTypeScope(autoPromptType);
+  const rules = [];
+  if (maxDismissalsPerWeek !== undefined) {
+    rules.push({
+      type: FrequencyCapRuleType.MAX_DISMISSALS,
+      max: maxDismissalsPerWeek,
+      seconds: maxDismissalsResultingHideSeconds || 0,
+      scope,
+    });
+  }
+  if (backoffSeconds !== undefined) {
+    rules.push({
+      type: FrequencyCapRuleType.DISMISSAL_BACKOFF,
+      seconds: backoffSeconds,
+      scope,
+    });
+  }
+  if (autoPromptConfig.maxImpressionsPerWeek !== undefined) {
+    rules.push({
+      type: FrequencyCapRuleType.MAX_IMPRESSIONS,
+      max: autoPromptConfig.maxImpressionsPerWeek,
+      scope,
+    });
+  }
+  return rules;
+}
+
+/**
+ * Converts frequency caps to rules. Caps without a campaign or prompt type
+ * apply to the events of the displayed prompt type. Invalid caps, and caps
+ * the history can't count, are dropped with a warning.
+ * @param {*} caps
+ * @param {!../api/basic-subscriptions.AutoPromptType} autoPromptType
+ * @return {!Array<!Rule>}
+ */
+export function frequencyCapsToRules(caps, autoPromptType) {
+  if (!Array.isArray(caps)) {
+    return [];
+  }
+  const events = {
+    'impression': HistoryEvent.IMPRESSION,
+    'dismissal': HistoryEvent.DISMISSAL,
+  };
+  const rules = [];
+  caps.forEach((cap) => {
+    if (
+      !isObject(cap) ||
+      !events[cap['event']] ||
+      typeof cap['max'] != 'number'
+    ) {
+      warn('[swg.js:autoPrompt] Ignoring invalid frequency cap', cap);
+      return;
+    }
+    const scope = cap['campaign']
+      ? getCampaignScope(cap['campaign'])
+      : getTypeScope(cap['autoPromptType'] || autoPromptType);
+    if (!scope) {
+      warn('[swg.js:autoPrompt] Ignoring frequency cap without a type', cap);
+      return;
+    }
+    // Longer windows and higher maximums than the history keeps would never
+    // block the prompt.
+    if (
+      cap['windowSeconds'] !== undefined &&
+      (cap['windowSeconds'] * SECOND_IN_MILLIS > RETENTION_MILLIS ||
+        cap['max'] > MAX_TIMESTAMPS)
+    ) {
+      warn('[swg.js:autoPrompt] Ignoring frequency cap over the history', cap);
+      return;
+    }
+    rules.push({
+      id: cap['id'],
+      type: FrequencyCapRuleType.MAX_EVENTS,
+      max: cap['max'],
+      seconds: cap['windowSeconds'],
+      event: events[cap['event']],
+      scope,
+    });
+  });
+  return rules;
+}
+
+/**
+ * Drops the targeting rules that can't be evaluated, with a warning.
+ * @param {*} rules
+ * @return {!Array<!../api/basic-subscriptions.AutoPromptRule>}
+ */
+export function getValidRules(rules) {
+  if (!Array.isArray(rules)) {
+    return [];
+  }
+  const types = Object.values(AutoPromptRuleType);
+  return rules.filter((rule) => {
+    if (isObject(rule) && types.includes(rule['type'])) {
+      return true;
+    }
+    warn('[swg.js:autoPrompt] Ignoring invalid rule', rule);
+    return false;
+  });
+}
+
+/**
+ * Returns the first rule that blocks the auto prompt, or null if the prompt
+ * may be displayed.
+ * @param {!Array<!Rule>} rules
+ * @param {!AutoPromptSignals} signals
+ * @return {?Rule}
+ */
+export function findBlockingRule(rules, signals) {
+  for (let i = 0; i < rules.length; i++) {
+    if (!isSatisfied(rules[i], signals)) {
+      return rules[i];
+    }
+  }
+  return null;
+}
+
+/**
+ * @param {!Rule} rule
+ * @param {!AutoPromptSignals} signals
+ * @return {boolean}
+ */
+function isSatisfied(rule, signals) {
+  const {history, now} = signals;
+  const count = (event, windowMillis) =>
+    history ? history.count(rule.scope, event, windowMillis, now) : 0;
+  const lastDismissal = history
+    ? history.getLast(rule.scope, HistoryEvent.DISMISSAL)
+    : null;
+  switch (rule.type) {
+    case FrequencyCapRuleType.MAX_DISMISSALS:
+      return !(
+        count(HistoryEvent.DISMISSAL, WEEK_IN_MILLIS) >= rule.max &&
+        now - lastDismissal < rule.seconds * SECOND_IN_MILLIS
+      );
+    case FrequencyCapRuleType.DISMISSAL_BACKOFF:
+      return !(
+        lastDismissal !== null &&
+        now - lastDismissal < rule.seconds * SECOND_IN_MILLIS
+      );
+    case FrequencyCapRuleType.MAX_IMPRESSIONS:
+      return count(HistoryEvent.IMPRESSION, WEEK_IN_MILLIS) < rule.max;
+    case FrequencyCapRuleType.MAX_EVENTS:
+      return (
+        count(
+          rule.event,
+          rule.seconds === undefined
+            ? undefined
+            : rule.seconds * SECOND_IN_MILLIS
+        ) < rule.max
+      );
+  }
+
+  const value = signals[rule.type];
+  if (value == null) {
+    return false;
+  }
+  switch (rule.type) {
+    case AutoPromptRuleType.REFERRER:
+      return (rule.values || []).some(
+        (host) => value === host || (!!host && value.endsWith('.' + host))
+      );
+    case AutoPromptRuleType.DEVICE_CLASS:
+      return (rule.values || []).includes(value);
+    case AutoPromptRuleType.DAY_PART:
+      // Hour ranges such as 22 to 6 wrap around midnight.
+      if (rule.min > rule.max) {
+        return value >= rule.min || value <= rule.max;
+      }
+      return isInRange(value, rule);
+    default:
+      return isInRange(value, rule);
+  }
+}
+
+/**
+ * @param {number} value
+ * @param {!Rule} rule
+ * @return {boolean}
+ */
+function isInRange(value, rule) {
+  return (
+    (rule.min === undefined || value >= rule.min) &&
+    (rule.max === undefined || value <= rule.max)
+  );
+}
+
+/**
+ * Returns the propensity bucket of a score, between 1 and 20, or null if the
+ * score isn't available.
+ * @param {?../api/propensity-api.PropensityScore} score
+ * @return {?number}
+ */
+export function getPropensityBucket(score) {
+  const scores = score && score.header.ok && score.body.scores;
+  const detail = scores && scores[0] && scores[0].score;
+  if (!detail) {
+    return null;
+  }
+  // Raw scores range from 0 to 100. A score of 0 falls in the first bucket.
+  return detail.bucketed
+    ? detail.value
+    : Math.max(1, Math.ceil(detail.value / 5));
+}
+
+/**
+ * Classifies the device from its user agent.
+ * @param {!Window} win
+ * @return {string}
+ */
+export function getDeviceClass(win) {
+  const userAgent = win.navigator.userAgent;
+  if (/iPad|Tablet|Android(?!.*Mobile)/i.test(userAgent)) {
+    return 'tablet';
+  }
+  if (/Mobi|iPhone|iPod/i.test(userAgent)) {
+    return 'mobile';
+  }
+  return 'desktop';
+}
@@ -110,6 +110,19 @@ describes.realWin('installBasicRuntime', {}, (env) => {
     expect(getBasicRuntime()).to.equal(runtime1);
   });
 
+  it('should install a new runtime once destroyed', async () => {
+    installBasicRuntime(win);
+    const runtime1 = getBasicRuntime();
+
+    await runtime1.destroy();
+    expect(win.SWG_BASIC).to.be.undefined;
+    expect(() => getBasicRuntime()).to.throw(/not initialized yet/);
+
+    installBasicRuntime(win);
+    expect(getBasicRuntime()).to.not.equal(runtime1);
+    expect(win.SWG_BASIC.push).to.be.a('function');
+  });
+
   it('should implement BasicSubscriptions interface', async () => {
     const promise = new Promise((resolve) => {
       dep(resolve);
@@ -270,6 +283,20 @@ describes.realWin('BasicRuntime', {}, (env) => {
         theme: ClientTheme.DARK,
       });
     });
+
+    it('should track navigations if specified', () => {
+      const startStub = sandbox.stub(basicRuntime.navigationTracker_, 'start');
+
+      basicRuntime.init({
+        type: 'NewsArticle',
+        isAccessibleForFree: true,
+        isPartOfType: ['Product'],
+        isPartOfProductId: 'herald-foo-times.com:basic',
+        trackNavigation: true,
+      });
+
+      expect(startStub).to.be.calledOnce;
+    });
   });
 
   describe('configured', () => {
@@ -438,6 +465,31 @@ describes.realWin('BasicRuntime', {}, (env) => {
       await basicRuntime.dismissSwgUI();
     });
 
+    it('should resolve the page config again on navigation', async () => {
+      const newPageConfig = new PageConfig('pub1:label2');
+      sandbox
+        .stub(PageConfigResolver.prototype, 'resolveConfig')
+        .resolves(newPageConfig);
+      configuredBasicRuntimeMock
+        .expects('navigateTo')
+        .withExactArgs(newPageConfig)
+        .once();
+
+      await basicRuntime.navigate();
+    });
+
+    it('should destroy the configured runtime once', async () => {
+      const stopStub = sandbox.stub(basicRuntime.navigationTracker_, 'stop');
+      configuredBasicRuntimeMock
+        .expects('destroy')
+        .returns(Promise.resolve())
+        .once();
+
+      await basicRuntime.destroy();
+      await basicRuntime.destroy();
+      expect(stopStub).to.be.calledOnce;
+    });
+
     it('should call attach on all buttons with the correct attribute if buttons should be enable', async () => {
       // Set up buttons on the doc.
       const subscriptionButton = createElement(doc.getRootNode(), 'button', {
@@ -604,6 +656,74 @@ describes.realWin('BasicConfiguredRuntime', {}, (env) => {
       configuredBasicRuntime.pageConfig();
     });
 
+    it('should reset the auto prompt on navigation', async () => {
+      const newPageConfig = new PageConfig('pub1:label2');
+      const resetStub = sandbox.stub(
+        configuredBasicRuntime.autoPromptManager_,
+        'reset'
+      );
+      const showStub = sandbox.stub(
+        configuredBasicRuntime.autoPromptManager_,
+        'showAutoPrompt'
+      );
+      entitlementsManagerMock.expects('blockNextToast').once();
+      configuredClassicRuntimeMock
+        .expects('navigateTo')
+        .withExactArgs(newPageConfig)
+        .returns(Promise.resolve())
+        .once();
+
+      await configuredBasicRuntime.navigateTo(newPageConfig);
+      expect(resetStub).to.be.calledOnce;
+      expect(showStub).to.not.be.called;
+    });
+
+    it('should show the auto prompt again on navigation', async () => {
+      const newPageConfig = new PageConfig('pub1:label2');
+      const options = {
+        autoPromptType: AutoPromptType.CONTRIBUTION,
+        alwaysShow: false,
+      };
+      const resetStub = sandbox.stub(
+        configuredBasicRuntime.autoPromptManager_,
+        'reset'
+      );
+      const showStub = sandbox
+        .stub(configuredBasicRuntime.autoPromptManager_, 'showAutoPrompt')
+        .resolves();
+      entitlementsManagerMock.expects('blockNextToast').once();
+      configuredClassicRuntimeMock
+        .expects('navigateTo')
+        .withExactArgs(newPageConfig)
+        .returns(Promise.resolve())
+        .once();
+      await configuredBasicRuntime.setupAndShowAutoPrompt(options);
+
+      await configuredBasicRuntime.navigateTo(newPageConfig);
+      expect(showStub).to.be.calledTwice;
+      expect(showStub.secondCall.args[0]).to.equal(options);
+      expect(showStub).to.be.calledAfter(resetStub);
+    });
+
+    it('should tear down the auto prompt and the classic runtime', async () => {
+      const destroyStub = sandbox.stub(
+        configuredBasicRuntime.autoPromptManager_,
+        'destroy'
+      );
+      const buttonApiDestroyStub = sandbox.stub(
+        configuredBasicRuntime.buttonApi_,
+        'destroy'
+      );
+      configuredClassicRuntimeMock
+        .expects('destroy')
+        .returns(Promise.resolve())
+        .once();
+
+      await configuredBasicRuntime.destroy();
+      expect(destroyStub).to.be.calledOnce;
+      expect(buttonApiDestroyStub).to.be.calledOnce;
+    });
+
     it('should delegate activities to ConfiguredRuntime', () => {
       configuredClassicRuntimeMock.expects('activities').once();
       configuredBasicRuntime.activities();
@@ -634,6 +754,21 @@ describes.realWin('BasicConfiguredRuntime', {}, (env) => {
       configuredBasicRuntime.callbacks();
     });
 
+    it('should delegate flowController to ConfiguredRuntime', () => {
+      configuredClassicRuntimeMock.expects('flowController').once();
+      configuredBasicRuntime.flowController();
+    });
+
+    it('should delegate diagnostics to ConfiguredRuntime', () => {
+      configuredClassicRuntimeMock.expects('diagnostics').once();
+      configuredBasicRuntime.diagnostics();
+    });
+
+    it('should delegate consentManager to ConfiguredRuntime', () => {
+      configuredClassicRuntimeMock.expects('consentManager').once();
+      configuredBasicRuntime.consentManager();
+    });
+
     it('should delegate storage to ConfiguredRuntime', () => {
       configuredClassicRuntimeMock.expects('storage').once();
       configuredBasicRuntime.storage();
@@ -20,6 +20,7 @@ import {ButtonApi, ButtonAttributeValues} from './button-api';
 import {ConfiguredRuntime} from './runtime';
 import {Constants} from '../utils/constants';
 import {ExperimentFlags} from './experiment-flags';
+import {NavigationTracker} from './navigation-tracker';
 import {PageConfigResolver} from '../model/page-config-resolver';
 import {PageConfigWriter} from '../model/page-config-writer';
 import {Toast} from '../ui/toast';
@@ -35,9 +36,9 @@ const CHECK_ENTITLEMENTS_REQUEST_ID = 'CHECK_ENTITLEMENTS';
 
 /**
  * Reference to the runtime, for testing.
- * @private {!BasicRuntime}
+ * @private {?BasicRuntime}
  */
-let basicRuntimeInstance_;
+let basicRuntimeInstance_ = null;
 
 /**
  * Returns runtime for testing if available. Throws if the runtime is not
@@ -77,7 +78,10 @@ export function installBasicRuntime(win) {
     }
 
     basicRuntime.whenReady().then(() => {
-      callback(publicBasicRuntime);
+      // Drop the callbacks pushed to a destroyed runtime.
+      if (!basicRuntime.isDestroyed()) {
+        callback(publicBasicRuntime);
+      }
     });
   }
 
@@ -126,6 +130,9 @@ export class BasicRuntime {
     /** @private {boolean} */
     this.committed_ = false;
 
+    /** @private {boolean} */
+    this.destroyed_ = false;
+
     /** @private {?function((!ConfiguredBasicRuntime|!Promise))} */
     this.configuredResolver_ = null;
 
@@ -139,6 +146,11 @@ export class BasicRuntime {
 
     /** @private {?PageConfigResolver} */
     this.pageConfigResolver_ = null;
+
+    /** @private @const {!NavigationTracker} */
+    this.navigationTracker_ = new NavigationTracker(win, () =>
+      this.navigate()
+    );
   }
 
   /**
@@ -148,6 +160,14 @@ export class BasicRuntime {
     return this.ready_;
   }
 
+  /**
+   * @return {boolean}
+   * @package
+   */
+  isDestroyed() {
+    return this.destroyed_;
+  }
+
   /**
    * @param {boolean} commit
    * @return {!Promise<!ConfiguredBasicRuntime>}
@@ -161,6 +181,9 @@ export class BasicRuntime {
       this.pageConfigResolver_.resolveConfig().then(
         (pageConfig) => {
           this.pageConfigResolver_ = null;
+          if (this.destroyed_) {
+            return;
+          }
           this.configuredResolver_(
             new ConfiguredBasicRuntime(
               this.doc_,
@@ -173,6 +196,9 @@ export class BasicRuntime {
           this.configuredResolver_ = null;
         },
         (reason) => {
+          if (this.destroyed_) {
+            return;
+          }
           this.configuredResolver_(Promise.reject(reason));
           this.configuredResolver_ = null;
         }
@@ -204,9 +230,50 @@ export class BasicRuntime {
     this.setupAndShowAutoPrompt({
       autoPromptType: params.autoPromptType,
       alwaysShow: params.alwaysShow || false,
+      autoPromptRules: params.autoPromptRules,
+      autoPromptTriggers: params.autoPromptTriggers,
+      autoPromptFrequencyCaps: params.autoPromptFrequencyCaps,
+      campaign: params.campaign,
     });
     this.setOnLoginRequest();
     this.processEntitlements();
+    if (params.trackNavigation) {
+      this.navigationTracker_.start();
+    }
+  }
+
+  /** @override */
+  navigate() {
+    return this.configured_(false).then((runtime) =>
+      new PageConfigResolver(this.doc_)
+        .resolveConfig()
+        .then((pageConfig) => runtime.navigateTo(pageConfig))
+    );
+  }
+
+  /** @override */
+  destroy() {
+    if (this.destroyed_) {
+      return Promise.resolve();
+    }
+    this.destroyed_ = true;
+    this.navigationTracker_.stop();
+
+    // Uninstall the runtime, so that `installBasicRuntime` creates a new one.
+    if (basicRuntimeInstance_ === this) {
+      delete this.win_[BASIC_RUNTIME_PROP];
+      basicRuntimeInstance_ = null;
+    }
+
+    if (this.configuredResolver_) {
+      // Not configured yet: drop the configuration and reject other calls.
+      this.configuredResolver_(Promise.reject(new Error('Runtime destroyed')));
+      this.configuredResolver_ = null;
+    }
+    return this.configured_(false).then(
+      (runtime) => runtime.destroy(),
+      () => {}
+    );
   }
 
   /** @override */
@@ -316,7 +383,15 @@ export class ConfiguredBasicRuntime {
     );
 
     /** @private @const {!AutoPromptManager} */
-    this.autoPromptManager_ = new AutoPromptManager(this);
+    this.autoPromptManager_ = new AutoPromptManager(this, () =>
+      this.configuredClassicRuntime_.getPropensityModule()
+    );
+
+    /**
+     * Options of the last auto prompt, shown again on navigation.
+     * @private {?./auto-prompt-manager.AutoPromptParams}
+     */
+    this.autoPromptOptions_ = null;
 
     /** @private @const {!ButtonApi} */
     this.buttonApi_ = new ButtonApi(
@@ -376,6 +451,21 @@ export class ConfiguredBasicRuntime {
     return this.configuredClassicRuntime_.callbacks();
   }
 
+  /** @override */
+  flowController() {
+    return this.configuredClassicRuntime_.flowController();
+  }
+
+  /** @override */
+  diagnostics() {
+    return this.configuredClassicRuntime_.diagnostics();
+  }
+
+  /** @override */
+  consentManager() {
+    return this.configuredClassicRuntime_.consentManager();
+  }
+
   /** @override */
   storage() {
     return this.configuredClassicRuntime_.storage();
@@ -406,6 +496,35 @@ export class ConfiguredBasicRuntime {
     // Implemented by the 'BasicRuntime' class.
   }
 
+  /** @override */
+  navigate() {
+    // Implemented by the 'BasicRuntime' class.
+  }
+
+  /** @override */
+  destroy() {
+    this.autoPromptManager_.destroy();
+    this.buttonApi_.destroy();
+    return this.configuredClassicRuntime_.destroy();
+  }
+
+  /**
+   * Switches to the article of another page of a single page application.
+   * @param {!../model/page-config.PageConfig} pageConfig
+   * @return {!Promise}
+   */
+  navigateTo(pageConfig) {
+    this.autoPromptManager_.reset();
+    // Do not show toast in swgz.
+    this.entitlementsManager().blockNextToast();
+    return this.configuredClassicRuntime_.navigateTo(pageConfig).then(() => {
+      // Show the auto prompt of the new article, if any.
+      if (this.autoPromptOptions_) {
+        return this.setupAndShowAutoPrompt(this.autoPromptOptions_);
+      }
+    });
+  }
+
   /** @override */
   setOnEntitlementsResponse(callback) {
     this.configuredClassicRuntime_.setOnEntitlementsResponse(callback);
@@ -497,6 +616,7 @@ export class ConfiguredBasicRuntime {
 
   /** @override */
   setupAndShowAutoPrompt(options) {
+    this.autoPromptOptions_ = options;
     if (
       options.autoPromptType === AutoPromptType.SUBSCRIPTION ||
       options.autoPromptType == AutoPromptType.SUBSCRIPTION_LARGE
@@ -569,6 +689,8 @@ export class ConfiguredBasicRuntime {
 function createPublicBasicRuntime(basicRuntime) {
   return /** @type {!../api/basic-subscriptions.BasicSubscriptions} */ ({
     init: basicRuntime.init.bind(basicRuntime),
+    navigate: basicRuntime.navigate.bind(basicRuntime),
+    destroy: basicRuntime.destroy.bind(basicRuntime),
     setOnEntitlementsResponse:
       basicRuntime.setOnEntitlementsResponse.bind(basicRuntime),
     setOnPaymentResponse: basicRuntime.setOnPaymentResponse.bind(basicRuntime),
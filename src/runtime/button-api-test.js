@@ -89,6 +89,23 @@ describes.realWin('ButtonApi', {}, (env) => {
       );
       expect(links).to.have.length(1);
     });
+
+    it('should remove the injected stylesheet once destroyed', () => {
+      const otherButtonApi = new ButtonApi(
+        resolveDoc(doc),
+        Promise.resolve(runtime)
+      );
+      buttonApi.init();
+      otherButtonApi.init();
+
+      otherButtonApi.destroy();
+      expect(doc.querySelectorAll('link[href="$assets$/swg-button.css"]')).to
+        .have.length(1);
+
+      buttonApi.destroy();
+      expect(doc.querySelectorAll('link[href="$assets$/swg-button.css"]')).to
+        .be.empty;
+    });
   });
 
   describe('Create and Attach', () => {
@@ -17,7 +17,7 @@
 import {AnalyticsEvent} from '../proto/api_messages';
 import {SWG_I18N_STRINGS} from '../i18n/swg-strings';
 import {SmartSubscriptionButtonApi, Theme} from './smart-button-api';
-import {createElement} from '../utils/dom';
+import {createElement, removeElement} from '../utils/dom';
 import {msg} from '../utils/i18n';
 
 /**
@@ -56,6 +56,12 @@ export class ButtonApi {
 
     /** @private @const {!Promise<!./runtime.ConfiguredRuntime>} */
     this.configuredRuntimePromise_ = configuredRuntimePromise;
+
+    /**
+     * The stylesheet injected by `init`, if any.
+     * @private {?Element}
+     */
+    this.styleSheet_ = null;
   }
 
   /**
@@ -73,13 +79,22 @@ export class ButtonApi {
     }
 
     // <link rel="stylesheet" href="..." type="text/css">
-    head.appendChild(
-      createElement(this.doc_.getWin().document, 'link', {
-        'rel': 'stylesheet',
-        'type': 'text/css',
-        'href': url,
-      })
-    );
+    this.styleSheet_ = createElement(this.doc_.getWin().document, 'link', {
+      'rel': 'stylesheet',
+      'type': 'text/css',
+      'href': url,
+    });
+    head.appendChild(this.styleSheet_);
+  }
+
+  /**
+   * Removes the stylesheet injected by `init`, if any.
+   */
+  destroy() {
+    if (this.styleSheet_) {
+      removeElement(this.styleSheet_);
+      this.styleSheet_ = null;
+    }
   }
 
   /**
@@ -16,6 +16,15 @@
 
 import {ActivityIframeView} from '../ui/activity-iframe-view';
 import {Callbacks} from './callbacks';
+import {
+  FlowErrorCode,
+  FlowOutcomeType,
+  FlowState,
+  ProductType,
+  SubscriptionEvent,
+  SubscriptionFlows,
+} from '../api/subscriptions';
+import {SwgError, SwgErrorCode} from '../utils/errors';
 import {tick} from '../../test/tick';
 
 describes.sandboxed('Callbacks', {}, () => {
@@ -230,4 +239,264 @@ describes.sandboxed('Callbacks', {}, () => {
     await tick();
     expect(spy).to.be.calledOnce.calledWith({flow: 'flow1', data: {a: 1}});
   });
+
+  describe('event handlers', () => {
+    it('should call the flow state change handlers', async () => {
+      const handler = sandbox.spy();
+      const change = {
+        flow: SubscriptionFlows.SHOW_OFFERS,
+        state: FlowState.DIALOG_OPEN,
+        previousState: FlowState.IDLE,
+      };
+      callbacks.on(SubscriptionEvent.FLOW_STATE_CHANGE, handler);
+      callbacks.triggerFlowStateChange(change);
+
+      await tick();
+      expect(handler).to.be.calledOnceWithExactly(change);
+    });
+
+    it('should call the error handlers', async () => {
+      const handler = sandbox.spy();
+      const error = new SwgError(SwgErrorCode.POPUP_BLOCKED, 'blocked');
+      callbacks.on(SubscriptionEvent.ERROR, handler);
+      callbacks.triggerError(error);
+
+      await tick();
+      expect(handler).to.be.calledOnceWithExactly(error);
+    });
+
+    it('should call every handler', async () => {
+      const callback = sandbox.spy();
+      const handler1 = sandbox.spy();
+      const handler2 = sandbox.spy();
+      callbacks.setOnLoginRequest(callback);
+      callbacks.on(SubscriptionEvent.LOGIN_REQUEST, handler1);
+      callbacks.on(SubscriptionEvent.LOGIN_REQUEST, handler2);
+      expect(callbacks.triggerLoginRequest({linkRequested: true})).to.be.true;
+
+      await tick();
+      expect(callback).to.be.calledOnceWithExactly({linkRequested: true});
+      expect(handler1).to.be.calledOnceWithExactly({linkRequested: true});
+      expect(handler2).to.be.calledOnceWithExactly({linkRequested: true});
+    });
+
+    it('should replace the callback only', async () => {
+      const callback1 = sandbox.spy();
+      const callback2 = sandbox.spy();
+      const handler = sandbox.spy();
+      callbacks.on(SubscriptionEvent.LINK_COMPLETE, handler);
+      callbacks.setOnLinkComplete(callback1);
+      callbacks.setOnLinkComplete(callback2);
+      callbacks.triggerLinkComplete();
+
+      await tick();
+      expect(callback1).to.not.be.called;
+      expect(callback2).to.be.calledOnce;
+      expect(handler).to.be.calledOnce;
+    });
+
+    it('should not call unsubscribed handlers', async () => {
+      const handler = sandbox.spy();
+      callbacks.on(SubscriptionEvent.FLOW_STARTED, handler);
+      callbacks.off(SubscriptionEvent.FLOW_STARTED, handler);
+
+      expect(callbacks.triggerFlowStarted('flow1')).to.be.false;
+      await tick();
+      expect(handler).to.not.be.called;
+    });
+
+    it('should call the other handlers if one fails', async () => {
+      const handler = sandbox.spy();
+      callbacks.on(SubscriptionEvent.FLOW_CANCELED, () => {
+        throw new Error('broken');
+      });
+      callbacks.on(SubscriptionEvent.FLOW_CANCELED, handler);
+      callbacks.triggerFlowCanceled('flow1');
+
+      await tick();
+      expect(handler).to.be.calledOnce;
+    });
+
+    it('should pass a pending event to the first handlers only', async () => {
+      const handler1 = sandbox.spy();
+      const handler2 = sandbox.spy();
+      callbacks.triggerLinkProgress();
+      callbacks.on(SubscriptionEvent.LINK_PROGRESS, handler1);
+
+      await tick();
+      callbacks.on(SubscriptionEvent.LINK_PROGRESS, handler2);
+      await tick();
+      expect(handler1).to.be.calledOnce;
+      expect(handler2).to.not.be.called;
+    });
+
+    it('should replay the last entitlements to later handlers', async () => {
+      const entitlements = {clone: () => entitlements};
+      const handler1 = sandbox.spy();
+      const handler2 = sandbox.spy();
+      callbacks.on(SubscriptionEvent.ENTITLEMENTS, handler1);
+      callbacks.triggerEntitlementsResponse(Promise.resolve(entitlements));
+
+      await tick();
+      expect(callbacks.hasEntitlementsResponsePending()).to.be.false;
+      callbacks.on(SubscriptionEvent.ENTITLEMENTS, handler2);
+      await tick();
+      expect(handler1).to.be.calledOnce;
+      expect(handler2).to.be.calledOnce;
+      expect(await handler2.args[0][0]).to.equal(entitlements);
+    });
+
+    it('should throw on unknown events', () => {
+      expect(() => callbacks.on('unknown', () => {})).to.throw(
+        'Unknown event: unknown'
+      );
+    });
+  });
+
+  describe('flow outcomes', () => {
+    it('should resolve purchases', async () => {
+      const clone = {productType: ProductType.UI_CONTRIBUTION};
+      const response = {
+        productType: ProductType.UI_CONTRIBUTION,
+        clone: () => clone,
+      };
+      const subscribeOutcome = sandbox.spy();
+      callbacks
+        .whenFlowOutcome([SubscriptionFlows.SUBSCRIBE])
+        .then(subscribeOutcome);
+      const outcome = callbacks.whenFlowOutcome([
+        SubscriptionFlows.SHOW_CONTRIBUTION_OPTIONS,
+        SubscriptionFlows.CONTRIBUTE,
+      ]);
+
+      callbacks.triggerPaymentResponse(Promise.resolve(response));
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.PURCHASED,
+        flow: SubscriptionFlows.CONTRIBUTE,
+        response: clone,
+      });
+      expect(subscribeOutcome).to.not.be.called;
+    });
+
+    it('should resolve payment errors', async () => {
+      const outcome = callbacks.whenFlowOutcome([SubscriptionFlows.SUBSCRIBE]);
+
+      callbacks.triggerPaymentResponse(Promise.reject({name: 'OtherError'}));
+      await expect(callbacks.paymentResponsePromise_).to.be.rejected;
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.SUBSCRIBE,
+        code: FlowErrorCode.PAYMENT_FAILED,
+      });
+    });
+
+    it('should resolve payment errors with the SwgError', async () => {
+      const outcome = callbacks.whenFlowOutcome([SubscriptionFlows.SUBSCRIBE]);
+      const error = new SwgError(SwgErrorCode.PAYMENT_DECLINED, 'declined');
+
+      callbacks.triggerPaymentResponse(Promise.reject(error));
+      await expect(callbacks.paymentResponsePromise_).to.be.rejectedWith(
+        'declined'
+      );
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.SUBSCRIBE,
+        code: FlowErrorCode.PAYMENT_FAILED,
+        error,
+      });
+    });
+
+    it('should resolve cancellations', async () => {
+      const outcome = callbacks.whenFlowOutcome([
+        SubscriptionFlows.SHOW_OFFERS,
+        SubscriptionFlows.SUBSCRIBE,
+      ]);
+
+      callbacks.triggerFlowCanceled(SubscriptionFlows.SUBSCRIBE);
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.CANCELED,
+        flow: SubscriptionFlows.SUBSCRIBE,
+      });
+    });
+
+    it('should resolve account linking', async () => {
+      const outcome = callbacks.whenFlowOutcome([
+        SubscriptionFlows.LINK_ACCOUNT,
+      ]);
+
+      callbacks.triggerLinkComplete();
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.LINKED,
+        flow: SubscriptionFlows.LINK_ACCOUNT,
+      });
+    });
+
+    it('should resolve already subscribed users', async () => {
+      const outcome = callbacks.whenFlowOutcome([
+        SubscriptionFlows.SHOW_OFFERS,
+      ]);
+
+      callbacks.triggerFlowAlreadySubscribed(
+        SubscriptionFlows.SHOW_OFFERS,
+        true
+      );
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.ALREADY_SUBSCRIBED,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+        linkRequested: true,
+      });
+    });
+
+    it('should resolve errors', async () => {
+      const outcome = callbacks.whenFlowOutcome([
+        SubscriptionFlows.LINK_ACCOUNT,
+      ]);
+
+      callbacks.triggerFlowError(
+        [SubscriptionFlows.LINK_ACCOUNT],
+        FlowErrorCode.START_FAILED
+      );
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.LINK_ACCOUNT,
+        code: FlowErrorCode.START_FAILED,
+      });
+    });
+
+    it('should cancel the outcome of a replaced flow', async () => {
+      const outcome = callbacks.whenFlowOutcome([
+        SubscriptionFlows.SHOW_OFFERS,
+        SubscriptionFlows.SUBSCRIBE,
+      ]);
+      const newOutcome = callbacks.whenFlowOutcome([
+        SubscriptionFlows.SUBSCRIBE,
+      ]);
+
+      expect(await outcome).to.deep.equal({
+        type: FlowOutcomeType.CANCELED,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+      });
+      callbacks.triggerFlowCanceled(SubscriptionFlows.SUBSCRIBE);
+      expect(await newOutcome).to.deep.equal({
+        type: FlowOutcomeType.CANCELED,
+        flow: SubscriptionFlows.SUBSCRIBE,
+      });
+    });
+
+    it('should resolve the dropped outcomes with null', async () => {
+      const outcome = callbacks.whenFlowOutcome([SubscriptionFlows.SUBSCRIBE]);
+
+      callbacks.dropFlowOutcomes();
+      callbacks.triggerFlowCanceled(SubscriptionFlows.SUBSCRIBE);
+
+      expect(await outcome).to.be.null;
+    });
+  });
 });
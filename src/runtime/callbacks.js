@@ -13,8 +13,16 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-import {isCancelError} from '../utils/errors';
-import {warn} from '../utils/log';
+import {
+  FlowErrorCode,
+  FlowOutcomeType,
+  FlowState,
+  ProductType,
+  SubscriptionEvent,
+  SubscriptionFlows,
+} from '../api/subscriptions';
+import {assert, log, warn} from '../utils/log';
+import {isCancelError, isSwgError} from '../utils/errors';
 
 /** @enum {number} */
 const CallbackId = {
@@ -27,20 +35,74 @@ const CallbackId = {
   FLOW_STARTED: 7,
   FLOW_CANCELED: 8,
   PAY_CONFIRM_OPENED: 9,
+  FLOW_STATE_CHANGE: 10,
+  ERROR: 11,
 };
 
+/**
+ * Callbacks of the events that can be subscribed to with `on`.
+ * @const {!Object<!SubscriptionEvent, !CallbackId>}
+ */
+const EVENT_CALLBACK_IDS = {
+  [SubscriptionEvent.ENTITLEMENTS]: CallbackId.ENTITLEMENTS,
+  [SubscriptionEvent.NATIVE_SUBSCRIBE_REQUEST]: CallbackId.SUBSCRIBE_REQUEST,
+  [SubscriptionEvent.PAYMENT_RESPONSE]: CallbackId.PAYMENT_RESPONSE,
+  [SubscriptionEvent.LOGIN_REQUEST]: CallbackId.LOGIN_REQUEST,
+  [SubscriptionEvent.LINK_PROGRESS]: CallbackId.LINK_PROGRESS,
+  [SubscriptionEvent.LINK_COMPLETE]: CallbackId.LINK_COMPLETE,
+  [SubscriptionEvent.FLOW_STARTED]: CallbackId.FLOW_STARTED,
+  [SubscriptionEvent.FLOW_CANCELED]: CallbackId.FLOW_CANCELED,
+  [SubscriptionEvent.FLOW_STATE_CHANGE]: CallbackId.FLOW_STATE_CHANGE,
+  [SubscriptionEvent.ERROR]: CallbackId.ERROR,
+};
+
+/**
+ * Callbacks whose last result is passed to every handler subscribed later,
+ * not only to the first ones.
+ * @const {!Array<!CallbackId>}
+ */
+const STICKY_CALLBACK_IDS = [CallbackId.ENTITLEMENTS];
+
+/**
+ * A pending promise of the outcome of one of the flows.
+ * @typedef {{
+ *   flows: !Array<string>,
+ *   resolve: function(?../api/subscriptions.FlowOutcome),
+ * }}
+ */
+let OutcomeWaiter;
+
 /**
  */
 export class Callbacks {
   /**
    */
   constructor() {
-    /** @private @const {!Object<CallbackId, function(*)>} */
+    /** @private @const {!Object<CallbackId, !Array<function(*)>>} */
+    this.handlers_ = {};
+    /**
+     * The handlers set with the `setOn...` methods, which replace each other.
+     * @private @const {!Object<CallbackId, function(*)>}
+     */
     this.callbacks_ = {};
     /** @private @const {!Object<CallbackId, *>} */
     this.resultBuffer_ = {};
+    /** @private @const {!Object<CallbackId, *>} */
+    this.stickyResults_ = {};
     /** @private {?Promise} */
     this.paymentResponsePromise_ = null;
+    /** @private {!Array<!OutcomeWaiter>} */
+    this.outcomeWaiters_ = [];
+    /** @private {?./flow-controller.FlowController} */
+    this.flowController_ = null;
+  }
+
+  /**
+   * Sets the controller notified as the flows progress.
+   * @param {!./flow-controller.FlowController} flowController
+   */
+  setFlowController(flowController) {
+    this.flowController_ = flowController;
   }
 
   /**
@@ -113,6 +175,11 @@ export class Callbacks {
    * @return {boolean} Whether the callback has been found.
    */
   triggerLinkComplete() {
+    this.setFlowState_(SubscriptionFlows.LINK_ACCOUNT, FlowState.DONE);
+    this.resolveFlowOutcome_(
+      [SubscriptionFlows.LINK_ACCOUNT],
+      FlowOutcomeType.LINKED
+    );
     return this.trigger_(CallbackId.LINK_COMPLETE, true);
   }
 
@@ -156,7 +223,7 @@ export class Callbacks {
    * @return {boolean}
    */
   hasSubscribeRequestCallback() {
-    return !!this.callbacks_[CallbackId.SUBSCRIBE_REQUEST];
+    return this.hasHandlers_(CallbackId.SUBSCRIBE_REQUEST);
   }
 
   /**
@@ -193,6 +260,14 @@ export class Callbacks {
   triggerPaymentResponse(responsePromise) {
     this.paymentResponsePromise_ = responsePromise.then(
       (res) => {
+        const flow =
+          res.productType == ProductType.UI_CONTRIBUTION
+            ? SubscriptionFlows.CONTRIBUTE
+            : SubscriptionFlows.SUBSCRIBE;
+        this.setFlowState_(flow, FlowState.PAY_COMPLETE);
+        this.resolveFlowOutcome_([flow], FlowOutcomeType.PURCHASED, {
+          response: res.clone(),
+        });
         this.trigger_(
           CallbackId.PAYMENT_RESPONSE,
           Promise.resolve(res.clone())
@@ -202,10 +277,18 @@ export class Callbacks {
         if (isCancelError(reason)) {
           return;
         }
+        if (this.flowController_) {
+          this.flowController_.handlePaymentFailed();
+        }
+        this.triggerFlowError(
+          [SubscriptionFlows.SUBSCRIBE, SubscriptionFlows.CONTRIBUTE],
+          FlowErrorCode.PAYMENT_FAILED,
+          reason
+        );
         throw reason;
       }
     );
-    return !!this.callbacks_[CallbackId.PAYMENT_RESPONSE];
+    return this.hasHandlers_(CallbackId.PAYMENT_RESPONSE);
   }
 
   /**
@@ -228,6 +311,9 @@ export class Callbacks {
    * @return {boolean} Whether the callback has been found.
    */
   triggerFlowStarted(flow, data = {}) {
+    if (this.flowController_) {
+      this.flowController_.handleFlowStarted(flow);
+    }
     return this.trigger_(CallbackId.FLOW_STARTED, {
       flow,
       data,
@@ -247,12 +333,137 @@ export class Callbacks {
    * @return {boolean} Whether the callback has been found.
    */
   triggerFlowCanceled(flow, data = {}) {
+    this.setFlowState_(flow, FlowState.CANCELED);
+    this.resolveFlowOutcome_([flow], FlowOutcomeType.CANCELED);
     return this.trigger_(CallbackId.FLOW_CANCELED, {
       flow,
       data,
     });
   }
 
+  /**
+   * Ends the flow, and notifies its outcome promises that the user is already
+   * subscribed. The login request is triggered separately.
+   * @param {string} flow
+   * @param {boolean} linkRequested
+   */
+  triggerFlowAlreadySubscribed(flow, linkRequested) {
+    this.setFlowState_(flow, FlowState.DONE);
+    this.resolveFlowOutcome_([flow], FlowOutcomeType.ALREADY_SUBSCRIBED, {
+      linkRequested,
+    });
+  }
+
+  /**
+   * Returns a promise resolved with the next outcome of any of the flows,
+   * e.g. of the offers flow or of the purchase started from it. Pending
+   * promises of the same flows are resolved as canceled, since only one flow
+   * is shown at a time. The promise is resolved with null if the outcomes
+   * are dropped.
+   * @param {!Array<string>} flows
+   * @return {!Promise<?../api/subscriptions.FlowOutcome>}
+   */
+  whenFlowOutcome(flows) {
+    this.outcomeWaiters_ = this.outcomeWaiters_.filter((waiter) => {
+      if (!flows.some((flow) => waiter.flows.includes(flow))) {
+        return true;
+      }
+      waiter.resolve({type: FlowOutcomeType.CANCELED, flow: waiter.flows[0]});
+      return false;
+    });
+    return new Promise((resolve) => {
+      this.outcomeWaiters_.push({flows, resolve});
+    });
+  }
+
+  /**
+   * Resolves the pending outcome promises with null, e.g. once the runtime is
+   * destroyed.
+   */
+  dropFlowOutcomes() {
+    for (const waiter of this.outcomeWaiters_) {
+      waiter.resolve(null);
+    }
+    this.outcomeWaiters_ = [];
+  }
+
+  /**
+   * Resolves the flows' outcome promises with an error, e.g. once they failed
+   * to start. The reason is passed on if it's a SwgError.
+   * @param {!Array<string>} flows
+   * @param {!FlowErrorCode} code
+   * @param {*=} reason
+   */
+  triggerFlowError(flows, code, reason) {
+    const details = isSwgError(reason) ? {code, error: reason} : {code};
+    this.resolveFlowOutcome_(flows, FlowOutcomeType.ERROR, details);
+  }
+
+  /**
+   * @param {!Array<string>} flows
+   * @param {!FlowOutcomeType} type
+   * @param {!Object=} details
+   * @private
+   */
+  resolveFlowOutcome_(flows, type, details = {}) {
+    this.outcomeWaiters_ = this.outcomeWaiters_.filter((waiter) => {
+      const flow = flows.find((name) => waiter.flows.includes(name));
+      if (!flow) {
+        return true;
+      }
+      waiter.resolve(
+        /** @type {!../api/subscriptions.FlowOutcome} */ (
+          Object.assign({type, flow}, details)
+        )
+      );
+      return false;
+    });
+  }
+
+  /**
+   * @param {!../api/subscriptions.FlowStateChange} change
+   * @return {boolean} Whether the callback has been found.
+   */
+  triggerFlowStateChange(change) {
+    return this.trigger_(CallbackId.FLOW_STATE_CHANGE, change);
+  }
+
+  /**
+   * Reports an error that no promise is rejected with.
+   * @param {!../utils/errors.SwgError} error
+   * @return {boolean} Whether the callback has been found.
+   */
+  triggerError(error) {
+    return this.trigger_(CallbackId.ERROR, error);
+  }
+
+  /**
+   * @param {string} flow
+   * @param {!FlowState} state
+   * @private
+   */
+  setFlowState_(flow, state) {
+    if (this.flowController_) {
+      this.flowController_.setState(flow, state);
+    }
+  }
+
+  /**
+   * @param {!SubscriptionEvent} event
+   * @param {function(?)} handler
+   */
+  on(event, handler) {
+    this.addHandler_(getCallbackId(event), handler);
+  }
+
+  /**
+   * @param {!SubscriptionEvent} event
+   * @param {function(?)} handler
+   */
+  off(event, handler) {
+    this.removeHandler_(getCallbackId(event), handler);
+  }
+
   /**
    * @param {!CallbackId} id
    * @param {function(?)} callback
@@ -263,14 +474,52 @@ export class Callbacks {
       warn(
         `[swg.js]: You have registered multiple callbacks for the same response.`
       );
+      this.removeHandler_(id, this.callbacks_[id]);
     }
     this.callbacks_[id] = callback;
-    // If result already exist, execute the callback right away.
+    this.addHandler_(id, callback);
+  }
+
+  /**
+   * @param {!CallbackId} id
+   * @param {function(?)} handler
+   * @private
+   */
+  addHandler_(id, handler) {
+    if (!this.handlers_[id]) {
+      this.handlers_[id] = [];
+    }
+    this.handlers_[id].push(handler);
+    // If result already exist, execute the handler right away.
     if (id in this.resultBuffer_) {
-      this.executeCallback_(id, callback, this.resultBuffer_[id]);
+      this.executeCallback_(id, [handler], this.resultBuffer_[id]);
+    } else if (id in this.stickyResults_) {
+      this.executeCallback_(id, [handler], this.stickyResults_[id]);
     }
   }
 
+  /**
+   * @param {!CallbackId} id
+   * @param {function(?)} handler
+   * @private
+   */
+  removeHandler_(id, handler) {
+    const handlers = this.handlers_[id] || [];
+    const index = handlers.indexOf(handler);
+    if (index != -1) {
+      handlers.splice(index, 1);
+    }
+  }
+
+  /**
+   * @param {!CallbackId} id
+   * @return {boolean}
+   * @private
+   */
+  hasHandlers_(id) {
+    return !!this.handlers_[id] && this.handlers_[id].length > 0;
+  }
+
   /**
    * @param {!CallbackId} id
    * @param {*} data
@@ -279,11 +528,14 @@ export class Callbacks {
    */
   trigger_(id, data) {
     this.resultBuffer_[id] = data;
-    const callback = this.callbacks_[id];
-    if (callback) {
-      this.executeCallback_(id, callback, data);
+    if (STICKY_CALLBACK_IDS.includes(id)) {
+      this.stickyResults_[id] = data;
+    }
+    const hasHandlers = this.hasHandlers_(id);
+    if (hasHandlers) {
+      this.executeCallback_(id, this.handlers_[id].slice(), data);
     }
-    return !!callback;
+    return hasHandlers;
   }
 
   /**
@@ -298,15 +550,36 @@ export class Callbacks {
 
   /**
    * @param {!CallbackId} id
-   * @param {function(*)} callback
+   * @param {!Array<function(*)>} handlers
    * @param {*} data
    * @private
    */
-  executeCallback_(id, callback, data) {
+  executeCallback_(id, handlers, data) {
     // Always execute callbacks in a microtask.
     Promise.resolve().then(() => {
-      callback(data);
+      handlers.forEach((handler) => {
+        // Skip the handlers unsubscribed since.
+        if (!this.handlers_[id].includes(handler)) {
+          return;
+        }
+        // A failing handler doesn't prevent the others from being called.
+        try {
+          handler(data);
+        } catch (e) {
+          log(e);
+        }
+      });
       this.resetCallback_(id);
     });
   }
 }
+
+/**
+ * @param {!SubscriptionEvent} event
+ * @return {!CallbackId}
+ */
+function getCallbackId(event) {
+  const id = EVENT_CALLBACK_IDS[event];
+  assert(id, 'Unknown event: %s', event);
+  return id;
+}
@@ -14,12 +14,19 @@
  * limitations under the License.
  */
 
-import {AutoPromptConfig} from '../model/auto-prompt-config';
+import {
+  AutoPromptConfig,
+  ClientDisplayTrigger,
+} from '../model/auto-prompt-config';
+import {Callbacks} from './callbacks';
 import {ClientConfig} from '../model/client-config';
 import {ClientConfigManager} from './client-config-manager';
 import {ClientTheme} from '../api/basic-subscriptions';
 import {DepsDef} from './deps';
 import {Fetcher} from './fetcher';
+import {PerformanceTimings} from '../utils/performance';
+import {RetryPolicy} from '../utils/retry-policy';
+import {SwgErrorCode} from '../utils/errors';
 
 describes.realWin('ClientConfigManager', {}, () => {
   let clientConfigManager;
@@ -34,6 +41,10 @@ describes.realWin('ClientConfigManager', {}, () => {
     fetcher = new Fetcher();
     fetcherMock = sandbox.mock(fetcher);
     depsMock = sandbox.mock(deps);
+    sandbox.stub(deps, 'win').returns(self);
+    sandbox
+      .stub(deps, 'performanceTimings')
+      .returns(new PerformanceTimings(self));
     entitlementsManagerMock = depsMock.expects('entitlementsManager').returns({
       getArticle: () => Promise.resolve(),
     });
@@ -50,7 +61,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({autoPromptConfig: {maxImpressionsPerWeek: 1}})
       .once();
 
@@ -119,7 +130,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({})
       .once();
 
@@ -132,7 +143,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({autoPromptConfig: {maxImpressionsPerWeek: 3}})
       .once();
 
@@ -147,7 +158,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({
         autoPromptConfig: {
           maxImpressionsPerWeek: 1,
@@ -175,29 +186,143 @@ describes.realWin('ClientConfigManager', {}, () => {
     ).to.equal(5);
   });
 
-  it('fetchClientConfig should log errors from the response', async () => {
+  it('getAutoPromptConfig should return the rules of the config', async () => {
+    const rules = [{id: 'rule1', type: 'articleCount', min: 3}];
+    fetcherMock
+      .expects('fetchCredentialedJson')
+      .resolves({autoPromptConfig: {rules}})
+      .once();
+
+    const autoPromptConfig = await clientConfigManager.getAutoPromptConfig();
+    expect(autoPromptConfig.rules).to.deep.equal(rules);
+  });
+
+  it('getAutoPromptConfig should return the display triggers', async () => {
+    fetcherMock
+      .expects('fetchCredentialedJson')
+      .resolves({
+        autoPromptConfig: {
+          clientDisplayTrigger: {
+            displayDelaySeconds: 1,
+            scrollDepthPercent: 50,
+            visibleElementSelector: '#footer',
+            exitIntent: true,
+            idleSeconds: 30,
+            nthArticle: 2,
+          },
+        },
+      })
+      .once();
+
+    const autoPromptConfig = await clientConfigManager.getAutoPromptConfig();
+    expect(autoPromptConfig.clientDisplayTrigger).to.deep.equal(
+      new ClientDisplayTrigger(1, {
+        scrollDepthPercent: 50,
+        visibleElementSelector: '#footer',
+        exitIntent: true,
+        idleSeconds: 30,
+        nthArticle: 2,
+      })
+    );
+  });
+
+  it('getAutoPromptConfig should return the frequency caps', async () => {
+    const frequencyCaps = [
+      {event: 'impression', max: 3, windowSeconds: 86400},
+      {event: 'dismissal', max: 1, campaign: 'spring'},
+    ];
+    fetcherMock
+      .expects('fetchCredentialedJson')
+      .resolves({autoPromptConfig: {frequencyCaps}})
+      .once();
+
+    const autoPromptConfig = await clientConfigManager.getAutoPromptConfig();
+    expect(autoPromptConfig.frequencyCaps).to.deep.equal(frequencyCaps);
+  });
+
+  it('fetchClientConfig should report errors from the response', async () => {
     const expectedUrl =
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({
         errorMessages: ['Something went wrong'],
       })
       .once();
+    const callbacks = new Callbacks();
+    const triggerErrorStub = sandbox.stub(callbacks, 'triggerError');
+    depsMock.expects('callbacks').returns(callbacks);
 
     await clientConfigManager.getAutoPromptConfig();
+    expect(triggerErrorStub).to.be.calledOnce;
+    const error = triggerErrorStub.args[0][0];
+    expect(error.code).to.equal(SwgErrorCode.INVALID_CONFIG);
+    expect(error.message).to.equal('Something went wrong');
     expect(self.console.warn).to.have.been.calledWithExactly(
       'SwG ClientConfigManager: Something went wrong'
     );
   });
 
+  it('fetchClientConfig should reject with request errors', async () => {
+    const reason = new Error('HTTP error 503');
+    reason.retriable = true;
+    reason.response = {status: 503};
+    fetcherMock.expects('fetchCredentialedJson').rejects(reason).once();
+
+    const error = await clientConfigManager
+      .fetchClientConfig()
+      .catch((e) => e);
+    expect(error.code).to.equal(SwgErrorCode.INVALID_CONFIG);
+    expect(error.message).to.equal('HTTP error 503');
+    expect(error.retryable).to.be.true;
+    expect(error.cause).to.equal(reason);
+  });
+
   it('getClientConfig should return a Promise with an empty config if fetchClientConfig is not called', async () => {
     const clientConfig = await clientConfigManager.getClientConfig();
     const expectedClientConfig = new ClientConfig();
     expect(clientConfig).to.deep.equal(expectedClientConfig);
   });
 
+  it('setArticle should provide the client config without fetching', async () => {
+    fetcherMock.expects('fetchCredentialedJson').never();
+
+    clientConfigManager.setArticle({
+      clientConfig: {autoPromptConfig: {maxImpressionsPerWeek: 1}},
+    });
+
+    const clientConfig = await clientConfigManager.getClientConfig();
+    expect(clientConfig.autoPromptConfig).to.deep.equal(
+      new AutoPromptConfig(1)
+    );
+    const autoPromptConfig = await clientConfigManager.getAutoPromptConfig();
+    expect(autoPromptConfig).to.deep.equal(new AutoPromptConfig(1));
+  });
+
+  it('setArticle should not override a requested client config', async () => {
+    const expectedUrl =
+      '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
+    fetcherMock
+      .expects('fetchCredentialedJson')
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
+      .resolves({paySwgVersion: '1'})
+      .once();
+    const promise = clientConfigManager.fetchClientConfig();
+
+    clientConfigManager.setArticle({clientConfig: {paySwgVersion: '2'}});
+
+    const clientConfig = await promise;
+    expect(clientConfig.paySwgVersion).to.equal('1');
+    expect(await clientConfigManager.getClientConfig()).to.equal(clientConfig);
+  });
+
+  it('setArticle should ignore articles without a client config', async () => {
+    clientConfigManager.setArticle({entitlements: {}});
+
+    expect(clientConfigManager.responsePromise_).to.be.null;
+  });
+
   it('should return default client options if unspecified', () => {
     expect(clientConfigManager.getLanguage()).to.equal('en');
     expect(clientConfigManager.getTheme()).to.equal(ClientTheme.LIGHT);
@@ -286,7 +411,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({
         uiPredicates: {
           canDisplayButton: true,
@@ -304,7 +429,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({})
       .once();
 
@@ -318,7 +443,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({paySwgVersion: '2'})
       .once();
 
@@ -331,7 +456,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({useUpdatedOfferFlows: true})
       .once();
 
@@ -344,7 +469,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({useUpdatedOfferFlows: false})
       .once();
 
@@ -357,7 +482,7 @@ describes.realWin('ClientConfigManager', {}, () => {
       '$frontend$/swg/_/api/v1/publication/pubId/clientconfiguration';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({
         uiPredicates: {
           canDisplayAutoPrompt: true,
@@ -378,7 +503,7 @@ describes.realWin('ClientConfigManager', {}, () => {
     const expectedAvatarUrl = 'avatar.png';
     fetcherMock
       .expects('fetchCredentialedJson')
-      .withExactArgs(expectedUrl)
+      .withExactArgs(expectedUrl, sandbox.match.instanceOf(RetryPolicy))
       .resolves({
         attributionParams: {
           displayName: expectedDisplayName,
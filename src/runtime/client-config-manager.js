@@ -18,6 +18,10 @@ import {AttributionParams} from '../model/attribution-params';
 import {AutoPromptConfig} from '../model/auto-prompt-config';
 import {ClientConfig} from '../model/client-config';
 import {ClientTheme} from '../api/basic-subscriptions';
+import {DiagnosticType, recordDiagnostic, recordFetch} from './diagnostics';
+import {PerformanceTiming} from '../utils/performance';
+import {RetriedRequest, createRetryPolicy} from './retry-policies';
+import {SwgError, SwgErrorCode, toRequestError} from '../utils/errors';
 import {UiPredicates} from '../model/auto-prompt-config';
 import {serviceUrl} from './services';
 import {warn} from '../utils/log';
@@ -32,11 +36,15 @@ export class ClientConfigManager {
    * @param {string} publicationId
    * @param {!./fetcher.Fetcher} fetcher
    * @param {!../api/basic-subscriptions.ClientOptions=} clientOptions
+   * @param {!../utils/retry-policy.RetryPolicy=} retryPolicy
    */
-  constructor(deps, publicationId, fetcher, clientOptions) {
+  constructor(deps, publicationId, fetcher, clientOptions, retryPolicy) {
     /** @private @const {!./deps.DepsDef} */
     this.deps_ = deps;
 
+    /** @private @const {!../utils/performance.PerformanceTimings} */
+    this.performanceTimings_ = deps.performanceTimings();
+
     /** @private @const {!../api/basic-subscriptions.ClientOptions} */
     this.clientOptions_ = clientOptions || {};
 
@@ -46,6 +54,10 @@ export class ClientConfigManager {
     /** @private @const {!./fetcher.Fetcher} */
     this.fetcher_ = fetcher;
 
+    /** @private @const {!../utils/retry-policy.RetryPolicy} */
+    this.retryPolicy_ =
+      retryPolicy || createRetryPolicy(deps, RetriedRequest.CLIENT_CONFIG);
+
     /** @private {?Promise<!ClientConfig>} */
     this.responsePromise_ = null;
   }
@@ -62,11 +74,31 @@ export class ClientConfigManager {
     }
     if (!this.responsePromise_) {
       readyPromise = readyPromise || Promise.resolve();
-      this.responsePromise_ = readyPromise.then(() => this.fetch_());
+      this.responsePromise_ = readyPromise.then(() =>
+        this.performanceTimings_.timeAsync(
+          PerformanceTiming.CLIENT_CONFIG,
+          () => this.fetch_()
+        )
+      );
     }
     return this.responsePromise_;
   }
 
+  /**
+   * Uses the client config of an article fetched by the entitlements manager,
+   * unless the client config was already requested. This saves a round trip
+   * when the client config is only needed after the article was fetched.
+   * @param {!Object} article The article JSON.
+   */
+  setArticle(article) {
+    if (this.responsePromise_ || !article['clientConfig']) {
+      return;
+    }
+    this.responsePromise_ = Promise.resolve(
+      this.parseClientConfig_(article['clientConfig'])
+    );
+  }
+
   /**
    * Gets the client config, if already requested. Otherwise returns a Promise
    * with an empty ClientConfig.
@@ -165,14 +197,33 @@ export class ClientConfigManager {
               encodeURIComponent(this.publicationId_) +
               '/clientconfiguration'
           );
-          return this.fetcher_.fetchCredentialedJson(url).then((json) => {
-            if (json.errorMessages && json.errorMessages.length > 0) {
-              for (const errorMessage of json.errorMessages) {
-                warn('SwG ClientConfigManager: ' + errorMessage);
+          const startTime = Date.now();
+          return this.fetcher_
+            .fetchCredentialedJson(url, this.retryPolicy_)
+            .then(
+              (json) => {
+                recordFetch(this.deps_, 'clientConfig', startTime);
+                if (json.errorMessages && json.errorMessages.length > 0) {
+                  for (const errorMessage of json.errorMessages) {
+                    warn('SwG ClientConfigManager: ' + errorMessage);
+                    this.deps_
+                      .callbacks()
+                      .triggerError(
+                        new SwgError(SwgErrorCode.INVALID_CONFIG, errorMessage)
+                      );
+                  }
+                }
+                return this.parseClientConfig_(json);
+              },
+              (reason) => {
+                const error = toRequestError(
+                  reason,
+                  SwgErrorCode.INVALID_CONFIG
+                );
+                recordFetch(this.deps_, 'clientConfig', startTime, error);
+                throw error;
               }
-            }
-            return this.parseClientConfig_(json);
-          });
+            );
         }
       });
   }
@@ -183,6 +234,7 @@ export class ClientConfigManager {
    * @return {!ClientConfig}
    */
   parseClientConfig_(json) {
+    recordDiagnostic(this.deps_, DiagnosticType.CONFIG, json);
     const paySwgVersion = json['paySwgVersion'];
     const autoPromptConfigJson = json['autoPromptConfig'];
     let autoPromptConfig = undefined;
@@ -192,7 +244,10 @@ export class ClientConfigManager {
         autoPromptConfigJson.clientDisplayTrigger?.displayDelaySeconds,
         autoPromptConfigJson.explicitDismissalConfig?.backoffSeconds,
         autoPromptConfigJson.explicitDismissalConfig?.maxDismissalsPerWeek,
-        autoPromptConfigJson.explicitDismissalConfig?.maxDismissalsResultingHideSeconds
+        autoPromptConfigJson.explicitDismissalConfig?.maxDismissalsResultingHideSeconds,
+        autoPromptConfigJson.rules,
+        autoPromptConfigJson.clientDisplayTrigger,
+        autoPromptConfigJson.frequencyCaps
       );
     }
 
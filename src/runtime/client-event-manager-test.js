@@ -293,6 +293,164 @@ describes.sandboxed('EventManager', {}, () => {
       await tick();
       expect(events).to.deep.equal([event]);
     });
+
+    it('should unsubscribe listeners', async () => {
+      const listener = sandbox.spy();
+      const subscription = eventManager.registerEventListener(listener);
+
+      subscription.unsubscribe();
+      subscription.unsubscribe();
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(listener).to.not.be.called;
+      expect(events).to.deep.equal([DEFAULT_EVENT]);
+    });
+
+    it('should unsubscribe filterers', async () => {
+      const subscription = eventManager.registerEventFilterer(
+        () => EventManagerApi.FilterResult.CANCEL_EVENT
+      );
+
+      subscription.unsubscribe();
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(events).to.deep.equal([DEFAULT_EVENT]);
+    });
+
+    it('should skip listeners unsubscribed by earlier listeners', async () => {
+      const listener = sandbox.spy();
+      let subscription;
+      eventManager.registerEventListener(() => subscription.unsubscribe());
+      subscription = eventManager.registerEventListener(listener);
+
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(listener).to.not.be.called;
+    });
+
+    it('should only pass the events of the given types', async () => {
+      const listener = sandbox.spy();
+      eventManager.registerEventListener(listener, {eventTypes: [OTHER_TYPE]});
+      const event = Object.assign({}, DEFAULT_EVENT, {eventType: OTHER_TYPE});
+
+      eventManager.logEvent(DEFAULT_EVENT);
+      eventManager.logEvent(event);
+      await tick();
+
+      expect(listener).to.be.calledOnceWithExactly(event);
+    });
+
+    it('should only pass the events from the given originators', async () => {
+      eventManager.registerEventFilterer(
+        () => EventManagerApi.FilterResult.CANCEL_EVENT,
+        {eventOriginators: [OTHER_ORIGIN]}
+      );
+      const event = Object.assign({}, DEFAULT_EVENT, {
+        eventOriginator: OTHER_ORIGIN,
+      });
+
+      eventManager.logEvent(event);
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(events).to.deep.equal([DEFAULT_EVENT]);
+    });
+
+    it('should call listeners by priority', async () => {
+      const calls = [];
+      eventManager.registerEventListener(() => calls.push('low'), {
+        priority: -1,
+      });
+      eventManager.registerEventListener(() => calls.push('high'), {
+        priority: 1,
+      });
+      eventManager.registerEventListener(() => calls.push('default'));
+
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(calls).to.deep.equal(['high', 'default', 'low']);
+      expect(events).to.deep.equal([DEFAULT_EVENT]);
+    });
+
+    it('should call filterers by priority', async () => {
+      const filterer = sandbox.spy();
+      eventManager.registerEventFilterer(filterer);
+      eventManager.registerEventFilterer(
+        () => EventManagerApi.FilterResult.CANCEL_EVENT,
+        {priority: 1}
+      );
+
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(filterer).to.not.be.called;
+      expect(events).to.deep.equal([]);
+    });
+
+    it('should wait for async listeners', async () => {
+      let resolveListener;
+      eventManager.registerEventListener(
+        () =>
+          new Promise((resolve) => {
+            resolveListener = resolve;
+          })
+      );
+      eventManager.logEvent(DEFAULT_EVENT);
+      const lastAction = sandbox.spy();
+      eventManager.lastAction_.then(lastAction);
+
+      await tick(2);
+      expect(lastAction).to.not.be.called;
+
+      resolveListener();
+      await eventManager.lastAction_;
+      expect(lastAction).to.be.calledOnce;
+    });
+
+    it('should not wait for async listeners to call the next ones', async () => {
+      const calls = [];
+      eventManager.registerEventListener(
+        () => {
+          calls.push('async');
+          return new Promise(() => {});
+        },
+        {priority: 1}
+      );
+      eventManager.registerEventListener(() => calls.push('sync'));
+
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(calls).to.deep.equal(['async', 'sync']);
+      expect(events).to.deep.equal([DEFAULT_EVENT]);
+    });
+
+    it('should log async listener errors', async () => {
+      const logStub = sandbox.stub(console, 'log');
+      const error = new Error('Async error.');
+      eventManager.registerEventListener(() => Promise.reject(error));
+
+      eventManager.logEvent(DEFAULT_EVENT);
+      await eventManager.lastAction_;
+
+      expect(logStub).to.have.been.calledWith(error);
+    });
+
+    it('should drop events once destroyed', async () => {
+      const filterer = sandbox.spy();
+      eventManager.registerEventFilterer(filterer);
+
+      eventManager.destroy();
+      eventManager.logEvent(DEFAULT_EVENT);
+      await tick();
+
+      expect(events).to.deep.equal([]);
+      expect(filterer).to.not.be.called;
+    });
   });
 
   describe('helpers', () => {
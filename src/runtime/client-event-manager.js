@@ -67,6 +67,32 @@ function validateEvent(event) {
   }
 }
 
+/**
+ * A registered listener or filterer.
+ * @typedef {{
+ *   callback: function(!../api/client-event-manager-api.ClientEvent):*,
+ *   eventTypes: ?Array<!AnalyticsEvent>,
+ *   eventOriginators: ?Array<!EventOriginator>,
+ *   priority: number,
+ * }}
+ */
+let Registration;
+
+/**
+ * Whether the event passes the registration's filters by type and originator.
+ * @param {!Registration} registration
+ * @param {!../api/client-event-manager-api.ClientEvent} event
+ * @return {boolean}
+ */
+function matchesEvent(registration, event) {
+  return (
+    (!registration.eventTypes ||
+      registration.eventTypes.includes(event.eventType)) &&
+    (!registration.eventOriginators ||
+      registration.eventOriginators.includes(event.eventOriginator))
+  );
+}
+
 /** @implements {../api/client-event-manager-api.ClientEventManagerApi} */
 export class ClientEventManager {
   /**
@@ -86,10 +112,16 @@ export class ClientEventManager {
    * @param {!Promise} configuredPromise
    */
   constructor(configuredPromise) {
-    /** @private {!Array<function(!../api/client-event-manager-api.ClientEvent)>} */
+    /**
+     * Sorted by priority, from the highest.
+     * @private @const {!Array<!Registration>}
+     */
     this.listeners_ = [];
 
-    /** @private {!Array<function(!../api/client-event-manager-api.ClientEvent):!FilterResult>} */
+    /**
+     * Sorted by priority, from the highest.
+     * @private @const {!Array<!Registration>}
+     */
     this.filterers_ = [];
 
     /** @private {?Promise} */
@@ -102,21 +134,54 @@ export class ClientEventManager {
   /**
    * @overrides
    */
-  registerEventListener(listener) {
+  registerEventListener(listener, options = {}) {
     if (!isFunction(listener)) {
       throw new Error('Event manager listeners must be a function');
     }
-    this.listeners_.push(listener);
+    return this.register_(this.listeners_, listener, options);
   }
 
   /**
    * @overrides
    */
-  registerEventFilterer(filterer) {
+  registerEventFilterer(filterer, options = {}) {
     if (!isFunction(filterer)) {
       throw new Error('Event manager filterers must be a function');
     }
-    this.filterers_.push(filterer);
+    return this.register_(this.filterers_, filterer, options);
+  }
+
+  /**
+   * Adds the callback after the ones with the same or a higher priority.
+   * @param {!Array<!Registration>} registrations
+   * @param {function(!../api/client-event-manager-api.ClientEvent):*} callback
+   * @param {!../api/client-event-manager-api.ClientEventListenerOptions} options
+   * @return {!../api/client-event-manager-api.ClientEventSubscription}
+   * @private
+   */
+  register_(registrations, callback, options) {
+    const registration = {
+      callback,
+      eventTypes: options.eventTypes || null,
+      eventOriginators: options.eventOriginators || null,
+      priority: options.priority || 0,
+    };
+    const index = registrations.findIndex(
+      (other) => other.priority < registration.priority
+    );
+    registrations.splice(
+      index == -1 ? registrations.length : index,
+      0,
+      registration
+    );
+    return {
+      unsubscribe: () => {
+        const index = registrations.indexOf(registration);
+        if (index != -1) {
+          registrations.splice(index, 1);
+        }
+      },
+    };
   }
 
   /**
@@ -126,23 +191,38 @@ export class ClientEventManager {
   logEvent(event) {
     validateEvent(event);
     this.lastAction_ = this.isReadyPromise_.then(() => {
-      for (let filterer = 0; filterer < this.filterers_.length; filterer++) {
+      for (const filterer of this.filterers_.slice()) {
+        // Skip the filterers unsubscribed since.
+        if (
+          !this.filterers_.includes(filterer) ||
+          !matchesEvent(filterer, event)
+        ) {
+          continue;
+        }
         try {
-          if (this.filterers_[filterer](event) === FilterResult.CANCEL_EVENT) {
+          if (filterer.callback(event) === FilterResult.CANCEL_EVENT) {
             return Promise.resolve();
           }
         } catch (e) {
           log(e);
         }
       }
-      for (let listener = 0; listener < this.listeners_.length; listener++) {
+      const results = [];
+      for (const listener of this.listeners_.slice()) {
+        if (
+          !this.listeners_.includes(listener) ||
+          !matchesEvent(listener, event)
+        ) {
+          continue;
+        }
         try {
-          this.listeners_[listener](event);
+          // Async listeners are awaited, and their failures logged.
+          results.push(Promise.resolve(listener.callback(event)).catch(log));
         } catch (e) {
           log(e);
         }
       }
-      return Promise.resolve();
+      return Promise.all(results);
     });
   }
 
@@ -165,4 +245,13 @@ export class ClientEventManager {
   getReadyPromise() {
     return this.isReadyPromise_;
   }
+
+  /**
+   * Removes all the listeners and filterers. Events logged afterwards are
+   * dropped.
+   */
+  destroy() {
+    this.listeners_.length = 0;
+    this.filterers_.length = 0;
+  }
 }
@@ -0,0 +1,322 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {ConsentManager, isValidConsentState} from './consent-manager';
+import {ConsentPurpose} from '../api/subscriptions';
+import {tick} from '../../test/tick';
+
+describes.sandboxed('ConsentManager', {}, () => {
+  let win;
+  let config;
+  let deps;
+
+  beforeEach(() => {
+    win = {};
+    config = {};
+    deps = {
+      win: () => win,
+      config: () => config,
+    };
+  });
+
+  /**
+   * Installs a TCF API, and returns a function that notifies its listener.
+   * @return {function(!Object, boolean=)}
+   */
+  function installTcfApi() {
+    let listener;
+    win['__tcfapi'] = (command, version, callback) => {
+      expect(command).to.equal('addEventListener');
+      expect(version).to.equal(2);
+      listener = callback;
+    };
+    return (tcData, success = true) => listener(tcData, success);
+  }
+
+  it('should grant everything without consent management platform', () => {
+    const consentManager = new ConsentManager(deps);
+
+    expect(consentManager.getState()).to.deep.equal({
+      [ConsentPurpose.STORAGE]: true,
+      [ConsentPurpose.ANALYTICS]: true,
+      [ConsentPurpose.PERSONALIZATION]: true,
+    });
+  });
+
+  it('should use the consent set by the publisher', () => {
+    config.consent = {storage: true, analytics: false};
+    const consentManager = new ConsentManager(deps);
+
+    expect(consentManager.hasConsent(ConsentPurpose.STORAGE)).to.be.true;
+    expect(consentManager.hasConsent(ConsentPurpose.ANALYTICS)).to.be.false;
+    expect(consentManager.hasConsent(ConsentPurpose.PERSONALIZATION)).to.be
+      .false;
+  });
+
+  it('should deny everything until the TCF API answers', () => {
+    const notify = installTcfApi();
+    const consentManager = new ConsentManager(deps);
+    expect(consentManager.hasConsent(ConsentPurpose.STORAGE)).to.be.false;
+
+    notify({eventStatus: 'cmpuishown', purpose: {consents: {1: true}}});
+    expect(consentManager.hasConsent(ConsentPurpose.STORAGE)).to.be.false;
+
+    notify({
+      eventStatus: 'useractioncomplete',
+      purpose: {consents: {1: true}},
+      vendor: {consents: {755: true}},
+    });
+    expect(consentManager.getState()).to.deep.equal({
+      [ConsentPurpose.STORAGE]: true,
+      [ConsentPurpose.ANALYTICS]: false,
+      [ConsentPurpose.PERSONALIZATION]: false,
+    });
+  });
+
+  it('should map the TCF purposes', () => {
+    const notify = installTcfApi();
+    const consentManager = new ConsentManager(deps);
+    const changeCallback = sandbox.spy();
+    consentManager.onChange(changeCallback);
+
+    notify({
+      eventStatus: 'tcloaded',
+      purpose: {consents: {1: true, 3: true, 4: true, 8: false}},
+      vendor: {consents: {755: true}},
+    });
+
+    const state = {
+      [ConsentPurpose.STORAGE]: true,
+      [ConsentPurpose.ANALYTICS]: false,
+      [ConsentPurpose.PERSONALIZATION]: true,
+    };
+    expect(consentManager.getState()).to.deep.equal(state);
+    expect(changeCallback).to.be.calledOnceWithExactly(state);
+  });
+
+  it('should deny the TCF purposes without the consent for Google', () => {
+    const notify = installTcfApi();
+    const consentManager = new ConsentManager(deps);
+
+    notify({
+      eventStatus: 'tcloaded',
+      purpose: {consents: {1: true, 3: true, 4: true, 8: true}},
+      vendor: {consents: {1: true}},
+    });
+
+    expect(consentManager.getState()).to.deep.equal({
+      [ConsentPurpose.STORAGE]: false,
+      [ConsentPurpose.ANALYTICS]: false,
+      [ConsentPurpose.PERSONALIZATION]: false,
+    });
+  });
+
+  it('should respect the publisher restrictions of TCF', () => {
+    const notify = installTcfApi();
+    const consentManager = new ConsentManager(deps);
+
+    notify({
+      eventStatus: 'tcloaded',
+      purpose: {consents: {1: true, 3: true, 4: true, 8: true}},
+      vendor: {consents: {755: true}},
+      publisher: {
+        restrictions: {1: {755: 1}, 3: {1: 0}, 4: {755: 0}, 8: {755: 2}},
+      },
+    });
+
+    expect(consentManager.getState()).to.deep.equal({
+      [ConsentPurpose.STORAGE]: true,
+      [ConsentPurpose.ANALYTICS]: false,
+      [ConsentPurpose.PERSONALIZATION]: false,
+    });
+  });
+
+  it('should grant everything when GDPR does not apply', () => {
+    const notify = installTcfApi();
+    const consentManager = new ConsentManager(deps);
+
+    notify({gdprApplies: false, eventStatus: 'tcloaded'});
+
+    expect(consentManager.hasConsent(ConsentPurpose.PERSONALIZATION)).to.be
+      .true;
+  });
+
+  it('should react to consent withdrawn', () => {
+    const notify = installTcfApi();
+    const consentManager = new ConsentManager(deps);
+    notify({
+      eventStatus: 'tcloaded',
+      purpose: {consents: {1: true}},
+      vendor: {consents: {755: true}},
+    });
+    const changeCallback = sandbox.spy();
+    consentManager.onChange(changeCallback);
+
+    notify({eventStatus: 'useractioncomplete', purpose: {consents: {}}});
+
+    expect(consentManager.hasConsent(ConsentPurpose.STORAGE)).to.be.false;
+    expect(changeCallback).to.be.calledOnce;
+  });
+
+  it('should not notify unchanged consent', () => {
+    config.consent = {storage: true};
+    const consentManager = new ConsentManager(deps);
+    const changeCallback = sandbox.spy();
+    consentManager.onChange(changeCallback);
+
+    consentManager.update();
+    config.consent = {storage: true, analytics: true};
+    consentManager.update();
+
+    expect(changeCallback).to.be.calledOnce;
+  });
+
+  it('should deny personalization once opted out with GPP', async () => {
+    let listener;
+    win['__gpp'] = (command, callback, parameter) => {
+      if (command == 'addEventListener') {
+        listener = callback;
+      } else {
+        expect(command).to.equal('getSection');
+        expect(parameter).to.equal('usca');
+        callback({'SaleOptOut': 1, 'SharingOptOut': 2}, true);
+      }
+    };
+    const consentManager = new ConsentManager(deps);
+    expect(consentManager.hasConsent(ConsentPurpose.STORAGE)).to.be.false;
+
+    listener(
+      {pingData: {signalStatus: 'ready', applicableSections: [8]}},
+      true
+    );
+    await tick(10);
+
+    expect(consentManager.getState()).to.deep.equal({
+      [ConsentPurpose.STORAGE]: true,
+      [ConsentPurpose.ANALYTICS]: true,
+      [ConsentPurpose.PERSONALIZATION]: false,
+    });
+  });
+
+  it('should read the sections of version 1.0 of GPP', async () => {
+    let listener;
+    win['__gpp'] = (command, callback) => (listener = callback);
+    const consentManager = new ConsentManager(deps);
+
+    listener(
+      {
+        pingData: {
+          applicableSections: [7],
+          parsedSections: {'usnat': [{'TargetedAdvertisingOptOut': 2}]},
+        },
+      },
+      true
+    );
+    await tick(10);
+
+    expect(consentManager.hasConsent(ConsentPurpose.PERSONALIZATION)).to.be
+      .true;
+  });
+
+  it('should deny everything for the EU TCF section of GPP without the TCF API', async () => {
+    let listener;
+    win['__gpp'] = (command, callback) => (listener = callback);
+    const consentManager = new ConsentManager(deps);
+
+    listener(
+      {pingData: {signalStatus: 'ready', applicableSections: [2]}},
+      true
+    );
+    await tick(10);
+
+    expect(consentManager.getState()).to.deep.equal({
+      [ConsentPurpose.STORAGE]: false,
+      [ConsentPurpose.ANALYTICS]: false,
+      [ConsentPurpose.PERSONALIZATION]: false,
+    });
+  });
+
+  it('should read the EU TCF section of GPP through the TCF API', async () => {
+    const notify = installTcfApi();
+    let listener;
+    win['__gpp'] = (command, callback) => (listener = callback);
+    const consentManager = new ConsentManager(deps);
+
+    listener(
+      {pingData: {signalStatus: 'ready', applicableSections: [2]}},
+      true
+    );
+    await tick(10);
+    expect(consentManager.hasConsent(ConsentPurpose.STORAGE)).to.be.false;
+
+    notify({
+      eventStatus: 'tcloaded',
+      purpose: {consents: {1: true}},
+      vendor: {consents: {755: true}},
+    });
+    expect(consentManager.hasConsent(ConsentPurpose.STORAGE)).to.be.true;
+  });
+
+  it('should remove the listeners once destroyed', async () => {
+    const tcfCommands = [];
+    win['__tcfapi'] = (command, version, callback, parameter) => {
+      tcfCommands.push([command, parameter]);
+      if (command == 'addEventListener') {
+        callback({listenerId: 3, eventStatus: 'tcloaded'}, true);
+      }
+    };
+    const gppCommands = [];
+    let gppListener;
+    win['__gpp'] = (command, callback, parameter) => {
+      gppCommands.push([command, parameter]);
+      if (command == 'addEventListener') {
+        gppListener = callback;
+      }
+    };
+    const consentManager = new ConsentManager(deps);
+    const changeCallback = sandbox.spy();
+    consentManager.onChange(changeCallback);
+
+    consentManager.destroy();
+    expect(tcfCommands).to.deep.equal([
+      ['addEventListener', undefined],
+      ['removeEventListener', 3],
+    ]);
+
+    // The GPP listener answers after the runtime was destroyed.
+    gppListener(
+      {
+        listenerId: 5,
+        pingData: {signalStatus: 'ready', applicableSections: []},
+      },
+      true
+    );
+    await tick(10);
+    expect(gppCommands).to.deep.equal([
+      ['addEventListener', undefined],
+      ['removeEventListener', 5],
+    ]);
+    expect(changeCallback).to.not.be.called;
+  });
+
+  it('should validate consent states', () => {
+    expect(isValidConsentState({storage: true, personalization: false})).to.be
+      .true;
+    expect(isValidConsentState({storage: 'yes'})).to.be.false;
+    expect(isValidConsentState({cookies: true})).to.be.false;
+    expect(isValidConsentState(true)).to.be.false;
+  });
+});
@@ -0,0 +1,405 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {ConsentPurpose} from '../api/subscriptions';
+import {isObject} from '../utils/types';
+
+/** @const {number} */
+const TCF_API_VERSION = 2;
+
+/**
+ * The TCF purposes each purpose requires consent for: 1 is storing and
+ * accessing information on the device, 3 and 4 are personalized ads, and 8
+ * is measuring content performance.
+ * @const {!Object<!ConsentPurpose, !Array<number>>}
+ */
+const TCF_PURPOSES = {
+  [ConsentPurpose.STORAGE]: [1],
+  [ConsentPurpose.ANALYTICS]: [1, 8],
+  [ConsentPurpose.PERSONALIZATION]: [1, 3, 4],
+};
+
+/**
+ * The IAB vendor ID of Google, which SwG needs the consent of the reader for.
+ * @const {number}
+ */
+const GOOGLE_VENDOR_ID = 755;
+
+/**
+ * The type of the publisher restrictions that still let a purpose be based
+ * on consent. The other types disallow the purpose, or require a legitimate
+ * interest for it.
+ * @const {number}
+ */
+const TCF_RESTRICTION_REQUIRE_CONSENT = 1;
+
+/**
+ * The GPP section of the EU TCF. Its consent is read through the TCF API.
+ * @const {number}
+ */
+const GPP_TCF_EU_SECTION = 2;
+
+/**
+ * The GPP sections of the US state privacy laws, keyed by section ID.
+ * @const {!Object<number, string>}
+ */
+const GPP_US_SECTIONS = {
+  7: 'usnat',
+  8: 'usca',
+  9: 'usva',
+  10: 'usco',
+  11: 'usut',
+  12: 'usct',
+};
+
+/**
+ * The fields of the US sections that the reader can opt out with. Only
+ * personalization is opted out of, since these laws don't require consent
+ * for storage and analytics.
+ * @const {!Array<string>}
+ */
+const GPP_OPT_OUT_FIELDS = [
+  'SaleOptOut',
+  'SharingOptOut',
+  'TargetedAdvertisingOptOut',
+];
+
+/**
+ * The value of the opt-out fields once the reader opted out.
+ * @const {number}
+ */
+const GPP_OPTED_OUT = 1;
+
+/**
+ * Tells which purposes the reader consented to. The consent is taken from
+ * the `consent` config when the publisher sets it. Otherwise, it's read from
+ * the IAB TCF and GPP APIs of the consent management platforms of the page,
+ * and purposes are denied until the platforms answer. Pages without any
+ * consent management platform have full consent.
+ */
+export class ConsentManager {
+  /**
+   * @param {!./deps.DepsDef} deps
+   */
+  constructor(deps) {
+    /** @private @const {!./deps.DepsDef} */
+    this.deps_ = deps;
+
+    /** @private @const {!Window} */
+    this.win_ = deps.win();
+
+    /**
+     * The consent read from each consent management platform of the page,
+     * or null until it answers.
+     * @private @const {!Object<string, ?../api/subscriptions.ConsentState>}
+     */
+    this.platformStates_ = {};
+
+    /** @private @const {!Array<function(!../api/subscriptions.ConsentState)>} */
+    this.changeCallbacks_ = [];
+
+    /** @private {string} */
+    this.lastState_ = JSON.stringify(this.getState());
+
+    /**
+     * The IDs of the listeners added to each consent management platform,
+     * once they answer.
+     * @private @const {!Object<string, number>}
+     */
+    this.listenerIds_ = {};
+
+    /** @private {boolean} */
+    this.destroyed_ = false;
+
+    this.listenToTcf_();
+    this.listenToGpp_();
+  }
+
+  /**
+   * @param {!ConsentPurpose} purpose
+   * @return {boolean}
+   */
+  hasConsent(purpose) {
+    const consent = this.deps_.config().consent;
+    if (consent) {
+      return consent[purpose] === true;
+    }
+    for (const platform in this.platformStates_) {
+      const state = this.platformStates_[platform];
+      if (!state || !state[purpose]) {
+        return false;
+      }
+    }
+    return true;
+  }
+
+  /**
+   * @return {!../api/subscriptions.ConsentState}
+   */
+  getState() {
+    const state = {};
+    for (const purpose of Object.values(ConsentPurpose)) {
+      state[purpose] = this.hasConsent(purpose);
+    }
+    return state;
+  }
+
+  /**
+   * Adds a callback called with the new state when the consent changes.
+   * @param {function(!../api/subscriptions.ConsentState)} callback
+   */
+  onChange(callback) {
+    this.changeCallbacks_.push(callback);
+  }
+
+  /**
+   * Notifies the change callbacks if the consent changed, e.g. after the
+   * `consent` config was set.
+   */
+  update() {
+    const state = this.getState();
+    const serializedState = JSON.stringify(state);
+    if (serializedState == this.lastState_) {
+      return;
+    }
+    this.lastState_ = serializedState;
+    for (const callback of this.changeCallbacks_) {
+      callback(state);
+    }
+  }
+
+  /**
+   * Removes the listeners added to the consent management platforms and the
+   * change callbacks.
+   */
+  destroy() {
+    this.destroyed_ = true;
+    this.changeCallbacks_.length = 0;
+    for (const platform in this.listenerIds_) {
+      this.removeListener_(platform);
+    }
+  }
+
+  /**
+   * Keeps the ID of the listener added to a consent management platform, and
+   * removes the listener if destroyed in the meantime.
+   * @param {string} platform
+   * @param {*} listenerId
+   * @return {boolean} Whether the listener is still active.
+   * @private
+   */
+  trackListener_(platform, listenerId) {
+    if (typeof listenerId == 'number') {
+      this.listenerIds_[platform] = listenerId;
+    }
+    if (this.destroyed_) {
+      this.removeListener_(platform);
+      return false;
+    }
+    return true;
+  }
+
+  /**
+   * @param {string} platform
+   * @private
+   */
+  removeListener_(platform) {
+    const listenerId = this.listenerIds_[platform];
+    if (listenerId === undefined) {
+      return;
+    }
+    delete this.listenerIds_[platform];
+    if (platform == 'tcf') {
+      this.win_['__tcfapi'](
+        'removeEventListener',
+        TCF_API_VERSION,
+        () => {},
+        listenerId
+      );
+    } else {
+      this.win_['__gpp']('removeEventListener', () => {}, listenerId);
+    }
+  }
+
+  /** @private */
+  listenToTcf_() {
+    const tcfApi = this.win_['__tcfapi'];
+    if (typeof tcfApi != 'function') {
+      return;
+    }
+    this.platformStates_['tcf'] = null;
+    tcfApi('addEventListener', TCF_API_VERSION, (tcData, success) => {
+      if (!success || !tcData) {
+        return;
+      }
+      if (!this.trackListener_('tcf', tcData['listenerId'])) {
+        return;
+      }
+      if (tcData['gdprApplies'] === false) {
+        this.platformStates_['tcf'] = grantAll();
+      } else if (
+        tcData['eventStatus'] == 'tcloaded' ||
+        tcData['eventStatus'] == 'useractioncomplete'
+      ) {
+        const state = {};
+        for (const purpose in TCF_PURPOSES) {
+          state[purpose] = TCF_PURPOSES[purpose].every((id) =>
+            hasTcfConsent(tcData, id)
+          );
+        }
+        this.platformStates_['tcf'] = state;
+      } else {
+        // The consent UI is shown.
+        return;
+      }
+      this.update();
+    });
+  }
+
+  /** @private */
+  listenToGpp_() {
+    const gppApi = this.win_['__gpp'];
+    if (typeof gppApi != 'function') {
+      return;
+    }
+    this.platformStates_['gpp'] = null;
+    gppApi('addEventListener', (event, success) => {
+      if (
+        !success ||
+        !event ||
+        !this.trackListener_('gpp', event['listenerId'])
+      ) {
+        return;
+      }
+      const pingData = event['pingData'];
+      if (!pingData || pingData['signalStatus'] == 'not ready') {
+        return;
+      }
+      const sectionIds = pingData['applicableSections'] || [];
+      // Consent management platforms expose the TCF API along with the EU
+      // TCF section, which is then read like other TCF consent. Without it,
+      // the consent of the reader is unknown.
+      if (
+        sectionIds.includes(GPP_TCF_EU_SECTION) &&
+        typeof this.win_['__tcfapi'] != 'function'
+      ) {
+        this.platformStates_['gpp'] = denyAll();
+        this.update();
+        return;
+      }
+      const sectionNames = sectionIds
+        .map((id) => GPP_US_SECTIONS[id])
+        .filter(Boolean);
+      Promise.all(
+        sectionNames.map((name) => getGppSection(gppApi, pingData, name))
+      ).then((sections) => {
+        if (this.destroyed_) {
+          return;
+        }
+        const optedOut = sections.some(
+          (section) =>
+            isObject(section) &&
+            GPP_OPT_OUT_FIELDS.some((field) => section[field] == GPP_OPTED_OUT)
+        );
+        const state = grantAll();
+        state[ConsentPurpose.PERSONALIZATION] = !optedOut;
+        this.platformStates_['gpp'] = state;
+        this.update();
+      });
+    });
+  }
+}
+
+/**
+ * @param {*} value
+ * @return {boolean}
+ */
+export function isValidConsentState(value) {
+  if (!isObject(value)) {
+    return false;
+  }
+  const purposes = Object.values(ConsentPurpose);
+  return Object.keys(value).every(
+    (key) => purposes.includes(key) && typeof value[key] == 'boolean'
+  );
+}
+
+/**
+ * @return {!../api/subscriptions.ConsentState}
+ */
+function grantAll() {
+  const state = {};
+  for (const purpose of Object.values(ConsentPurpose)) {
+    state[purpose] = true;
+  }
+  return state;
+}
+
+/**
+ * @return {!../api/subscriptions.ConsentState}
+ */
+function denyAll() {
+  const state = {};
+  for (const purpose of Object.values(ConsentPurpose)) {
+    state[purpose] = false;
+  }
+  return state;
+}
+
+/**
+ * Whether the reader consented to a TCF purpose for Google, and the
+ * publisher lets Google base the purpose on consent.
+ * @param {!Object} tcData
+ * @param {number} purposeId
+ * @return {boolean}
+ */
+function hasTcfConsent(tcData, purposeId) {
+  const purposeConsents = (tcData['purpose'] || {})['consents'] || {};
+  const vendorConsents = (tcData['vendor'] || {})['consents'] || {};
+  const restrictions = (tcData['publisher'] || {})['restrictions'] || {};
+  const restriction = (restrictions[purposeId] || {})[GOOGLE_VENDOR_ID];
+  return (
+    !!purposeConsents[purposeId] &&
+    !!vendorConsents[GOOGLE_VENDOR_ID] &&
+    (restriction === undefined ||
+      restriction === TCF_RESTRICTION_REQUIRE_CONSENT)
+  );
+}
+
+/**
+ * Returns a section of the GPP string. Version 1.0 of the API has the
+ * sections in its ping data, while later versions return them on request.
+ * @param {function(...*)} gppApi
+ * @param {!Object} pingData
+ * @param {string} name
+ * @return {!Promise<*>}
+ */
+function getGppSection(gppApi, pingData, name) {
+  const promise = pingData['parsedSections']
+    ? Promise.resolve(pingData['parsedSections'][name])
+    : new Promise((resolve) => {
+        gppApi(
+          'getSection',
+          (data, success) => resolve(success ? data : null),
+          name
+        );
+      });
+  // Sections with subsections are returned as arrays, with the core
+  // subsection first.
+  return promise.then((section) =>
+    Array.isArray(section) ? section[0] : section
+  );
+}
@@ -252,6 +252,35 @@ describes.realWin('ContributionsFlow', {}, (env) => {
     await contributionsFlow.start();
   });
 
+  it('closes contributions when aborted', async () => {
+    const dialogManagerMock = sandbox.mock(runtime.dialogManager());
+    dialogManagerMock.expects('openView').resolves();
+    await contributionsFlow.start();
+    const activityIframeView =
+      await contributionsFlow.activityIframeViewPromise_;
+    const disconnectStub = sandbox.stub(activityIframeView, 'disconnect');
+    dialogManagerMock
+      .expects('completeView')
+      .withExactArgs(activityIframeView)
+      .once();
+
+    contributionsFlow.abort();
+
+    expect(disconnectStub).to.be.calledOnce;
+    dialogManagerMock.verify();
+  });
+
+  it('does not show contributions once aborted', async () => {
+    const dialogManagerMock = sandbox.mock(runtime.dialogManager());
+    callbacksMock.expects('triggerFlowStarted').never();
+    dialogManagerMock.expects('openView').never();
+
+    contributionsFlow.abort();
+    await contributionsFlow.start();
+
+    dialogManagerMock.verify();
+  });
+
   it('activates pay, login', async () => {
     const payStub = sandbox.stub(PayStartFlow.prototype, 'start');
     const loginStub = sandbox.stub(runtime.callbacks(), 'triggerLoginRequest');
@@ -53,6 +53,9 @@ export class ContributionsFlow {
 
     this.activityIframeView_ = null;
 
+    /** @private {boolean} */
+    this.aborted_ = false;
+
     // Default to showing close button.
     const isClosable = options?.isClosable ?? true;
 
@@ -85,9 +88,13 @@ export class ContributionsFlow {
    */
   handleLinkRequest_(response) {
     if (response.getSubscriberOrMember()) {
-      this.deps_.callbacks().triggerLoginRequest({
-        linkRequested: !!response.getLinkRequested(),
-      });
+      const linkRequested = !!response.getLinkRequested();
+      const callbacks = this.deps_.callbacks();
+      callbacks.triggerFlowAlreadySubscribed(
+        SubscriptionFlows.SHOW_CONTRIBUTION_OPTIONS,
+        linkRequested
+      );
+      callbacks.triggerLoginRequest({linkRequested});
     }
   }
 
@@ -119,7 +126,7 @@ export class ContributionsFlow {
    */
   start() {
     return this.activityIframeViewPromise_.then((activityIframeView) => {
-      if (!activityIframeView) {
+      if (!activityIframeView || this.aborted_) {
         return Promise.resolve();
       }
 
@@ -180,4 +187,17 @@ export class ContributionsFlow {
       this.activityIframeView_.execute(new EntitlementsResponse());
     }
   }
+
+  /**
+   * Closes the contribution options, or prevents them from being shown if
+   * they aren't yet.
+   */
+  abort() {
+    this.aborted_ = true;
+    if (this.activityIframeView_) {
+      this.dialogManager_.completeView(this.activityIframeView_);
+      this.activityIframeView_.disconnect();
+      this.activityIframeView_ = null;
+    }
+  }
 }
@@ -0,0 +1,193 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {CrossTabSync} from './cross-tab-sync';
+
+class FakeBroadcastChannel {
+  constructor(name) {
+    this.name = name;
+    this.onmessage = null;
+    this.posted = [];
+    this.closed = false;
+    FakeBroadcastChannel.instances.push(this);
+  }
+
+  postMessage(message) {
+    this.posted.push(message);
+  }
+
+  close() {
+    this.closed = true;
+  }
+}
+FakeBroadcastChannel.instances = [];
+
+describes.realWin('CrossTabSync', {}, () => {
+  let listener;
+
+  beforeEach(() => {
+    FakeBroadcastChannel.instances = [];
+    listener = sandbox.spy();
+  });
+
+  describe('with BroadcastChannel', () => {
+    let sync;
+    let channel;
+
+    beforeEach(() => {
+      sync = new CrossTabSync({BroadcastChannel: FakeBroadcastChannel});
+      sync.onChange(listener);
+      channel = FakeBroadcastChannel.instances[0];
+    });
+
+    it('should open a channel', () => {
+      expect(channel.name).to.equal('subscribe.google.com');
+    });
+
+    it('should post changes', () => {
+      sync.broadcast({key: 'ents', value: 'raw', useLocalStorage: false});
+
+      expect(channel.posted).to.deep.equal([
+        {'key': 'ents', 'value': 'raw', 'useLocalStorage': false},
+      ]);
+    });
+
+    it('should notify listeners of received changes', () => {
+      channel.onmessage({
+        data: {'key': 'USER_TOKEN', 'value': 'token', 'useLocalStorage': true},
+      });
+
+      expect(listener).to.be.calledOnceWithExactly({
+        key: 'USER_TOKEN',
+        value: 'token',
+        useLocalStorage: true,
+      });
+    });
+
+    it('should ignore malformed messages', () => {
+      channel.onmessage({data: null});
+      channel.onmessage({data: {'value': 'raw'}});
+
+      expect(listener).to.not.be.called;
+    });
+
+    it('should close the channel', () => {
+      sync.close();
+      sync.broadcast({key: 'ents', value: 'raw', useLocalStorage: false});
+
+      expect(channel.closed).to.be.true;
+      expect(channel.posted).to.be.empty;
+    });
+  });
+
+  describe('with storage events', () => {
+    let win;
+    let sync;
+
+    beforeEach(() => {
+      win = {
+        addEventListener: sandbox.spy(),
+        removeEventListener: sandbox.spy(),
+        localStorage: {setItem: sandbox.spy(), removeItem: sandbox.spy()},
+      };
+      sync = new CrossTabSync(win);
+      sync.onChange(listener);
+    });
+
+    function dispatchStorageEvent(event) {
+      win.addEventListener.args[0][1](event);
+    }
+
+    it('should relay changes through local storage', () => {
+      sync.broadcast({key: 'ents', value: null, useLocalStorage: false});
+
+      expect(win.localStorage.setItem).to.be.calledOnce;
+      const args = win.localStorage.setItem.args[0];
+      expect(args[0]).to.equal('subscribe.google.com:sync');
+      const message = JSON.parse(args[1]);
+      expect(message['key']).to.equal('ents');
+      expect(message['value']).to.be.null;
+      expect(message['id']).to.be.a('string');
+      expect(win.localStorage.removeItem).to.be.calledOnceWith(
+        'subscribe.google.com:sync'
+      );
+      expect(win.localStorage.removeItem).to.be.calledAfter(
+        win.localStorage.setItem
+      );
+    });
+
+    it('should not relay changes without consent to storage', () => {
+      let hasConsent = false;
+      const consentManager = {
+        hasConsent: (purpose) => purpose == 'storage' && hasConsent,
+      };
+      sync = new CrossTabSync(win, consentManager);
+
+      sync.broadcast({key: 'ents', value: 'raw', useLocalStorage: false});
+      expect(win.localStorage.setItem).to.not.be.called;
+
+      hasConsent = true;
+      sync.broadcast({key: 'ents', value: 'raw', useLocalStorage: false});
+      expect(win.localStorage.setItem).to.be.calledOnce;
+    });
+
+    it('should not throw if local storage fails', () => {
+      win.localStorage.setItem = () => {
+        throw new Error('QuotaExceededError');
+      };
+
+      expect(() =>
+        sync.broadcast({key: 'ents', value: 'raw', useLocalStorage: false})
+      ).to.not.throw();
+    });
+
+    it('should notify listeners of relayed changes', () => {
+      expect(win.addEventListener).to.be.calledWith('storage');
+
+      dispatchStorageEvent({
+        key: 'subscribe.google.com:sync',
+        newValue: JSON.stringify({
+          'key': 'ents',
+          'value': 'raw',
+          'useLocalStorage': false,
+          'id': '1',
+        }),
+      });
+
+      expect(listener).to.be.calledOnceWithExactly({
+        key: 'ents',
+        value: 'raw',
+        useLocalStorage: false,
+      });
+    });
+
+    it('should ignore other storage events', () => {
+      dispatchStorageEvent({key: 'other', newValue: '{"key": "ents"}'});
+      dispatchStorageEvent({key: 'subscribe.google.com:sync', newValue: null});
+
+      expect(listener).to.not.be.called;
+    });
+
+    it('should stop listening when closed', () => {
+      sync.close();
+
+      expect(win.removeEventListener).to.be.calledWith(
+        'storage',
+        win.addEventListener.args[0][1]
+      );
+    });
+  });
+});
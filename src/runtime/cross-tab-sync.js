@@ -0,0 +1,164 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {ConsentPurpose} from '../api/subscriptions';
+import {getUuid} from '../utils/string';
+import {tryParseJson} from '../utils/json';
+
+const CHANNEL_NAME = 'subscribe.google.com';
+
+/**
+ * Key of the local storage entry used to relay messages when
+ * BroadcastChannel isn't supported. The entry is removed right after it's
+ * written, so that entitlements and tokens don't outlive the session.
+ */
+const RELAY_STORAGE_KEY = 'subscribe.google.com:sync';
+
+/**
+ * A storage change made in another tab.
+ * - key: The storage key, without prefix.
+ * - value: The new value, or null if the key was removed.
+ * - useLocalStorage: Whether the key lives in local storage.
+ *
+ * @typedef {{
+ *   key: string,
+ *   value: ?string,
+ *   useLocalStorage: boolean,
+ * }}
+ */
+export let StorageChange;
+
+/**
+ * Relays storage changes between tabs of the same origin. Uses
+ * BroadcastChannel where available, and falls back to `storage` events on a
+ * local storage relay entry otherwise. The relay entry is only written once
+ * the reader consents to storage.
+ */
+export class CrossTabSync {
+  /**
+   * @param {!Window} win
+   * @param {?./consent-manager.ConsentManager=} consentManager
+   */
+  constructor(win, consentManager = null) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {?./consent-manager.ConsentManager} */
+    this.consentManager_ = consentManager;
+
+    /** @private @const {!Array<function(!StorageChange)>} */
+    this.listeners_ = [];
+
+    /** @private {?BroadcastChannel} */
+    this.channel_ = null;
+
+    /** @private @const {function(!Event)} */
+    this.onStorageEvent_ = this.handleStorageEvent_.bind(this);
+
+    if (typeof this.win_.BroadcastChannel == 'function') {
+      this.channel_ = new this.win_.BroadcastChannel(CHANNEL_NAME);
+      this.channel_.onmessage = (event) => {
+        this.handleMessage_(event.data);
+      };
+    } else {
+      this.win_.addEventListener('storage', this.onStorageEvent_);
+    }
+  }
+
+  /**
+   * Notifies other tabs of a storage change.
+   * @param {!StorageChange} change
+   */
+  broadcast(change) {
+    const message = {
+      'key': change.key,
+      'value': change.value,
+      'useLocalStorage': change.useLocalStorage,
+    };
+    try {
+      if (this.channel_) {
+        this.channel_.postMessage(message);
+      } else if (
+        !this.consentManager_ ||
+        this.consentManager_.hasConsent(ConsentPurpose.STORAGE)
+      ) {
+        // A unique ID guarantees a `storage` event even if the same change is
+        // relayed twice.
+        message['id'] = getUuid();
+        const localStorage = this.win_.localStorage;
+        try {
+          localStorage.setItem(RELAY_STORAGE_KEY, JSON.stringify(message));
+        } finally {
+          // Other tabs are notified of the write even if the entry is gone.
+          localStorage.removeItem(RELAY_STORAGE_KEY);
+        }
+      }
+    } catch (e) {
+      // Cross-tab sync is best-effort.
+    }
+  }
+
+  /**
+   * Registers a listener for storage changes made in other tabs.
+   * @param {function(!StorageChange)} callback
+   */
+  onChange(callback) {
+    this.listeners_.push(callback);
+  }
+
+  /**
+   * Stops relaying changes.
+   */
+  close() {
+    this.listeners_.length = 0;
+    if (this.channel_) {
+      this.channel_.close();
+      this.channel_ = null;
+    } else {
+      this.win_.removeEventListener('storage', this.onStorageEvent_);
+    }
+  }
+
+  /**
+   * @param {!Event} event
+   * @private
+   */
+  handleStorageEvent_(event) {
+    const storageEvent = /** @type {!StorageEvent} */ (event);
+    if (storageEvent.key != RELAY_STORAGE_KEY || !storageEvent.newValue) {
+      return;
+    }
+    this.handleMessage_(tryParseJson(storageEvent.newValue));
+  }
+
+  /**
+   * @param {*} message
+   * @private
+   */
+  handleMessage_(message) {
+    if (!message || typeof message['key'] != 'string') {
+      return;
+    }
+    const change = {
+      key: message['key'],
+      value: message['value'] == null ? null : String(message['value']),
+      useLocalStorage: !!message['useLocalStorage'],
+    };
+    for (const listener of this.listeners_) {
+      listener(change);
+    }
+  }
+}
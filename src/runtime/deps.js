@@ -61,6 +61,21 @@ export class DepsDef {
    */
   callbacks() {}
 
+  /**
+   * @return {!./flow-controller.FlowController}
+   */
+  flowController() {}
+
+  /**
+   * @return {!./diagnostics.DiagnosticsRecorder}
+   */
+  diagnostics() {}
+
+  /**
+   * @return {!./consent-manager.ConsentManager}
+   */
+  consentManager() {}
+
   /**
    * @return {!../runtime/storage.Storage}
    */
@@ -85,4 +100,9 @@ export class DepsDef {
    * @return {!../runtime/client-config-manager.ClientConfigManager}
    */
   clientConfigManager() {}
+
+  /**
+   * @return {!../utils/performance.PerformanceTimings}
+   */
+  performanceTimings() {}
 }
@@ -0,0 +1,229 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsEvent, EventOriginator} from '../proto/api_messages';
+import {getEnumName} from '../utils/types';
+import {parseUrl} from '../utils/url';
+
+/**
+ * The number of entries kept. Older entries are dropped first.
+ * @const {number}
+ */
+const MAX_ENTRIES = 200;
+
+/**
+ * Nested values deeper than this aren't recorded.
+ * @const {number}
+ */
+const MAX_DEPTH = 8;
+
+/**
+ * Properties whose values are redacted, e.g. "subscriptionToken".
+ * @const {!RegExp}
+ */
+const REDACTED_PROPERTY_REGEX = /token|jwt|signature|signed|purchaseData|key/i;
+
+/**
+ * JWTs in other values, e.g. in URLs.
+ * @const {!RegExp}
+ */
+const JWT_REGEX = /eyJ[\w-]*\.[\w-]+\.[\w-]*/g;
+
+/** @const {string} */
+const REDACTED = '[redacted]';
+
+/** @enum {string} */
+export const DiagnosticType = {
+  // An event logged to the event manager.
+  EVENT: 'event',
+  // A request to the server, with its duration.
+  FETCH: 'fetch',
+  // The client config.
+  CONFIG: 'config',
+  // The entitlements served to the page.
+  ENTITLEMENTS: 'entitlements',
+  // A change of state of a flow.
+  FLOW: 'flow',
+  // The dialog was opened or closed.
+  DIALOG: 'dialog',
+};
+
+/**
+ * @typedef {{
+ *   time: number,
+ *   type: !DiagnosticType,
+ *   data: *,
+ * }}
+ */
+export let DiagnosticEntry;
+
+/**
+ * Records what happens on the page, so that support staff can understand
+ * issues reported by readers, e.g. a paywall that stayed after a purchase.
+ * Nothing is recorded unless the `enableDiagnostics` config is set. Only the
+ * latest entries are kept, and tokens are redacted.
+ */
+export class DiagnosticsRecorder {
+  /**
+   * @param {!./deps.DepsDef} deps
+   */
+  constructor(deps) {
+    /** @private @const {!./deps.DepsDef} */
+    this.deps_ = deps;
+
+    /** @private @const {!Array<!DiagnosticEntry>} */
+    this.entries_ = [];
+
+    /** @private @const {number} */
+    this.startTime_ = Date.now();
+
+    deps.eventManager().registerEventListener((event) =>
+      this.record(DiagnosticType.EVENT, {
+        'eventType': getEnumName(AnalyticsEvent, event.eventType),
+        'eventOriginator': getEnumName(EventOriginator, event.eventOriginator),
+        'isFromUserAction': event.isFromUserAction,
+      })
+    );
+    const dialogManager = deps.dialogManager();
+    /** @private @const {!Array<function()>} */
+    this.removeDialogCallbacks_ = [
+      dialogManager.onOpen(() =>
+        this.record(DiagnosticType.DIALOG, {'action': 'open'})
+      ),
+      dialogManager.onClose(() =>
+        this.record(DiagnosticType.DIALOG, {'action': 'close'})
+      ),
+    ];
+  }
+
+  /**
+   * Stops recording the dialog, which may be shared with other runtimes.
+   */
+  destroy() {
+    this.removeDialogCallbacks_.forEach((remove) => remove());
+  }
+
+  /**
+   * @return {boolean}
+   */
+  isEnabled() {
+    return !!this.deps_.config().enableDiagnostics;
+  }
+
+  /**
+   * Adds an entry, if enabled, and drops the oldest entry if there are too
+   * many. The data can be given as a function, which is only called if
+   * enabled.
+   * @param {!DiagnosticType} type
+   * @param {*} data
+   */
+  record(type, data) {
+    if (!this.isEnabled()) {
+      return;
+    }
+    this.entries_.push({
+      time: Date.now() - this.startTime_,
+      type,
+      data: redact(typeof data == 'function' ? data() : data),
+    });
+    if (this.entries_.length > MAX_ENTRIES) {
+      this.entries_.shift();
+    }
+  }
+
+  /**
+   * @return {!Array<!DiagnosticEntry>}
+   */
+  getEntries() {
+    return this.entries_.slice();
+  }
+
+  /**
+   * Exports the entries, along with the page they were recorded on, as JSON.
+   * @return {string}
+   */
+  export() {
+    const url = parseUrl(this.deps_.win().location.href);
+    return JSON.stringify({
+      'version': '$internalRuntimeVersion$',
+      'publicationId': this.deps_.pageConfig().getPublicationId(),
+      // The query and fragment are left out, since they can have tokens.
+      'page': url.origin + url.pathname,
+      'startedAt': new Date(this.startTime_).toISOString(),
+      'exportedAt': new Date().toISOString(),
+      'entries': this.entries_,
+    });
+  }
+}
+
+/**
+ * Records an entry with the recorder of the deps, if any.
+ * @param {!./deps.DepsDef} deps
+ * @param {!DiagnosticType} type
+ * @param {*} data
+ */
+export function recordDiagnostic(deps, type, data) {
+  const recorder = deps.diagnostics();
+  if (recorder) {
+    recorder.record(type, data);
+  }
+}
+
+/**
+ * Records a request to the server that started at the given time.
+ * @param {!./deps.DepsDef} deps
+ * @param {string} request
+ * @param {number} startTime
+ * @param {!Error=} error
+ */
+export function recordFetch(deps, request, startTime, error = undefined) {
+  recordDiagnostic(deps, DiagnosticType.FETCH, {
+    'request': request,
+    'durationMs': Date.now() - startTime,
+    'error': error ? error.code || error.name : undefined,
+  });
+}
+
+/**
+ * Returns a copy of the value without tokens. The values of the properties
+ * that hold tokens are replaced, as well as the JWTs found in strings.
+ * Functions are left out.
+ * @param {*} value
+ * @param {number=} depth
+ * @return {*}
+ */
+export function redact(value, depth = 0) {
+  if (typeof value == 'string') {
+    return value.replace(JWT_REGEX, REDACTED);
+  }
+  if (!value || typeof value != 'object') {
+    return typeof value == 'function' ? undefined : value;
+  }
+  if (depth >= MAX_DEPTH) {
+    return undefined;
+  }
+  if (Array.isArray(value)) {
+    return value.map((item) => redact(item, depth + 1));
+  }
+  const copy = {};
+  for (const key of Object.keys(value)) {
+    copy[key] =
+      REDACTED_PROPERTY_REGEX.test(key) && value[key] != null
+        ? REDACTED
+        : redact(value[key], depth + 1);
+  }
+  return copy;
+}
@@ -0,0 +1,200 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {DisplayTrigger} from './display-trigger';
+
+describes.realWin('DisplayTrigger', {}, () => {
+  let clock;
+  let win;
+  let doc;
+  let observers;
+  let callback;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    observers = [];
+    doc = new EventTarget();
+    doc.documentElement = {scrollHeight: 2000};
+    doc.querySelector = sandbox.stub().returns(null);
+    win = new EventTarget();
+    Object.assign(win, {
+      document: doc,
+      pageYOffset: 0,
+      innerHeight: 500,
+      setTimeout: (fn, delay) => setTimeout(fn, delay),
+      clearTimeout: (id) => clearTimeout(id),
+      IntersectionObserver: class {
+        constructor(fn) {
+          this.fn = fn;
+          this.disconnect = sandbox.spy();
+          observers.push(this);
+        }
+
+        observe(element) {
+          this.element = element;
+        }
+      },
+    });
+    callback = sandbox.spy();
+  });
+
+  function start(triggers) {
+    const trigger = new DisplayTrigger(win, triggers);
+    trigger.start(callback);
+    return trigger;
+  }
+
+  function scrollTo(pageYOffset) {
+    win.pageYOffset = pageYOffset;
+    win.dispatchEvent(new Event('scroll'));
+  }
+
+  it('should fire after the display delay without engagement triggers', () => {
+    start({displayDelaySeconds: 2});
+
+    clock.tick(1999);
+    expect(callback).to.not.be.called;
+    clock.tick(1);
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should fire on scroll depth', () => {
+    start({scrollDepthPercent: 50});
+    clock.tick(0);
+
+    scrollTo(400);
+    expect(callback).to.not.be.called;
+    scrollTo(500);
+    expect(callback).to.be.calledOnce;
+    scrollTo(1500);
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should fire right away if the page is already scrolled deep enough', () => {
+    win.pageYOffset = 1500;
+    start({scrollDepthPercent: 50, exitIntent: true});
+    clock.tick(0);
+
+    expect(callback).to.be.calledOnce;
+    doc.dispatchEvent(new MouseEvent('mouseout', {clientY: 0}));
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should wait for the display delay before checking triggers', () => {
+    start({displayDelaySeconds: 1, scrollDepthPercent: 50});
+
+    scrollTo(1500);
+    expect(callback).to.not.be.called;
+    clock.tick(1000);
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should fire when the element becomes visible', () => {
+    const element = {};
+    doc.querySelector.withArgs('#paywall').returns(element);
+    start({visibleElementSelector: '#paywall'});
+    clock.tick(0);
+
+    expect(observers[0].element).to.equal(element);
+    observers[0].fn([{isIntersecting: false}]);
+    expect(callback).to.not.be.called;
+    observers[0].fn([{isIntersecting: true}]);
+    expect(callback).to.be.calledOnce;
+    expect(observers[0].disconnect).to.be.calledOnce;
+  });
+
+  it('should fire right away if no element matches the selector', () => {
+    start({visibleElementSelector: '#missing'});
+    clock.tick(0);
+
+    expect(observers).to.be.empty;
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should wait for the other triggers if no element matches', () => {
+    start({visibleElementSelector: '#missing', exitIntent: true});
+    clock.tick(0);
+
+    expect(callback).to.not.be.called;
+    doc.dispatchEvent(new MouseEvent('mouseout', {clientY: 0}));
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should listen to scrolling passively', () => {
+    const addEventListenerSpy = sandbox.spy(win, 'addEventListener');
+    start({scrollDepthPercent: 50});
+    clock.tick(0);
+
+    const call = addEventListenerSpy
+      .getCalls()
+      .find((call) => call.args[0] == 'scroll');
+    expect(call.args[2]).to.deep.equal({passive: true});
+  });
+
+  it('should fire on exit intent', () => {
+    start({exitIntent: true});
+    clock.tick(0);
+
+    doc.dispatchEvent(new MouseEvent('mouseout', {clientY: 200}));
+    expect(callback).to.not.be.called;
+    doc.dispatchEvent(new MouseEvent('mouseout', {clientY: 0}));
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should fire once the reader is idle', () => {
+    start({idleSeconds: 10});
+    clock.tick(0);
+
+    clock.tick(9000);
+    win.dispatchEvent(new Event('keydown'));
+    clock.tick(9000);
+    expect(callback).to.not.be.called;
+    clock.tick(1000);
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should fire on the first of several triggers', () => {
+    start({scrollDepthPercent: 90, idleSeconds: 5});
+    clock.tick(0);
+
+    clock.tick(5000);
+    expect(callback).to.be.calledOnce;
+    scrollTo(1500);
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should not fire once cancelled', () => {
+    const trigger = start({
+      displayDelaySeconds: 1,
+      scrollDepthPercent: 50,
+      idleSeconds: 5,
+    });
+    clock.tick(1000);
+
+    trigger.cancel();
+    scrollTo(1500);
+    clock.tick(5000);
+    expect(callback).to.not.be.called;
+  });
+
+  it('should not fire if cancelled during the display delay', () => {
+    const trigger = start({displayDelaySeconds: 1});
+
+    trigger.cancel();
+    clock.tick(1000);
+    expect(callback).to.not.be.called;
+  });
+});
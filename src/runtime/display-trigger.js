@@ -0,0 +1,225 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {warn} from '../utils/log';
+
+const SECOND_IN_MILLIS = 1000;
+
+/** Events that reset the idle timer. */
+const ACTIVITY_EVENTS = ['scroll', 'mousemove', 'keydown', 'touchstart'];
+
+/**
+ * Waits for the display triggers of the auto prompt. After the display delay,
+ * the prompt is displayed as soon as any of the configured engagement triggers
+ * fires, or right away if none is configured.
+ */
+export class DisplayTrigger {
+  /**
+   * @param {!Window} win
+   * @param {!../api/basic-subscriptions.AutoPromptTriggers} triggers
+   */
+  constructor(win, triggers) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {!../api/basic-subscriptions.AutoPromptTriggers} */
+    this.triggers_ = triggers;
+
+    /**
+     * Functions undoing the timers and listeners set up so far.
+     * @private {!Array<function()>}
+     */
+    this.cleanups_ = [];
+
+    /** @private {?function()} */
+    this.callback_ = null;
+  }
+
+  /**
+   * Calls the callback once the triggers fire. The callback is never called
+   * if the trigger is cancelled first.
+   * @param {function()} callback
+   */
+  start(callback) {
+    this.callback_ = callback;
+    this.setTimeout_(
+      () => this.listen_(),
+      (this.triggers_.displayDelaySeconds || 0) * SECOND_IN_MILLIS
+    );
+  }
+
+  /**
+   * Stops waiting for the triggers, for example when the reader navigates to
+   * another page of a single page application.
+   */
+  cancel() {
+    this.callback_ = null;
+    this.cleanup_();
+  }
+
+  /** @private */
+  listen_() {
+    const {
+      scrollDepthPercent,
+      visibleElementSelector,
+      exitIntent,
+      idleSeconds,
+    } = this.triggers_;
+    const listeners = [];
+    if (scrollDepthPercent !== undefined) {
+      listeners.push(() => this.listenToScrollDepth_(scrollDepthPercent));
+    }
+    if (visibleElementSelector) {
+      listeners.push(() =>
+        this.listenToElementVisibility_(visibleElementSelector)
+      );
+    }
+    if (exitIntent) {
+      listeners.push(() => this.listenToExitIntent_());
+    }
+    if (idleSeconds !== undefined) {
+      listeners.push(() => this.listenToIdleness_(idleSeconds));
+    }
+    // Triggers can fire while listening, e.g. if the page is already scrolled
+    // deep enough. The remaining ones aren't needed anymore then.
+    let listening = false;
+    for (let i = 0; i < listeners.length && this.callback_; i++) {
+      if (listeners[i]() !== false) {
+        listening = true;
+      }
+    }
+    // Without any trigger to wait for, the prompt would never be displayed.
+    if (!listening) {
+      this.fire_();
+    }
+  }
+
+  /**
+   * @param {number} percent
+   * @private
+   */
+  listenToScrollDepth_(percent) {
+    const onScroll = () => {
+      const doc = this.win_.document.documentElement;
+      const scrolled = this.win_.pageYOffset + this.win_.innerHeight;
+      if (scrolled >= (doc.scrollHeight * percent) / 100) {
+        this.fire_();
+      }
+    };
+    this.addEventListener_(this.win_, 'scroll', onScroll, {passive: true});
+    onScroll();
+  }
+
+  /**
+   * @param {string} selector
+   * @return {boolean} Whether the trigger is set up. It isn't if no element
+   *     matches the selector.
+   * @private
+   */
+  listenToElementVisibility_(selector) {
+    const element = this.win_.document.querySelector(selector);
+    if (!element) {
+      warn(`[swg.js:autoPrompt] No element matches "${selector}"`);
+      return false;
+    }
+    if (!this.win_.IntersectionObserver) {
+      this.fire_();
+      return true;
+    }
+    const observer = new this.win_.IntersectionObserver((entries) => {
+      if (entries.some((entry) => entry.isIntersecting)) {
+        this.fire_();
+      }
+    });
+    observer.observe(element);
+    this.cleanups_.push(() => observer.disconnect());
+    return true;
+  }
+
+  /**
+   * Fires when the pointer leaves the window through its top edge, towards
+   * the tabs and address bar.
+   * @private
+   */
+  listenToExitIntent_() {
+    this.addEventListener_(this.win_.document, 'mouseout', (event) => {
+      if (!event.relatedTarget && event.clientY <= 0) {
+        this.fire_();
+      }
+    });
+  }
+
+  /**
+   * @param {number} seconds
+   * @private
+   */
+  listenToIdleness_(seconds) {
+    let timeoutId;
+    const restart = () => {
+      this.win_.clearTimeout(timeoutId);
+      timeoutId = this.win_.setTimeout(
+        () => this.fire_(),
+        seconds * SECOND_IN_MILLIS
+      );
+    };
+    ACTIVITY_EVENTS.forEach((type) => {
+      this.addEventListener_(this.win_, type, restart, {passive: true});
+    });
+    this.cleanups_.push(() => this.win_.clearTimeout(timeoutId));
+    restart();
+  }
+
+  /**
+   * Calls the callback, unless it was already called or cancelled.
+   * @private
+   */
+  fire_() {
+    const callback = this.callback_;
+    this.cancel();
+    if (callback) {
+      callback();
+    }
+  }
+
+  /**
+   * @param {!EventTarget} target
+   * @param {string} type
+   * @param {function(!Event)} listener
+   * @param {!AddEventListenerOptions=} options
+   * @private
+   */
+  addEventListener_(target, type, listener, options) {
+    target.addEventListener(type, listener, options);
+    this.cleanups_.push(() => target.removeEventListener(type, listener));
+  }
+
+  /**
+   * @param {function()} callback
+   * @param {number} delayMs
+   * @private
+   */
+  setTimeout_(callback, delayMs) {
+    const timeoutId = this.win_.setTimeout(callback, delayMs);
+    this.cleanups_.push(() => this.win_.clearTimeout(timeoutId));
+  }
+
+  /** @private */
+  cleanup_() {
+    const cleanups = this.cleanups_;
+    this.cleanups_ = [];
+    cleanups.forEach((cleanup) => cleanup());
+  }
+}
@@ -27,7 +27,9 @@ import {
 } from '../proto/api_messages';
 import {AnalyticsService} from './analytics-service';
 import {Callbacks} from './callbacks';
+import {ClientConfigManager} from './client-config-manager';
 import {ClientEventManager} from './client-event-manager';
+import {ConsentManager} from './consent-manager';
 import {Constants} from '../utils/constants';
 import {DepsDef} from './deps';
 import {DialogManager} from '../components/dialog-manager';
@@ -38,15 +40,22 @@ import {
   PRIVILEGED_SOURCE,
 } from '../api/entitlements';
 import {EntitlementsManager} from './entitlements-manager';
+import {FlowController} from './flow-controller';
 import {GlobalDoc} from '../model/doc';
+import {JwtHelper} from '../utils/jwt';
+import {JwtVerifier} from './jwt-verifier';
+import {MeterToastApi} from './meter-toast-api';
 import {PageConfig} from '../model/page-config';
+import {PerformanceTimings} from '../utils/performance';
 import {Storage} from './storage';
+import {SwgErrorCode} from '../utils/errors';
 import {Toast} from '../ui/toast';
 import {XhrFetcher} from './fetcher';
 import {analyticsEventToEntitlementResult} from './event-type-mapping';
 import {base64UrlEncodeFromBytes, utf8EncodeSync} from '../utils/bytes';
 import {defaultConfig} from '../api/subscriptions';
 import {serializeProtoMessageForUrl} from '../utils/url';
+import {tick} from '../../test/tick';
 
 const ENTITLEMENTS_URL =
   '$frontend$/swg/_/api/v1/publication/pub1/entitlements';
@@ -72,6 +81,7 @@ describes.realWin('EntitlementsManager', {}, (env) => {
   let dialogManagerMock;
   let eventManager;
   let eventManagerMock;
+  let verifyStub;
 
   beforeEach(() => {
     // Work around `location.search` being non-configurable,
@@ -101,8 +111,13 @@ describes.realWin('EntitlementsManager', {}, (env) => {
     sandbox.stub(deps, 'storage').returns(storage);
     sandbox.stub(deps, 'pageConfig').returns(pageConfig);
     sandbox.stub(deps, 'config').returns(config);
+    sandbox.stub(deps, 'consentManager').returns(new ConsentManager(deps));
     sandbox.stub(deps, 'eventManager').returns(eventManager);
     sandbox.stub(deps, 'dialogManager').returns(dialogManager);
+    sandbox.stub(deps, 'flowController').returns(new FlowController(deps));
+    sandbox
+      .stub(deps, 'performanceTimings')
+      .returns(new PerformanceTimings(win));
     const activityPorts = new ActivityPorts(deps);
     activitiesMock = sandbox.mock(activityPorts);
     sandbox.stub(deps, 'activities').returns(activityPorts);
@@ -113,6 +128,8 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       .returns(new AnalyticsContext());
     sandbox.stub(deps, 'analytics').returns(analyticsService);
 
+    // Signatures of cached entitlements are verified in their own tests.
+    verifyStub = sandbox.stub(JwtVerifier.prototype, 'verify').resolves(true);
     manager = new EntitlementsManager(win, pageConfig, fetcher, deps);
     jwtHelperMock = sandbox.mock(manager.jwtHelper_);
     encryptedDocumentKey =
@@ -160,12 +177,15 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       },
       options
     );
-    const header = {};
+    const header = options.kid ? {'kid': options.kid} : {};
     const payload = {
       'iss': 'google.com',
       'exp': options.exp,
       'entitlements': entitlements,
     };
+    if (options.iat !== undefined) {
+      payload['iat'] = options.iat;
+    }
     return {
       'signedEntitlements': enc(header) + '.' + enc(payload) + '.SIG',
       'isReadyToPay': isReadyToPay,
@@ -817,6 +837,35 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       expect(manager.positiveRetries_).to.equal(3);
     });
 
+    it('should re-fetch for the new article after navigation', async () => {
+      xhrMock
+        .expects('fetch')
+        .returns(
+          Promise.resolve({
+            text: () => Promise.resolve('{}'),
+          })
+        )
+        .twice();
+
+      expectLog(AnalyticsEvent.ACTION_GET_ENTITLEMENTS, false);
+      expectLog(AnalyticsEvent.EVENT_NO_ENTITLEMENTS, false);
+      expectGetSwgUserTokenToBeCalled();
+
+      await manager.getEntitlements();
+
+      expectLog(AnalyticsEvent.ACTION_GET_ENTITLEMENTS, false);
+      expectLog(AnalyticsEvent.EVENT_NO_ENTITLEMENTS, false);
+      expectGetSwgUserTokenToBeCalled();
+
+      expect(manager.navigate(new PageConfig('pub1:label2'))).to.be.true;
+      const entitlements = await manager.getEntitlements();
+      expect(entitlements.product_).to.equal('pub1:label2');
+    });
+
+    it('should not report entitlements requests of previous articles', () => {
+      expect(manager.navigate(new PageConfig('pub1:label2'))).to.be.false;
+    });
+
     it('should fetch with positive expectation with one attempt', async () => {
       xhrMock
         .expects('fetch')
@@ -851,6 +900,8 @@ describes.realWin('EntitlementsManager', {}, (env) => {
     });
 
     it('should fetch with positive expectation with two attempts', async () => {
+      // Without jitter, retries back off from 500ms.
+      sandbox.stub(Math, 'random').returns(0);
       let totalTime = 0;
       sandbox.stub(win, 'setTimeout').callsFake((callback, timeout) => {
         totalTime += timeout;
@@ -907,6 +958,8 @@ describes.realWin('EntitlementsManager', {}, (env) => {
     });
 
     it('should fetch with positive expectation with max attempts', async () => {
+      // Without jitter, retries back off from 500ms.
+      sandbox.stub(Math, 'random').returns(0);
       let totalTime = 0;
       sandbox.stub(win, 'setTimeout').callsFake((callback, timeout) => {
         totalTime += timeout;
@@ -941,6 +994,16 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       expect(totalTime).to.be.greaterThan(999);
     });
 
+    it('should not refetch failed fetches after a purchase', async () => {
+      const setTimeoutSpy = sandbox.spy(win, 'setTimeout');
+      xhrMock.expects('fetch').rejects(new Error('broken')).once();
+      expectGetSwgUserTokenToBeCalled();
+      manager.reset(true);
+
+      await expect(manager.getEntitlements()).to.be.rejectedWith('broken');
+      expect(setTimeoutSpy).to.not.be.called;
+    });
+
     it('should re-fetch after clear', async () => {
       xhrMock
         .expects('fetch')
@@ -973,6 +1036,7 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       storageMock.expects('remove').withExactArgs('ents').once();
       storageMock.expects('remove').withExactArgs('toast').once();
       storageMock.expects('remove').withExactArgs('isreadytopay').once();
+      storageMock.expects('remove').withExactArgs('entssnapshot', true).once();
 
       manager.clear();
       expect(manager.positiveRetries_).to.equal(0);
@@ -1192,6 +1256,36 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       manager.consume_(ents);
     });
 
+    it('should remove the meter toast listeners once destroyed', () => {
+      sandbox.stub(MeterToastApi.prototype, 'start').resolves();
+      const removeStub = sandbox.stub(
+        MeterToastApi.prototype,
+        'removeCloseEventListener'
+      );
+      jwtHelperMock
+        .expects('decode')
+        .withExactArgs('token1')
+        .returns({
+          metering: {
+            ownerId: 'scenic-2017.appspot.com',
+            action: 'READ',
+            clientUserAttribute: 'standard_registered_user',
+          },
+        });
+      const ents = new Entitlements(
+        'service1',
+        'RaW',
+        [new Entitlement(GOOGLE_METERING_SOURCE, ['product1'], 'token1')],
+        'product1'
+      );
+      manager.consume_(ents);
+
+      manager.destroy();
+      manager.destroy();
+
+      expect(removeStub).to.be.calledOnce;
+    });
+
     it('should not open metering dialog when metering entitlements are consumed and showToast is false', () => {
       sandbox.stub(fetcher.xhr_, 'fetch').resolves();
       dialogManagerMock.expects('openDialog').never();
@@ -1362,7 +1456,7 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       await manager.consumeMeter_(ents);
     });
 
-    it('should log error messages from entitlements server', async () => {
+    it('should report error messages from entitlements server', async () => {
       xhrMock
         .expects('fetch')
         .withExactArgs(
@@ -1384,7 +1478,13 @@ describes.realWin('EntitlementsManager', {}, (env) => {
           })
         );
       expectGetSwgUserTokenToBeCalled();
+      const triggerErrorStub = sandbox.stub(callbacks, 'triggerError');
       await manager.getEntitlements();
+      expect(triggerErrorStub).to.be.calledOnce;
+      const error = triggerErrorStub.args[0][0];
+      expect(error.code).to.equal(SwgErrorCode.ENTITLEMENTS_SERVER_ERROR);
+      expect(error.message).to.equal('Something went wrong');
+      expect(error.retryable).to.be.false;
       expect(self.console.warn).to.have.been.calledWithExactly(
         'SwG Entitlements: Something went wrong'
       );
@@ -1483,6 +1583,44 @@ describes.realWin('EntitlementsManager', {}, (env) => {
         'getArticle should return the article endpoint response'
       );
     });
+
+    it('should provide the article to the client config manager', async () => {
+      manager = new EntitlementsManager(
+        win,
+        pageConfig,
+        fetcher,
+        deps,
+        /* useArticleEndpoint */ true
+      );
+      const clientConfigManager = new ClientConfigManager(
+        deps,
+        'pub1',
+        fetcher
+      );
+      sandbox.stub(deps, 'clientConfigManager').returns(clientConfigManager);
+      const setArticleSpy = sandbox.spy(clientConfigManager, 'setArticle');
+      const article = {
+        entitlements: {},
+        clientConfig: {paySwgVersion: '2'},
+      };
+      xhrMock
+        .expects('fetch')
+        .returns(
+          Promise.resolve({
+            text: () => Promise.resolve(JSON.stringify(article)),
+          })
+        )
+        .once();
+      expectLog(AnalyticsEvent.ACTION_GET_ENTITLEMENTS, false);
+      expectLog(AnalyticsEvent.EVENT_NO_ENTITLEMENTS, false);
+      expectGetSwgUserTokenToBeCalled();
+
+      await manager.getEntitlements();
+
+      expect(setArticleSpy).to.be.calledOnceWithExactly(article);
+      const clientConfig = await clientConfigManager.getClientConfig();
+      expect(clientConfig.paySwgVersion).to.equal('2');
+    });
   });
 
   describe('event listening', () => {
@@ -2305,5 +2443,509 @@ describes.realWin('EntitlementsManager', {}, (env) => {
       storageMock.expects('remove').withExactArgs('isreadytopay').once();
       manager.reset(true);
     });
+    describe('with signature verification', () => {
+      beforeEach(() => {
+        verifyStub.reset();
+        expectGetIsReadyToPayToBeCalled(null);
+      });
+
+      it('should use verified cached entitlements', async () => {
+        const raw = entitlementsResponse({
+          source: 'google',
+          products: ['pub1:label1'],
+          subscriptionToken: 's1',
+        })['signedEntitlements'];
+        verifyStub.withArgs(raw).resolves(true);
+        storageMock
+          .expects('get')
+          .withExactArgs('ents')
+          .returns(Promise.resolve(raw))
+          .once();
+        storageMock.expects('remove').withArgs('ents').never();
+        expectEntitlementPingback(
+          EntitlementSource.GOOGLE_SUBSCRIBER_ENTITLEMENT,
+          EntitlementResult.UNLOCKED_SUBSCRIBER,
+          /* jwtString */ null,
+          /* jwtSource */ null,
+          /* isUserRegistered */ true
+        );
+
+        const entitlements = await manager.getEntitlements();
+        expect(entitlements.raw).to.equal(raw);
+        expect(verifyStub).to.be.calledOnce;
+      });
+
+      it('should refetch if cached entitlements are unverified', async () => {
+        const forgedRaw = entitlementsResponse({
+          source: 'google',
+          products: ['pub1:label1'],
+          subscriptionToken: 'forged',
+        })['signedEntitlements'];
+        verifyStub.resolves(false);
+        const raw = expectGoogleResponse()['signedEntitlements'];
+        storageMock
+          .expects('get')
+          .withExactArgs('ents')
+          .returns(Promise.resolve(forgedRaw))
+          .once();
+        storageMock.expects('remove').withExactArgs('ents').once();
+        storageMock.expects('remove').withExactArgs('entssnapshot', true);
+        storageMock
+          .expects('set')
+          .withExactArgs('ents', raw)
+          .returns(Promise.resolve())
+          .once();
+        expectGetSwgUserTokenToBeCalled();
+
+        const entitlements = await manager.getEntitlements();
+        expect(entitlements.raw).to.equal(raw);
+        expect(verifyStub).to.be.calledOnceWith(forgedRaw);
+      });
+    });
+  });
+
+  describe('cross-tab sync', () => {
+    let fetchStub;
+    let triggerStub;
+
+    beforeEach(() => {
+      fetchStub = sandbox.stub(manager, 'fetchEntitlementsWithCaching_');
+      triggerStub = sandbox.stub(callbacks, 'triggerEntitlementsResponse');
+    });
+
+    it('should sync entitlements and the user token', () => {
+      const storage = deps.storage();
+      const syncStub = sandbox.stub(storage, 'syncAcrossTabs');
+
+      new EntitlementsManager(win, pageConfig, fetcher, deps);
+
+      expect(syncStub).to.be.calledOnceWith(['ents', Constants.USER_TOKEN]);
+    });
+
+    it('should refetch entitlements changed in another tab', async () => {
+      const params = {metering: {state: {id: 'u1'}}};
+      const before = manager.createEntitlements_('', []);
+      const after = manager.createEntitlements_('', [
+        {source: 'google', products: ['pub1:label1']},
+      ]);
+      fetchStub.onFirstCall().resolves(before);
+      fetchStub.onSecondCall().resolves(after);
+      expectLog(AnalyticsEvent.EVENT_NO_ENTITLEMENTS, false);
+      sandbox.stub(manager, 'maybeShowToast_');
+      await manager.getEntitlements(params);
+
+      manager.onStorageChangedInOtherTab_();
+
+      await expect(manager.getEntitlements()).to.eventually.equal(after);
+      expect(fetchStub).to.be.calledTwice;
+      expect(fetchStub.args[1][0]).to.equal(params);
+      expect(triggerStub).to.be.calledTwice;
+      await expect(triggerStub.args[1][0]).to.eventually.equal(after);
+    });
+
+    it('should not refetch entitlements that grant the same', async () => {
+      const entitlements = {source: 'google', products: ['pub1:label1']};
+      const current = manager.createEntitlements_('', [entitlements]);
+      fetchStub.resolves(current);
+      sandbox.stub(manager, 'onEntitlementsFetched_');
+      await manager.getEntitlements();
+      const raw = entitlementsResponse(entitlements)['signedEntitlements'];
+      await deps.storage().set('ents', raw);
+
+      manager.onStorageChangedInOtherTab_('ents');
+      await tick(2);
+
+      expect(fetchStub).to.be.calledOnce;
+    });
+
+    it('should refetch entitlements that grant something else', async () => {
+      const current = manager.createEntitlements_('', []);
+      fetchStub.resolves(current);
+      sandbox.stub(manager, 'onEntitlementsFetched_');
+      await manager.getEntitlements();
+      const raw = entitlementsResponse({
+        source: 'google',
+        products: ['pub1:label1'],
+      })['signedEntitlements'];
+      await deps.storage().set('ents', raw);
+
+      manager.onStorageChangedInOtherTab_('ents');
+      await tick(2);
+
+      expect(fetchStub).to.be.calledTwice;
+    });
+
+    it('should not fetch entitlements if they were never requested', () => {
+      manager.onStorageChangedInOtherTab_();
+
+      expect(fetchStub).to.not.be.called;
+      expect(triggerStub).to.not.be.called;
+    });
+  });
+
+  describe('cache policy', () => {
+    let storage;
+    let triggerStub;
+
+    beforeEach(() => {
+      // Without GAA params, no pingbacks are sent.
+      win.location.search = '';
+      storage = deps.storage();
+      sandbox.stub(Toast.prototype, 'open');
+      sandbox.stub(self.console, 'log');
+      triggerStub = sandbox.stub(callbacks, 'triggerEntitlementsResponse');
+    });
+
+    function cachedGoogleEntitlements(options) {
+      return entitlementsResponse(
+        {
+          source: 'google',
+          products: ['pub1:label1'],
+          subscriptionToken: 's1',
+        },
+        options
+      )['signedEntitlements'];
+    }
+
+    function expectResponse(json) {
+      xhrMock
+        .expects('fetch')
+        .returns(
+          Promise.resolve({
+            text: () => Promise.resolve(JSON.stringify(json)),
+          })
+        )
+        .once();
+    }
+
+    function googleResponse(options = {}) {
+      return entitlementsResponse(
+        {
+          source: 'google',
+          products: ['pub1:label1'],
+          subscriptionToken: 's1',
+        },
+        Object.assign({iat: Math.floor(Date.now() / 1000)}, options)
+      );
+    }
+
+    /** Waits for the background revalidation to complete. */
+    async function revalidation(fetchSpy) {
+      await fetchSpy.returnValues[0];
+      await tick(2);
+    }
+
+    it('should not read the cache in network-only mode', async () => {
+      config.entitlementsCachePolicy = {mode: 'network-only'};
+      await storage.set('ents', cachedGoogleEntitlements());
+      expectNoResponse();
+
+      const entitlements = await manager.getEntitlements();
+
+      expect(entitlements.enablesThis()).to.be.false;
+    });
+
+    it('should cache negative results in cache-negative mode', async () => {
+      config.entitlementsCachePolicy = {mode: 'cache-negative'};
+      expectNoResponse();
+
+      await manager.getEntitlements();
+      manager.reset();
+      const entitlements = await manager.getEntitlements();
+
+      expect(entitlements.enablesAny()).to.be.false;
+      expect(triggerStub).to.be.calledTwice;
+    });
+
+    it('should expire negative results', async () => {
+      config.entitlementsCachePolicy = {
+        mode: 'cache-negative',
+        negativeTtlSeconds: 10,
+      };
+      expectNoResponse();
+      expectNoResponse();
+
+      await manager.getEntitlements();
+      manager.reset();
+      nowStub.returns(Date.now() + 10001);
+      await manager.getEntitlements();
+    });
+
+    it('should not use negative results for metering', async () => {
+      config.entitlementsCachePolicy = {mode: 'cache-negative'};
+      expectNoResponse();
+      expectNoResponse();
+
+      await manager.getEntitlements();
+      manager.reset();
+      await manager.getEntitlements({metering: {state: {id: 'u1'}}});
+    });
+
+    it('should cache negative results per article', async () => {
+      config.entitlementsCachePolicy = {mode: 'cache-negative'};
+      const link = env.win.document.createElement('link');
+      link.rel = 'canonical';
+      link.href = 'https://pub.com/article1';
+      env.win.document.head.appendChild(link);
+      expectNoResponse();
+      expectNoResponse();
+
+      await manager.getEntitlements();
+      manager.reset();
+      link.href = 'https://pub.com/article2';
+      await manager.getEntitlements();
+      await tick(2);
+
+      const entriesByUrl = JSON.parse(await storage.get('negents'));
+      expect(Object.keys(entriesByUrl)).to.deep.equal([
+        'https://pub.com/article1',
+        'https://pub.com/article2',
+      ]);
+    });
+
+    it('should drop negative results on positive results', async () => {
+      config.entitlementsCachePolicy = {mode: 'cache-negative'};
+      expectNoResponse();
+      await manager.getEntitlements();
+
+      manager.pushNextEntitlements(cachedGoogleEntitlements());
+
+      await expect(storage.get('negents')).to.eventually.be.null;
+    });
+
+    it('should not cache negative results by default', async () => {
+      expectNoResponse();
+
+      await manager.getEntitlements();
+
+      await expect(storage.get('negents')).to.eventually.be.null;
+    });
+
+    describe('stale-while-revalidate', () => {
+      let fetchSpy;
+
+      beforeEach(() => {
+        config.entitlementsCachePolicy = {mode: 'stale-while-revalidate'};
+        fetchSpy = sandbox.spy(manager, 'fetchAndCacheEntitlements_');
+      });
+
+      it('should notify again if revalidated entitlements changed', async () => {
+        await storage.set(
+          'negents',
+          JSON.stringify({
+            [manager.getArticleUrl_()]: {
+              'exp': 0,
+              'entitlements': {'entitlements': []},
+            },
+          })
+        );
+        expectResponse(googleResponse());
+
+        const stale = await manager.getEntitlements();
+        expect(stale.enablesThis()).to.be.false;
+        await revalidation(fetchSpy);
+
+        expect(triggerStub).to.be.calledTwice;
+        const fresh = await triggerStub.args[1][0];
+        expect(fresh.enablesThis()).to.be.true;
+        await expect(manager.getEntitlements()).to.eventually.equal(fresh);
+      });
+
+      it('should not notify again if entitlements are unchanged', async () => {
+        await storage.set(
+          'ents',
+          cachedGoogleEntitlements({exp: Date.now() / 1000 - 10})
+        );
+        expectResponse(googleResponse());
+
+        const stale = await manager.getEntitlements();
+        expect(stale.enablesThis()).to.be.true;
+        await revalidation(fetchSpy);
+
+        expect(triggerStub).to.be.calledOnce;
+      });
+
+      it('should keep cached entitlements if revalidation fails', async () => {
+        await storage.set('ents', cachedGoogleEntitlements());
+        xhrMock.expects('fetch').rejects(new Error('offline')).once();
+
+        const stale = await manager.getEntitlements();
+        await fetchSpy.returnValues[0].catch(() => {});
+        await tick(2);
+
+        await expect(manager.getEntitlements()).to.eventually.equal(stale);
+        expect(triggerStub).to.be.calledOnce;
+      });
+
+      it('should ignore revalidations after a reset', async () => {
+        await storage.set('ents', cachedGoogleEntitlements());
+        expectNoResponse();
+
+        await manager.getEntitlements();
+        manager.reset();
+        await revalidation(fetchSpy);
+
+        expect(manager.responsePromise_).to.be.null;
+        expect(triggerStub).to.be.calledOnce;
+      });
+    });
+
+    describe('offline grace period', () => {
+      beforeEach(() => {
+        config.entitlementsCachePolicy = {offlineGracePeriodSeconds: 3600};
+      });
+
+      function expectOffline() {
+        xhrMock.expects('fetch').rejects(new Error('offline')).once();
+      }
+
+      async function saveSnapshot() {
+        expectResponse(googleResponse());
+        await manager.getEntitlements();
+        // Forget the session cache, like a new page load would.
+        await storage.remove('ents');
+        manager.reset();
+      }
+
+      it('should save positive entitlements', async () => {
+        const response = googleResponse();
+        expectResponse(response);
+
+        await manager.getEntitlements();
+
+        const snapshot = JSON.parse(
+          await storage.get('entssnapshot', /* useLocalStorage */ true)
+        );
+        expect(snapshot).to.deep.equal({
+          'raw': response['signedEntitlements'],
+          'isReadyToPay': false,
+        });
+      });
+
+      it('should serve stale entitlements while offline', async () => {
+        await saveSnapshot();
+        expectOffline();
+
+        const entitlements = await manager.getEntitlements();
+
+        expect(entitlements.isStale).to.be.true;
+        expect(entitlements.source).to.equal('cache-offline');
+        expect(entitlements.enablesThis()).to.be.true;
+        expect(triggerStub).to.be.calledTwice;
+      });
+
+      it('should verify snapshots with the stored keys while offline', async () => {
+        verifyStub.restore();
+        const signatureStub = sandbox
+          .stub(JwtHelper.prototype, 'verify')
+          .resolves(true);
+        // Stored by an earlier page load, and expired since.
+        const jwks = {
+          'keys': {'k1': {'kid': 'k1'}},
+          'fetchedAt': Date.now() - 24 * 3600 * 1000,
+        };
+        await storage.set('jwks', JSON.stringify(jwks), true);
+        const raw = googleResponse({kid: 'k1'})['signedEntitlements'];
+        const snapshot = {'raw': raw, 'isReadyToPay': false};
+        await storage.set('entssnapshot', JSON.stringify(snapshot), true);
+        expectOffline(); // Entitlements.
+        expectOffline(); // Signing keys.
+
+        const entitlements = await manager.getEntitlements();
+
+        expect(entitlements.source).to.equal('cache-offline');
+        expect(entitlements.enablesThis()).to.be.true;
+        expect(signatureStub).to.be.calledOnceWith(raw, {'kid': 'k1'});
+      });
+
+      it('should count the grace period from the issue time', async () => {
+        const raw = googleResponse({iat: Date.now() / 1000 - 3600})[
+          'signedEntitlements'
+        ];
+        // The saved time can't be trusted.
+        const snapshot = {'raw': raw, 'isReadyToPay': false, 'savedAt': 0};
+        await storage.set('entssnapshot', JSON.stringify(snapshot), true);
+        expectOffline();
+
+        await expect(manager.getEntitlements()).to.be.rejectedWith('offline');
+      });
+
+      it('should reject unverified snapshots', async () => {
+        await saveSnapshot();
+        verifyStub.resolves(false);
+        expectOffline();
+
+        await expect(manager.getEntitlements()).to.be.rejectedWith('offline');
+      });
+
+      it('should reject if the server rejects the request', async () => {
+        await saveSnapshot();
+        const error = new Error('Forbidden');
+        error.response = {status: 403};
+        xhrMock.expects('fetch').rejects(error).once();
+
+        await expect(manager.getEntitlements())
+          .to.be.rejectedWith('Forbidden')
+          .and.eventually.have.property(
+            'code',
+            SwgErrorCode.ENTITLEMENTS_SERVER_ERROR
+          );
+      });
+
+      it('should reject after the grace period', async () => {
+        await saveSnapshot();
+        expectOffline();
+        nowStub.returns(Date.now() + 3600 * 1000);
+
+        await expect(manager.getEntitlements()).to.be.rejectedWith('offline');
+      });
+
+      it('should reject without a snapshot', async () => {
+        expectOffline();
+
+        await expect(manager.getEntitlements())
+          .to.be.rejectedWith('offline')
+          .and.eventually.have.property('code', SwgErrorCode.NETWORK);
+      });
+
+      it('should reject for documents that need decryption', async () => {
+        await saveSnapshot();
+        expectOffline();
+
+        await expect(
+          manager.getEntitlements({
+            encryption: {encryptedDocumentKey},
+          })
+        ).to.be.rejectedWith('offline');
+      });
+
+      it('should drop the snapshot on negative entitlements', async () => {
+        await saveSnapshot();
+        expectNoResponse();
+        await manager.getEntitlements();
+        manager.reset();
+        expectOffline();
+
+        await expect(manager.getEntitlements()).to.be.rejectedWith('offline');
+      });
+
+      it('should not save a snapshot if disabled', async () => {
+        config.entitlementsCachePolicy = {};
+        expectResponse(googleResponse());
+
+        await manager.getEntitlements();
+
+        await expect(storage.get('entssnapshot', true)).to.eventually.be.null;
+      });
+
+      it('should remove the snapshot once disabled', async () => {
+        await saveSnapshot();
+        config.entitlementsCachePolicy = {};
+        expectOffline();
+
+        await expect(manager.getEntitlements()).to.be.rejectedWith('offline');
+        await expect(storage.get('entssnapshot', true)).to.eventually.be.null;
+      });
+    });
   });
 });
@@ -23,35 +23,57 @@ import {
   EventOriginator,
   EventParams,
 } from '../proto/api_messages';
-import {Constants} from '../utils/constants';
 import {
+  CACHE_OFFLINE_SOURCE,
   Entitlement,
   Entitlements,
   GOOGLE_METERING_SOURCE,
   PRIVILEGED_SOURCE,
 } from '../api/entitlements';
+import {Constants} from '../utils/constants';
+import {DiagnosticType, recordDiagnostic, recordFetch} from './diagnostics';
 import {
+  EntitlementsCacheMode,
   GetEntitlementsParamsExternalDef,
   GetEntitlementsParamsInternalDef,
 } from '../api/subscriptions';
 import {JwtHelper} from '../utils/jwt';
+import {JwtVerifier} from './jwt-verifier';
 import {MeterClientTypes} from '../api/metering';
 import {MeterToastApi} from './meter-toast-api';
+import {PerformanceTiming} from '../utils/performance';
+import {RetriedRequest, createRetryPolicy} from './retry-policies';
+import {
+  SwgError,
+  SwgErrorCode,
+  isSwgError,
+  toRequestError,
+} from '../utils/errors';
 import {Toast} from '../ui/toast';
 import {addQueryParam, getCanonicalUrl, parseQueryString} from '../utils/url';
 import {analyticsEventToEntitlementResult} from './event-type-mapping';
 import {base64UrlEncodeFromBytes, utf8EncodeSync} from '../utils/bytes';
 import {feArgs, feUrl} from '../runtime/services';
 import {hash} from '../utils/string';
+import {isObject} from '../utils/types';
 import {queryStringHasFreshGaaParams} from '../utils/gaa';
 import {serviceUrl} from './services';
 import {toTimestamp} from '../utils/date-utils';
+import {tryParseJson} from '../utils/json';
 import {warn} from '../utils/log';
 
 const SERVICE_ID = 'subscribe.google.com';
 const TOAST_STORAGE_KEY = 'toast';
 const ENTS_STORAGE_KEY = 'ents';
 const IS_READY_TO_PAY_STORAGE_KEY = 'isreadytopay';
+const NEGATIVE_ENTS_STORAGE_KEY = 'negents';
+const OFFLINE_SNAPSHOT_STORAGE_KEY = 'entssnapshot';
+
+/** Default TTL of cached negative entitlements, in seconds. */
+const DEFAULT_NEGATIVE_TTL_SECONDS = 300;
+
+/** Most articles whose negative entitlements are cached at once. */
+const MAX_NEGATIVE_ENTS_ARTICLES = 20;
 
 /**
  * Article response object.
@@ -71,12 +93,21 @@ export class EntitlementsManager {
    * @param {!../model/page-config.PageConfig} pageConfig
    * @param {!./fetcher.Fetcher} fetcher
    * @param {!./deps.DepsDef} deps
+   * @param {boolean=} useArticleEndpoint
+   * @param {!../utils/retry-policy.RetryPolicy=} retryPolicy
    */
-  constructor(win, pageConfig, fetcher, deps, useArticleEndpoint) {
+  constructor(
+    win,
+    pageConfig,
+    fetcher,
+    deps,
+    useArticleEndpoint,
+    retryPolicy
+  ) {
     /** @private @const {!Window} */
     this.win_ = win;
 
-    /** @private @const {!../model/page-config.PageConfig} */
+    /** @private {!../model/page-config.PageConfig} */
     this.pageConfig_ = pageConfig;
 
     /** @private @const {string} */
@@ -88,9 +119,19 @@ export class EntitlementsManager {
     /** @private @const {!./deps.DepsDef} */
     this.deps_ = deps;
 
+    /** @private @const {!../utils/performance.PerformanceTimings} */
+    this.performanceTimings_ = deps.performanceTimings();
+
+    /** @private @const {!../utils/retry-policy.RetryPolicy} */
+    this.retryPolicy_ =
+      retryPolicy || createRetryPolicy(deps, RetriedRequest.ENTITLEMENTS);
+
     /** @private @const {!JwtHelper} */
     this.jwtHelper_ = new JwtHelper();
 
+    /** @private @const {!JwtVerifier} */
+    this.jwtVerifier_ = new JwtVerifier(win, fetcher, deps.storage());
+
     /** @private {?Promise<!Entitlements>} */
     this.responsePromise_ = null;
 
@@ -139,6 +180,21 @@ export class EntitlementsManager {
     /** @private {?Article} */
     this.article_ = null;
 
+    /**
+     * Params of the latest `getEntitlements` call, reused when entitlements
+     * are refreshed after a change in another tab.
+     * @private {!GetEntitlementsParamsExternalDef|undefined}
+     */
+    this.lastParams_ = undefined;
+
+    /** @private {?MeterToastApi} */
+    this.meterToastApi_ = null;
+
+    this.storage_.syncAcrossTabs(
+      [ENTS_STORAGE_KEY, Constants.USER_TOKEN],
+      this.onStorageChangedInOtherTab_.bind(this)
+    );
+
     this.deps_
       .eventManager()
       .registerEventListener(this.possiblyPingbackOnClientEvent_.bind(this));
@@ -156,6 +212,7 @@ export class EntitlementsManager {
     if (expectPositive) {
       this.storage_.remove(ENTS_STORAGE_KEY);
       this.storage_.remove(IS_READY_TO_PAY_STORAGE_KEY);
+      this.removeNegativeEntitlements_();
     }
   }
 
@@ -169,6 +226,37 @@ export class EntitlementsManager {
     this.storage_.remove(ENTS_STORAGE_KEY);
     this.storage_.remove(TOAST_STORAGE_KEY);
     this.storage_.remove(IS_READY_TO_PAY_STORAGE_KEY);
+    this.removeNegativeEntitlements_();
+    this.removeOfflineSnapshot_();
+  }
+
+  /**
+   * Switches to the article of another page of a single page application.
+   * The entitlements of the previous article are dropped, while the cached
+   * entitlements of the publication are kept.
+   * @param {!../model/page-config.PageConfig} pageConfig
+   * @return {boolean} Whether entitlements were requested for the previous
+   *     article.
+   */
+  navigate(pageConfig) {
+    const requested = !!this.responsePromise_;
+    this.pageConfig_ = pageConfig;
+    this.responsePromise_ = null;
+    this.positiveRetries_ = 0;
+    this.encodedParams_ = null;
+    this.article_ = null;
+    this.lastParams_ = undefined;
+    return requested;
+  }
+
+  /**
+   * Removes the page listeners of the meter toast, if one was shown.
+   */
+  destroy() {
+    if (this.meterToastApi_) {
+      this.meterToastApi_.removeCloseEventListener();
+      this.meterToastApi_ = null;
+    }
   }
 
   /**
@@ -194,6 +282,7 @@ export class EntitlementsManager {
     }
 
     if (!this.responsePromise_) {
+      this.lastParams_ = params;
       this.responsePromise_ = this.getEntitlementsFlow_(params);
     }
     return this.responsePromise_.then((response) => {
@@ -217,11 +306,60 @@ export class EntitlementsManager {
     );
     if (entitlements && entitlements.enablesThis()) {
       this.storage_.set(ENTS_STORAGE_KEY, raw);
+      this.removeNegativeEntitlements_();
       return true;
     }
     return false;
   }
 
+  /**
+   * Another tab stored new entitlements or a new user token, for instance
+   * after the user subscribed there. Drops the in-memory response and, if
+   * entitlements were already requested in this tab, fetches them again so
+   * that the entitlements response callback fires with the new state.
+   *
+   * Refetched entitlements are stored, and so relayed back to the other tab.
+   * To keep tabs from refetching each other's entitlements forever, new
+   * entitlements that grant the same as the current ones are ignored.
+   * @param {string=} key
+   * @private
+   */
+  onStorageChangedInOtherTab_(key) {
+    const responsePromise = this.responsePromise_;
+    if (!responsePromise) {
+      return;
+    }
+    const refetch = () => {
+      if (this.responsePromise_ !== responsePromise) {
+        // Entitlements were reset in the meantime.
+        return;
+      }
+      this.responsePromise_ = null;
+      this.getEntitlements(this.lastParams_);
+    };
+    if (key != ENTS_STORAGE_KEY) {
+      refetch();
+      return;
+    }
+    Promise.all([responsePromise, this.storage_.get(ENTS_STORAGE_KEY)]).then(
+      (results) => {
+        const current = results[0];
+        const raw = results[1];
+        const changed =
+          raw &&
+          this.getValidJwtEntitlements_(
+            raw,
+            /* requireNonExpired */ true,
+            current.isReadyToPay
+          );
+        if (!changed || !sameEntitlements(current, changed)) {
+          refetch();
+        }
+      },
+      refetch
+    );
+  }
+
   /**
    * Retrieves the 'gaa_n' parameter from the query string.
    */
@@ -372,10 +510,16 @@ export class EntitlementsManager {
    * @private
    */
   getEntitlementsFlow_(params) {
-    return this.fetchEntitlementsWithCaching_(params).then((entitlements) => {
-      this.onEntitlementsFetched_(entitlements);
-      return entitlements;
-    });
+    return this.fetchEntitlementsWithCaching_(params)
+      .catch((reason) => this.getOfflineEntitlements_(params, reason))
+      .then((entitlements) => {
+        recordDiagnostic(this.deps_, DiagnosticType.ENTITLEMENTS, () => ({
+          'entitlements': entitlements.json(),
+          'enablesThis': entitlements.enablesThis(),
+        }));
+        this.onEntitlementsFetched_(entitlements);
+        return entitlements;
+      });
   }
 
   /**
@@ -384,6 +528,10 @@ export class EntitlementsManager {
    * @private
    */
   fetchEntitlementsWithCaching_(params) {
+    const mode = this.getCacheMode_();
+    if (mode == EntitlementsCacheMode.NETWORK_ONLY) {
+      return this.fetchAndCacheEntitlements_(params);
+    }
     return Promise.all([
       this.storage_.get(ENTS_STORAGE_KEY),
       this.storage_.get(IS_READY_TO_PAY_STORAGE_KEY),
@@ -395,26 +543,327 @@ export class EntitlementsManager {
       if (raw && !needsDecryption) {
         const cached = this.getValidJwtEntitlements_(
           raw,
-          /* requireNonExpired */ true,
+          /* requireNonExpired */
+          mode != EntitlementsCacheMode.STALE_WHILE_REVALIDATE,
           irtpStringToBoolean(irtp)
         );
         if (cached && cached.enablesThis()) {
-          // Already have a positive response.
-          this.positiveRetries_ = 0;
-          return cached;
+          return this.verifyCachedEntitlements_(raw).then((verified) => {
+            if (!verified) {
+              // The cached entitlements may have been tampered with.
+              this.storage_.remove(ENTS_STORAGE_KEY);
+              return this.fetchAndCacheEntitlements_(params);
+            }
+            // Already have a positive response.
+            this.positiveRetries_ = 0;
+            return this.serveFromCache_(cached, params);
+          });
         }
       }
-      // If cache didn't match, perform fetch.
-      return this.fetchEntitlements_(params).then((ents) => {
-        // If the product is enabled by cacheable entitlements, store them in cache.
-        if (ents && ents.enablesThisWithCacheableEntitlements() && ents.raw) {
-          this.storage_.set(ENTS_STORAGE_KEY, ents.raw);
+      if (needsDecryption) {
+        return this.fetchAndCacheEntitlements_(params);
+      }
+      return this.getNegativeEntitlements_(params).then((cached) =>
+        cached
+          ? this.serveFromCache_(cached, params)
+          : this.fetchAndCacheEntitlements_(params)
+      );
+    });
+  }
+
+  /**
+   * @param {!GetEntitlementsParamsExternalDef=} params
+   * @return {!Promise<!Entitlements>}
+   * @private
+   */
+  fetchAndCacheEntitlements_(params) {
+    return this.fetchEntitlements_(params).then((ents) => {
+      // If the product is enabled by cacheable entitlements, store them in cache.
+      if (ents && ents.enablesThisWithCacheableEntitlements() && ents.raw) {
+        this.storage_.set(ENTS_STORAGE_KEY, ents.raw);
+        this.removeNegativeEntitlements_();
+        this.saveOfflineSnapshot_(ents);
+      } else if (ents && !ents.enablesThis()) {
+        this.saveNegativeEntitlements_(ents);
+        // The subscription may have ended.
+        this.removeOfflineSnapshot_();
+      }
+      return ents;
+    });
+  }
+
+  /**
+   * @param {!Entitlements} entitlements
+   * @private
+   */
+  saveNegativeEntitlements_(entitlements) {
+    if (!this.cachesNegative_()) {
+      return;
+    }
+    const ttlSeconds =
+      this.getCachePolicy_().negativeTtlSeconds ?? DEFAULT_NEGATIVE_TTL_SECONDS;
+    const url = this.getArticleUrl_();
+    const entry = {
+      'exp': Date.now() + ttlSeconds * 1000,
+      'entitlements': entitlements.json(),
+    };
+    this.readNegativeEntitlements_().then((entriesByUrl) => {
+      // Re-inserted, so that entries stay ordered from the oldest.
+      delete entriesByUrl[url];
+      entriesByUrl[url] = entry;
+      const urls = Object.keys(entriesByUrl);
+      for (const oldUrl of urls.slice(0, -MAX_NEGATIVE_ENTS_ARTICLES)) {
+        delete entriesByUrl[oldUrl];
+      }
+      this.storage_.set(
+        NEGATIVE_ENTS_STORAGE_KEY,
+        JSON.stringify(entriesByUrl)
+      );
+    });
+  }
+
+  /**
+   * @return {string} Identifies the article in caches: its canonical URL, or
+   *     its URL if it has none.
+   * @private
+   */
+  getArticleUrl_() {
+    return getCanonicalUrl(this.deps_.doc()) || this.win_.location.href;
+  }
+
+  /**
+   * Reads the cached negative entitlements of all articles, keyed by
+   * article URL.
+   * @return {!Promise<!Object<string, !JsonObject>>}
+   * @private
+   */
+  readNegativeEntitlements_() {
+    return this.storage_.get(NEGATIVE_ENTS_STORAGE_KEY).then((value) => {
+      const entriesByUrl = value ? tryParseJson(value) : null;
+      return isObject(entriesByUrl) ? entriesByUrl : {};
+    });
+  }
+
+  /**
+   * Returns cached entitlements right away. Under the stale-while-revalidate
+   * policy, also fetches fresh entitlements in the background, and notifies
+   * the entitlements response callback again if they're different.
+   * @param {!Entitlements} cached
+   * @param {!GetEntitlementsParamsExternalDef=} params
+   * @return {!Entitlements}
+   * @private
+   */
+  serveFromCache_(cached, params) {
+    if (this.getCacheMode_() != EntitlementsCacheMode.STALE_WHILE_REVALIDATE) {
+      return cached;
+    }
+    const responsePromise = this.responsePromise_;
+    const freshPromise = this.fetchAndCacheEntitlements_(params);
+    Promise.all([responsePromise, freshPromise]).then(
+      (results) => {
+        const fresh = results[1];
+        if (this.responsePromise_ !== responsePromise) {
+          // Entitlements were reset in the meantime.
+          return;
         }
-        return ents;
-      });
+        this.responsePromise_ = Promise.resolve(fresh);
+        if (!sameEntitlements(cached, fresh)) {
+          this.onEntitlementsFetched_(fresh);
+        }
+      },
+      () => {
+        // Keep serving the cached entitlements.
+      }
+    );
+    return cached;
+  }
+
+  /**
+   * Reads cached negative entitlements. Expired entries are ignored,
+   * unless the policy is stale-while-revalidate.
+   * @param {!GetEntitlementsParamsExternalDef=} params
+   * @return {!Promise<?Entitlements>}
+   * @private
+   */
+  getNegativeEntitlements_(params) {
+    const mode = this.getCacheMode_();
+    // Metering may unlock the article even if it wasn't unlocked before.
+    const skipCache =
+      mode == EntitlementsCacheMode.CACHE_NEGATIVE && !!params?.metering;
+    if (!this.cachesNegative_() || skipCache) {
+      return Promise.resolve(null);
+    }
+    const url = this.getArticleUrl_();
+    return this.readNegativeEntitlements_().then((entriesByUrl) => {
+      const cached = entriesByUrl[url];
+      if (!isObject(cached) || !cached['entitlements']) {
+        return null;
+      }
+      if (
+        mode == EntitlementsCacheMode.CACHE_NEGATIVE &&
+        !(cached['exp'] > Date.now())
+      ) {
+        return null;
+      }
+      const json = cached['entitlements'];
+      return this.createEntitlements_(
+        '',
+        json['entitlements'] || [],
+        json['isReadyToPay']
+      );
     });
   }
 
+  /**
+   * @private
+   */
+  removeNegativeEntitlements_() {
+    if (this.cachesNegative_()) {
+      this.storage_.remove(NEGATIVE_ENTS_STORAGE_KEY);
+    }
+  }
+
+  /**
+   * Keeps the latest positive entitlements from the server in local storage,
+   * so they can be used while offline.
+   * @param {!Entitlements} entitlements
+   * @private
+   */
+  saveOfflineSnapshot_(entitlements) {
+    if (!this.getCachePolicy_().offlineGracePeriodSeconds) {
+      // The policy may have been turned off since the snapshot was saved.
+      this.removeOfflineSnapshot_();
+      return;
+    }
+    this.storage_.set(
+      OFFLINE_SNAPSHOT_STORAGE_KEY,
+      JSON.stringify({
+        'raw': entitlements.raw,
+        'isReadyToPay': entitlements.isReadyToPay,
+      }),
+      /* useLocalStorage */ true
+    );
+  }
+
+  /**
+   * @private
+   */
+  removeOfflineSnapshot_() {
+    this.storage_.remove(OFFLINE_SNAPSHOT_STORAGE_KEY, true);
+  }
+
+  /**
+   * Falls back to the offline snapshot when entitlements can't be fetched
+   * because the server can't be reached or failed, as long as the signed
+   * entitlements were issued within the grace period. Rejects with the
+   * original reason otherwise.
+   * @param {!GetEntitlementsParamsExternalDef|undefined} params
+   * @param {*} reason
+   * @return {!Promise<!Entitlements>}
+   * @private
+   */
+  getOfflineEntitlements_(params, reason) {
+    const gracePeriodSeconds =
+      this.getCachePolicy_().offlineGracePeriodSeconds;
+    if (!gracePeriodSeconds) {
+      this.removeOfflineSnapshot_();
+      return Promise.reject(reason);
+    }
+    // Documents that need decryption can't be unlocked from the snapshot.
+    if (!isTemporaryFailure(reason) || params?.encryption) {
+      return Promise.reject(reason);
+    }
+    return this.storage_
+      .get(OFFLINE_SNAPSHOT_STORAGE_KEY, true)
+      .then((value) => {
+        const snapshot = value ? tryParseJson(value) : null;
+        const raw = snapshot && snapshot['raw'];
+        if (!raw) {
+          return null;
+        }
+        // Anyone can write to local storage, so the age of the snapshot is
+        // taken from the signed entitlements.
+        const age = Date.now() - this.getIssuedAtMs_(raw);
+        if (!(age >= 0 && age < gracePeriodSeconds * 1000)) {
+          return null;
+        }
+        const entitlements = this.getValidJwtEntitlements_(
+          raw,
+          /* requireNonExpired */ false,
+          snapshot['isReadyToPay']
+        );
+        if (!entitlements || !entitlements.enablesThis()) {
+          return null;
+        }
+        return this.verifyCachedEntitlements_(raw).then((verified) =>
+          verified ? entitlements : null
+        );
+      })
+      .then((entitlements) => {
+        if (!entitlements) {
+          throw reason;
+        }
+        entitlements.isStale = true;
+        entitlements.source = CACHE_OFFLINE_SOURCE;
+        return entitlements;
+      });
+  }
+
+  /**
+   * @param {string} raw
+   * @return {number} When the entitlements were issued, or NaN if unknown.
+   * @private
+   */
+  getIssuedAtMs_(raw) {
+    try {
+      const iat = this.jwtHelper_.decode(raw)['iat'];
+      return typeof iat == 'number' ? iat * 1000 : NaN;
+    } catch (e) {
+      return NaN;
+    }
+  }
+
+  /**
+   * @return {!../api/subscriptions.EntitlementsCachePolicy}
+   * @private
+   */
+  getCachePolicy_() {
+    return this.config_.entitlementsCachePolicy || {};
+  }
+
+  /**
+   * @return {!EntitlementsCacheMode}
+   * @private
+   */
+  getCacheMode_() {
+    return this.getCachePolicy_().mode || EntitlementsCacheMode.DEFAULT;
+  }
+
+  /**
+   * Whether negative results are cached under the current policy.
+   * @return {boolean}
+   * @private
+   */
+  cachesNegative_() {
+    const mode = this.getCacheMode_();
+    return (
+      mode == EntitlementsCacheMode.CACHE_NEGATIVE ||
+      mode == EntitlementsCacheMode.STALE_WHILE_REVALIDATE
+    );
+  }
+
+  /**
+   * Verifies the signature of cached entitlements, since anyone can write
+   * to storage. Resolves to false if the signing keys can't be fetched, and
+   * weren't stored by an earlier page load.
+   * @param {string} raw
+   * @return {!Promise<boolean>}
+   * @private
+   */
+  verifyCachedEntitlements_(raw) {
+    return this.jwtVerifier_.verify(raw);
+  }
+
   /**
    * If the manager is also responsible for fetching the Article, it
    * will be accessible from here and should resolve a null promise otherwise.
@@ -435,23 +884,51 @@ export class EntitlementsManager {
    * @private
    */
   fetchEntitlements_(params) {
-    // TODO(dvoytenko): Replace retries with consistent fetch.
-    let positiveRetries = this.positiveRetries_;
+    const maxAttempts = this.positiveRetries_;
     this.positiveRetries_ = 0;
-    const attempt = () => {
-      positiveRetries--;
-      return this.fetch_(params).then((entitlements) => {
-        if (entitlements.enablesThis() || positiveRetries <= 0) {
-          return entitlements;
+    const fetch = () =>
+      this.performanceTimings_.timeAsync(PerformanceTiming.ENTITLEMENTS, () =>
+        this.fetch_(params)
+      );
+    if (maxAttempts <= 1) {
+      return fetch();
+    }
+    // Right after a purchase, the server may not know about the subscription
+    // yet, so entitlements that don't enable this product are refetched with
+    // backoff. Failed fetches were already retried by the fetcher, and aren't
+    // retried again.
+    let lastEntitlements = null;
+    let failure = null;
+    const policy = createRetryPolicy(this.deps_, RetriedRequest.ENTITLEMENTS, {
+      maxAttempts,
+    });
+    return policy
+      .run(this.win_, () =>
+        fetch().then(
+          (entitlements) => {
+            if (entitlements.enablesThis()) {
+              return entitlements;
+            }
+            lastEntitlements = entitlements;
+            const err = new Error('Entitlements are not positive yet');
+            err.retriable = true;
+            throw err;
+          },
+          (reason) => {
+            failure = {reason};
+            throw new Error('Failed to fetch entitlements');
+          }
+        )
+      )
+      .catch((reason) => {
+        if (failure) {
+          throw failure.reason;
         }
-        return new Promise((resolve) => {
-          this.win_.setTimeout(() => {
-            resolve(attempt());
-          }, 550);
-        });
+        if (!lastEntitlements) {
+          throw reason;
+        }
+        return lastEntitlements;
       });
-    };
-    return attempt();
   }
 
   /**
@@ -704,9 +1181,9 @@ export class EntitlementsManager {
         // If showToast is explicitly false, call onConsumeCallback directly.
         return onConsumeCallback();
       }
-      const meterToastApi = new MeterToastApi(this.deps_);
-      meterToastApi.setOnConsumeCallback(onConsumeCallback);
-      return meterToastApi.start();
+      this.meterToastApi_ = new MeterToastApi(this.deps_);
+      this.meterToastApi_.setOnConsumeCallback(onConsumeCallback);
+      return this.meterToastApi_.start();
     }
   }
 
@@ -858,18 +1335,44 @@ export class EntitlementsManager {
         this.deps_
           .eventManager()
           .logSwgEvent(AnalyticsEvent.ACTION_GET_ENTITLEMENTS, false);
-        return this.fetcher_.fetchCredentialedJson(url);
+        const startTime = Date.now();
+        return this.fetcher_.fetchCredentialedJson(url, this.retryPolicy_).then(
+          (json) => {
+            recordFetch(this.deps_, 'entitlements', startTime);
+            return json;
+          },
+          (reason) => {
+            const error = toRequestError(
+              reason,
+              SwgErrorCode.ENTITLEMENTS_SERVER_ERROR
+            );
+            recordFetch(this.deps_, 'entitlements', startTime, error);
+            throw error;
+          }
+        );
       })
       .then((json) => {
         let response = json;
         if (this.useArticleEndpoint_) {
           this.article_ = json;
           response = json['entitlements'];
+          const clientConfigManager = this.deps_.clientConfigManager();
+          if (clientConfigManager) {
+            clientConfigManager.setArticle(json);
+          }
         }
 
         if (json.errorMessages && json.errorMessages.length > 0) {
           for (const errorMessage of json.errorMessages) {
             warn('SwG Entitlements: ' + errorMessage);
+            this.deps_
+              .callbacks()
+              .triggerError(
+                new SwgError(
+                  SwgErrorCode.ENTITLEMENTS_SERVER_ERROR,
+                  errorMessage
+                )
+              );
           }
         }
         return this.parseEntitlements(response);
@@ -893,6 +1396,36 @@ function addDevModeParamsToUrl(location, url) {
   return addQueryParam(url, 'devEnt', devModeScenario);
 }
 
+/**
+ * Whether two responses grant the same entitlements. Tokens are ignored, since
+ * they change with every response.
+ * @param {!Entitlements} a
+ * @param {!Entitlements} b
+ * @return {boolean}
+ */
+function sameEntitlements(a, b) {
+  const summarize = (entitlements) =>
+    JSON.stringify(
+      entitlements.entitlements.map((entitlement) => [
+        entitlement.source,
+        entitlement.products,
+      ])
+    );
+  return summarize(a) == summarize(b);
+}
+
+/**
+ * Whether a failed fetch may succeed later, e.g. once the user is back online.
+ * @param {*} reason
+ * @return {boolean}
+ */
+function isTemporaryFailure(reason) {
+  return (
+    isSwgError(reason) &&
+    (reason.code == SwgErrorCode.NETWORK || reason.retryable)
+  );
+}
+
 /**
  * Convert String value of isReadyToPay
  * (from JSON or Cache) to a boolean value.
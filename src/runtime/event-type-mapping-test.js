@@ -19,8 +19,10 @@ import {
   ContributionSpecificAnalyticsEventToGoogleAnalyticsEvent,
   SubscriptionSpecificAnalyticsEventToGoogleAnalyticsEvent,
   analyticsEventToEntitlementResult,
+  analyticsEventToGoogleAnalytics4Event,
   analyticsEventToGoogleAnalyticsEvent,
   analyticsEventToPublisherEvent,
+  isValidGoogleAnalyticsEvents,
   publisherEventToAnalyticsEvent,
   showcaseEventToAnalyticsEvents,
 } from './event-type-mapping';
@@ -182,3 +184,54 @@ describes.realWin('analyticsEventToGoogleAnalyticsEvent', {}, () => {
     expect(actual).to.be.equal(expected);
   });
 });
+
+describes.realWin('analyticsEventToGoogleAnalytics4Event', {}, () => {
+  it('should map to the GA4 recommended ecommerce events', () => {
+    expect(
+      analyticsEventToGoogleAnalytics4Event(
+        AnalyticsEvent.ACTION_PAYMENT_COMPLETE
+      )
+    ).to.equal('purchase');
+    expect(
+      analyticsEventToGoogleAnalytics4Event(AnalyticsEvent.IMPRESSION_PAYWALL)
+    ).to.be.null;
+  });
+
+  it('should use the configured event names', () => {
+    const eventNames = {
+      'IMPRESSION_PAYWALL': 'view_promotion',
+      'ACTION_PAYMENT_COMPLETE': null,
+    };
+
+    expect(
+      analyticsEventToGoogleAnalytics4Event(
+        AnalyticsEvent.IMPRESSION_PAYWALL,
+        eventNames
+      )
+    ).to.equal('view_promotion');
+    expect(
+      analyticsEventToGoogleAnalytics4Event(
+        AnalyticsEvent.ACTION_PAYMENT_COMPLETE,
+        eventNames
+      )
+    ).to.be.null;
+    expect(
+      analyticsEventToGoogleAnalytics4Event(
+        AnalyticsEvent.IMPRESSION_OFFERS,
+        eventNames
+      )
+    ).to.equal('view_item_list');
+  });
+
+  it('should validate the configured event names', () => {
+    expect(
+      isValidGoogleAnalyticsEvents({'IMPRESSION_PAYWALL': 'view_promotion'})
+    ).to.be.true;
+    expect(isValidGoogleAnalyticsEvents({'IMPRESSION_PAYWALL': null})).to.be
+      .true;
+    expect(isValidGoogleAnalyticsEvents({'PAYWALL': 'view_promotion'})).to.be
+      .false;
+    expect(isValidGoogleAnalyticsEvents({'IMPRESSION_PAYWALL': 1})).to.be
+      .false;
+  });
+});
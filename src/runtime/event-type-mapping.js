@@ -17,6 +17,7 @@
 import {AnalyticsEvent, EntitlementResult} from '../proto/api_messages';
 import {Event} from '../api/logger-api';
 import {ShowcaseEvent, SubscriptionFlows} from '../api/subscriptions';
+import {getEnumName, isObject} from '../utils/types';
 
 /** @const {!Object<string,AnalyticsEvent>} */
 const PublisherEventToAnalyticsEvent = {
@@ -193,6 +194,20 @@ export const ContributionSpecificAnalyticsEventToGoogleAnalyticsEvent = {
   ),
 };
 
+/**
+ * The GA4 recommended ecommerce events sent for analytics events. Publishers
+ * can rename these events, or send others, with the `googleAnalyticsEvents`
+ * config.
+ * @const {!Object<?AnalyticsEvent,string>}
+ */
+export const AnalyticsEventToGoogleAnalytics4Event = {
+  [AnalyticsEvent.IMPRESSION_OFFERS]: 'view_item_list',
+  [AnalyticsEvent.IMPRESSION_CONTRIBUTION_OFFERS]: 'view_item_list',
+  [AnalyticsEvent.ACTION_OFFER_SELECTED]: 'select_item',
+  [AnalyticsEvent.ACTION_PAYMENT_FLOW_STARTED]: 'begin_checkout',
+  [AnalyticsEvent.ACTION_PAYMENT_COMPLETE]: 'purchase',
+};
+
 /**
  * Converts a propensity event enum into an analytics event enum.
  * @param {!Event|string} propensityEvent
@@ -241,3 +256,34 @@ export function analyticsEventToGoogleAnalyticsEvent(event, subscriptionFlow) {
   }
   return gaEvent || AnalyticsEventToGoogleAnalyticsEvent[event];
 }
+
+/**
+ * Converts an analytics event enum into the name of a GA4 event.
+ * @param {?AnalyticsEvent} event
+ * @param {!Object<string, ?string>=} eventNames GA4 event names keyed by
+ *     analytics event name, e.g. `{'IMPRESSION_PAYWALL': 'view_promotion'}`,
+ *     that override the default ones. Null doesn't send the event.
+ * @returns {?string}
+ */
+export function analyticsEventToGoogleAnalytics4Event(event, eventNames = {}) {
+  const name = getEnumName(AnalyticsEvent, event);
+  if (name in eventNames) {
+    return eventNames[name];
+  }
+  return AnalyticsEventToGoogleAnalytics4Event[event] || null;
+}
+
+/**
+ * @param {*} value
+ * @return {boolean}
+ */
+export function isValidGoogleAnalyticsEvents(value) {
+  if (!isObject(value)) {
+    return false;
+  }
+  return Object.keys(value).every(
+    (key) =>
+      key in AnalyticsEvent &&
+      (value[key] === null || typeof value[key] == 'string')
+  );
+}
@@ -66,4 +66,13 @@ export const ExperimentFlags = {
    * entitlements and clientconfiguration endpoints.
    */
   USE_ARTICLE_ENDPOINT: 'use-article-endpoint',
+
+  /**
+   * Sends analytics requests from a persisted queue, in batches, instead of
+   * through the analytics iframe. Opt-in for now: it's turned on by default
+   * once the analytics service drops the requests sent twice by event ID,
+   * and removed with the iframe sending afterwards. See the rollout in
+   * docs/core-apis.md.
+   */
+  DURABLE_ANALYTICS_QUEUE: 'durable-analytics-queue',
 };
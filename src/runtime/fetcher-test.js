@@ -15,6 +15,7 @@
  */
 
 import {AnalyticsContext} from '../proto/api_messages';
+import {RetryPolicy} from '../utils/retry-policy';
 import {Xhr} from '../utils/xhr';
 import {XhrFetcher} from './fetcher';
 import {serializeProtoMessageForUrl} from '../utils/url';
@@ -88,6 +89,121 @@ describes.realWin('XhrFetcher', {}, (env) => {
       });
       expect(fetchUrl).to.equal(url);
     });
+
+    it('should fallback to standard POST if the beacon is refused', () => {
+      navigator.sendBeacon = () => false;
+      const url = serviceUrl('clientlogs');
+      const retryPolicy = new RetryPolicy();
+      sandbox.spy(fetcher, 'sendPost');
+
+      fetcher.sendBeacon(url, CONTEXT, retryPolicy);
+
+      expect(fetcher.sendPost).to.be.calledWithExactly(
+        url,
+        CONTEXT,
+        retryPolicy
+      );
+      expect(fetchUrl).to.equal(url);
+    });
+  });
+
+  describe('Coalescing', () => {
+    let fetchStub;
+
+    beforeEach(() => {
+      Xhr.prototype.fetch.restore();
+      fetchStub = sandbox.stub(Xhr.prototype, 'fetch').callsFake(() =>
+        Promise.resolve({text: () => Promise.resolve('{"a": 1}')})
+      );
+    });
+
+    it('should share identical GETs in flight', async () => {
+      const first = fetcher.fetchCredentialedJson('url');
+      const second = fetcher.fetchCredentialedJson('url');
+
+      const jsons = await Promise.all([first, second]);
+
+      expect(fetchStub).to.be.calledOnce;
+      expect(jsons[0]).to.deep.equal({'a': 1});
+      expect(jsons[1]).to.deep.equal({'a': 1});
+      expect(jsons[0]).to.not.equal(jsons[1]);
+    });
+
+    it('should not share different GETs', async () => {
+      await Promise.all([
+        fetcher.fetchCredentialedJson('url1'),
+        fetcher.fetchCredentialedJson('url2'),
+      ]);
+
+      expect(fetchStub).to.be.calledTwice;
+    });
+
+    it('should fetch again once the request settled', async () => {
+      await fetcher.fetchCredentialedJson('url');
+      await fetcher.fetchCredentialedJson('url');
+
+      expect(fetchStub).to.be.calledTwice;
+    });
+
+    it('should fetch again after a failure', async () => {
+      fetchStub.onCall(0).rejects(new Error('Network failure'));
+
+      await expect(fetcher.fetchCredentialedJson('url')).to.be.rejectedWith(
+        'Network failure'
+      );
+      await fetcher.fetchCredentialedJson('url');
+
+      expect(fetchStub).to.be.calledTwice;
+    });
+  });
+
+  describe('Retries', () => {
+    let clock;
+    let retryPolicy;
+
+    beforeEach(() => {
+      clock = sandbox.useFakeTimers();
+      retryPolicy = new RetryPolicy({initialDelayMs: 100, jitter: 0});
+    });
+
+    function retriableError() {
+      const err = new Error('HTTP error 503');
+      err.retriable = true;
+      return err;
+    }
+
+    it('should retry with the retry policy', async () => {
+      Xhr.prototype.fetch.restore();
+      const fetchStub = sandbox.stub(Xhr.prototype, 'fetch');
+      fetchStub.onCall(0).rejects(retriableError());
+      fetchStub.resolves({text: () => Promise.resolve('{"a": 1}')});
+
+      const promise = fetcher.fetchCredentialedJson('url', retryPolicy);
+      await clock.tickAsync(100);
+
+      expect(await promise).to.deep.equal({'a': 1});
+      expect(fetchStub).to.be.calledTwice;
+      expect(fetchStub.args[1][0]).to.equal('url');
+    });
+
+    it('should pass the abort signal of each attempt', async () => {
+      retryPolicy = new RetryPolicy({timeoutMs: 1000});
+
+      await fetcher.fetch('url', {method: 'GET'}, retryPolicy);
+
+      expect(fetchUrl).to.equal('url');
+      expect(fetchInit.method).to.equal('GET');
+      expect(fetchInit.signal).to.be.instanceOf(AbortSignal);
+    });
+
+    it('should not retry without a retry policy', async () => {
+      Xhr.prototype.fetch.restore();
+      const error = retriableError();
+      const fetchStub = sandbox.stub(Xhr.prototype, 'fetch').rejects(error);
+
+      await expect(fetcher.fetch('url', {})).to.be.rejectedWith(error);
+      expect(fetchStub).to.be.calledOnce;
+    });
   });
 
   describe('Fetch', () => {
@@ -142,5 +258,17 @@ describes.realWin('XhrFetcher', {}, (env) => {
       };
       fetcher.sendPost(sentUrl, CONTEXT);
     });
+
+    it('should post without cookies', () => {
+      sentInit = {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/x-www-form-urlencoded;charset=UTF-8',
+        },
+        credentials: 'omit',
+        body: 'f.req=' + serializeProtoMessageForUrl(CONTEXT),
+      };
+      fetcher.sendAnonymousPost(sentUrl, CONTEXT);
+    });
   });
 });
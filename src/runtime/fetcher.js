@@ -24,31 +24,45 @@ import {serializeProtoMessageForUrl} from '../utils/url';
 export class Fetcher {
   /**
    * @param {string} unusedUrl
+   * @param {!../utils/retry-policy.RetryPolicy=} unusedRetryPolicy
    * @return {!Promise<!Object>}
    */
-  fetchCredentialedJson(unusedUrl) {}
+  fetchCredentialedJson(unusedUrl, unusedRetryPolicy) {}
 
   /**
    * @param {string} unusedUrl
    * @param {!../utils/xhr.FetchInitDef} unusedInit
+   * @param {!../utils/retry-policy.RetryPolicy=} unusedRetryPolicy
    * @return {!Promise<!../utils/xhr.FetchResponse>}
    */
-  fetch(unusedUrl, unusedInit) {}
+  fetch(unusedUrl, unusedInit, unusedRetryPolicy) {}
 
   /**
    * POST data to a URL endpoint, do not wait for a response.
    * @param {!string} unusedUrl
    * @param {!../proto/api_messages.Message} unusedData
+   * @param {!../utils/retry-policy.RetryPolicy=} unusedRetryPolicy Used if
+   *     the data has to be sent with a regular POST.
    */
-  sendBeacon(unusedUrl, unusedData) {}
+  sendBeacon(unusedUrl, unusedData, unusedRetryPolicy) {}
 
   /**
    * POST data to a URL endpoint, get a Promise for a response
    * @param {!string} unusedUrl
    * @param {!../proto/api_messages.Message} unusedMessage
+   * @param {!../utils/retry-policy.RetryPolicy=} unusedRetryPolicy
    * @return {!Promise<!../utils/xhr.FetchResponse>}
    */
-  sendPost(unusedUrl, unusedMessage) {}
+  sendPost(unusedUrl, unusedMessage, unusedRetryPolicy) {}
+
+  /**
+   * POST data to a URL endpoint without cookies, do not wait for a response.
+   * Unlike beacons, which always send cookies.
+   * @param {!string} unusedUrl
+   * @param {!../proto/api_messages.Message} unusedMessage
+   * @param {!../utils/retry-policy.RetryPolicy=} unusedRetryPolicy
+   */
+  sendAnonymousPost(unusedUrl, unusedMessage, unusedRetryPolicy) {}
 }
 
 /**
@@ -59,58 +73,112 @@ export class XhrFetcher {
    * @param {!Window} win
    */
   constructor(win) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
     /** @const {!Xhr} */
     this.xhr_ = new Xhr(win);
+
+    /**
+     * Response texts of credentialed GETs in flight, keyed by URL.
+     * @private @const {!Object<string, !Promise<string>>}
+     */
+    this.pendingGets_ = {};
   }
 
-  /** @override */
-  fetchCredentialedJson(url) {
-    const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
-      method: 'GET',
-      headers: {'Accept': 'text/plain, application/json'},
-      credentials: 'include',
+  /**
+   * Identical requests made while one is in flight share its response, and
+   * the retry policy of the first one.
+   * @override
+   */
+  fetchCredentialedJson(url, retryPolicy) {
+    return this.fetchCredentialedText_(url, retryPolicy).then((text) => {
+      // Remove "")]}'\n" XSSI prevention prefix in safe responses.
+      const cleanedText = text.replace(/^(\)\]\}'\n)/, '');
+      return parseJson(cleanedText);
     });
-    return this.fetch(url, init).then((response) => {
-      return response.text().then((text) => {
-        // Remove "")]}'\n" XSSI prevention prefix in safe responses.
-        const cleanedText = text.replace(/^(\)\]\}'\n)/, '');
-        return parseJson(cleanedText);
+  }
+
+  /**
+   * @param {string} url
+   * @param {!../utils/retry-policy.RetryPolicy=} retryPolicy
+   * @return {!Promise<string>}
+   * @private
+   */
+  fetchCredentialedText_(url, retryPolicy) {
+    if (!this.pendingGets_[url]) {
+      const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
+        method: 'GET',
+        headers: {'Accept': 'text/plain, application/json'},
+        credentials: 'include',
       });
-    });
+      const text = this.fetch(url, init, retryPolicy).then((response) =>
+        response.text()
+      );
+      this.pendingGets_[url] = text;
+      const settled = () => {
+        delete this.pendingGets_[url];
+      };
+      text.then(settled, settled);
+    }
+    return this.pendingGets_[url];
   }
 
   /** @override */
-  sendPost(url, message) {
-    const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
-      method: 'POST',
-      headers: {
-        'Content-Type': 'application/x-www-form-urlencoded;charset=UTF-8',
-      },
-      credentials: 'include',
-      body: 'f.req=' + serializeProtoMessageForUrl(message),
-    });
-    return this.fetch(url, init).then(
+  sendPost(url, message, retryPolicy) {
+    const init = createPostInit(message, 'include');
+    return this.fetch(url, init, retryPolicy).then(
       (response) => (response && response.json()) || {}
     );
   }
 
   /** @override */
-  fetch(url, init) {
-    return this.xhr_.fetch(url, init);
+  sendAnonymousPost(url, message, retryPolicy) {
+    const init = createPostInit(message, 'omit');
+    this.fetch(url, init, retryPolicy).catch(() => {});
   }
 
   /** @override */
-  sendBeacon(url, data) {
+  fetch(url, init, retryPolicy) {
+    if (!retryPolicy) {
+      return this.xhr_.fetch(url, init);
+    }
+    return retryPolicy.run(this.win_, (signal) =>
+      this.xhr_.fetch(url, signal ? Object.assign({}, init, {signal}) : init)
+    );
+  }
+
+  /** @override */
+  sendBeacon(url, data, retryPolicy) {
     if (navigator.sendBeacon) {
       const headers = {type: 'application/x-www-form-urlencoded;charset=UTF-8'};
       const blob = new Blob(
         ['f.req=' + serializeProtoMessageForUrl(data)],
         headers
       );
-      navigator.sendBeacon(url, blob);
-      return;
+      // The browser refuses beacons when its queue is full. Send a regular
+      // POST in that case.
+      if (navigator.sendBeacon(url, blob) !== false) {
+        return;
+      }
     }
     // Only newer browsers support beacon.  Fallback to standard XHR POST.
-    this.sendPost(url, data);
+    this.sendPost(url, data, retryPolicy);
   }
 }
+
+/**
+ * @param {!../proto/api_messages.Message} message
+ * @param {string} credentials
+ * @return {!../utils/xhr.FetchInitDef}
+ */
+function createPostInit(message, credentials) {
+  return /** @type {!../utils/xhr.FetchInitDef} */ ({
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/x-www-form-urlencoded;charset=UTF-8',
+    },
+    credentials,
+    body: 'f.req=' + serializeProtoMessageForUrl(message),
+  });
+}
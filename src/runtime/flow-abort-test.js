@@ -0,0 +1,203 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsEvent} from '../proto/api_messages';
+import {SubscriptionFlows} from '../api/subscriptions';
+import {isCancelError} from '../utils/errors';
+import {runAbortableFlow} from './flow-abort';
+import {tick} from '../../test/tick';
+
+const FLOW = SubscriptionFlows.SHOW_OFFERS;
+
+describes.realWin('runAbortableFlow', {}, () => {
+  let clock;
+  let deps;
+  let logSwgEvent;
+  let start;
+  let abort;
+  let resolveFlow;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    logSwgEvent = sandbox.spy();
+    deps = {
+      win: () => ({
+        setTimeout: (fn, delay) => setTimeout(fn, delay),
+        clearTimeout: (id) => clearTimeout(id),
+      }),
+      eventManager: () => ({logSwgEvent}),
+    };
+    start = sandbox.stub().returns(
+      new Promise((resolve) => {
+        resolveFlow = resolve;
+      })
+    );
+    abort = sandbox.spy();
+  });
+
+  async function expectAborted(promise) {
+    const reason = await promise.then(
+      () => null,
+      (reason) => reason
+    );
+    expect(isCancelError(reason)).to.be.true;
+    expect(abort).to.be.calledOnce;
+    expect(logSwgEvent).to.be.calledOnce;
+    expect(logSwgEvent.args[0][0]).to.equal(AnalyticsEvent.ACTION_FLOW_ABORTED);
+    expect(logSwgEvent.args[0][1]).to.be.false;
+    expect(logSwgEvent.args[0][2].getSubscriptionFlow()).to.equal(
+      SubscriptionFlows.SHOW_OFFERS
+    );
+  }
+
+  it('should run flows without options', async () => {
+    const promise = runAbortableFlow(deps, FLOW, undefined, start, abort);
+    resolveFlow('result');
+
+    expect(await promise).to.equal('result');
+    expect(abort).to.not.be.called;
+  });
+
+  it('should abort flows through their signal', async () => {
+    const controller = new AbortController();
+    const promise = runAbortableFlow(
+      deps,
+      FLOW,
+      {signal: controller.signal},
+      start,
+      abort
+    );
+    expect(start).to.be.calledOnce;
+    controller.abort();
+
+    await expectAborted(promise);
+  });
+
+  it('should not start aborted flows', async () => {
+    const controller = new AbortController();
+    controller.abort();
+
+    await expect(
+      runAbortableFlow(deps, FLOW, {signal: controller.signal}, start, abort)
+    ).to.be.rejectedWith('Flow aborted');
+    expect(start).to.not.be.called;
+  });
+
+  it('should abort flows once timed out', async () => {
+    const promise = runAbortableFlow(
+      deps,
+      FLOW,
+      {timeoutMs: 1000},
+      start,
+      abort
+    );
+    clock.tick(999);
+    expect(abort).to.not.be.called;
+    clock.tick(1);
+
+    await expectAborted(promise);
+  });
+
+  it('should abort started flows until they are done', async () => {
+    const controller = new AbortController();
+    const promise = runAbortableFlow(
+      deps,
+      FLOW,
+      {signal: controller.signal},
+      start,
+      abort,
+      new Promise(() => {})
+    );
+    resolveFlow('result');
+    expect(await promise).to.equal('result');
+
+    controller.abort();
+    expect(abort).to.be.calledOnce;
+    expect(logSwgEvent).to.be.calledOnce;
+  });
+
+  it('should not abort flows once done', async () => {
+    const controller = new AbortController();
+    const promise = runAbortableFlow(
+      deps,
+      FLOW,
+      {signal: controller.signal},
+      start,
+      abort,
+      Promise.resolve()
+    );
+    resolveFlow('result');
+    expect(await promise).to.equal('result');
+    await tick();
+
+    controller.abort();
+    expect(abort).to.not.be.called;
+  });
+
+  it('should only time flows until they started', async () => {
+    const promise = runAbortableFlow(
+      deps,
+      FLOW,
+      {timeoutMs: 1000},
+      start,
+      abort,
+      new Promise(() => {})
+    );
+    resolveFlow('result');
+    expect(await promise).to.equal('result');
+
+    clock.tick(1000);
+    expect(abort).to.not.be.called;
+  });
+
+  it('should log the abort of any flow', async () => {
+    const controller = new AbortController();
+    const promise = runAbortableFlow(
+      deps,
+      SubscriptionFlows.WAIT_FOR_SUBSCRIPTION_LOOKUP,
+      {signal: controller.signal},
+      start,
+      abort
+    );
+    controller.abort();
+
+    await expect(promise).to.be.rejectedWith('Flow aborted');
+    expect(abort).to.be.calledOnce;
+    expect(logSwgEvent).to.be.calledOnce;
+    expect(logSwgEvent.args[0][0]).to.equal(AnalyticsEvent.ACTION_FLOW_ABORTED);
+    expect(logSwgEvent.args[0][2].getSubscriptionFlow()).to.equal(
+      SubscriptionFlows.WAIT_FOR_SUBSCRIPTION_LOOKUP
+    );
+  });
+
+  it('should not abort completed flows', async () => {
+    const controller = new AbortController();
+    const promise = runAbortableFlow(
+      deps,
+      FLOW,
+      {signal: controller.signal, timeoutMs: 1000},
+      start,
+      abort
+    );
+    resolveFlow('result');
+    expect(await promise).to.equal('result');
+
+    controller.abort();
+    clock.tick(1000);
+    expect(abort).to.not.be.called;
+    expect(logSwgEvent).to.not.be.called;
+  });
+});
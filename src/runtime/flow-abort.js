@@ -0,0 +1,99 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsEvent, EventParams} from '../proto/api_messages';
+import {createCancelError} from '../utils/errors';
+
+/**
+ * Runs a flow that the publisher can abort, through the signal or the timeout
+ * of the flow options. Once aborted, the flow is stopped with `abort`, the
+ * abort is logged as `ACTION_FLOW_ABORTED`, not from a user action, and the
+ * returned promise is rejected with an AbortError unless the flow already
+ * started. An already aborted flow isn't started at all. The signal can abort
+ * the flow until it's done, while the timeout only applies until the flow
+ * started.
+ *
+ * @param {!./deps.DepsDef} deps
+ * @param {string} flow The name of the flow, see `SubscriptionFlows`.
+ * @param {!../api/subscriptions.FlowOptions|undefined} options
+ * @param {function():!Promise<T>} start Starts the flow. Returns its result.
+ * @param {function()} abort Stops the flow.
+ * @param {!Promise=} whenDone Resolved once the flow ends, if it goes on
+ *     after it started, e.g. while its dialog is open.
+ * @return {!Promise<T>}
+ * @template T
+ */
+export function runAbortableFlow(deps, flow, options, start, abort, whenDone) {
+  const signal = options && options.signal;
+  const timeoutMs = options && options.timeoutMs;
+  if (!signal && timeoutMs == null) {
+    return start();
+  }
+  const win = deps.win();
+  if (signal && signal.aborted) {
+    return Promise.reject(createCancelError(win, 'Flow aborted'));
+  }
+  return new Promise((resolve, reject) => {
+    let timeout = null;
+    const stopTimeout = () => {
+      if (timeout !== null) {
+        win.clearTimeout(timeout);
+        timeout = null;
+      }
+    };
+    const stop = () => {
+      stopTimeout();
+      if (signal) {
+        signal.removeEventListener('abort', onAbort);
+      }
+    };
+    const abortWith = (message) => {
+      stop();
+      abort();
+      const eventParams = new EventParams();
+      eventParams.setSubscriptionFlow(flow);
+      deps
+        .eventManager()
+        .logSwgEvent(AnalyticsEvent.ACTION_FLOW_ABORTED, false, eventParams);
+      // Has no effect once the flow started.
+      reject(createCancelError(win, message));
+    };
+    const onAbort = () => abortWith('Flow aborted');
+    if (signal) {
+      signal.addEventListener('abort', onAbort);
+    }
+    if (timeoutMs != null) {
+      timeout = win.setTimeout(() => abortWith('Flow timed out'), timeoutMs);
+    }
+    if (whenDone) {
+      whenDone.then(stop);
+    }
+    start().then(
+      (result) => {
+        if (whenDone) {
+          stopTimeout();
+        } else {
+          stop();
+        }
+        resolve(result);
+      },
+      (reason) => {
+        stop();
+        reject(reason);
+      }
+    );
+  });
+}
@@ -0,0 +1,201 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {Callbacks} from './callbacks';
+import {DialogManager} from '../components/dialog-manager';
+import {FlowController} from './flow-controller';
+import {FlowState, SubscriptionFlows} from '../api/subscriptions';
+import {GlobalDoc} from '../model/doc';
+
+describes.realWin('FlowController', {}, (env) => {
+  let callbacks;
+  let triggerFlowStateChange;
+  let closeDialog;
+  let removeCloseCallback;
+  let dialogManager;
+  let flowController;
+  let clock;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    callbacks = new Callbacks();
+    triggerFlowStateChange = sandbox.spy(callbacks, 'triggerFlowStateChange');
+    dialogManager = new DialogManager(new GlobalDoc(env.win));
+    removeCloseCallback = sandbox.spy();
+    sandbox.stub(dialogManager, 'onClose').callsFake((callback) => {
+      closeDialog = callback;
+      return removeCloseCallback;
+    });
+    flowController = new FlowController({
+      win: () => self,
+      callbacks: () => callbacks,
+      dialogManager: () => dialogManager,
+      diagnostics: () => null,
+    });
+  });
+
+  it('should track started flows', () => {
+    callbacks.triggerFlowStarted(SubscriptionFlows.SHOW_OFFERS);
+
+    expect(flowController.getActiveFlows()).to.deep.equal([
+      {flow: SubscriptionFlows.SHOW_OFFERS, state: FlowState.DIALOG_OPEN},
+    ]);
+    expect(triggerFlowStateChange).to.be.calledOnceWithExactly({
+      flow: SubscriptionFlows.SHOW_OFFERS,
+      state: FlowState.DIALOG_OPEN,
+      previousState: FlowState.IDLE,
+    });
+  });
+
+  it('should hand the dialog over to purchases', () => {
+    flowController.handleFlowStarted(SubscriptionFlows.SHOW_OFFERS);
+    flowController.handleFlowStarted(SubscriptionFlows.SUBSCRIBE);
+
+    expect(flowController.getActiveFlows()).to.deep.equal([
+      {flow: SubscriptionFlows.SUBSCRIBE, state: FlowState.PAY_STARTED},
+    ]);
+    expect(triggerFlowStateChange).to.be.calledWithExactly({
+      flow: SubscriptionFlows.SHOW_OFFERS,
+      state: FlowState.DONE,
+      previousState: FlowState.DIALOG_OPEN,
+    });
+  });
+
+  it('should go through the states of purchases', () => {
+    const flow = SubscriptionFlows.CONTRIBUTE;
+    flowController.handleFlowStarted(flow);
+    flowController.setState(flow, FlowState.PAY_COMPLETE);
+    flowController.setState(flow, FlowState.ACCOUNT_CREATED);
+    expect(flowController.getState(flow)).to.equal(FlowState.ACCOUNT_CREATED);
+
+    flowController.setState(flow, FlowState.DONE);
+    expect(flowController.getState(flow)).to.equal(FlowState.IDLE);
+    expect(flowController.getActiveFlows()).to.be.empty;
+    expect(triggerFlowStateChange).to.have.callCount(4);
+  });
+
+  it('should ignore invalid changes', () => {
+    flowController.setState(
+      SubscriptionFlows.SUBSCRIBE,
+      FlowState.ACCOUNT_CREATED
+    );
+    flowController.handleFlowStarted(SubscriptionFlows.SHOW_OFFERS);
+    flowController.handleFlowStarted(SubscriptionFlows.SHOW_OFFERS);
+    flowController.setState(SubscriptionFlows.SHOW_OFFERS, FlowState.IDLE);
+
+    expect(flowController.getActiveFlows()).to.deep.equal([
+      {flow: SubscriptionFlows.SHOW_OFFERS, state: FlowState.DIALOG_OPEN},
+    ]);
+    expect(triggerFlowStateChange).to.be.calledOnce;
+  });
+
+  it('should end the flows shown in the dialog once closed', () => {
+    flowController.handleFlowStarted(SubscriptionFlows.SUBSCRIBE);
+    flowController.handleFlowStarted(SubscriptionFlows.LINK_ACCOUNT);
+    flowController.handleFlowStarted(SubscriptionFlows.SHOW_LOGIN_PROMPT);
+
+    closeDialog();
+
+    expect(flowController.getActiveFlows()).to.deep.equal([
+      {flow: SubscriptionFlows.SUBSCRIBE, state: FlowState.PAY_STARTED},
+      {flow: SubscriptionFlows.LINK_ACCOUNT, state: FlowState.DIALOG_OPEN},
+    ]);
+  });
+
+  it('should end completed purchases without a dialog', () => {
+    const flow = SubscriptionFlows.SUBSCRIBE;
+    flowController.handleFlowStarted(flow);
+    flowController.setState(flow, FlowState.PAY_COMPLETE);
+    clock.tick(29999);
+    flowController.setState(flow, FlowState.ACCOUNT_CREATED);
+    clock.tick(29999);
+    expect(flowController.getState(flow)).to.equal(FlowState.ACCOUNT_CREATED);
+
+    clock.tick(1);
+
+    expect(flowController.getActiveFlows()).to.be.empty;
+    expect(flowController.canStart(SubscriptionFlows.SHOW_OFFERS)).to.be.true;
+  });
+
+  it('should end completed purchases once their dialog closes', () => {
+    const flow = SubscriptionFlows.CONTRIBUTE;
+    sandbox.stub(dialogManager, 'getDialog').returns({});
+    flowController.handleFlowStarted(flow);
+    flowController.setState(flow, FlowState.PAY_COMPLETE);
+    clock.tick(30000);
+    expect(flowController.getState(flow)).to.equal(FlowState.PAY_COMPLETE);
+
+    closeDialog();
+
+    expect(flowController.getActiveFlows()).to.be.empty;
+  });
+
+  it('should fail the purchases in progress', () => {
+    flowController.handleFlowStarted(SubscriptionFlows.SUBSCRIBE);
+
+    flowController.handlePaymentFailed();
+
+    expect(flowController.getActiveFlows()).to.be.empty;
+    expect(triggerFlowStateChange).to.be.calledWithExactly({
+      flow: SubscriptionFlows.SUBSCRIBE,
+      state: FlowState.FAILED,
+      previousState: FlowState.PAY_STARTED,
+    });
+    expect(triggerFlowStateChange).to.not.be.calledWithMatch({
+      flow: SubscriptionFlows.CONTRIBUTE,
+    });
+  });
+
+  it('should not start flows during purchases', () => {
+    flowController.handleFlowStarted(SubscriptionFlows.SUBSCRIBE);
+
+    expect(
+      flowController.getConflictingFlow(SubscriptionFlows.SHOW_OFFERS)
+    ).to.equal(SubscriptionFlows.SUBSCRIBE);
+    expect(flowController.canStart(SubscriptionFlows.CONTRIBUTE)).to.be.false;
+  });
+
+  it('should not show unsolicited flows over other flows', () => {
+    flowController.handleFlowStarted(SubscriptionFlows.SHOW_OFFERS);
+
+    expect(
+      flowController.getConflictingFlow(SubscriptionFlows.SHOW_METER_TOAST)
+    ).to.equal(SubscriptionFlows.SHOW_OFFERS);
+    expect(
+      flowController.canStart(SubscriptionFlows.SHOW_CONTRIBUTION_OPTIONS)
+    ).to.be.true;
+  });
+
+  it('should show flows over unsolicited flows', () => {
+    flowController.handleFlowStarted(SubscriptionFlows.SHOW_METER_TOAST);
+
+    expect(flowController.canStart(SubscriptionFlows.SHOW_OFFERS)).to.be.true;
+    expect(flowController.canStart(SubscriptionFlows.SHOW_METER_TOAST)).to.be
+      .true;
+  });
+
+  it('should stop tracking the dialog once destroyed', () => {
+    const flow = SubscriptionFlows.SUBSCRIBE;
+    flowController.handleFlowStarted(flow);
+    flowController.setState(flow, FlowState.PAY_COMPLETE);
+
+    flowController.destroy();
+    clock.tick(30000);
+
+    expect(removeCloseCallback).to.be.calledOnce;
+    expect(flowController.getState(flow)).to.equal(FlowState.PAY_COMPLETE);
+  });
+});
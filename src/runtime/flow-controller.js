@@ -0,0 +1,298 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {DiagnosticType, recordDiagnostic} from './diagnostics';
+import {FlowState, SubscriptionFlows} from '../api/subscriptions';
+
+/**
+ * The states each state can change to. Flows are removed once they reach a
+ * final state, which makes them idle again.
+ * @const {!Object<!FlowState, !Array<!FlowState>>}
+ */
+const TRANSITIONS = {
+  [FlowState.IDLE]: [
+    FlowState.DIALOG_OPEN,
+    FlowState.PAY_STARTED,
+    // The payment is completed on the page the user returns to, after a
+    // redirect.
+    FlowState.PAY_COMPLETE,
+    FlowState.DONE,
+    FlowState.CANCELED,
+    FlowState.FAILED,
+  ],
+  [FlowState.DIALOG_OPEN]: [
+    FlowState.DONE,
+    FlowState.CANCELED,
+    FlowState.FAILED,
+  ],
+  [FlowState.PAY_STARTED]: [
+    FlowState.PAY_COMPLETE,
+    FlowState.CANCELED,
+    FlowState.FAILED,
+  ],
+  [FlowState.PAY_COMPLETE]: [
+    FlowState.ACCOUNT_CREATED,
+    FlowState.DONE,
+    FlowState.FAILED,
+  ],
+  [FlowState.ACCOUNT_CREATED]: [FlowState.DONE, FlowState.FAILED],
+};
+
+/** @const {!Array<!FlowState>} */
+const FINAL_STATES = [FlowState.DONE, FlowState.CANCELED, FlowState.FAILED];
+
+/**
+ * States in which the flow is shown in the dialog.
+ * @const {!Array<!FlowState>}
+ */
+const DIALOG_STATES = [
+  FlowState.DIALOG_OPEN,
+  FlowState.PAY_COMPLETE,
+  FlowState.ACCOUNT_CREATED,
+];
+
+/**
+ * States in which a purchase is completed, until the account creation that
+ * follows it is done.
+ * @const {!Array<!FlowState>}
+ */
+const COMPLETED_PURCHASE_STATES = [
+  FlowState.PAY_COMPLETE,
+  FlowState.ACCOUNT_CREATED,
+];
+
+/**
+ * Time after which a completed purchase ends if no dialog is open, e.g. if
+ * the account creation didn't open. Otherwise, it ends once the dialog closes.
+ */
+const COMPLETED_PURCHASE_TIMEOUT_MS = 30000;
+
+/** @const {!Array<string>} */
+const PURCHASE_FLOWS = [
+  SubscriptionFlows.SUBSCRIBE,
+  SubscriptionFlows.CONTRIBUTE,
+];
+
+/**
+ * Flows shown without the user asking for them, which aren't shown over
+ * other flows.
+ * @const {!Array<string>}
+ */
+const UNSOLICITED_FLOWS = [
+  SubscriptionFlows.SHOW_METER_TOAST,
+  SubscriptionFlows.SHOW_LOGIN_NOTIFICATION,
+];
+
+/**
+ * Tracks the state of each flow, and prevents conflicting flows from being
+ * shown at the same time.
+ */
+export class FlowController {
+  /**
+   * @param {!./deps.DepsDef} deps
+   */
+  constructor(deps) {
+    /** @private @const {!./deps.DepsDef} */
+    this.deps_ = deps;
+
+    /** @private @const {!Window} */
+    this.win_ = deps.win();
+
+    /** @private @const {!./callbacks.Callbacks} */
+    this.callbacks_ = deps.callbacks();
+
+    /**
+     * The states of the flows in progress.
+     * @private @const {!Object<string, !FlowState>}
+     */
+    this.states_ = {};
+
+    /**
+     * The timeouts of the completed purchases.
+     * @private @const {!Object<string, number>}
+     */
+    this.purchaseTimeouts_ = {};
+
+    this.callbacks_.setFlowController(this);
+
+    /** @private @const {function()} */
+    this.removeCloseCallback_ = deps
+      .dialogManager()
+      .onClose(() => this.handleDialogClosed_());
+  }
+
+  /**
+   * Stops tracking the dialog, which may be shared with other runtimes.
+   */
+  destroy() {
+    this.removeCloseCallback_();
+    for (const flow of Object.keys(this.purchaseTimeouts_)) {
+      this.clearPurchaseTimeout_(flow);
+    }
+  }
+
+  /**
+   * @param {string} flow
+   * @return {!FlowState}
+   */
+  getState(flow) {
+    return this.states_[flow] || FlowState.IDLE;
+  }
+
+  /**
+   * @return {!Array<!../api/subscriptions.ActiveFlow>}
+   */
+  getActiveFlows() {
+    return Object.keys(this.states_).map((flow) => ({
+      flow,
+      state: this.states_[flow],
+    }));
+  }
+
+  /**
+   * Returns the flow in progress that prevents the flow from starting, if any.
+   * No flow starts during a purchase, and unsolicited flows, like the meter
+   * toast, don't start over any other flow.
+   * @param {string} flow
+   * @return {?string}
+   */
+  getConflictingFlow(flow) {
+    const flows = Object.keys(this.states_);
+    const purchase = flows.find((name) => PURCHASE_FLOWS.includes(name));
+    if (purchase) {
+      return purchase;
+    }
+    if (UNSOLICITED_FLOWS.includes(flow)) {
+      return flows.find((name) => name != flow) || null;
+    }
+    return null;
+  }
+
+  /**
+   * @param {string} flow
+   * @return {boolean}
+   */
+  canStart(flow) {
+    return !this.getConflictingFlow(flow);
+  }
+
+  /**
+   * Moves the flow to the dialog-open or pay-started state. The flows shown
+   * in the dialog until then are done, since the new flow takes it over.
+   * @param {string} flow
+   */
+  handleFlowStarted(flow) {
+    const state = PURCHASE_FLOWS.includes(flow)
+      ? FlowState.PAY_STARTED
+      : FlowState.DIALOG_OPEN;
+    if (!this.canChange_(flow, state)) {
+      return;
+    }
+    for (const name of Object.keys(this.states_)) {
+      if (name != flow && this.isInDialog_(name)) {
+        this.setState(name, FlowState.DONE);
+      }
+    }
+    this.setState(flow, state);
+  }
+
+  /**
+   * Fails the purchases in progress.
+   */
+  handlePaymentFailed() {
+    for (const flow of PURCHASE_FLOWS) {
+      if (this.states_[flow]) {
+        this.setState(flow, FlowState.FAILED);
+      }
+    }
+  }
+
+  /**
+   * Changes the state of the flow. Changes that the flow's current state
+   * doesn't allow are ignored, e.g. canceling a flow that isn't in progress.
+   * @param {string} flow
+   * @param {!FlowState} state
+   */
+  setState(flow, state) {
+    if (!this.canChange_(flow, state)) {
+      return;
+    }
+    const previousState = this.getState(flow);
+    if (FINAL_STATES.includes(state)) {
+      delete this.states_[flow];
+    } else {
+      this.states_[flow] = state;
+    }
+    this.clearPurchaseTimeout_(flow);
+    if (COMPLETED_PURCHASE_STATES.includes(state)) {
+      this.purchaseTimeouts_[flow] = this.win_.setTimeout(() => {
+        delete this.purchaseTimeouts_[flow];
+        if (!this.deps_.dialogManager().getDialog()) {
+          this.setState(flow, FlowState.DONE);
+        }
+      }, COMPLETED_PURCHASE_TIMEOUT_MS);
+    }
+    recordDiagnostic(this.deps_, DiagnosticType.FLOW, {
+      'flow': flow,
+      'state': state,
+      'previousState': previousState,
+    });
+    this.callbacks_.triggerFlowStateChange({flow, state, previousState});
+  }
+
+  /**
+   * @param {string} flow
+   * @private
+   */
+  clearPurchaseTimeout_(flow) {
+    if (flow in this.purchaseTimeouts_) {
+      this.win_.clearTimeout(this.purchaseTimeouts_[flow]);
+      delete this.purchaseTimeouts_[flow];
+    }
+  }
+
+  /**
+   * @param {string} flow
+   * @param {!FlowState} state
+   * @return {boolean}
+   * @private
+   */
+  canChange_(flow, state) {
+    return TRANSITIONS[this.getState(flow)].includes(state);
+  }
+
+  /**
+   * @param {string} flow
+   * @return {boolean}
+   * @private
+   */
+  isInDialog_(flow) {
+    // Accounts are linked in a popup.
+    return (
+      flow != SubscriptionFlows.LINK_ACCOUNT &&
+      DIALOG_STATES.includes(this.getState(flow))
+    );
+  }
+
+  /** @private */
+  handleDialogClosed_() {
+    for (const flow of Object.keys(this.states_)) {
+      if (this.isInDialog_(flow)) {
+        this.setState(flow, FlowState.DONE);
+      }
+    }
+  }
+}
@@ -30,6 +30,7 @@ describes.realWin('GoogleAnalyticsEventListener', {}, (env) => {
   let winMock;
   let eventManager;
   let deps;
+  let config;
   let listener;
 
   beforeEach(() => {
@@ -48,6 +49,11 @@ describes.realWin('GoogleAnalyticsEventListener', {}, (env) => {
     deps = new DepsDef();
     sandbox.stub(deps, 'win').returns(win);
     sandbox.stub(deps, 'eventManager').returns(eventManager);
+    config = {};
+    sandbox.stub(deps, 'config').returns(config);
+    sandbox
+      .stub(deps, 'analytics')
+      .returns({getTransactionId: () => 'transaction1'});
     listener = new GoogleAnalyticsEventListener(deps);
     if (callStart) {
       listener.start();
@@ -242,4 +248,207 @@ describes.realWin('GoogleAnalyticsEventListener', {}, (env) => {
     });
     await eventManager.lastAction_;
   });
+
+  describe('GA4', () => {
+    it('should log to gtag', async () => {
+      setupEnvironment(
+        Object.assign({}, env.win, {
+          gtag: () => {},
+        }),
+        true
+      );
+      winMock
+        .expects('gtag')
+        .withExactArgs('event', 'view_item_list', {'items': []})
+        .once();
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.IMPRESSION_OFFERS,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+      });
+      await eventManager.lastAction_;
+    });
+
+    it('should log purchases with the SKU to gtag', async () => {
+      setupEnvironment(
+        Object.assign({}, env.win, {
+          gtag: () => {},
+        }),
+        true
+      );
+      winMock
+        .expects('gtag')
+        .withExactArgs('event', 'purchase', {
+          'items': [
+            {
+              'item_id': 'sku1',
+              'item_category': SubscriptionFlows.SUBSCRIBE,
+            },
+          ],
+          'transaction_id': 'transaction1',
+        })
+        .once();
+      const eventParams = new EventParams();
+      eventParams.setSku('sku1');
+      eventParams.setSubscriptionFlow(SubscriptionFlows.SUBSCRIBE);
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.ACTION_PAYMENT_COMPLETE,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        additionalParameters: eventParams,
+      });
+      await eventManager.lastAction_;
+    });
+
+    it('should log the price and currency of the SKU to gtag', async () => {
+      setupEnvironment(
+        Object.assign({}, env.win, {
+          gtag: () => {},
+        }),
+        true
+      );
+      winMock
+        .expects('gtag')
+        .withExactArgs('event', 'purchase', {
+          'items': [
+            {
+              'item_id': 'sku1',
+              'item_category': SubscriptionFlows.SUBSCRIBE,
+              'price': 9.99,
+            },
+          ],
+          'value': 9.99,
+          'currency': 'USD',
+          'transaction_id': 'transaction1',
+        })
+        .once();
+      const eventParams = new EventParams();
+      eventParams.setSku('sku1');
+      eventParams.setSubscriptionFlow(SubscriptionFlows.SUBSCRIBE);
+      eventParams.setPrice(9.99);
+      eventParams.setCurrency('USD');
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.ACTION_PAYMENT_COMPLETE,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        additionalParameters: eventParams,
+      });
+      await eventManager.lastAction_;
+    });
+
+    it('should log free SKUs with a zero value', async () => {
+      setupEnvironment(
+        Object.assign({}, env.win, {
+          gtag: () => {},
+        }),
+        true
+      );
+      winMock
+        .expects('gtag')
+        .withExactArgs('event', 'select_item', {
+          'items': [{'item_id': 'sku1', 'price': 0}],
+          'value': 0,
+          'currency': 'EUR',
+        })
+        .once();
+      const eventParams = new EventParams();
+      eventParams.setSku('sku1');
+      eventParams.setPrice(0);
+      eventParams.setCurrency('EUR');
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.ACTION_OFFER_SELECTED,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        additionalParameters: eventParams,
+      });
+      await eventManager.lastAction_;
+    });
+
+    it('should log to both ga and gtag', async () => {
+      setupEnvironment(
+        Object.assign({}, env.win, {
+          ga: () => {},
+          gtag: () => {},
+        }),
+        true
+      );
+      winMock.expects('ga').once();
+      winMock.expects('gtag').once();
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.IMPRESSION_OFFERS,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+      });
+      await eventManager.lastAction_;
+    });
+
+    it('should push to the data layer without gtag', async () => {
+      const dataLayer = [];
+      setupEnvironment(Object.assign({}, env.win, {dataLayer}), true);
+      const eventParams = new EventParams();
+      eventParams.setSku('sku1');
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.ACTION_OFFER_SELECTED,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        additionalParameters: eventParams,
+      });
+      await eventManager.lastAction_;
+
+      expect(dataLayer).to.deep.equal([
+        {'ecommerce': null},
+        {
+          'event': 'select_item',
+          'ecommerce': {'items': [{'item_id': 'sku1'}]},
+        },
+      ]);
+    });
+
+    it('should push the price and currency to the data layer', async () => {
+      const dataLayer = [];
+      setupEnvironment(Object.assign({}, env.win, {dataLayer}), true);
+      const eventParams = new EventParams();
+      eventParams.setSku('sku1');
+      eventParams.setPrice(4.5);
+      eventParams.setCurrency('GBP');
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.ACTION_OFFER_SELECTED,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        additionalParameters: eventParams,
+      });
+      await eventManager.lastAction_;
+
+      expect(dataLayer).to.deep.equal([
+        {'ecommerce': null},
+        {
+          'event': 'select_item',
+          'ecommerce': {
+            'items': [{'item_id': 'sku1', 'price': 4.5}],
+            'value': 4.5,
+            'currency': 'GBP',
+          },
+        },
+      ]);
+    });
+
+    it('should use the configured event names', async () => {
+      setupEnvironment(
+        Object.assign({}, env.win, {
+          gtag: () => {},
+        }),
+        true
+      );
+      config.googleAnalyticsEvents = {
+        'IMPRESSION_PAYWALL': 'view_promotion',
+        'IMPRESSION_OFFERS': null,
+      };
+      winMock
+        .expects('gtag')
+        .withExactArgs('event', 'view_promotion', {'items': []})
+        .once();
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.IMPRESSION_OFFERS,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+      });
+      eventManager.logEvent({
+        eventType: AnalyticsEvent.IMPRESSION_PAYWALL,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+      });
+      await eventManager.lastAction_;
+    });
+  });
 });
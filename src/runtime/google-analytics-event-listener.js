@@ -14,13 +14,20 @@
  * limitations under the License.
  */
 
-import {analyticsEventToGoogleAnalyticsEvent} from './event-type-mapping';
+import {AnalyticsEvent, EventParams} from '../proto/api_messages';
+import {
+  analyticsEventToGoogleAnalytics4Event,
+  analyticsEventToGoogleAnalyticsEvent,
+} from './event-type-mapping';
 
 export class GoogleAnalyticsEventListener {
   /**
    * @param {!./deps.DepsDef} deps
    */
   constructor(deps) {
+    /** @private @const {!./deps.DepsDef} */
+    this.deps_ = deps;
+
     /** @private @const {!Window} */
     this.win_ = deps.win();
 
@@ -42,8 +49,12 @@ export class GoogleAnalyticsEventListener {
    * @param {!../api/client-event-manager-api.ClientEvent} event
    */
   handleClientEvent_(event) {
-    // Bail immediately if ga function doesn't exist in Window.
-    if (typeof this.win_.ga != 'function') {
+    const hasUniversalAnalytics = typeof this.win_.ga == 'function';
+    const hasGtag = typeof this.win_.gtag == 'function';
+    const hasDataLayer = Array.isArray(this.win_.dataLayer);
+    // Bail immediately if neither analytics.js, gtag.js nor Google Tag Manager
+    // is on the page.
+    if (!hasUniversalAnalytics && !hasGtag && !hasDataLayer) {
       return;
     }
     let subscriptionFlow = '';
@@ -57,8 +68,68 @@ export class GoogleAnalyticsEventListener {
       event.eventType,
       subscriptionFlow
     );
-    if (gaEvent) {
+    if (hasUniversalAnalytics && gaEvent) {
       this.win_.ga('send', 'event', gaEvent);
     }
+    if (!hasGtag && !hasDataLayer) {
+      return;
+    }
+    const ga4EventName = analyticsEventToGoogleAnalytics4Event(
+      event.eventType,
+      this.deps_.config().googleAnalyticsEvents
+    );
+    if (!ga4EventName) {
+      return;
+    }
+    const params = this.getGoogleAnalytics4Params_(event, subscriptionFlow);
+    if (hasGtag) {
+      // gtag.js pushes the event to the data layer itself.
+      this.win_.gtag('event', ga4EventName, params);
+    } else {
+      // Clears the previous ecommerce object, as recommended by Google Tag
+      // Manager.
+      this.win_.dataLayer.push({'ecommerce': null});
+      this.win_.dataLayer.push({'event': ga4EventName, 'ecommerce': params});
+    }
+  }
+
+  /**
+   * Returns the parameters of a GA4 ecommerce event.
+   * @param {!../api/client-event-manager-api.ClientEvent} event
+   * @param {string} subscriptionFlow
+   * @return {!Object}
+   * @private
+   */
+  getGoogleAnalytics4Params_(event, subscriptionFlow) {
+    const item = {};
+    let price = null;
+    let currency = null;
+    if (event.additionalParameters instanceof EventParams) {
+      const sku = event.additionalParameters.getSku();
+      if (sku) {
+        item['item_id'] = sku;
+      }
+      price = event.additionalParameters.getPrice();
+      currency = event.additionalParameters.getCurrency();
+    }
+    if (subscriptionFlow) {
+      item['item_category'] = subscriptionFlow;
+    }
+    if (price != null) {
+      item['price'] = price;
+    }
+    const params = {'items': Object.keys(item).length ? [item] : []};
+    if (price != null) {
+      // The event has a single item, so its value is the price of the item.
+      params['value'] = price;
+    }
+    if (currency) {
+      params['currency'] = currency;
+    }
+    if (event.eventType === AnalyticsEvent.ACTION_PAYMENT_COMPLETE) {
+      // GA4 deduplicates purchases by transaction ID.
+      params['transaction_id'] = this.deps_.analytics().getTransactionId();
+    }
+    return params;
   }
 }
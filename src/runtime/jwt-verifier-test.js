@@ -0,0 +1,199 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {JwtVerifier} from './jwt-verifier';
+import {Storage} from './storage';
+import {XhrFetcher} from './fetcher';
+import {base64UrlEncodeFromBytes, utf8EncodeSync} from '../utils/bytes';
+
+const JWKS_URL =
+  'https://www.googleapis.com/robot/v1/metadata/jwk/subscribewithgoogle@system.gserviceaccount.com';
+
+describes.realWin('JwtVerifier', {}, (env) => {
+  let win;
+  let fetcherMock;
+  let verifier;
+  let verifyStub;
+  let now;
+
+  beforeEach(() => {
+    win = env.win;
+    const fetcher = new XhrFetcher(win);
+    fetcherMock = sandbox.mock(fetcher);
+    verifier = new JwtVerifier(win, fetcher);
+    verifyStub = sandbox.stub(verifier.jwtHelper_, 'verify').resolves(true);
+    now = 1600389016959;
+    sandbox.stub(Date, 'now').callsFake(() => now);
+  });
+
+  afterEach(() => {
+    fetcherMock.verify();
+  });
+
+  function token(kid) {
+    const header = base64UrlEncodeFromBytes(
+      utf8EncodeSync(JSON.stringify({'alg': 'ES256', 'kid': kid}))
+    );
+    return `${header}.e30.SIG`;
+  }
+
+  function expectJwksFetch(keys, times = 1) {
+    fetcherMock
+      .expects('fetch')
+      .withExactArgs(JWKS_URL, {method: 'GET', credentials: 'omit'})
+      .resolves({ok: true, json: () => Promise.resolve({'keys': keys})})
+      .exactly(times);
+  }
+
+  it('should verify tokens against the matching key', async () => {
+    expectJwksFetch([{'kid': 'k1'}, {'kid': 'k2', 'x': 'y'}]);
+
+    await expect(verifier.verify(token('k2'))).to.eventually.be.true;
+    expect(verifyStub).to.be.calledOnceWith(token('k2'), {
+      'kid': 'k2',
+      'x': 'y',
+    });
+  });
+
+  it('should reject invalid signatures', async () => {
+    expectJwksFetch([{'kid': 'k1'}]);
+    verifyStub.resolves(false);
+
+    await expect(verifier.verify(token('k1'))).to.eventually.be.false;
+  });
+
+  it('should cache keys', async () => {
+    expectJwksFetch([{'kid': 'k1'}]);
+
+    await verifier.verify(token('k1'));
+    await expect(verifier.verify(token('k1'))).to.eventually.be.true;
+  });
+
+  it('should refetch expired keys', async () => {
+    expectJwksFetch([{'kid': 'k1'}], 2);
+
+    await verifier.verify(token('k1'));
+    now += 60 * 60 * 1000 + 1;
+    await expect(verifier.verify(token('k1'))).to.eventually.be.true;
+  });
+
+  it('should refetch keys for an unknown key ID', async () => {
+    expectJwksFetch([{'kid': 'k1'}]);
+    expectJwksFetch([{'kid': 'k2'}]);
+
+    await verifier.verify(token('k1'));
+    now += 60 * 1000;
+    await expect(verifier.verify(token('k2'))).to.eventually.be.true;
+  });
+
+  it('should throttle refetches for unknown key IDs', async () => {
+    expectJwksFetch([{'kid': 'k1'}]);
+
+    await verifier.verify(token('k1'));
+    now += 1000;
+    await expect(verifier.verify(token('forged'))).to.eventually.be.false;
+    expect(verifyStub).to.be.calledOnce;
+  });
+
+  it('should retry after a failed fetch', async () => {
+    fetcherMock
+      .expects('fetch')
+      .rejects(new Error('offline'))
+      .once();
+    expectJwksFetch([{'kid': 'k1'}]);
+
+    await expect(verifier.verify(token('k1'))).to.eventually.be.false;
+    await expect(verifier.verify(token('k1'))).to.eventually.be.true;
+  });
+
+  it('should reject tokens if the keys fail to load', async () => {
+    fetcherMock
+      .expects('fetch')
+      .resolves({ok: false, status: 500, json: () => Promise.resolve({})})
+      .once();
+
+    await expect(verifier.verify(token('k1'))).to.eventually.be.false;
+    expect(verifyStub).to.not.be.called;
+  });
+
+  describe('with storage', () => {
+    let storage;
+
+    beforeEach(() => {
+      storage = new Storage(win, {storageBackends: {local: ['memory']}});
+      verifier = new JwtVerifier(win, verifier.fetcher_, storage);
+      verifyStub = sandbox.stub(verifier.jwtHelper_, 'verify').resolves(true);
+    });
+
+    function newVerifier() {
+      const newVerifier = new JwtVerifier(win, verifier.fetcher_, storage);
+      newVerifier.jwtHelper_ = verifier.jwtHelper_;
+      return newVerifier;
+    }
+
+    it('should use the stored keys on the next page load', async () => {
+      expectJwksFetch([{'kid': 'k1'}]);
+      await verifier.verify(token('k1'));
+      expect(
+        JSON.parse(await storage.get('jwks', /* useLocalStorage */ true))
+      ).to.deep.equal({'keys': {'k1': {'kid': 'k1'}}, 'fetchedAt': now});
+
+      now += 1000;
+      await expect(newVerifier().verify(token('k1'))).to.eventually.be.true;
+    });
+
+    it('should refetch the stored keys once expired', async () => {
+      expectJwksFetch([{'kid': 'k1'}], 2);
+      await verifier.verify(token('k1'));
+
+      now += 60 * 60 * 1000 + 1;
+      await expect(newVerifier().verify(token('k1'))).to.eventually.be.true;
+    });
+
+    it('should use expired keys if they fail to load', async () => {
+      expectJwksFetch([{'kid': 'k1'}]);
+      await verifier.verify(token('k1'));
+      fetcherMock
+        .expects('fetch')
+        .rejects(new Error('offline'))
+        .once();
+
+      now += 24 * 60 * 60 * 1000;
+      await expect(newVerifier().verify(token('k1'))).to.eventually.be.true;
+    });
+
+    it('should ignore malformed stored keys', async () => {
+      await storage.set('jwks', '{"keys": 1}', /* useLocalStorage */ true);
+      expectJwksFetch([{'kid': 'k1'}]);
+
+      await expect(verifier.verify(token('k1'))).to.eventually.be.true;
+    });
+  });
+
+  it('should reject tokens without a key ID', async () => {
+    fetcherMock.expects('fetch').never();
+
+    await expect(verifier.verify(token(undefined))).to.eventually.be.false;
+    await expect(verifier.verify('VeRy BroKen')).to.eventually.be.false;
+  });
+
+  it('should reject tokens if WebCrypto is not available', async () => {
+    fetcherMock.expects('fetch').never();
+    verifier = new JwtVerifier({}, verifier.fetcher_);
+
+    await expect(verifier.verify(token('k1'))).to.eventually.be.false;
+  });
+});
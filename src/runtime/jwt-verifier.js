@@ -0,0 +1,217 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {JwtHelper} from '../utils/jwt';
+import {isObject} from '../utils/types';
+import {tryParseJson} from '../utils/json';
+
+/** Public keys of the account that signs SwG tokens, as a JSON Web Key Set. */
+const JWKS_URL =
+  'https://www.googleapis.com/robot/v1/metadata/jwk/subscribewithgoogle@system.gserviceaccount.com';
+
+/** How long fetched signing keys are trusted before they're refetched. */
+const KEYS_TTL_MS = 60 * 60 * 1000;
+
+/** Local storage key of the signing keys and when they were fetched. */
+const KEYS_STORAGE_KEY = 'jwks';
+
+/**
+ * Minimum time between refetches triggered by an unknown key ID. Key IDs come
+ * from untrusted tokens, so they must not be able to force a fetch each time.
+ */
+const MIN_REFETCH_INTERVAL_MS = 60 * 1000;
+
+/**
+ * Verifies signatures of JWTs issued by SwG, such as signed entitlements,
+ * using the JSON Web Key Set of SwG's signing account. Keys are cached in
+ * memory and in local storage, so that page loads don't wait for them, and
+ * refetched when they expire or when a token is signed with an unknown key,
+ * which happens after key rotation. Expired keys are still used when they
+ * can't be refetched, e.g. while offline.
+ */
+export class JwtVerifier {
+  /**
+   * @param {!Window} win
+   * @param {!./fetcher.Fetcher} fetcher
+   * @param {?./storage.Storage=} storage
+   */
+  constructor(win, fetcher, storage = null) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {!./fetcher.Fetcher} */
+    this.fetcher_ = fetcher;
+
+    /** @private @const {?./storage.Storage} */
+    this.storage_ = storage;
+
+    /** @private @const {!JwtHelper} */
+    this.jwtHelper_ = new JwtHelper();
+
+    /**
+     * Signing keys, keyed by key ID. Resolves to null if there are none yet.
+     * @private {?Promise<?Object<string, !JsonObject>>}
+     */
+    this.keys_ = null;
+
+    /** @private {number} */
+    this.keysFetchedAt_ = 0;
+  }
+
+  /**
+   * Resolves to true if the token is signed by one of SwG's keys. Resolves to
+   * false if it isn't, or if that can't be determined, for instance because
+   * WebCrypto isn't available.
+   * @param {string} encodedToken
+   * @return {!Promise<boolean>}
+   */
+  verify(encodedToken) {
+    const subtle = this.win_.crypto && this.win_.crypto.subtle;
+    if (!subtle) {
+      return Promise.resolve(false);
+    }
+    let kid;
+    try {
+      const header = this.jwtHelper_.decodeHeader(encodedToken);
+      kid = header && header['kid'];
+    } catch (e) {
+      return Promise.resolve(false);
+    }
+    if (typeof kid != 'string') {
+      return Promise.resolve(false);
+    }
+    return this.getKey_(kid)
+      .then((jwk) =>
+        jwk ? this.jwtHelper_.verify(encodedToken, jwk, subtle) : false
+      )
+      .catch(() => false);
+  }
+
+  /**
+   * @param {string} kid
+   * @return {!Promise<?JsonObject>}
+   * @private
+   */
+  getKey_(kid) {
+    const keysPromise = this.getKeys_();
+    return keysPromise.then((keys) => {
+      if (this.keys_ && this.keys_ !== keysPromise) {
+        // Another verification started fetching the keys in the meantime.
+        return this.getKey_(kid);
+      }
+      const age = Date.now() - this.keysFetchedAt_;
+      if (keys && age <= KEYS_TTL_MS) {
+        if (keys[kid]) {
+          return keys[kid];
+        }
+        // The keys may have been rotated since they were fetched.
+        if (age < MIN_REFETCH_INTERVAL_MS) {
+          return null;
+        }
+      }
+      return this.fetchKeys_(keys).then((keys) => keys[kid] || null);
+    });
+  }
+
+  /**
+   * @return {!Promise<?Object<string, !JsonObject>>} The keys in memory, or
+   *     else the stored ones.
+   * @private
+   */
+  getKeys_() {
+    if (!this.keys_) {
+      this.keys_ = this.readStoredKeys_();
+    }
+    return this.keys_;
+  }
+
+  /**
+   * @return {!Promise<?Object<string, !JsonObject>>}
+   * @private
+   */
+  readStoredKeys_() {
+    if (!this.storage_) {
+      return Promise.resolve(null);
+    }
+    return this.storage_
+      .get(KEYS_STORAGE_KEY, /* useLocalStorage */ true)
+      .then((value) => {
+        const stored = value ? tryParseJson(value) : null;
+        if (
+          !stored ||
+          !isObject(stored['keys']) ||
+          typeof stored['fetchedAt'] != 'number'
+        ) {
+          return null;
+        }
+        // Keys stored in the future would otherwise be trusted for longer.
+        this.keysFetchedAt_ = Math.min(stored['fetchedAt'], Date.now());
+        return stored['keys'];
+      });
+  }
+
+  /**
+   * @param {?Object<string, !JsonObject>} fallbackKeys Used if the keys can't
+   *     be fetched.
+   * @return {!Promise<!Object<string, !JsonObject>>}
+   * @private
+   */
+  fetchKeys_(fallbackKeys) {
+    const fetchedAt = Date.now();
+    this.keysFetchedAt_ = fetchedAt;
+    const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
+      method: 'GET',
+      credentials: 'omit',
+    });
+    const keys = this.fetcher_
+      .fetch(JWKS_URL, init)
+      .then((response) => {
+        if (!response.ok) {
+          throw new Error('Failed to fetch signing keys: ' + response.status);
+        }
+        return response.json();
+      })
+      .then((json) => {
+        const keysById = {};
+        for (const jwk of (json && json['keys']) || []) {
+          if (jwk && typeof jwk['kid'] == 'string') {
+            keysById[jwk['kid']] = jwk;
+          }
+        }
+        if (this.storage_) {
+          this.storage_.set(
+            KEYS_STORAGE_KEY,
+            JSON.stringify({'keys': keysById, 'fetchedAt': fetchedAt}),
+            /* useLocalStorage */ true
+          );
+        }
+        return keysById;
+      });
+    if (fallbackKeys) {
+      // Keep using the previous keys until the next refetch.
+      this.keys_ = keys.catch(() => fallbackKeys);
+      return this.keys_;
+    }
+    this.keys_ = keys;
+    // Don't keep a failed fetch around, so the next verification retries.
+    keys.catch(() => {
+      if (this.keys_ === keys) {
+        this.keys_ = null;
+      }
+    });
+    return keys;
+  }
+}
@@ -34,7 +34,7 @@ import {
   LinkbackFlow,
 } from './link-accounts-flow';
 import {PageConfig} from '../model/page-config';
-import {createCancelError} from '../utils/errors';
+import {SwgErrorCode, createCancelError} from '../utils/errors';
 import {tick} from '../../test/tick';
 
 describes.realWin('LinkbackFlow', {}, (env) => {
@@ -137,6 +137,31 @@ describes.realWin('LinkbackFlow', {}, (env) => {
     linkbackFlow.start();
     expect(receivedType).to.equal(AnalyticsEvent.IMPRESSION_LINK);
   });
+
+  it('should report a blocked popup', () => {
+    const triggerErrorStub = sandbox.stub(runtime.callbacks(), 'triggerError');
+    dialogManagerMock.expects('popupOpened').withExactArgs(null).once();
+    activitiesMock.expects('open').returns({targetWin: null}).once();
+    linkbackFlow.start();
+
+    expect(triggerErrorStub).to.be.calledOnce;
+    const error = triggerErrorStub.args[0][0];
+    expect(error.code).to.equal(SwgErrorCode.POPUP_BLOCKED);
+    expect(error.retryable).to.be.true;
+  });
+
+  it('should close the popup when aborted', () => {
+    const popupWin = {close: sandbox.spy()};
+    dialogManagerMock.expects('popupOpened').withExactArgs(popupWin).once();
+    dialogManagerMock.expects('popupClosed').once();
+    activitiesMock.expects('open').returns({targetWin: popupWin}).once();
+    linkbackFlow.start();
+
+    linkbackFlow.abort();
+
+    expect(popupWin.close).to.be.calledOnce;
+    dialogManagerMock.verify();
+  });
 });
 
 describes.realWin('LinkCompleteFlow', {}, (env) => {
@@ -21,8 +21,13 @@ import {
   LinkingInfoResponse,
 } from '../proto/api_messages';
 import {SubscriptionFlows, WindowOpenMode} from '../api/subscriptions';
+import {
+  SwgError,
+  SwgErrorCode,
+  createCancelError,
+  isCancelError,
+} from '../utils/errors';
 import {acceptPortResultData} from '../utils/activity-utils';
-import {createCancelError, isCancelError} from '../utils/errors';
 import {feArgs, feOrigin, feUrl} from './services';
 
 const LINK_REQUEST_ID = 'swg-link';
@@ -46,6 +51,9 @@ export class LinkbackFlow {
 
     /** @private @const {!../components/dialog-manager.DialogManager} */
     this.dialogManager_ = deps.dialogManager();
+
+    /** @private {?Window} */
+    this.popupWin_ = null;
   }
 
   /**
@@ -73,9 +81,34 @@ export class LinkbackFlow {
       {}
     );
     this.deps_.eventManager().logSwgEvent(AnalyticsEvent.IMPRESSION_LINK);
-    this.dialogManager_.popupOpened(opener && opener.targetWin);
+    const popupWin = opener && opener.targetWin;
+    this.popupWin_ = popupWin || null;
+    if (!forceRedirect && !popupWin) {
+      // The user can try again, e.g. once popups are allowed.
+      this.deps_
+        .callbacks()
+        .triggerError(
+          new SwgError(
+            SwgErrorCode.POPUP_BLOCKED,
+            'The popup to link accounts was blocked',
+            true
+          )
+        );
+    }
+    this.dialogManager_.popupOpened(popupWin);
     return Promise.resolve();
   }
+
+  /**
+   * Closes the popup of the flow.
+   */
+  abort() {
+    if (this.popupWin_) {
+      this.popupWin_.close();
+      this.popupWin_ = null;
+    }
+    this.dialogManager_.popupClosed();
+  }
 }
 
 /**
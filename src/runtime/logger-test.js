@@ -57,6 +57,7 @@ describes.realWin('Logger', {}, (env) => {
       eventManager: () => eventManager,
       pageConfig: () => pageConfig,
       config: () => config,
+      consentManager: () => ({hasConsent: () => true}),
     };
     logger = new Logger(fakeDeps);
 
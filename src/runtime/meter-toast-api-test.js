@@ -18,6 +18,7 @@ import {ActivityPort} from '../components/activities';
 import {AnalyticsEvent} from '../proto/api_messages';
 import {ClientEventManager} from './client-event-manager';
 import {ConfiguredRuntime} from './runtime';
+import {FlowState, SubscriptionFlows} from '../api/subscriptions';
 import {
   IFRAME_BOX_SHADOW,
   MINIMIZED_IFRAME_SIZE,
@@ -150,6 +151,19 @@ describes.realWin('MeterToastApi', {}, (env) => {
     await meterToastApi.start();
   });
 
+  it('should not show the toast over other flows', async () => {
+    runtime
+      .flowController()
+      .setState(SubscriptionFlows.SHOW_OFFERS, FlowState.DIALOG_OPEN);
+    callbacksMock.expects('triggerFlowStarted').never();
+    activitiesMock.expects('openIframe').never();
+    dialogManagerMock.expects('openDialog').never();
+
+    await meterToastApi.start();
+
+    expect(onConsumeCallbackFake).to.not.be.called;
+  });
+
   it('should start the flow correctly with iframe url', async () => {
     const meterToastApiWithUrl = new MeterToastApi(runtime, {
       iframeUrl: '/meteriframe',
@@ -120,6 +120,12 @@ export class MeterToastApi {
    * @return {!Promise}
    */
   start() {
+    const flowController = this.deps_.flowController();
+    if (!flowController.canStart(SubscriptionFlows.SHOW_METER_TOAST)) {
+      // The toast isn't shown over other flows, and the meter isn't consumed
+      // without it.
+      return Promise.resolve();
+    }
     this.deps_
       .callbacks()
       .triggerFlowStarted(SubscriptionFlows.SHOW_METER_TOAST);
@@ -274,5 +274,13 @@ describes.realWin('MiniPromptApi', {}, (env) => {
       });
       expect(miniPrompt.style.visibility).to.equal('hidden');
     });
+
+    it('should remove the prompt once destroyed', () => {
+      autoPromptType = AutoPromptType.CONTRIBUTION;
+      miniPromptApi.create({autoPromptType, clickCallback: clickCallbackSpy});
+
+      miniPromptApi.destroy();
+      expectMiniPromptNotCreated();
+    });
   });
 });
@@ -18,7 +18,7 @@ import {AnalyticsEvent} from '../proto/api_messages';
 import {AutoPromptType} from '../api/basic-subscriptions';
 import {SWG_I18N_STRINGS} from '../i18n/swg-strings';
 import {assert, warn} from '../utils/log';
-import {createElement} from '../utils/dom';
+import {createElement, removeElement} from '../utils/dom';
 import {msg} from '../utils/i18n';
 import {setStyle} from '../utils/style';
 
@@ -51,6 +51,9 @@ export class MiniPromptApi {
 
     /** @private @const {!./client-event-manager.ClientEventManager} */
     this.eventManager_ = deps.eventManager();
+
+    /** @private {?Element} */
+    this.miniPromptDiv_ = null;
   }
 
   /**
@@ -138,6 +141,7 @@ export class MiniPromptApi {
     miniPromptDiv.appendChild(titleContainerDiv);
     miniPromptDiv.appendChild(closeContainerDiv);
     this.doc_.getWin().document.body.appendChild(miniPromptDiv);
+    this.miniPromptDiv_ = miniPromptDiv;
 
     // Handle events and logging for the various sub-components.
     const clickFun = () => {
@@ -158,6 +162,16 @@ export class MiniPromptApi {
     this.logImpression_(options.autoPromptType);
   }
 
+  /**
+   * Removes the displayed mini prompt, if any.
+   */
+  destroy() {
+    if (this.miniPromptDiv_) {
+      removeElement(this.miniPromptDiv_);
+      this.miniPromptDiv_ = null;
+    }
+  }
+
   /**
    * Logs an impression of the mini prompt.
    * @param {!AutoPromptType|undefined} autoPromptType
@@ -0,0 +1,113 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {NavigationTracker} from './navigation-tracker';
+
+describes.realWin('NavigationTracker', {}, () => {
+  let clock;
+  let win;
+  let pushState;
+  let replaceState;
+  let callback;
+  let tracker;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    win = new EventTarget();
+    pushState = sandbox.spy((state, title, url) => {
+      win.location.href = url;
+    });
+    replaceState = sandbox.spy((state, title, url) => {
+      win.location.href = url;
+    });
+    Object.assign(win, {
+      location: {href: 'https://example.com/article1'},
+      history: {pushState, replaceState},
+      setTimeout: (fn, delay) => setTimeout(fn, delay),
+    });
+    callback = sandbox.spy();
+    tracker = new NavigationTracker(win, callback);
+    tracker.start();
+  });
+
+  function popState(url) {
+    win.location.href = url;
+    win.dispatchEvent(new Event('popstate'));
+  }
+
+  it('should call the callback after pushState navigations', () => {
+    win.history.pushState({}, '', 'https://example.com/article2');
+
+    expect(pushState).to.be.calledOnceWithExactly(
+      {},
+      '',
+      'https://example.com/article2'
+    );
+    expect(callback).to.not.be.called;
+    clock.tick(0);
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should call the callback after replaceState navigations', () => {
+    win.history.replaceState({}, '', 'https://example.com/article2');
+    clock.tick(0);
+
+    expect(replaceState).to.be.calledOnce;
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should call the callback on popstate', () => {
+    popState('https://example.com/article2');
+    clock.tick(0);
+
+    expect(callback).to.be.calledOnce;
+  });
+
+  it('should ignore changes of the state or fragment alone', () => {
+    win.history.replaceState({page: 1}, '', 'https://example.com/article1');
+    win.history.pushState({}, '', 'https://example.com/article1#comments');
+    popState('https://example.com/article1');
+    clock.tick(0);
+
+    expect(callback).to.not.be.called;
+  });
+
+  it('should restore the History API once stopped', () => {
+    tracker.stop();
+    win.history.pushState({}, '', 'https://example.com/article2');
+    popState('https://example.com/article3');
+    clock.tick(0);
+
+    expect(win.history.pushState).to.equal(pushState);
+    expect(win.history.replaceState).to.equal(replaceState);
+    expect(callback).to.not.be.called;
+  });
+
+  it('should not call the callback if stopped after a navigation', () => {
+    win.history.pushState({}, '', 'https://example.com/article2');
+    tracker.stop();
+    clock.tick(0);
+
+    expect(callback).to.not.be.called;
+  });
+
+  it('should only wrap the History API once', () => {
+    const wrappedPushState = win.history.pushState;
+    tracker.start();
+
+    expect(win.history.pushState).to.equal(wrappedPushState);
+  });
+});
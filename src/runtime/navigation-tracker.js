@@ -0,0 +1,113 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/**
+ * Detects the client-side navigations of single page applications, made
+ * through the History API. Changes of the URL fragment alone aren't
+ * navigations.
+ */
+export class NavigationTracker {
+  /**
+   * @param {!Window} win
+   * @param {function()} callback Called after each navigation, once the
+   *     application had a chance to render the new page.
+   */
+  constructor(win, callback) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {function()} */
+    this.callback_ = callback;
+
+    /** @private {string} */
+    this.url_ = '';
+
+    /**
+     * Restores the History API, while tracking.
+     * @private {?function()}
+     */
+    this.stop_ = null;
+  }
+
+  /**
+   * Starts tracking navigations. Does nothing if already tracking.
+   */
+  start() {
+    if (this.stop_) {
+      return;
+    }
+    const history = this.win_.history;
+    const pushState = history.pushState;
+    const replaceState = history.replaceState;
+    const wrappedPushState = (...args) => {
+      pushState.apply(history, args);
+      this.check_();
+    };
+    const wrappedReplaceState = (...args) => {
+      replaceState.apply(history, args);
+      this.check_();
+    };
+    const onPopState = () => this.check_();
+
+    this.url_ = this.getUrl_();
+    history.pushState = wrappedPushState;
+    history.replaceState = wrappedReplaceState;
+    this.win_.addEventListener('popstate', onPopState);
+    this.stop_ = () => {
+      // Leave the History API alone if it was wrapped again since.
+      if (history.pushState === wrappedPushState) {
+        history.pushState = pushState;
+      }
+      if (history.replaceState === wrappedReplaceState) {
+        history.replaceState = replaceState;
+      }
+      this.win_.removeEventListener('popstate', onPopState);
+    };
+  }
+
+  /**
+   * Stops tracking navigations.
+   */
+  stop() {
+    if (this.stop_) {
+      this.stop_();
+      this.stop_ = null;
+    }
+  }
+
+  /** @private */
+  check_() {
+    const url = this.getUrl_();
+    if (url == this.url_) {
+      return;
+    }
+    this.url_ = url;
+    // Applications usually render the new page after updating the URL.
+    this.win_.setTimeout(() => {
+      if (this.stop_) {
+        this.callback_();
+      }
+    }, 0);
+  }
+
+  /**
+   * @return {string} The current URL, without fragment.
+   * @private
+   */
+  getUrl_() {
+    return this.win_.location.href.split('#')[0];
+  }
+}
@@ -246,6 +246,32 @@ describes.realWin('OffersFlow', {}, (env) => {
     await offersFlow.start();
   });
 
+  it('should close offers when aborted', async () => {
+    dialogManagerMock.expects('openView').resolves();
+    await offersFlow.start();
+    const activityIframeView = await offersFlow.activityIframeViewPromise_;
+    const disconnectStub = sandbox.stub(activityIframeView, 'disconnect');
+    dialogManagerMock
+      .expects('completeView')
+      .withExactArgs(activityIframeView)
+      .once();
+
+    offersFlow.abort();
+
+    expect(disconnectStub).to.be.calledOnce;
+    dialogManagerMock.verify();
+  });
+
+  it('should not show offers once aborted', async () => {
+    callbacksMock.expects('triggerFlowStarted').never();
+    dialogManagerMock.expects('openView').never();
+
+    offersFlow.abort();
+    await offersFlow.start();
+
+    dialogManagerMock.verify();
+  });
+
   it('should have valid OffersFlow constructed with a list', async () => {
     offersFlow = new OffersFlow(runtime, {list: 'other'});
     activitiesMock
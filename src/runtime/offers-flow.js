@@ -74,6 +74,9 @@ export class OffersFlow {
 
     this.activityIframeView_ = null;
 
+    /** @private {boolean} */
+    this.aborted_ = false;
+
     // Default to hiding close button.
     const isClosable = options?.isClosable ?? false;
 
@@ -174,9 +177,13 @@ export class OffersFlow {
         AnalyticsEvent.ACTION_ALREADY_SUBSCRIBED,
         true
       );
-      this.deps_.callbacks().triggerLoginRequest({
-        linkRequested: !!response.getLinkRequested(),
-      });
+      const linkRequested = !!response.getLinkRequested();
+      const callbacks = this.deps_.callbacks();
+      callbacks.triggerFlowAlreadySubscribed(
+        SubscriptionFlows.SHOW_OFFERS,
+        linkRequested
+      );
+      callbacks.triggerLoginRequest({linkRequested});
     }
   }
 
@@ -197,7 +204,7 @@ export class OffersFlow {
   start() {
     if (this.activityIframeViewPromise_) {
       return this.activityIframeViewPromise_.then((activityIframeView) => {
-        if (!activityIframeView) {
+        if (!activityIframeView || this.aborted_) {
           return Promise.resolve();
         }
 
@@ -243,6 +250,18 @@ export class OffersFlow {
     return Promise.resolve();
   }
 
+  /**
+   * Closes the offers, or prevents them from being shown if they aren't yet.
+   */
+  abort() {
+    this.aborted_ = true;
+    if (this.activityIframeView_) {
+      this.dialogManager_.completeView(this.activityIframeView_);
+      this.activityIframeView_.disconnect();
+      this.activityIframeView_ = null;
+    }
+  }
+
   /**
    * Returns whether this flow is configured as enabled, not showing
    * even on explicit start when flag is configured false.
@@ -446,9 +465,13 @@ export class AbbrvOfferFlow {
         AnalyticsEvent.ACTION_ALREADY_SUBSCRIBED,
         true
       );
-      this.deps_.callbacks().triggerLoginRequest({
-        linkRequested: !!response.getLinkRequested(),
-      });
+      const linkRequested = !!response.getLinkRequested();
+      const callbacks = this.deps_.callbacks();
+      callbacks.triggerFlowAlreadySubscribed(
+        SubscriptionFlows.SHOW_ABBRV_OFFER,
+        linkRequested
+      );
+      callbacks.triggerLoginRequest({linkRequested});
     }
   }
 
@@ -19,6 +19,7 @@ import {ExperimentFlags} from './experiment-flags';
 import {PageConfig} from '../model/page-config';
 import {PayClient, RedirectVerifierHelper} from './pay-client';
 import {PaymentsAsyncClient} from '../../third_party/gpay/src/payjs_async';
+import {SwgErrorCode} from '../utils/errors';
 import {setExperiment, setExperimentsStringForTesting} from './experiments';
 
 const INTEGR_DATA_STRING =
@@ -293,6 +294,19 @@ describes.realWin('PayClient', {}, (env) => {
     );
   });
 
+  it('should convert other errors to payment errors', async () => {
+    payClient.start({});
+    const reason = {
+      'statusCode': 'INTERNAL_ERROR',
+      'statusMessage': 'Internal error',
+    };
+    const error = await withResult(Promise.reject(reason)).catch((e) => e);
+    expect(error.code).to.equal(SwgErrorCode.PAYMENT_DECLINED);
+    expect(error.message).to.equal('Internal error');
+    expect(error.retryable).to.be.true;
+    expect(error.cause).to.equal(reason);
+  });
+
   it('should return response on initialization', async () => {
     payClient.start({});
     const data = await withResult(Promise.resolve(INTEGR_DATA_OBJ));
@@ -17,8 +17,8 @@
 import {ExperimentFlags} from './experiment-flags';
 import {PaymentsAsyncClient} from '../../third_party/gpay/src/payjs_async';
 import {Preconnect} from '../utils/preconnect';
+import {SwgError, SwgErrorCode, createCancelError} from '../utils/errors';
 import {bytesToString, stringToBytes} from '../utils/bytes';
-import {createCancelError} from '../utils/errors';
 import {feCached} from './services';
 import {getSwgMode} from './services';
 import {isExperimentOn} from './experiments';
@@ -168,6 +168,7 @@ export class PayClient {
   /**
    * @param {!PaymentDataRequest} paymentRequest
    * @param {!PayOptionsDef=} options
+   * @return {!Promise} Resolves once the payment sheet is requested.
    */
   start(paymentRequest, options = {}) {
     this.request_ = paymentRequest;
@@ -270,7 +271,7 @@ export class PayClient {
           }
           return Promise.reject(error);
         }
-        return Promise.reject(reason);
+        return Promise.reject(createPaymentError(reason));
       });
   }
 
@@ -477,3 +478,21 @@ function setInternalParam(paymentRequest, param, value) {
     [param]: value,
   });
 }
+
+/**
+ * Converts a failure of the payment to a SwgError. Only internal errors of
+ * the payments service may succeed if retried.
+ * @param {*} reason
+ * @return {!SwgError}
+ */
+function createPaymentError(reason) {
+  const statusCode = reason && reason['statusCode'];
+  const message =
+    (reason && (reason['statusMessage'] || reason.message)) || String(reason);
+  return new SwgError(
+    SwgErrorCode.PAYMENT_DECLINED,
+    message,
+    statusCode == 'INTERNAL_ERROR',
+    reason
+  );
+}
@@ -26,6 +26,12 @@ import {ClientConfig} from '../model/client-config';
 import {ConfiguredRuntime} from './runtime';
 import {Constants} from '../utils/constants';
 import {Entitlements} from '../api/entitlements';
+import {
+  FlowState,
+  ProductType,
+  ReplaceSkuProrationMode,
+  SubscriptionFlows,
+} from '../api/subscriptions';
 import {PageConfig} from '../model/page-config';
 import {PayClient} from './pay-client';
 import {
@@ -37,12 +43,9 @@ import {
   parseSubscriptionResponse,
   parseUserData,
 } from './pay-flow';
-import {
-  ProductType,
-  ReplaceSkuProrationMode,
-  SubscriptionFlows,
-} from '../api/subscriptions';
+import {PerformanceTiming} from '../utils/performance';
 import {PurchaseData, SubscribeResponse} from '../api/subscribe-response';
+import {SwgErrorCode} from '../utils/errors';
 import {UserData} from '../api/user-data';
 import {tick} from '../../test/tick';
 
@@ -194,6 +197,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     eventManagerMock
       .expects('logSwgEvent')
@@ -240,6 +244,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     eventManagerMock
       .expects('logSwgEvent')
@@ -287,6 +292,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     eventManagerMock
       .expects('logSwgEvent')
@@ -338,6 +344,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     eventManagerMock
       .expects('logSwgEvent')
@@ -390,6 +397,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     eventManagerMock
       .expects('logSwgEvent')
@@ -439,6 +447,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     analyticsMock.expects('setSku').withExactArgs('oldSku2');
     eventManagerMock
@@ -476,6 +485,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     await flow.start();
   });
@@ -509,6 +519,7 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: false,
         }
       )
+      .resolves(true)
       .once();
     await flow.start();
   });
@@ -542,8 +553,47 @@ describes.realWin('PayStartFlow', {}, (env) => {
           forceDisableNative: true,
         }
       )
+      .resolves(true)
+      .once();
+    await flow.start();
+  });
+
+  it('should not open the payment sheet once aborted', async () => {
+    clientConfigManagerMock
+      .expects('getClientConfig')
+      .returns(Promise.resolve(new ClientConfig({paySwgVersion: '1'})))
       .once();
+    callbacksMock.expects('triggerFlowStarted').never();
+    payClientMock.expects('start').never();
+
+    const promise = flow.start();
+    flow.abort();
+    await promise;
+
+    expect(runtime.performanceTimings().getDurations()).to.not.have.property(
+      PerformanceTiming.PAY_START
+    );
+  });
+
+  it('should time the flow until the payment sheet is requested', async () => {
+    clientConfigManagerMock
+      .expects('getClientConfig')
+      .returns(Promise.resolve(new ClientConfig({paySwgVersion: '1'})))
+      .once();
+    let requestSheet;
+    const sheetRequested = new Promise((resolve) => (requestSheet = resolve));
+    payClientMock.expects('start').returns(sheetRequested).once();
+
     await flow.start();
+    expect(runtime.performanceTimings().getDurations()).to.not.have.property(
+      PerformanceTiming.PAY_START
+    );
+
+    requestSheet(true);
+    await sheetRequested;
+    expect(runtime.performanceTimings().getDurations()).to.have.property(
+      PerformanceTiming.PAY_START
+    );
   });
 });
 
@@ -1115,6 +1165,67 @@ describes.realWin('PayCompleteFlow', {}, (env) => {
     expect(messageStub).to.be.calledOnce.calledWith(accountCreationRequest);
   });
 
+  it('should move the purchase to the done state once complete', async () => {
+    const flowController = runtime.flowController();
+    flowController.setState(
+      SubscriptionFlows.SUBSCRIBE,
+      FlowState.PAY_COMPLETE
+    );
+    const setStateSpy = sandbox.spy(flowController, 'setState');
+    const response = createDefaultSubscribeResponse();
+    const port = new ActivityPort();
+    port.onResizeRequest = () => {};
+    port.whenReady = () => Promise.resolve();
+    port.acceptResult = () => Promise.resolve();
+    activitiesMock.expects('openIframe').returns(Promise.resolve(port));
+    entitlementsManagerMock.expects('reset').withExactArgs(true).once();
+    entitlementsManagerMock.expects('pushNextEntitlements').once();
+    entitlementsManagerMock.expects('setToastShown').withExactArgs(true).once();
+    entitlementsManagerMock
+      .expects('unblockNextNotification')
+      .withExactArgs()
+      .once();
+    sandbox.stub(port, 'execute');
+
+    await flow.start(response);
+    await flow.complete();
+
+    expect(setStateSpy.args).to.deep.equal([
+      [SubscriptionFlows.SUBSCRIBE, FlowState.ACCOUNT_CREATED],
+      [SubscriptionFlows.SUBSCRIBE, FlowState.DONE],
+    ]);
+    expect(flowController.getActiveFlows()).to.be.empty;
+  });
+
+  it('should report account creation failures', async () => {
+    const response = createDefaultSubscribeResponse();
+    const port = new ActivityPort();
+    port.onResizeRequest = () => {};
+    port.whenReady = () => Promise.resolve();
+    port.acceptResult = () => Promise.reject(new Error('intentional'));
+    activitiesMock.expects('openIframe').returns(Promise.resolve(port));
+    entitlementsManagerMock.expects('reset').withExactArgs(true).once();
+    entitlementsManagerMock.expects('pushNextEntitlements').once();
+    entitlementsManagerMock.expects('setToastShown').withExactArgs(true).once();
+    entitlementsManagerMock
+      .expects('unblockNextNotification')
+      .withExactArgs()
+      .once();
+    callbacksMock
+      .expects('triggerError')
+      .withExactArgs(
+        sandbox.match({
+          code: SwgErrorCode.ACCOUNT_CREATION_FAILED,
+          retryable: false,
+        })
+      )
+      .once();
+    sandbox.stub(port, 'execute');
+
+    await flow.start(response);
+    await flow.complete();
+  });
+
   it('should complete the flow without account creation if skipAccountCreationScreen: true', async () => {
     clientConfigManagerMock
       .expects('getClientConfig')
@@ -29,18 +29,20 @@ import {
 import {ActivityIframeView} from '../ui/activity-iframe-view';
 import {AnalyticsEvent, EventParams} from '../proto/api_messages';
 import {Constants} from '../utils/constants';
-import {JwtHelper} from '../utils/jwt';
 import {
+  FlowState,
   ProductType,
   SubscriptionFlows,
   WindowOpenMode,
 } from '../api/subscriptions';
+import {JwtHelper} from '../utils/jwt';
+import {PerformanceTiming} from '../utils/performance';
 import {PurchaseData, SubscribeResponse} from '../api/subscribe-response';
+import {SwgError, SwgErrorCode, isCancelError} from '../utils/errors';
 import {UserData} from '../api/user-data';
 import {feArgs, feUrl} from './services';
 import {getPropertyFromJsonString, parseJson} from '../utils/json';
 import {getSwgMode} from './services';
-import {isCancelError} from '../utils/errors';
 import {parseUrl} from '../utils/url';
 
 /**
@@ -101,6 +103,9 @@ export class PayStartFlow {
     /** @private @const {!./pay-client.PayClient} */
     this.payClient_ = deps.payClient();
 
+    /** @private @const {!../utils/performance.PerformanceTimings} */
+    this.performanceTimings_ = deps.performanceTimings();
+
     /** @private @const {!../model/page-config.PageConfig} */
     this.pageConfig_ = deps.pageConfig();
 
@@ -121,6 +126,9 @@ export class PayStartFlow {
 
     /** @private @const {!../runtime/client-config-manager.ClientConfigManager} */
     this.clientConfigManager_ = deps.clientConfigManager();
+
+    /** @private {boolean} */
+    this.aborted_ = false;
   }
 
   /**
@@ -128,17 +136,30 @@ export class PayStartFlow {
    * @return {!Promise}
    */
   start() {
+    const endTiming = this.performanceTimings_.start(
+      PerformanceTiming.PAY_START
+    );
     // Get the paySwgVersion for buyflow.
     const promise = this.clientConfigManager_.getClientConfig();
     return promise.then((clientConfig) => {
-      this.start_(clientConfig.paySwgVersion);
+      if (!this.aborted_) {
+        this.start_(clientConfig.paySwgVersion).then(endTiming);
+      }
     });
   }
 
+  /**
+   * Prevents the payment sheet from being opened. Once open, the sheet is
+   * managed by Google Pay.
+   */
+  abort() {
+    this.aborted_ = true;
+  }
+
   /**
    * Starts the payments flow for the given version.
    * @param {!string=} paySwgVersion
-   * @return {!Promise}
+   * @return {!Promise} Resolves once the payment sheet is requested.
    */
   start_(paySwgVersion) {
     const /** @type {SwgPaymentRequest} */ swgPaymentRequest = {
@@ -189,7 +210,7 @@ export class PayStartFlow {
       getEventParams(swgPaymentRequest['skuId'])
     );
     PayCompleteFlow.waitingForPayClient_ = true;
-    this.payClient_.start(
+    return this.payClient_.start(
       /** @type {!PaymentDataRequest} */
       ({
         'apiVersion': 1,
@@ -209,7 +230,6 @@ export class PayStartFlow {
         forceDisableNative: paySwgVersion == '2',
       }
     );
-    return Promise.resolve();
   }
 }
 
@@ -305,6 +325,9 @@ export class PayCompleteFlow {
 
     /** @private {?string} */
     this.sku_ = null;
+
+    /** @private {?string} */
+    this.flow_ = null;
   }
 
   /**
@@ -318,6 +341,10 @@ export class PayCompleteFlow {
    */
   start(response) {
     this.sku_ = parseSkuFromPurchaseDataSafe(response.purchaseData);
+    this.flow_ =
+      response['productType'] == ProductType.UI_CONTRIBUTION
+        ? SubscriptionFlows.CONTRIBUTE
+        : SubscriptionFlows.SUBSCRIBE;
     this.eventManager_.logSwgEvent(
       AnalyticsEvent.IMPRESSION_ACCOUNT_CHANGED,
       true,
@@ -431,6 +458,7 @@ export class PayCompleteFlow {
       getEventParams(this.sku_ || '')
     );
     this.deps_.entitlementsManager().unblockNextNotification();
+    this.setFlowState_(FlowState.ACCOUNT_CREATED);
     return Promise.all([
       this.activityIframeViewPromise_,
       this.readyPromise_,
@@ -446,8 +474,21 @@ export class PayCompleteFlow {
       }
       return activityIframeView
         .acceptResult()
-        .catch(() => {
-          // Ignore errors.
+        .catch((reason) => {
+          // The purchase is complete regardless, so the failure is only
+          // reported.
+          if (!isCancelError(reason)) {
+            this.deps_
+              .callbacks()
+              .triggerError(
+                new SwgError(
+                  SwgErrorCode.ACCOUNT_CREATION_FAILED,
+                  'Account creation failed',
+                  false,
+                  reason
+                )
+              );
+          }
         })
         .then(() => {
           if (!clientConfig.skipAccountCreationScreen) {
@@ -458,9 +499,20 @@ export class PayCompleteFlow {
             );
           }
           this.deps_.entitlementsManager().setToastShown(true);
+          this.setFlowState_(FlowState.DONE);
         });
     });
   }
+
+  /**
+   * @param {!FlowState} state
+   * @private
+   */
+  setFlowState_(state) {
+    if (this.flow_) {
+      this.deps_.flowController().setState(this.flow_, state);
+    }
+  }
 }
 
 /** @private {boolean} */
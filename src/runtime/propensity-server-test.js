@@ -24,6 +24,8 @@ import {ClientEventManager} from './client-event-manager';
 import {Event, SubscriptionState} from '../api/logger-api';
 import {PageConfig} from '../model/page-config';
 import {PropensityServer} from './propensity-server';
+import {RetriedRequest} from './retry-policies';
+import {RetryPolicy} from '../utils/retry-policy';
 import {parseQueryString} from '../utils/url';
 
 /**
@@ -52,12 +54,14 @@ describes.realWin('PropensityServer', {}, (env) => {
   let fetcher;
   let pageConfig;
   let defaultEvent;
+  let hasConsent;
 
   const config = {};
   const fakeDeps = {
     eventManager: () => eventManager,
     pageConfig: () => pageConfig,
     config: () => config,
+    consentManager: () => ({hasConsent: () => hasConsent}),
   };
   const serverUrl = 'http://localhost:31862';
   const defaultParameters = {'custom': 'value'};
@@ -66,6 +70,7 @@ describes.realWin('PropensityServer', {}, (env) => {
 
   beforeEach(() => {
     win = env.win;
+    hasConsent = true;
     registeredCallback = null;
     fetcher = {fetch: () => {}};
     eventManager = new ClientEventManager(Promise.resolve());
@@ -191,6 +196,39 @@ describes.realWin('PropensityServer', {}, (env) => {
       defaultEvent.additionalParameters = defaultParameters;
     });
 
+    it('should send events with its retry policy', () => {
+      const retryPolicy = new RetryPolicy();
+      propensityServer = new PropensityServer(
+        win,
+        fakeDeps,
+        fetcher,
+        retryPolicy
+      );
+      const fetchStub = sandbox.stub(fetcher, 'fetch').resolves();
+
+      registeredCallback(defaultEvent);
+
+      expect(fetchStub).to.be.calledOnce;
+      expect(fetchStub.args[0][2]).to.equal(retryPolicy);
+    });
+
+    it('should not send events about its own retries', () => {
+      const fetchStub = sandbox.stub(fetcher, 'fetch').resolves();
+      defaultEvent.eventType = AnalyticsEvent.EVENT_CUSTOM;
+      defaultEvent.additionalParameters = {
+        'retry': {'request': RetriedRequest.PROPENSITY, 'attempt': 1},
+      };
+
+      registeredCallback(defaultEvent);
+
+      expect(fetchStub).to.not.be.called;
+      defaultEvent.additionalParameters = {
+        'retry': {'request': RetriedRequest.ENTITLEMENTS, 'attempt': 1},
+      };
+      registeredCallback(defaultEvent);
+      expect(fetchStub).to.be.calledOnce;
+    });
+
     it('should process failures', async () => {
       let capturedUrl;
       let capturedRequest;
@@ -410,6 +448,71 @@ describes.realWin('PropensityServer', {}, (env) => {
       expect(capturedRequest.credentials).to.equal('include');
       expect(capturedRequest.method).to.equal('GET');
     });
+
+    it('should not read the cookies without consent', () => {
+      const getDocumentCookie = sandbox.stub(
+        PropensityServer.prototype,
+        'getDocumentCookie_'
+      );
+      hasConsent = false;
+
+      expect(propensityServer.getClientId_()).to.be.null;
+      expect(getDocumentCookie).to.not.be.called;
+    });
+  });
+
+  describe('Consent', () => {
+    beforeEach(() => {
+      hasConsent = false;
+    });
+
+    it('should not send events without consent', async () => {
+      const fetchStub = sandbox.stub(fetcher, 'fetch');
+
+      registeredCallback(defaultEvent);
+      await propensityServer.sendSubscriptionState(
+        SubscriptionState.SUBSCRIBER,
+        JSON.stringify(productsOrSkus)
+      );
+
+      expect(fetchStub).to.not.be.called;
+    });
+
+    it('should not get the propensity without consent', async () => {
+      const fetchStub = sandbox.stub(fetcher, 'fetch');
+
+      const score = await propensityServer.getPropensity(
+        '/hello',
+        PropensityApi.PropensityType.GENERAL
+      );
+
+      expect(fetchStub).to.not.be.called;
+      expect(score).to.deep.equal({
+        header: {ok: false},
+        body: {error: 'No consent'},
+      });
+    });
+  });
+
+  describe('Destroy', () => {
+    it('should not send requests once destroyed', async () => {
+      const fetchStub = sandbox.stub(fetcher, 'fetch');
+      propensityServer.destroy();
+
+      registeredCallback(defaultEvent);
+      await propensityServer.sendSubscriptionState(
+        SubscriptionState.SUBSCRIBER,
+        JSON.stringify(productsOrSkus)
+      );
+      await expect(
+        propensityServer.getPropensity(
+          '/hello',
+          PropensityApi.PropensityType.GENERAL
+        )
+      ).to.be.rejectedWith(/Runtime destroyed/);
+
+      expect(fetchStub).to.not.be.called;
+    });
   });
 
   describe('Originators', () => {
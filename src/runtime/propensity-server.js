@@ -18,6 +18,12 @@ import {
   EventOriginator,
   EventParams,
 } from '../proto/api_messages';
+import {ConsentPurpose} from '../api/subscriptions';
+import {
+  RetriedRequest,
+  createRetryPolicy,
+  isRetryEventOf,
+} from './retry-policies';
 import {addQueryParam} from '../utils/url';
 import {adsUrl} from './services';
 import {analyticsEventToPublisherEvent} from './event-type-mapping';
@@ -34,8 +40,9 @@ export class PropensityServer {
    * @param {!Window} win
    * @param {!./deps.DepsDef} deps
    * @param {!./fetcher.Fetcher} fetcher
+   * @param {!../utils/retry-policy.RetryPolicy=} retryPolicy
    */
-  constructor(win, deps, fetcher) {
+  constructor(win, deps, fetcher, retryPolicy) {
     /** @private @const {!Window} */
     this.win_ = win;
     /** @private @const {!./deps.DepsDef} */
@@ -46,14 +53,38 @@ export class PropensityServer {
     this.clientId_ = null;
     /** @private @const {!./fetcher.Fetcher} */
     this.fetcher_ = fetcher;
+    /** @private @const {!../utils/retry-policy.RetryPolicy} */
+    this.retryPolicy_ =
+      retryPolicy || createRetryPolicy(deps, RetriedRequest.PROPENSITY);
     /** @private @const {number} */
     this.version_ = 1;
+    /** @private {boolean} */
+    this.destroyed_ = false;
 
     this.deps_
       .eventManager()
       .registerEventListener(this.handleClientEvent_.bind(this));
   }
 
+  /**
+   * Stops sending requests, e.g. once the runtime is destroyed.
+   */
+  destroy() {
+    this.destroyed_ = true;
+  }
+
+  /**
+   * Whether the reader consented to propensity, which reads advertising
+   * cookies. Checked on each request, since consent can change.
+   * @return {boolean}
+   * @private
+   */
+  hasConsent_() {
+    return this.deps_
+      .consentManager()
+      .hasConsent(ConsentPurpose.PERSONALIZATION);
+  }
+
   /**
    * @private
    * @return {string}
@@ -68,6 +99,9 @@ export class PropensityServer {
    * @private
    */
   getClientId_() {
+    if (!this.hasConsent_()) {
+      return null;
+    }
     if (!this.clientId_) {
       // Match '__gads' (name of the cookie) dropped by Ads Tag.
       const gadsmatch = this.getDocumentCookie_().match(
@@ -101,6 +135,9 @@ export class PropensityServer {
    * @param {?string} productsOrSkus
    */
   sendSubscriptionState(state, productsOrSkus) {
+    if (this.destroyed_ || !this.hasConsent_()) {
+      return Promise.resolve();
+    }
     const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
       method: 'GET',
       credentials: 'include',
@@ -110,7 +147,11 @@ export class PropensityServer {
     if (productsOrSkus) {
       url = addQueryParam(url, 'extrainfo', productsOrSkus);
     }
-    return this.fetcher_.fetch(this.propensityUrl_(url), init);
+    return this.fetcher_.fetch(
+      this.propensityUrl_(url),
+      init,
+      this.retryPolicy_
+    );
   }
 
   /**
@@ -119,6 +160,9 @@ export class PropensityServer {
    * @private
    */
   sendEvent_(event, context) {
+    if (this.destroyed_ || !this.hasConsent_()) {
+      return Promise.resolve();
+    }
     const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
       method: 'GET',
       credentials: 'include',
@@ -128,7 +172,11 @@ export class PropensityServer {
     if (context) {
       url = addQueryParam(url, 'extrainfo', context);
     }
-    return this.fetcher_.fetch(this.propensityUrl_(url), init);
+    return this.fetcher_.fetch(
+      this.propensityUrl_(url),
+      init,
+      this.retryPolicy_
+    );
   }
 
   /**
@@ -142,6 +190,10 @@ export class PropensityServer {
       return;
     }
 
+    if (isRetryEventOf(event, RetriedRequest.PROPENSITY)) {
+      return;
+    }
+
     /**
      * Does a live check of the config because we don't know when publisher
      * called to enable (it may be after a consent dialog).
@@ -241,6 +293,17 @@ export class PropensityServer {
    * @return {?Promise<../api/propensity-api.PropensityScore>}
    */
   getPropensity(referrer, type) {
+    if (this.destroyed_) {
+      return Promise.reject(new Error('Runtime destroyed'));
+    }
+    if (!this.hasConsent_()) {
+      return Promise.resolve(
+        /** @type {!../api/propensity-api.PropensityScore} */ ({
+          header: {ok: false},
+          body: {error: 'No consent'},
+        })
+      );
+    }
     const init = /** @type {!../utils/xhr.FetchInitDef} */ ({
       method: 'GET',
       credentials: 'include',
@@ -253,7 +316,7 @@ export class PropensityServer {
       '&ref=' +
       referrer;
     return this.fetcher_
-      .fetch(this.propensityUrl_(url), init)
+      .fetch(this.propensityUrl_(url), init, this.retryPolicy_)
       .then((result) => result.json())
       .then((response) => {
         return this.parsePropensityResponse_(response);
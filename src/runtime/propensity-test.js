@@ -130,6 +130,14 @@ describes.realWin('Propensity', {}, (env) => {
     expect(subscriptionState).to.equal(SubscriptionState.UNKNOWN);
   });
 
+  it('should destroy the server', () => {
+    const destroyStub = sandbox.stub(PropensityServer.prototype, 'destroy');
+
+    propensity.destroy();
+
+    expect(destroyStub).to.be.calledOnce;
+  });
+
   it('should report server errors', () => {
     sandbox
       .stub(PropensityServer.prototype, 'sendSubscriptionState')
@@ -29,21 +29,34 @@ export class Propensity {
    * @param {!Window} win
    * @param {!./deps.DepsDef} deps
    * @param {!./fetcher.Fetcher} fetcher
+   * @param {!../utils/retry-policy.RetryPolicy=} retryPolicy
    *
    * IMPORTANT: deps may not be full initialized config and pageConfig are
    * available immediately, other function should be gated on a ready promise.
    * #TODO(jpettitt) switch refactor to take out the win and use deps to get win
    */
-  constructor(win, deps, fetcher) {
+  constructor(win, deps, fetcher, retryPolicy) {
     /** @private @const {!Window} */
     this.win_ = win;
     /** @private {PropensityServer} */
-    this.propensityServer_ = new PropensityServer(win, deps, fetcher);
+    this.propensityServer_ = new PropensityServer(
+      win,
+      deps,
+      fetcher,
+      retryPolicy
+    );
 
     /** @private @const {!../api/client-event-manager-api.ClientEventManagerApi} */
     this.eventManager_ = deps.eventManager();
   }
 
+  /**
+   * Stops sending requests to the Propensity Service.
+   */
+  destroy() {
+    this.propensityServer_.destroy();
+  }
+
   /** @override */
   sendSubscriptionState(state, jsonProducts) {
     if (!Object.values(SubscriptionState).includes(state)) {
@@ -0,0 +1,148 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsEvent, EventOriginator} from '../proto/api_messages';
+import {ClientEventManager} from './client-event-manager';
+import {
+  RetriedRequest,
+  createRetryPolicy,
+  isRetryEventOf,
+} from './retry-policies';
+
+describes.realWin('retry policies', {}, () => {
+  let clock;
+  let eventManager;
+  let eventManagerMock;
+  let deps;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    eventManager = new ClientEventManager(Promise.resolve());
+    eventManagerMock = sandbox.mock(eventManager);
+    deps = {eventManager: () => eventManager};
+  });
+
+  afterEach(() => {
+    eventManagerMock.verify();
+  });
+
+  function serverError(status) {
+    const err = new Error(`HTTP error ${status}`);
+    err.retriable = true;
+    err.response = {status, headers: {get: () => null}};
+    return err;
+  }
+
+  describe('createRetryPolicy', () => {
+    it('should log retries', async () => {
+      const attempt = sandbox.stub();
+      attempt.onCall(0).rejects(serverError(503));
+      attempt.resolves('ok');
+      eventManagerMock
+        .expects('logEvent')
+        .withExactArgs({
+          eventType: AnalyticsEvent.EVENT_CUSTOM,
+          eventOriginator: EventOriginator.SWG_CLIENT,
+          isFromUserAction: false,
+          additionalParameters: {
+            'retry': {
+              'request': 'entitlements',
+              'attempt': 1,
+              'delayMs': 500,
+              'status': 503,
+            },
+          },
+        })
+        .once();
+
+      const policy = createRetryPolicy(deps, RetriedRequest.ENTITLEMENTS, {
+        jitter: 0,
+      });
+      const promise = policy.run(self, attempt);
+      await clock.tickAsync(500);
+
+      expect(await promise).to.equal('ok');
+    });
+
+    it('should call the onRetry option', async () => {
+      const onRetry = sandbox.spy();
+      const attempt = sandbox.stub();
+      attempt.onCall(0).rejects(serverError(500));
+      attempt.resolves('ok');
+      eventManagerMock.expects('logEvent').once();
+
+      const policy = createRetryPolicy(deps, RetriedRequest.CLIENT_CONFIG, {
+        initialDelayMs: 10,
+        onRetry,
+      });
+      const promise = policy.run(self, attempt);
+      await clock.tickAsync(10);
+      await promise;
+
+      expect(onRetry).to.be.calledOnce;
+      expect(onRetry.args[0][0].attempt).to.equal(1);
+    });
+
+    it('should use the default attempts of the request', async () => {
+      const error = serverError(500);
+      const attempt = sandbox.stub().rejects(error);
+      eventManagerMock.expects('logEvent').once();
+
+      const policy = createRetryPolicy(deps, RetriedRequest.PROPENSITY, {
+        initialDelayMs: 10,
+      });
+      const promise = policy.run(self, attempt);
+      await clock.tickAsync(10);
+
+      await expect(promise).to.be.rejectedWith(error);
+      expect(attempt).to.be.calledTwice;
+    });
+  });
+
+  describe('isRetryEventOf', () => {
+    it('should match retry events of the request', () => {
+      const event = {
+        eventType: AnalyticsEvent.EVENT_CUSTOM,
+        eventOriginator: EventOriginator.SWG_CLIENT,
+        additionalParameters: {'retry': {'request': 'clientLogs'}},
+      };
+
+      expect(isRetryEventOf(event, RetriedRequest.CLIENT_LOGS)).to.be.true;
+      expect(isRetryEventOf(event, RetriedRequest.PROPENSITY)).to.be.false;
+    });
+
+    it('should not match other events', () => {
+      expect(
+        isRetryEventOf(
+          {
+            eventType: AnalyticsEvent.EVENT_CUSTOM,
+            additionalParameters: {'retry': 'clientLogs'},
+          },
+          RetriedRequest.CLIENT_LOGS
+        )
+      ).to.be.false;
+      expect(
+        isRetryEventOf(
+          {
+            eventType: AnalyticsEvent.IMPRESSION_OFFERS,
+            additionalParameters: {'retry': {'request': 'clientLogs'}},
+          },
+          RetriedRequest.CLIENT_LOGS
+        )
+      ).to.be.false;
+    });
+  });
+});
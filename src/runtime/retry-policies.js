@@ -0,0 +1,91 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {AnalyticsEvent, EventOriginator} from '../proto/api_messages';
+import {RetryPolicy} from '../utils/retry-policy';
+import {isObject} from '../utils/types';
+
+/**
+ * Requests made by the runtime that can be retried.
+ * @enum {string}
+ */
+export const RetriedRequest = {
+  ENTITLEMENTS: 'entitlements',
+  CLIENT_CONFIG: 'clientConfig',
+  PROPENSITY: 'propensity',
+  CLIENT_LOGS: 'clientLogs',
+};
+
+/** @const {!Object<!RetriedRequest, !../utils/retry-policy.RetryPolicyOptions>} */
+const DEFAULT_OPTIONS = {
+  [RetriedRequest.ENTITLEMENTS]: {maxAttempts: 3},
+  [RetriedRequest.CLIENT_CONFIG]: {maxAttempts: 3},
+  [RetriedRequest.PROPENSITY]: {maxAttempts: 2},
+  [RetriedRequest.CLIENT_LOGS]: {maxAttempts: 2},
+};
+
+/**
+ * Creates the retry policy of a request. Each retry is logged as an
+ * `EVENT_CUSTOM` SwG event whose additional parameters describe the retry.
+ * @param {!./deps.DepsDef} deps
+ * @param {!RetriedRequest} request
+ * @param {!../utils/retry-policy.RetryPolicyOptions=} options Overrides the
+ *     request's default options.
+ * @return {!RetryPolicy}
+ */
+export function createRetryPolicy(deps, request, options = {}) {
+  return new RetryPolicy(
+    Object.assign({}, DEFAULT_OPTIONS[request], options, {
+      onRetry: (retry) => {
+        if (options.onRetry) {
+          options.onRetry(retry);
+        }
+        const response = retry.error.response;
+        deps.eventManager().logEvent({
+          eventType: AnalyticsEvent.EVENT_CUSTOM,
+          eventOriginator: EventOriginator.SWG_CLIENT,
+          isFromUserAction: false,
+          additionalParameters: {
+            'retry': {
+              'request': request,
+              'attempt': retry.attempt,
+              'delayMs': retry.delayMs,
+              'status': (response && response.status) || null,
+            },
+          },
+        });
+      },
+    })
+  );
+}
+
+/**
+ * Whether the event reports a retry of the given request. Services that send
+ * events over the network ignore retries of their own requests, so a failing
+ * endpoint can't keep generating events about itself.
+ * @param {!../api/client-event-manager-api.ClientEvent} event
+ * @param {!RetriedRequest} request
+ * @return {boolean}
+ */
+export function isRetryEventOf(event, request) {
+  const params = event.additionalParameters;
+  return (
+    event.eventType === AnalyticsEvent.EVENT_CUSTOM &&
+    isObject(params) &&
+    isObject(params['retry']) &&
+    params['retry']['request'] === request
+  );
+}
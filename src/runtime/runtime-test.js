@@ -23,9 +23,15 @@ import {
 import {AnalyticsEvent, EventOriginator} from '../proto/api_messages';
 import {
   AnalyticsMode,
+  ConsentPurpose,
+  FlowErrorCode,
+  FlowOutcomeType,
+  FlowState,
   ProductType,
   ReplaceSkuProrationMode,
   ShowcaseEvent,
+  SubscriptionEvent,
+  SubscriptionFlows,
   Subscriptions,
 } from '../api/subscriptions';
 import {AnalyticsService} from './analytics-service';
@@ -58,7 +64,10 @@ import {PageConfig} from '../model/page-config';
 import {PageConfigResolver} from '../model/page-config-resolver';
 import {PayClient} from './pay-client';
 import {PayStartFlow} from './pay-flow';
+import {PerformanceTiming} from '../utils/performance';
 import {Propensity} from './propensity';
+import {RetriedRequest} from './retry-policies';
+import {RetryPolicy} from '../utils/retry-policy';
 import {SubscribeResponse} from '../api/subscribe-response';
 import {WaitForSubscriptionLookupApi} from './wait-for-subscription-lookup-api';
 import {analyticsEventToGoogleAnalyticsEvent} from './event-type-mapping';
@@ -69,6 +78,7 @@ import {
   setExperimentsStringForTesting,
 } from './experiments';
 import {parseUrl} from '../utils/url';
+import {tick} from '../../test/tick';
 
 const EDGE_USER_AGENT =
   'Mozilla/5.0 (Windows NT 10.0)' +
@@ -143,6 +153,34 @@ describes.realWin('installRuntime', {}, (env) => {
     expect(getRuntime()).to.equal(runtime1);
   });
 
+  it('should install a new runtime once destroyed', async () => {
+    installRuntime(win);
+    const runtime1 = getRuntime();
+
+    await runtime1.destroy();
+    expect(win.SWG).to.be.undefined;
+    expect(win.SUBSCRIPTIONS).to.be.undefined;
+
+    expect(() => getRuntime()).to.throw(/not initialized yet/);
+
+    installRuntime(win);
+    expect(getRuntime()).to.not.equal(runtime1);
+    expect(win.SWG.push).to.be.a('function');
+  });
+
+  it('should drop the callbacks pushed once destroyed', async () => {
+    installRuntime(win);
+    const runtime = getRuntime();
+    const push = win.SWG.push;
+    const callback = sandbox.spy();
+
+    await runtime.destroy();
+    push(callback);
+    await runtime.whenReady();
+
+    expect(callback).to.not.be.called;
+  });
+
   it('should implement Subscriptions interface', async () => {
     const promise = new Promise((resolve) => {
       dep(resolve);
@@ -454,6 +492,136 @@ describes.realWin('Runtime', {}, (env) => {
       expect(resolveStub).to.not.be.called;
     });
 
+    it('should resolve the page config again on navigation', async () => {
+      const cr = await runtime.configured_(true);
+      const navigateToStub = sandbox.stub(cr, 'navigateTo').resolves();
+      const newConfig = new PageConfig('pub1:label2', true);
+      configPromise = Promise.resolve(newConfig);
+
+      await runtime.navigate();
+      expect(resolveStub).to.be.calledTwice;
+      expect(navigateToStub).to.be.calledOnceWithExactly(newConfig);
+    });
+
+    it('should track navigations if configured', async () => {
+      const startStub = sandbox.stub(runtime.navigationTracker_, 'start');
+      const stopStub = sandbox.stub(runtime.navigationTracker_, 'stop');
+      runtime.configured_(true);
+
+      await runtime.configure({trackNavigation: true});
+      expect(startStub).to.be.calledOnce;
+      expect(stopStub).to.not.be.called;
+
+      await runtime.configure({trackNavigation: false});
+      expect(stopStub).to.be.calledOnce;
+    });
+
+    it('should destroy the configured runtime', async () => {
+      const cr = await runtime.configured_(true);
+      const destroyStub = sandbox.stub(cr, 'destroy').resolves();
+      const stopStub = sandbox.stub(runtime.navigationTracker_, 'stop');
+
+      await runtime.destroy();
+      await runtime.destroy();
+      expect(destroyStub).to.be.calledOnce;
+      expect(stopStub).to.be.calledOnce;
+    });
+
+    it('should reject calls once destroyed while configuring', async () => {
+      const p = runtime.configured_(true);
+      await runtime.destroy();
+
+      await expect(p).to.be.rejectedWith(/Runtime destroyed/);
+      await expect(runtime.getEntitlements()).to.be.rejectedWith(
+        /Runtime destroyed/
+      );
+    });
+
+    it('should create runtimes for other publications', async () => {
+      const subscriptions = runtime.createRuntime({publicationId: 'pub2'});
+      const created = runtime.createdRuntimes_[0];
+      const cr = await runtime.configured_(true);
+
+      expect(created.pageConfig().getPublicationId()).to.equal('pub2');
+      expect(created.pageConfig().isLocked()).to.be.false;
+      expect(created.dialogManager()).to.equal(cr.dialogManager());
+      expect(created.entitlementsManager()).to.not.equal(
+        cr.entitlementsManager()
+      );
+      expect(created.clientConfigManager()).to.not.equal(
+        cr.clientConfigManager()
+      );
+      expect(created.performanceTimings()).to.not.equal(
+        cr.performanceTimings()
+      );
+      expect(created.analytics().getContext()).to.not.equal(
+        cr.analytics().getContext()
+      );
+      expect(created.storage().keyPrefix_).to.equal('pub2:');
+      const names = Object.getOwnPropertyNames(Subscriptions.prototype);
+      for (const name of names) {
+        expect(subscriptions).to.have.property(name);
+      }
+    });
+
+    it('should expose the created runtimes', async () => {
+      const clearStub = sandbox.stub(ConfiguredRuntime.prototype, 'clear');
+      const subscriptions = runtime.createRuntime({publicationId: 'pub2'});
+
+      subscriptions.clear();
+
+      expect(clearStub).to.be.calledOnce;
+      expect(clearStub.firstCall.thisValue).to.equal(
+        runtime.createdRuntimes_[0]
+      );
+      expect(() => subscriptions.init('pub3')).to.throw(/already initialized/);
+      await expect(subscriptions.navigate()).to.be.rejectedWith(
+        /can not navigate/
+      );
+      expect(() =>
+        subscriptions.createRuntime({publicationId: 'pub3'})
+      ).to.throw(/only be created by the page runtime/);
+      expect(runtime.createdRuntimes_).to.have.length(1);
+    });
+
+    it('should forget the created runtimes once destroyed', async () => {
+      const subscriptions = runtime.createRuntime({publicationId: 'pub2'});
+      const destroyStub = sandbox
+        .stub(runtime.createdRuntimes_[0], 'destroy')
+        .resolves();
+
+      await subscriptions.destroy();
+      expect(destroyStub).to.be.calledOnce;
+      expect(runtime.createdRuntimes_).to.be.empty;
+
+      await runtime.destroy();
+      expect(destroyStub).to.be.calledOnce;
+    });
+
+    it('should create runtimes for a product of another publication', () => {
+      runtime.createRuntime({publicationId: 'pub2', productId: 'pub2:label'});
+
+      const pageConfig = runtime.createdRuntimes_[0].pageConfig();
+      expect(pageConfig.getProductId()).to.equal('pub2:label');
+    });
+
+    it('should not create runtimes for mismatching products', () => {
+      expect(() =>
+        runtime.createRuntime({publicationId: 'pub2', productId: 'pub3:label'})
+      ).to.throw(/Product of another publication/);
+    });
+
+    it('should destroy the created runtimes', async () => {
+      runtime.createRuntime({publicationId: 'pub2'});
+      const destroyStub = sandbox.stub(runtime.createdRuntimes_[0], 'destroy');
+
+      await runtime.destroy();
+      expect(destroyStub).to.be.calledOnce;
+      expect(() => runtime.createRuntime({publicationId: 'pub2'})).to.throw(
+        /Runtime destroyed/
+      );
+    });
+
     it('should initialize only once', () => {
       runtime.configured_(true);
       runtime.configured_(true);
@@ -636,7 +804,7 @@ describes.realWin('Runtime', {}, (env) => {
     it('should delegate "showOffers"', async () => {
       configuredRuntimeMock
         .expects('showOffers')
-        .withExactArgs(undefined)
+        .withExactArgs(undefined, undefined)
         .once();
 
       await runtime.showOffers();
@@ -645,12 +813,26 @@ describes.realWin('Runtime', {}, (env) => {
 
     it('should delegate "showOffers" with options', async () => {
       const options = {list: 'other'};
-      configuredRuntimeMock.expects('showOffers').withExactArgs(options).once();
+      configuredRuntimeMock
+        .expects('showOffers')
+        .withExactArgs(options, undefined)
+        .once();
 
       await runtime.showOffers(options);
       expect(configureStub).to.be.calledOnce.calledWith(true);
     });
 
+    it('should delegate "showOffers" with flow options', async () => {
+      const flowOptions = {timeoutMs: 1000};
+      configuredRuntimeMock
+        .expects('showOffers')
+        .withExactArgs(undefined, flowOptions)
+        .once();
+
+      await runtime.showOffers(undefined, flowOptions);
+      expect(configureStub).to.be.calledOnce.calledWith(true);
+    });
+
     it('should delegate "showUpdateOffers"', async () => {
       configuredRuntimeMock
         .expects('showUpdateOffers')
@@ -715,7 +897,10 @@ describes.realWin('Runtime', {}, (env) => {
     });
 
     it('should delegate "subscribe"', async () => {
-      configuredRuntimeMock.expects('subscribe').withExactArgs('sku1').once();
+      configuredRuntimeMock
+        .expects('subscribe')
+        .withExactArgs('sku1', undefined)
+        .once();
 
       await runtime.subscribe('sku1');
       expect(configureStub).to.be.calledOnce.calledWith(true);
@@ -834,6 +1019,59 @@ describes.realWin('Runtime', {}, (env) => {
       expect(configureStub).to.be.calledOnce.calledWith(false);
     });
 
+    it('should delegate "on" and "off"', async () => {
+      const handler = function () {};
+      configuredRuntimeMock
+        .expects('on')
+        .withExactArgs(SubscriptionEvent.LINK_COMPLETE, handler)
+        .once();
+      configuredRuntimeMock
+        .expects('off')
+        .withExactArgs(SubscriptionEvent.LINK_COMPLETE, handler)
+        .once();
+
+      await runtime.on(SubscriptionEvent.LINK_COMPLETE, handler);
+      await runtime.off(SubscriptionEvent.LINK_COMPLETE, handler);
+      expect(configureStub).to.be.calledTwice.calledWith(false);
+    });
+
+    it('should delegate "getActiveFlows"', async () => {
+      const activeFlows = [
+        {flow: SubscriptionFlows.SUBSCRIBE, state: FlowState.PAY_STARTED},
+      ];
+      configuredRuntimeMock
+        .expects('getActiveFlows')
+        .withExactArgs()
+        .resolves(activeFlows)
+        .once();
+
+      expect(await runtime.getActiveFlows()).to.equal(activeFlows);
+      expect(configureStub).to.be.calledOnce.calledWith(false);
+    });
+
+    it('should delegate "getDiagnostics"', async () => {
+      configuredRuntimeMock
+        .expects('getDiagnostics')
+        .withExactArgs()
+        .resolves('{}')
+        .once();
+
+      expect(await runtime.getDiagnostics()).to.equal('{}');
+      expect(configureStub).to.be.calledOnce.calledWith(false);
+    });
+
+    it('should delegate "getPerformanceTimings"', async () => {
+      const timings = {'entitlements': 100};
+      configuredRuntimeMock
+        .expects('getPerformanceTimings')
+        .withExactArgs()
+        .resolves(timings)
+        .once();
+
+      expect(await runtime.getPerformanceTimings()).to.equal(timings);
+      expect(configureStub).to.be.calledOnce.calledWith(false);
+    });
+
     it('should delegate "saveSubscription" with token', async () => {
       const requestCallback = () => ({token: 'test'});
       configuredRuntimeMock
@@ -937,6 +1175,20 @@ describes.realWin('Runtime', {}, (env) => {
       expect(xhrFetchStub).to.not.be.called;
     });
 
+    it('should override retry policies', async () => {
+      const retryPolicy = new RetryPolicy({maxAttempts: 1});
+      const xhrFetchStub = sandbox
+        .stub(XhrFetcher.prototype, 'fetchCredentialedJson')
+        .callsFake(() => Promise.resolve({}));
+      runtime = new ConfiguredRuntime(new GlobalDoc(win), config, {
+        retryPolicies: {[RetriedRequest.ENTITLEMENTS]: retryPolicy},
+      });
+
+      await runtime.getEntitlements();
+      expect(xhrFetchStub).to.be.calledOnce;
+      expect(xhrFetchStub.args[0][1]).to.equal(retryPolicy);
+    });
+
     it('should return propensity module', async () => {
       const propensity = new Propensity(win, configuredRuntime, new Fetcher());
       configuredRuntimeMock
@@ -1057,6 +1309,163 @@ describes.realWin('ConfiguredRuntime', {}, (env) => {
     ).to.throw();
   });
 
+  it('should allow storageBackends to be set in config', () => {
+    const publisherBackend = {
+      getItem: () => null,
+      setItem: () => {},
+      removeItem: () => {},
+    };
+    runtime = new ConfiguredRuntime(win, config, null, {
+      storageBackends: {
+        session: ['sessionStorage', 'cookie'],
+        local: [publisherBackend, 'indexedDB'],
+      },
+    });
+    expect(runtime.config().storageBackends.local[0]).to.equal(
+      publisherBackend
+    );
+  });
+
+  it('should throw if storageBackends has an unknown backend', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          storageBackends: {session: ['floppyDisk']},
+        })
+    ).to.throw('Unknown storageBackends value: {"session":["floppyDisk"]}');
+  });
+
+  it('should throw if storageBackends has an unknown chain', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          storageBackends: {forever: ['localStorage']},
+        })
+    ).to.throw(/Unknown storageBackends value/);
+  });
+
+  it('should throw if storageBackends has an incomplete backend', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          storageBackends: {local: [{getItem: () => null}]},
+        })
+    ).to.throw(/Unknown storageBackends value/);
+  });
+
+  it('should throw if trackNavigation is not a boolean', () => {
+    expect(
+      () => new ConfiguredRuntime(win, config, null, {trackNavigation: 'yes'})
+    ).to.throw(/Unknown trackNavigation value/);
+  });
+
+  it('should throw if enableDiagnostics is not a boolean', () => {
+    expect(
+      () => new ConfiguredRuntime(win, config, null, {enableDiagnostics: 1})
+    ).to.throw(/Unknown enableDiagnostics value/);
+  });
+
+  it('should throw if consent is invalid', () => {
+    expect(
+      () => new ConfiguredRuntime(win, config, null, {consent: {ads: true}})
+    ).to.throw(/Unknown consent value/);
+  });
+
+  it('should throw if googleAnalyticsEvents is invalid', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          googleAnalyticsEvents: {'PAYWALL': 'view_promotion'},
+        })
+    ).to.throw(
+      'Unknown googleAnalyticsEvents value: {"PAYWALL":"view_promotion"}'
+    );
+  });
+
+  it('should throw if analyticsSinks is invalid', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          analyticsSinks: [{type: 'httpCollector'}],
+        })
+    ).to.throw('Unknown analyticsSinks value: [{"type":"httpCollector"}]');
+  });
+
+  it('should not start analytics without consent', () => {
+    const startStub = sandbox.stub(AnalyticsService.prototype, 'start');
+
+    runtime = new ConfiguredRuntime(win, config, null, {
+      consent: {storage: true},
+    });
+
+    expect(startStub).to.not.be.called;
+    expect(runtime.consentManager().hasConsent(ConsentPurpose.STORAGE)).to.be
+      .true;
+  });
+
+  it('should notify consent changes', () => {
+    runtime = new ConfiguredRuntime(win, config);
+    const changeCallback = sandbox.spy();
+    runtime.consentManager().onChange(changeCallback);
+
+    runtime.configure({consent: {analytics: true}});
+
+    expect(changeCallback).to.be.calledOnce;
+  });
+
+  it('should allow entitlementsCachePolicy to be set in config', () => {
+    runtime = new ConfiguredRuntime(win, config, null, {
+      entitlementsCachePolicy: {
+        mode: 'cache-negative',
+        negativeTtlSeconds: 60,
+        offlineGracePeriodSeconds: 86400,
+      },
+    });
+    expect(runtime.config().entitlementsCachePolicy).to.deep.equal({
+      mode: 'cache-negative',
+      negativeTtlSeconds: 60,
+      offlineGracePeriodSeconds: 86400,
+    });
+  });
+
+  it('should throw if entitlementsCachePolicy has an unknown mode', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          entitlementsCachePolicy: {mode: 'cache-everything'},
+        })
+    ).to.throw(
+      'Unknown entitlementsCachePolicy value: {"mode":"cache-everything"}'
+    );
+  });
+
+  it('should throw if entitlementsCachePolicy has an invalid TTL', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          entitlementsCachePolicy: {negativeTtlSeconds: -1},
+        })
+    ).to.throw(/Unknown entitlementsCachePolicy value/);
+  });
+
+  it('should throw if entitlementsCachePolicy has an invalid grace period', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          entitlementsCachePolicy: {offlineGracePeriodSeconds: '1d'},
+        })
+    ).to.throw(/Unknown entitlementsCachePolicy value/);
+  });
+
+  it('should throw if entitlementsCachePolicy has an unknown property', () => {
+    expect(
+      () =>
+        new ConfiguredRuntime(win, config, null, {
+          entitlementsCachePolicy: {maxAge: 60},
+        })
+    ).to.throw(/Unknown entitlementsCachePolicy value/);
+  });
+
   describe('while configuring', () => {
     let resolveConfig;
     let rejectConfig;
@@ -1295,6 +1704,18 @@ describes.realWin('ConfiguredRuntime', {}, (env) => {
         const result = await promise;
         expect(result).to.deep.equal({flow: 'flow1', data: {b: 2}});
       });
+
+      it('should call the handlers subscribed to an event', async () => {
+        const callback = sandbox.spy();
+        const handler = sandbox.spy();
+        runtime.setOnFlowStarted(callback);
+        runtime.on(SubscriptionEvent.FLOW_STARTED, handler);
+        runtime.callbacks().triggerFlowStarted('flow1');
+
+        await tick();
+        expect(callback).to.be.calledOnce;
+        expect(handler).to.be.calledOnceWithExactly({flow: 'flow1', data: {}});
+      });
     });
 
     describe('config', () => {
@@ -1436,6 +1857,142 @@ describes.realWin('ConfiguredRuntime', {}, (env) => {
       runtime.closeDialog();
     });
 
+    it('should tear down once destroyed', async () => {
+      dialogManagerMock.expects('completeAll').once();
+      entitlementsManagerMock.expects('destroy').once();
+      analyticsMock.expects('destroy').once();
+      eventManagerMock.expects('destroy').once();
+      const closeStub = sandbox.stub(runtime.crossTabSync_, 'close');
+      const teardownStubs = [
+        runtime.flowController_,
+        runtime.diagnostics_,
+        runtime.propensityModule_,
+        runtime.consentManager_,
+        runtime.storage_,
+        runtime.buttonApi_,
+      ].map((component) => sandbox.stub(component, 'destroy'));
+
+      await runtime.destroy();
+      await runtime.destroy();
+      expect(closeStub).to.be.calledOnce;
+      for (const stub of teardownStubs) {
+        expect(stub).to.be.calledOnce;
+      }
+    });
+
+    it('should only close its own dialog once destroyed', async () => {
+      const dialogManager = new DialogManager(new GlobalDoc(win));
+      const runtime1 = new ConfiguredRuntime(win, config, {dialogManager});
+      const runtime2 = new ConfiguredRuntime(win, config, {dialogManager});
+      const view = {getPerformanceTimings: () => runtime2.performanceTimings()};
+      sandbox.stub(dialogManager, 'getDialog').returns({
+        getCurrentView: () => view,
+      });
+      const completeAllStub = sandbox.stub(dialogManager, 'completeAll');
+
+      await runtime1.destroy();
+      expect(completeAllStub).to.not.be.called;
+
+      await runtime2.destroy();
+      expect(completeAllStub).to.be.calledOnce;
+    });
+
+    it('should leave nothing listening once destroyed', async () => {
+      const targets = [win, win.document];
+      const addSpies = targets.map((target) =>
+        sandbox.spy(target, 'addEventListener')
+      );
+      const removeSpies = targets.map((target) =>
+        sandbox.spy(target, 'removeEventListener')
+      );
+      win.__tcfapi = sandbox.spy((command, version, callback) => {
+        if (command === 'addEventListener') {
+          callback({listenerId: 1, eventStatus: 'tcloaded'}, true);
+        }
+      });
+      win.__gpp = sandbox.spy((command, callback) => {
+        if (command === 'addEventListener') {
+          callback({listenerId: 2, eventName: 'listenerRegistered'}, true);
+        }
+      });
+      const dialogManager = new DialogManager(new GlobalDoc(win));
+      const otherRuntime = new ConfiguredRuntime(win, config, {
+        dialogManager,
+      });
+      otherRuntime.storage().syncAcrossTabs(['key'], () => {});
+
+      await otherRuntime.destroy();
+      const tcfApi = win.__tcfapi;
+      const gppApi = win.__gpp;
+      delete win.__tcfapi;
+      delete win.__gpp;
+
+      targets.forEach((target, i) => {
+        for (const {args} of addSpies[i].getCalls()) {
+          expect(removeSpies[i]).to.be.calledWith(args[0], args[1]);
+        }
+      });
+      expect(tcfApi).to.be.calledWith('removeEventListener', 2);
+      expect(gppApi).to.be.calledWith('removeEventListener');
+      expect(dialogManager.openCallbacks_).to.be.empty;
+      expect(dialogManager.closeCallbacks_).to.be.empty;
+    });
+
+    it('should restore the DOM once destroyed', async () => {
+      const {head, body} = win.document;
+      const initialHead = head./*OK*/ innerHTML;
+      const initialBody = body./*OK*/ innerHTML;
+      const otherRuntime = new ConfiguredRuntime(win, config);
+      expect(head./*OK*/ innerHTML).to.not.equal(initialHead);
+
+      await otherRuntime.destroy();
+      expect(head./*OK*/ innerHTML).to.equal(initialHead);
+      expect(body./*OK*/ innerHTML).to.equal(initialBody);
+    });
+
+    it('should switch to the article of another page', async () => {
+      const newConfig = new PageConfig('pub1:label2', false);
+      dialogManagerMock.expects('completeAll').once();
+      entitlementsManagerMock
+        .expects('navigate')
+        .withExactArgs(newConfig)
+        .returns(false)
+        .once();
+      entitlementsManagerMock.expects('getEntitlements').never();
+
+      await runtime.navigateTo(newConfig);
+      expect(runtime.pageConfig()).to.equal(newConfig);
+    });
+
+    it('should fetch entitlements again after navigation if they were requested', async () => {
+      const newConfig = new PageConfig('pub1:label2', false);
+      dialogManagerMock.expects('completeAll').once();
+      entitlementsManagerMock
+        .expects('navigate')
+        .withExactArgs(newConfig)
+        .returns(true)
+        .once();
+      entitlementsManagerMock
+        .expects('getEntitlements')
+        .withExactArgs(undefined)
+        .returns(
+          Promise.resolve(
+            new Entitlements('service', 'raw', [], null, () => {})
+          )
+        )
+        .once();
+
+      await runtime.navigateTo(newConfig);
+    });
+
+    it('should not navigate to another publication', () => {
+      entitlementsManagerMock.expects('navigate').never();
+
+      expect(() => runtime.navigateTo(new PageConfig('pub2:label1'))).to.throw(
+        /Navigation to another publication/
+      );
+    });
+
     it('should not start entitlements flow without product', async () => {
       sandbox.stub(config, 'getProductId').callsFake(() => null);
       entitlementsManagerMock.expects('getEntitlements').never();
@@ -1700,7 +2257,8 @@ new subscribers. Use the showOffers() method instead.'
         .stub(LinkbackFlow.prototype, 'start')
         .callsFake(() => Promise.resolve());
 
-      await runtime.linkAccount();
+      runtime.linkAccount();
+      await runtime.documentParsed_;
       expect(startStub).to.be.calledOnce;
     });
 
@@ -1712,7 +2270,8 @@ new subscribers. Use the showOffers() method instead.'
           return Promise.resolve();
         });
 
-      await runtime.linkAccount({ampReaderId: 'ari1'});
+      runtime.linkAccount({ampReaderId: 'ari1'});
+      await runtime.documentParsed_;
       expect(startStub).to.be.calledOnce;
     });
 
@@ -1734,7 +2293,8 @@ new subscribers. Use the showOffers() method instead.'
           return Promise.resolve();
         });
 
-      await runtime.subscribe('sku1');
+      runtime.subscribe('sku1');
+      await runtime.documentParsed_;
       expect(startStub).to.be.calledOnce;
       expect(flowInstance.subscriptionRequest_.skuId).to.equal('sku1');
       expect(flowInstance.productType_).to.equal(ProductType.SUBSCRIPTION);
@@ -1838,12 +2398,208 @@ subscribe() method'
           return Promise.resolve();
         });
 
-      await runtime.contribute('sku1');
+      runtime.contribute('sku1');
+      await runtime.documentParsed_;
       expect(startStub).to.be.calledOnce;
       expect(flowInstance.subscriptionRequest_.skuId).to.equal('sku1');
       expect(flowInstance.productType_).to.equal(ProductType.UI_CONTRIBUTION);
     });
 
+    it('should resolve "subscribe" with the purchase', async () => {
+      sandbox.stub(PayStartFlow.prototype, 'start').resolves();
+      const response = new SubscribeResponse('RaW');
+      const onOutcome = sandbox.spy();
+
+      const promise = runtime.subscribe('sku1', {onOutcome});
+      await runtime.documentParsed_;
+      expect(onOutcome).to.not.be.called;
+      runtime.callbacks().triggerPaymentResponse(Promise.resolve(response));
+
+      const outcome = await promise;
+      const {type, flow, response: purchase} = outcome;
+      expect(type).to.equal(FlowOutcomeType.PURCHASED);
+      expect(flow).to.equal(SubscriptionFlows.SUBSCRIBE);
+      expect(purchase.raw).to.equal('RaW');
+      expect(onOutcome).to.be.calledOnceWithExactly(outcome);
+    });
+
+    it('should resolve "showOffers" once the user cancels', async () => {
+      sandbox.stub(OffersFlow.prototype, 'start').resolves();
+      const onOutcome = sandbox.spy();
+
+      const promise = runtime.showOffers(undefined, {onOutcome});
+      await runtime.documentParsed_;
+      runtime.callbacks().triggerFlowCanceled(SubscriptionFlows.SHOW_OFFERS);
+
+      const outcome = {
+        type: FlowOutcomeType.CANCELED,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+      };
+      expect(await promise).to.deep.equal(outcome);
+      expect(onOutcome).to.be.calledOnceWithExactly(outcome);
+    });
+
+    it('should resolve "showOffers" without flow options', async () => {
+      sandbox.stub(OffersFlow.prototype, 'start').resolves();
+
+      const promise = runtime.showOffers();
+      await runtime.documentParsed_;
+      runtime.callbacks().triggerFlowCanceled(SubscriptionFlows.SHOW_OFFERS);
+
+      expect(await promise).to.deep.equal({
+        type: FlowOutcomeType.CANCELED,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+      });
+    });
+
+    it('should abort "showOffers" through its signal', async () => {
+      sandbox
+        .stub(OffersFlow.prototype, 'start')
+        .returns(new Promise(() => {}));
+      const abortStub = sandbox.stub(OffersFlow.prototype, 'abort');
+      const controller = new AbortController();
+      const onOutcome = sandbox.spy();
+
+      const promise = runtime.showOffers(undefined, {
+        signal: controller.signal,
+        onOutcome,
+      });
+      await runtime.documentParsed_;
+      await tick();
+      controller.abort();
+
+      await expect(promise).to.be.rejectedWith('Flow aborted');
+      expect(abortStub).to.be.calledOnce;
+      expect(onOutcome).to.be.calledOnceWithExactly({
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+        code: FlowErrorCode.ABORTED,
+      });
+    });
+
+    it('should abort "showOffers" once started', async () => {
+      sandbox.stub(OffersFlow.prototype, 'start').resolves();
+      const abortStub = sandbox.stub(OffersFlow.prototype, 'abort');
+      const controller = new AbortController();
+      const onOutcome = sandbox.spy();
+
+      const promise = runtime.showOffers(undefined, {
+        signal: controller.signal,
+        onOutcome,
+      });
+      await runtime.documentParsed_;
+      await tick();
+      controller.abort();
+
+      const outcome = {
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+        code: FlowErrorCode.ABORTED,
+      };
+      expect(await promise).to.deep.equal(outcome);
+      expect(abortStub).to.be.calledOnce;
+      expect(onOutcome).to.be.calledOnceWithExactly(outcome);
+    });
+
+    it('should resolve the outcomes as aborted once destroyed', async () => {
+      sandbox.stub(OffersFlow.prototype, 'start').resolves();
+      const onOutcome = sandbox.spy();
+      dialogManagerMock.expects('completeAll').once();
+      entitlementsManagerMock.expects('destroy').once();
+      analyticsMock.expects('destroy').once();
+      eventManagerMock.expects('destroy').once();
+
+      const promise = runtime.showOffers(undefined, {onOutcome});
+      await runtime.documentParsed_;
+      await tick();
+      await runtime.destroy();
+      runtime.callbacks().triggerFlowCanceled(SubscriptionFlows.SHOW_OFFERS);
+
+      expect(await promise).to.deep.equal({
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+        code: FlowErrorCode.ABORTED,
+      });
+      expect(onOutcome).to.not.be.called;
+      expect(runtime.callbacks().outcomeWaiters_).to.be.empty;
+    });
+
+    it('should return the active flows', async () => {
+      runtime.callbacks().triggerFlowStarted(SubscriptionFlows.SHOW_OFFERS);
+
+      expect(await runtime.getActiveFlows()).to.deep.equal([
+        {flow: SubscriptionFlows.SHOW_OFFERS, state: FlowState.DIALOG_OPEN},
+      ]);
+    });
+
+    it('should export the diagnostics', async () => {
+      runtime.configure({enableDiagnostics: true});
+      runtime.callbacks().triggerFlowStarted(SubscriptionFlows.SHOW_OFFERS);
+
+      const diagnostics = JSON.parse(await runtime.getDiagnostics());
+      expect(diagnostics.publicationId).to.equal('pub1');
+      const entry = diagnostics.entries.find((entry) => entry.type == 'flow');
+      expect(entry.data).to.deep.equal({
+        flow: SubscriptionFlows.SHOW_OFFERS,
+        state: FlowState.DIALOG_OPEN,
+        previousState: FlowState.IDLE,
+      });
+    });
+
+    it('should return the performance timings', async () => {
+      runtime.performanceTimings().start(PerformanceTiming.ENTITLEMENTS)();
+
+      const timings = await runtime.getPerformanceTimings();
+      expect(timings[PerformanceTiming.ENTITLEMENTS]).to.be.a('number');
+    });
+
+    it('should not start "showOffers" during a purchase', async () => {
+      const startStub = sandbox.stub(OffersFlow.prototype, 'start');
+      const onOutcome = sandbox.spy();
+      runtime.callbacks().triggerFlowStarted(SubscriptionFlows.SUBSCRIBE);
+
+      const outcome = {
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.SHOW_OFFERS,
+        code: FlowErrorCode.FLOW_CONFLICT,
+      };
+      expect(await runtime.showOffers(undefined, {onOutcome})).to.deep.equal(
+        outcome
+      );
+      expect(startStub).to.not.be.called;
+      expect(onOutcome).to.be.calledOnceWithExactly(outcome);
+    });
+
+    it('should not start "showAbbrvOffer" during a purchase', async () => {
+      const startStub = sandbox.stub(AbbrvOfferFlow.prototype, 'start');
+      runtime.callbacks().triggerFlowStarted(SubscriptionFlows.CONTRIBUTE);
+
+      await expect(runtime.showAbbrvOffer()).to.be.rejectedWith(
+        "The showAbbrvOffer flow can't start while the contribute flow is in progress."
+      );
+      expect(startStub).to.not.be.called;
+    });
+
+    it('should resolve "linkAccount" with an error if it fails', async () => {
+      const error = new Error('broken');
+      sandbox.stub(LinkbackFlow.prototype, 'start').rejects(error);
+      jserrorMock
+        .expects('error')
+        .withExactArgs('Flow failed to start', error)
+        .once();
+      const onOutcome = sandbox.spy();
+
+      const outcome = {
+        type: FlowOutcomeType.ERROR,
+        flow: SubscriptionFlows.LINK_ACCOUNT,
+        code: FlowErrorCode.START_FAILED,
+      };
+      expect(await runtime.linkAccount({}, {onOutcome})).to.deep.equal(
+        outcome
+      );
+      expect(onOutcome).to.be.calledOnceWithExactly(outcome);
+    });
+
     it('should start saveSubscriptionFlow with callback for token', async () => {
       let linkSaveFlow;
       const newPromise = new Promise(() => {});
@@ -1919,6 +2675,38 @@ subscribe() method'
       expect(result).to.equal(accountResult);
     });
 
+    it('should log the abort of "waitForSubscriptionLookup"', async () => {
+      sandbox
+        .stub(WaitForSubscriptionLookupApi.prototype, 'start')
+        .returns(new Promise(() => {}));
+      const abortStub = sandbox.stub(
+        WaitForSubscriptionLookupApi.prototype,
+        'abort'
+      );
+      eventManagerMock
+        .expects('logSwgEvent')
+        .withExactArgs(
+          AnalyticsEvent.ACTION_FLOW_ABORTED,
+          false,
+          sandbox.match(
+            (params) =>
+              params.getSubscriptionFlow() ===
+              SubscriptionFlows.WAIT_FOR_SUBSCRIPTION_LOOKUP
+          )
+        )
+        .once();
+      const controller = new AbortController();
+
+      const promise = runtime.waitForSubscriptionLookup(new Promise(() => {}), {
+        signal: controller.signal,
+      });
+      await runtime.documentParsed_;
+      controller.abort();
+
+      await expect(promise).to.be.rejectedWith('Flow aborted');
+      expect(abortStub).to.be.calledOnce;
+    });
+
     it('should directly call "attachButton"', () => {
       const options = {};
       const callback = () => {};
@@ -21,20 +21,39 @@ import {
   EventOriginator,
   EventParams,
 } from '../proto/api_messages';
-import {AnalyticsMode} from '../api/subscriptions';
+import {
+  AnalyticsMode,
+  ConsentPurpose,
+  EntitlementsCacheMode,
+} from '../api/subscriptions';
 import {AnalyticsService} from './analytics-service';
+import {AnalyticsSinkManager} from './analytics-sink-manager';
 import {ButtonApi} from './button-api';
 import {Callbacks} from './callbacks';
 import {ClientConfigManager} from './client-config-manager';
 import {ClientEventManager} from './client-event-manager';
+import {ConsentManager, isValidConsentState} from './consent-manager';
 import {ContributionsFlow} from './contributions-flow';
+import {CrossTabSync} from './cross-tab-sync';
 import {DeferredAccountFlow} from './deferred-account-flow';
 import {DepsDef} from './deps';
+import {DiagnosticsRecorder} from './diagnostics';
 import {DialogManager} from '../components/dialog-manager';
 import {Doc, resolveDoc} from '../model/doc';
 import {EntitlementsManager} from './entitlements-manager';
 import {ExperimentFlags} from './experiment-flags';
 import {Fetcher, XhrFetcher} from './fetcher';
+import {FlowController} from './flow-controller';
+import {
+  FlowErrorCode,
+  FlowOutcomeType,
+  FlowState,
+  ProductType,
+  SubscriptionFlows,
+  Subscriptions,
+  WindowOpenMode,
+  defaultConfig,
+} from '../api/subscriptions';
 import {GoogleAnalyticsEventListener} from './google-analytics-event-listener';
 import {JsError} from './jserror';
 import {
@@ -45,6 +64,7 @@ import {
 import {Logger} from './logger';
 import {LoginNotificationApi} from './login-notification-api';
 import {LoginPromptApi} from './login-prompt-api';
+import {NavigationTracker} from './navigation-tracker';
 import {OffersApi} from './offers-api';
 import {PageConfig} from '../model/page-config';
 import {
@@ -53,27 +73,33 @@ import {
 } from '../model/page-config-resolver';
 import {PayClient} from './pay-client';
 import {PayCompleteFlow, PayStartFlow} from './pay-flow';
+import {PerformanceTimings} from '../utils/performance';
 import {Preconnect} from '../utils/preconnect';
-import {
-  ProductType,
-  Subscriptions,
-  WindowOpenMode,
-  defaultConfig,
-} from '../api/subscriptions';
 import {Propensity} from './propensity';
+import {RetriedRequest} from './retry-policies';
 import {CSS as SWG_DIALOG} from '../../build/css/components/dialog.css';
 import {Storage} from './storage';
 import {WaitForSubscriptionLookupApi} from './wait-for-subscription-lookup-api';
 import {assert} from '../utils/log';
 import {debugLog} from '../utils/log';
-import {injectStyleSheet, isLegacyEdgeBrowser} from '../utils/dom';
-import {isBoolean} from '../utils/types';
+import {
+  injectStyleSheet,
+  isLegacyEdgeBrowser,
+  removeElement,
+} from '../utils/dom';
+import {isBoolean, isEnumValue, isObject} from '../utils/types';
 import {isExperimentOn} from './experiments';
 import {isSecure, wasReferredByGoogle} from '../utils/url';
+import {isValidAnalyticsSink} from './analytics-sinks';
+import {
+  isValidGoogleAnalyticsEvents,
+  showcaseEventToAnalyticsEvents,
+} from './event-type-mapping';
+import {isValidStorageBackend} from './storage-backends';
 import {parseUrl} from '../utils/url';
 import {queryStringHasFreshGaaParams} from '../utils/gaa';
+import {runAbortableFlow} from './flow-abort';
 import {setExperiment} from './experiments';
-import {showcaseEventToAnalyticsEvents} from './event-type-mapping';
 import {warn} from '../utils/log';
 
 const RUNTIME_PROP = 'SWG';
@@ -81,9 +107,9 @@ const RUNTIME_LEGACY_PROP = 'SUBSCRIPTIONS'; // MIGRATE
 
 /**
  * Reference to the runtime, for testing.
- * @private {!Runtime}
+ * @private {?Runtime}
  */
-let runtimeInstance_;
+let runtimeInstance_ = null;
 
 /**
  * Returns runtime for testing if available. Throws if the runtime is not
@@ -129,7 +155,10 @@ export function installRuntime(win) {
     }
 
     runtime.whenReady().then(() => {
-      callback(publicRuntime);
+      // Drop the callbacks pushed to a destroyed runtime.
+      if (!runtime.isDestroyed()) {
+        callback(publicRuntime);
+      }
     });
   }
 
@@ -181,6 +210,9 @@ export class Runtime {
     /** @private {boolean} */
     this.committed_ = false;
 
+    /** @private {boolean} */
+    this.destroyed_ = false;
+
     /** @private {?function((!ConfiguredRuntime|!Promise))} */
     this.configuredResolver_ = null;
 
@@ -192,6 +224,23 @@ export class Runtime {
     /** @private {?PageConfigResolver} */
     this.pageConfigResolver_ = null;
 
+    /** @private @const {!NavigationTracker} */
+    this.navigationTracker_ = new NavigationTracker(win, () =>
+      this.navigate()
+    );
+
+    /**
+     * Shared by the runtimes of all the publications of the page.
+     * @private @const {!DialogManager}
+     */
+    this.dialogManager_ = new DialogManager(this.doc_);
+
+    /**
+     * Runtimes created for other publications of the page.
+     * @private @const {!Array<!ConfiguredRuntime>}
+     */
+    this.createdRuntimes_ = [];
+
     /** @private @const {!ButtonApi} */
     this.buttonApi_ = new ButtonApi(this.doc_, this.configuredPromise_);
     this.buttonApi_.init(); // Injects swg-button stylesheet.
@@ -204,6 +253,14 @@ export class Runtime {
     return this.ready_;
   }
 
+  /**
+   * @return {boolean}
+   * @package
+   */
+  isDestroyed() {
+    return this.destroyed_;
+  }
+
   /**
    * @param {boolean} commit
    * @return {!Promise<!ConfiguredRuntime>}
@@ -212,34 +269,28 @@ export class Runtime {
   configured_(commit) {
     if (!this.committed_ && commit) {
       this.committed_ = true;
-      /** @type {!Promise<!PageConfig>} */
-      let pageConfigPromise;
-      if (this.productOrPublicationId_) {
-        pageConfigPromise = Promise.resolve(
-          new PageConfig(this.productOrPublicationId_, /* locked */ false)
-        );
-      } else {
-        this.pageConfigResolver_ = new PageConfigResolver(this.doc_);
-        pageConfigPromise = this.pageConfigResolver_
-          .resolveConfig()
-          .then((config) => {
-            this.pageConfigResolver_ = null;
-            return config;
-          });
-      }
-      pageConfigPromise.then(
+      this.resolvePageConfig_().then(
         (pageConfig) => {
+          if (this.destroyed_) {
+            return;
+          }
           this.configuredResolver_(
             new ConfiguredRuntime(
               this.doc_,
               pageConfig,
-              /* integr */ {configPromise: this.configuredPromise_},
+              /* integr */ {
+                configPromise: this.configuredPromise_,
+                dialogManager: this.dialogManager_,
+              },
               this.config_
             )
           );
           this.configuredResolver_ = null;
         },
         (reason) => {
+          if (this.destroyed_) {
+            return;
+          }
           this.configuredResolver_(Promise.reject(reason));
           this.configuredResolver_ = null;
         }
@@ -250,6 +301,25 @@ export class Runtime {
     return this.configuredPromise_;
   }
 
+  /**
+   * Resolves the config of the page, from the product or publication ID
+   * passed to `init`, or from the markup of the page.
+   * @return {!Promise<!PageConfig>}
+   * @private
+   */
+  resolvePageConfig_() {
+    if (this.productOrPublicationId_) {
+      return Promise.resolve(
+        new PageConfig(this.productOrPublicationId_, /* locked */ false)
+      );
+    }
+    this.pageConfigResolver_ = new PageConfigResolver(this.doc_);
+    return this.pageConfigResolver_.resolveConfig().then((config) => {
+      this.pageConfigResolver_ = null;
+      return config;
+    });
+  }
+
   /**
    * Starts the subscription flow if it hasn't been started and the page is
    * configured to start it automatically.
@@ -280,7 +350,15 @@ export class Runtime {
   configure(config) {
     // Accumulate config for startup.
     Object.assign(this.config_, config);
-    return this.configured_(false).then((runtime) => runtime.configure(config));
+    return this.configured_(false).then((runtime) => {
+      const result = runtime.configure(config);
+      if (this.config_.trackNavigation) {
+        this.navigationTracker_.start();
+      } else {
+        this.navigationTracker_.stop();
+      }
+      return result;
+    });
   }
 
   /** @override */
@@ -298,6 +376,78 @@ export class Runtime {
     return this.configured_(true).then((runtime) => runtime.clear());
   }
 
+  /** @override */
+  navigate() {
+    return this.configured_(true).then((runtime) =>
+      this.resolvePageConfig_().then((pageConfig) =>
+        runtime.navigateTo(pageConfig)
+      )
+    );
+  }
+
+  /** @override */
+  destroy() {
+    if (this.destroyed_) {
+      return Promise.resolve();
+    }
+    this.destroyed_ = true;
+    this.navigationTracker_.stop();
+    for (const runtime of this.createdRuntimes_) {
+      runtime.destroy();
+    }
+    this.createdRuntimes_.length = 0;
+
+    // Uninstall the runtime, so that `installRuntime` creates a new one.
+    if (runtimeInstance_ === this) {
+      delete this.win_[RUNTIME_PROP];
+      delete this.win_[RUNTIME_LEGACY_PROP];
+      runtimeInstance_ = null;
+    }
+    this.buttonApi_.destroy();
+    // Closes the dialog and removes the graypane, even if not configured.
+    this.dialogManager_.completeAll();
+
+    if (this.configuredResolver_) {
+      // Not configured yet: drop the configuration and reject other calls.
+      this.configuredResolver_(Promise.reject(new Error('Runtime destroyed')));
+      this.configuredResolver_ = null;
+    }
+    return this.configured_(false).then(
+      (runtime) => runtime.destroy(),
+      () => {}
+    );
+  }
+
+  /** @override */
+  createRuntime(options) {
+    assert(!this.destroyed_, 'Runtime destroyed');
+    const pageConfig = new PageConfig(
+      options.productId || options.publicationId,
+      /* locked */ false
+    );
+    assert(
+      pageConfig.getPublicationId() == options.publicationId,
+      'Product of another publication: ' + options.productId
+    );
+    const runtime = new ConfiguredRuntime(
+      this.doc_,
+      pageConfig,
+      /* integr */ {
+        dialogManager: this.dialogManager_,
+        namespace: options.publicationId,
+      },
+      this.config_
+    );
+    this.createdRuntimes_.push(runtime);
+    return createPublicCreatedRuntime(runtime, () => {
+      const index = this.createdRuntimes_.indexOf(runtime);
+      if (index != -1) {
+        this.createdRuntimes_.splice(index, 1);
+      }
+      return runtime.destroy();
+    });
+  }
+
   /** @override */
   getEntitlements(params) {
     return this.configured_(true).then((runtime) =>
@@ -318,9 +468,9 @@ export class Runtime {
   }
 
   /** @override */
-  showOffers(options) {
+  showOffers(options, flowOptions) {
     return this.configured_(true).then((runtime) =>
-      runtime.showOffers(options)
+      runtime.showOffers(options, flowOptions)
     );
   }
 
@@ -346,16 +496,16 @@ export class Runtime {
   }
 
   /** @override */
-  showContributionOptions(options) {
+  showContributionOptions(options, flowOptions) {
     return this.configured_(true).then((runtime) =>
-      runtime.showContributionOptions(options)
+      runtime.showContributionOptions(options, flowOptions)
     );
   }
 
   /** @override */
-  waitForSubscriptionLookup(accountPromise) {
+  waitForSubscriptionLookup(accountPromise, flowOptions) {
     return this.configured_(true).then((runtime) =>
-      runtime.waitForSubscriptionLookup(accountPromise)
+      runtime.waitForSubscriptionLookup(accountPromise, flowOptions)
     );
   }
 
@@ -374,8 +524,10 @@ export class Runtime {
   }
 
   /** @override */
-  subscribe(sku) {
-    return this.configured_(true).then((runtime) => runtime.subscribe(sku));
+  subscribe(sku, flowOptions) {
+    return this.configured_(true).then((runtime) =>
+      runtime.subscribe(sku, flowOptions)
+    );
   }
 
   /** @override */
@@ -400,9 +552,9 @@ export class Runtime {
   }
 
   /** @override */
-  contribute(skuOrSubscriptionRequest) {
+  contribute(skuOrSubscriptionRequest, flowOptions) {
     return this.configured_(true).then((runtime) =>
-      runtime.contribute(skuOrSubscriptionRequest)
+      runtime.contribute(skuOrSubscriptionRequest, flowOptions)
     );
   }
 
@@ -435,9 +587,9 @@ export class Runtime {
   }
 
   /** @override */
-  linkAccount(params = {}) {
+  linkAccount(params = {}, flowOptions) {
     return this.configured_(true).then((runtime) =>
-      runtime.linkAccount(params)
+      runtime.linkAccount(params, flowOptions)
     );
   }
 
@@ -455,6 +607,41 @@ export class Runtime {
     );
   }
 
+  /** @override */
+  on(event, handler) {
+    return this.configured_(false).then((runtime) =>
+      runtime.on(event, handler)
+    );
+  }
+
+  /** @override */
+  off(event, handler) {
+    return this.configured_(false).then((runtime) =>
+      runtime.off(event, handler)
+    );
+  }
+
+  /** @override */
+  getActiveFlows() {
+    return this.configured_(false).then((runtime) =>
+      runtime.getActiveFlows()
+    );
+  }
+
+  /** @override */
+  getDiagnostics() {
+    return this.configured_(false).then((runtime) =>
+      runtime.getDiagnostics()
+    );
+  }
+
+  /** @override */
+  getPerformanceTimings() {
+    return this.configured_(false).then((runtime) =>
+      runtime.getPerformanceTimings()
+    );
+  }
+
   /** @override */
   saveSubscription(saveSubscriptionRequestCallback) {
     return this.configured_(true).then((runtime) => {
@@ -544,8 +731,12 @@ export class ConfiguredRuntime {
    * @param {{
    *     fetcher: (!Fetcher|undefined),
    *     configPromise: (!Promise|undefined),
+   *     dialogManager: (!DialogManager|undefined),
+   *     namespace: (string|undefined),
    *     enableGoogleAnalytics: (boolean|undefined),
-   *     useArticleEndpoint: (boolean|undefined)
+   *     useArticleEndpoint: (boolean|undefined),
+   *     retryPolicies: (!Object<!RetriedRequest,
+   *         !../utils/retry-policy.RetryPolicy>|undefined)
    *   }=} integr
    * @param {!../api/subscriptions.Config=} config
    * @param {!{
@@ -556,6 +747,7 @@ export class ConfiguredRuntime {
   constructor(winOrDoc, pageConfig, integr, config, clientOptions) {
     integr = integr || {};
     integr.configPromise = integr.configPromise || Promise.resolve();
+    const retryPolicies = integr.retryPolicies || {};
 
     /** @private @const {!ClientEventManager} */
     this.eventManager_ = new ClientEventManager(integr.configPromise);
@@ -578,7 +770,7 @@ export class ConfiguredRuntime {
       this.configure_(config);
     }
 
-    /** @private @const {!../model/page-config.PageConfig} */
+    /** @private {!../model/page-config.PageConfig} */
     this.pageConfig_ = pageConfig;
 
     /** @private @const {!Promise} */
@@ -590,15 +782,45 @@ export class ConfiguredRuntime {
     /** @private @const {!Fetcher} */
     this.fetcher_ = integr.fetcher || new XhrFetcher(this.win_);
 
+    /** @private @const {!PerformanceTimings} */
+    this.performanceTimings_ = new PerformanceTimings(this.win_);
+
+    /** @private @const {!ConsentManager} */
+    this.consentManager_ = new ConsentManager(this);
+
+    /** @private @const {!CrossTabSync} */
+    this.crossTabSync_ = new CrossTabSync(this.win_, this.consentManager_);
+
     /** @private @const {!Storage} */
-    this.storage_ = new Storage(this.win_);
+    this.storage_ = new Storage(
+      this.win_,
+      this.config_,
+      this.crossTabSync_,
+      integr.namespace,
+      this.consentManager_
+    );
 
     /** @private @const {!DialogManager} */
-    this.dialogManager_ = new DialogManager(this.doc_);
+    this.dialogManager_ = integr.dialogManager || new DialogManager(this.doc_);
+
+    /**
+     * Whether the dialog manager is not shared with other runtimes.
+     * @private @const {boolean}
+     */
+    this.ownsDialogManager_ = !integr.dialogManager;
+
+    /** @private {boolean} */
+    this.destroyed_ = false;
 
     /** @private @const {!Callbacks} */
     this.callbacks_ = new Callbacks();
 
+    /** @private @const {!FlowController} */
+    this.flowController_ = new FlowController(this);
+
+    /** @private @const {!DiagnosticsRecorder} */
+    this.diagnostics_ = new DiagnosticsRecorder(this);
+
     /** @private {?OffersFlow} */
     this.lastOffersFlow_ = null;
 
@@ -617,11 +839,22 @@ export class ConfiguredRuntime {
     // WARNING: DepsDef ('this') is being progressively defined below.
     // Constructors will crash if they rely on something that doesn't exist yet.
     /** @private @const {!../components/activities.ActivityPorts} */
-    this.activityPorts_ = new ActivityPorts(this);
+    this.activityPorts_ = new ActivityPorts(this, integr.namespace);
 
     /** @private @const {!AnalyticsService} */
-    this.analyticsService_ = new AnalyticsService(this, this.fetcher_);
-    this.analyticsService_.start();
+    this.analyticsService_ = new AnalyticsService(
+      this,
+      this.fetcher_,
+      retryPolicies[RetriedRequest.CLIENT_LOGS]
+    );
+    // Otherwise, the service iframe is opened for the first event logged
+    // with consent.
+    if (this.consentManager_.hasConsent(ConsentPurpose.ANALYTICS)) {
+      this.analyticsService_.start();
+    }
+
+    /** @private @const {!AnalyticsSinkManager} */
+    this.analyticsSinkManager_ = new AnalyticsSinkManager(this);
 
     /** @private @const {!PayClient} */
     this.payClient_ = new PayClient(this);
@@ -635,7 +868,8 @@ export class ConfiguredRuntime {
       this.pageConfig_,
       this.fetcher_,
       this, // See note about 'this' above
-      integr.useArticleEndpoint || false
+      integr.useArticleEndpoint || false,
+      retryPolicies[RetriedRequest.ENTITLEMENTS]
     );
 
     /** @private @const {!ClientConfigManager} */
@@ -643,14 +877,16 @@ export class ConfiguredRuntime {
       this, // See note about 'this' above
       pageConfig.getPublicationId(),
       this.fetcher_,
-      clientOptions
+      clientOptions,
+      retryPolicies[RetriedRequest.CLIENT_CONFIG]
     );
 
     /** @private @const {!Propensity} */
     this.propensityModule_ = new Propensity(
       this.win_,
       this, // See note about 'this' above
-      this.fetcher_
+      this.fetcher_,
+      retryPolicies[RetriedRequest.PROPENSITY]
     );
 
     // ALL CLEAR: DepsDef definition now complete.
@@ -662,16 +898,18 @@ export class ConfiguredRuntime {
     /** @private @const {!ButtonApi} */
     this.buttonApi_ = new ButtonApi(this.doc_, Promise.resolve(this));
 
-    const preconnect = new Preconnect(this.win_.document);
+    /** @private @const {!Preconnect} */
+    this.preconnect_ = new Preconnect(this.win_.document);
 
-    preconnect.prefetch('$assets$/loader.svg');
-    preconnect.preconnect('https://www.gstatic.com/');
-    preconnect.preconnect('https://fonts.googleapis.com/');
-    preconnect.preconnect('https://www.google.com/');
+    this.preconnect_.prefetch('$assets$/loader.svg');
+    this.preconnect_.preconnect('https://www.gstatic.com/');
+    this.preconnect_.preconnect('https://fonts.googleapis.com/');
+    this.preconnect_.preconnect('https://www.google.com/');
     LinkCompleteFlow.configurePending(this);
     PayCompleteFlow.configurePending(this);
 
-    injectStyleSheet(this.doc_, SWG_DIALOG);
+    /** @private @const {!Element} */
+    this.styleSheet_ = injectStyleSheet(this.doc_, SWG_DIALOG);
 
     // Report redirect errors if any.
     this.activityPorts_.onRedirectError((error) => {
@@ -729,6 +967,26 @@ export class ConfiguredRuntime {
     return this.callbacks_;
   }
 
+  /** @override */
+  flowController() {
+    return this.flowController_;
+  }
+
+  /** @override */
+  diagnostics() {
+    return this.diagnostics_;
+  }
+
+  /** @override */
+  performanceTimings() {
+    return this.performanceTimings_;
+  }
+
+  /** @override */
+  consentManager() {
+    return this.consentManager_;
+  }
+
   /** @override */
   storage() {
     return this.storage_;
@@ -806,6 +1064,43 @@ export class ConfiguredRuntime {
             error = 'Unknown skipAccountCreationScreen value: ' + value;
           }
           break;
+        case 'storageBackends':
+          if (!isValidStorageBackendsConfig(value)) {
+            error = 'Unknown storageBackends value: ' + JSON.stringify(value);
+          }
+          break;
+        case 'entitlementsCachePolicy':
+          if (!isValidEntitlementsCachePolicy(value)) {
+            error =
+              'Unknown entitlementsCachePolicy value: ' + JSON.stringify(value);
+          }
+          break;
+        case 'trackNavigation':
+          if (!isBoolean(value)) {
+            error = 'Unknown trackNavigation value: ' + value;
+          }
+          break;
+        case 'enableDiagnostics':
+          if (!isBoolean(value)) {
+            error = 'Unknown enableDiagnostics value: ' + value;
+          }
+          break;
+        case 'consent':
+          if (!isValidConsentState(value)) {
+            error = 'Unknown consent value: ' + JSON.stringify(value);
+          }
+          break;
+        case 'googleAnalyticsEvents':
+          if (!isValidGoogleAnalyticsEvents(value)) {
+            error =
+              'Unknown googleAnalyticsEvents value: ' + JSON.stringify(value);
+          }
+          break;
+        case 'analyticsSinks':
+          if (!Array.isArray(value) || !value.every(isValidAnalyticsSink)) {
+            error = 'Unknown analyticsSinks value: ' + JSON.stringify(value);
+          }
+          break;
         default:
           error = 'Unknown config property: ' + key;
       }
@@ -814,6 +1109,14 @@ export class ConfiguredRuntime {
     assert(!error, error || undefined);
     // Assign.
     Object.assign(this.config_, config);
+    if ('consent' in config && this.consentManager_) {
+      // Not yet created when configured by the constructor.
+      this.consentManager_.update();
+    }
+    if ('analyticsSinks' in config && this.analyticsSinkManager_) {
+      // Not yet created when configured by the constructor.
+      this.analyticsSinkManager_.update();
+    }
   }
 
   /** @override */
@@ -833,6 +1136,73 @@ export class ConfiguredRuntime {
     this.closeDialog();
   }
 
+  /** @override */
+  navigate() {
+    // Implemented by the `Runtime` class.
+  }
+
+  /** @override */
+  createRuntime() {
+    // Implemented by the `Runtime` class.
+  }
+
+  /** @override */
+  destroy() {
+    if (this.destroyed_) {
+      return Promise.resolve();
+    }
+    this.destroyed_ = true;
+    if (this.ownsDialogManager_) {
+      this.closeDialog();
+    } else {
+      // The dialog manager is shared with other runtimes, so only close the
+      // dialog if it shows a view of this runtime.
+      const dialog = this.dialogManager_.getDialog();
+      const view = dialog && dialog.getCurrentView();
+      if (view && view.getPerformanceTimings() === this.performanceTimings_) {
+        this.closeDialog();
+      }
+    }
+    this.callbacks_.dropFlowOutcomes();
+    this.flowController_.destroy();
+    this.diagnostics_.destroy();
+    this.entitlementsManager_.destroy();
+    this.analyticsService_.destroy();
+    this.analyticsSinkManager_.destroy();
+    this.eventManager_.destroy();
+    this.propensityModule_.destroy();
+    this.consentManager_.destroy();
+    this.storage_.destroy();
+    this.crossTabSync_.close();
+    this.buttonApi_.destroy();
+    this.preconnect_.destroy();
+    removeElement(this.styleSheet_);
+    return Promise.resolve();
+  }
+
+  /**
+   * Switches to the article of another page of a single page application.
+   * Entitlements are fetched again if they were requested for the previous
+   * article, so that the entitlements response callback is notified of the
+   * new one.
+   * @param {!../model/page-config.PageConfig} pageConfig
+   * @return {!Promise}
+   */
+  navigateTo(pageConfig) {
+    assert(
+      pageConfig.getPublicationId() == this.pageConfig_.getPublicationId(),
+      'Navigation to another publication: ' + pageConfig.getPublicationId()
+    );
+    this.pageConfig_ = pageConfig;
+    const requested = this.entitlementsManager_.navigate(pageConfig);
+    this.closeDialog();
+    if (requested) {
+      return this.getEntitlements().then(() => {});
+    }
+    this.start();
+    return Promise.resolve();
+  }
+
   /** Close dialog. */
   closeDialog() {
     this.dialogManager_.completeAll();
@@ -879,14 +1249,20 @@ export class ConfiguredRuntime {
   }
 
   /** @override */
-  showOffers(options) {
+  showOffers(options, flowOptions) {
     return this.documentParsed_.then(() => {
       const errorMessage =
         'The showOffers() method cannot be used to update a subscription. ' +
         'Use the showUpdateOffers() method instead.';
       assert(options ? !options['oldSku'] : true, errorMessage);
       this.lastOffersFlow_ = new OffersFlow(this, options);
-      return this.lastOffersFlow_.start();
+      const flow = this.lastOffersFlow_;
+      return this.startFlow_(
+        [SubscriptionFlows.SHOW_OFFERS, SubscriptionFlows.SUBSCRIBE],
+        flowOptions,
+        () => flow.start(),
+        () => flow.abort()
+      );
     });
   }
 
@@ -909,6 +1285,7 @@ export class ConfiguredRuntime {
   /** @override */
   showSubscribeOption(options) {
     return this.documentParsed_.then(() => {
+      this.assertCanStart_(SubscriptionFlows.SHOW_SUBSCRIBE_OPTION);
       const flow = new SubscribeOptionFlow(this, options);
       return flow.start();
     });
@@ -917,19 +1294,115 @@ export class ConfiguredRuntime {
   /** @override */
   showAbbrvOffer(options) {
     return this.documentParsed_.then(() => {
+      this.assertCanStart_(SubscriptionFlows.SHOW_ABBRV_OFFER);
       const flow = new AbbrvOfferFlow(this, options);
       return flow.start();
     });
   }
 
   /** @override */
-  showContributionOptions(options) {
+  showContributionOptions(options, flowOptions) {
     return this.documentParsed_.then(() => {
       this.lastContributionsFlow_ = new ContributionsFlow(this, options);
-      return this.lastContributionsFlow_.start();
+      const flow = this.lastContributionsFlow_;
+      return this.startFlow_(
+        [
+          SubscriptionFlows.SHOW_CONTRIBUTION_OPTIONS,
+          SubscriptionFlows.CONTRIBUTE,
+        ],
+        flowOptions,
+        () => flow.start(),
+        () => flow.abort()
+      );
     });
   }
 
+  /**
+   * Starts a flow and returns the promise of its outcome, which is passed to
+   * the `onOutcome` flow option as well. The outcome is an error if the flow
+   * fails to start, conflicts with a flow in progress, or is aborted once
+   * started. The promise is rejected with an AbortError if the flow is
+   * aborted before it started.
+   * @param {!Array<string>} flows The flow, followed by the flows it can start
+   *     itself, e.g. the purchase started from the offers.
+   * @param {!../api/subscriptions.FlowOptions|undefined} flowOptions
+   * @param {function():!Promise} start
+   * @param {function()} abort
+   * @return {!Promise<!../api/subscriptions.FlowOutcome>}
+   * @private
+   */
+  startFlow_(flows, flowOptions, start, abort) {
+    const flow = flows[0];
+    const onOutcome = flowOptions && flowOptions.onOutcome;
+    const conflictingFlow = this.flowController_.getConflictingFlow(flow);
+    if (conflictingFlow) {
+      warn(
+        `[swg.js]: The ${flow} flow can't start while the ${conflictingFlow} flow is in progress.`
+      );
+      const outcome = {
+        type: FlowOutcomeType.ERROR,
+        flow,
+        code: FlowErrorCode.FLOW_CONFLICT,
+      };
+      if (onOutcome) {
+        onOutcome(outcome);
+      }
+      return Promise.resolve(outcome);
+    }
+    const whenOutcome = this.callbacks_
+      .whenFlowOutcome(flows)
+      .then((outcome) => {
+        if (!outcome) {
+          // The runtime was destroyed. The publisher isn't called back.
+          return {
+            type: FlowOutcomeType.ERROR,
+            flow,
+            code: FlowErrorCode.ABORTED,
+          };
+        }
+        if (onOutcome) {
+          onOutcome(outcome);
+        }
+        return outcome;
+      });
+    return runAbortableFlow(
+      this,
+      flow,
+      flowOptions,
+      () =>
+        start().catch((reason) => {
+          this.flowController_.setState(flow, FlowState.FAILED);
+          this.callbacks_.triggerFlowError(
+            flows,
+            FlowErrorCode.START_FAILED,
+            reason
+          );
+          this.jserror_.error('Flow failed to start', reason);
+        }),
+      () => {
+        abort();
+        this.flowController_.setState(flow, FlowState.CANCELED);
+        this.callbacks_.triggerFlowError(flows, FlowErrorCode.ABORTED);
+      },
+      whenOutcome
+    ).then(() => whenOutcome);
+  }
+
+  /**
+   * Throws if the flow conflicts with a flow in progress.
+   * @param {string} flow
+   * @private
+   */
+  assertCanStart_(flow) {
+    const conflictingFlow = this.flowController_.getConflictingFlow(flow);
+    assert(
+      !conflictingFlow,
+      "The %s flow can't start while the %s flow is in progress.",
+      flow,
+      conflictingFlow
+    );
+  }
+
   /**
    * Get the last contribution offers flow.
    * @return {?ContributionsFlow}
@@ -939,10 +1412,16 @@ export class ConfiguredRuntime {
   }
 
   /** @override */
-  waitForSubscriptionLookup(accountPromise) {
+  waitForSubscriptionLookup(accountPromise, flowOptions) {
     return this.documentParsed_.then(() => {
       const wait = new WaitForSubscriptionLookupApi(this, accountPromise);
-      return wait.start();
+      return runAbortableFlow(
+        this,
+        SubscriptionFlows.WAIT_FOR_SUBSCRIPTION_LOOKUP,
+        flowOptions,
+        () => wait.start(),
+        () => wait.abort()
+      );
     });
   }
 
@@ -962,9 +1441,15 @@ export class ConfiguredRuntime {
   }
 
   /** @override */
-  linkAccount(params = {}) {
+  linkAccount(params = {}, flowOptions) {
     return this.documentParsed_.then(() => {
-      return new LinkbackFlow(this).start(params);
+      const flow = new LinkbackFlow(this);
+      return this.startFlow_(
+        [SubscriptionFlows.LINK_ACCOUNT],
+        flowOptions,
+        () => flow.start(params),
+        () => flow.abort()
+      );
     });
   }
 
@@ -978,6 +1463,7 @@ export class ConfiguredRuntime {
   /** @override */
   showLoginPrompt() {
     return this.documentParsed_.then(() => {
+      this.assertCanStart_(SubscriptionFlows.SHOW_LOGIN_PROMPT);
       return new LoginPromptApi(this).start();
     });
   }
@@ -985,6 +1471,7 @@ export class ConfiguredRuntime {
   /** @override */
   showLoginNotification() {
     return this.documentParsed_.then(() => {
+      this.assertCanStart_(SubscriptionFlows.SHOW_LOGIN_NOTIFICATION);
       return new LoginNotificationApi(this).start();
     });
   }
@@ -1005,13 +1492,19 @@ export class ConfiguredRuntime {
   }
 
   /** @override */
-  subscribe(sku) {
+  subscribe(sku, flowOptions) {
     const errorMessage =
       'The subscribe() method can only take a sku as its parameter; ' +
       'for subscription updates please use the updateSubscription() method';
     assert(typeof sku === 'string', errorMessage);
     return this.documentParsed_.then(() => {
-      return new PayStartFlow(this, {'skuId': sku}).start();
+      const flow = new PayStartFlow(this, {'skuId': sku});
+      return this.startFlow_(
+        [SubscriptionFlows.SUBSCRIBE],
+        flowOptions,
+        () => flow.start(),
+        () => flow.abort()
+      );
     });
   }
 
@@ -1039,24 +1532,29 @@ export class ConfiguredRuntime {
   }
 
   /** @override */
-  contribute(skuOrSubscriptionRequest) {
+  contribute(skuOrSubscriptionRequest, flowOptions) {
     /** @type {!../api/subscriptions.SubscriptionRequest} */
     const request =
       typeof skuOrSubscriptionRequest == 'string'
         ? {'skuId': skuOrSubscriptionRequest}
         : skuOrSubscriptionRequest;
     return this.documentParsed_.then(() => {
-      return new PayStartFlow(
-        this,
-        request,
-        ProductType.UI_CONTRIBUTION
-      ).start();
+      const flow = new PayStartFlow(this, request, ProductType.UI_CONTRIBUTION);
+      return this.startFlow_(
+        [SubscriptionFlows.CONTRIBUTE],
+        flowOptions,
+        () => flow.start(),
+        () => flow.abort()
+      );
     });
   }
 
   /** @override */
   completeDeferredAccountCreation(options) {
     return this.documentParsed_.then(() => {
+      this.assertCanStart_(
+        SubscriptionFlows.COMPLETE_DEFERRED_ACCOUNT_CREATION
+      );
       return new DeferredAccountFlow(this, options || null).start();
     });
   }
@@ -1071,6 +1569,31 @@ export class ConfiguredRuntime {
     this.callbacks_.setOnFlowCanceled(callback);
   }
 
+  /** @override */
+  on(event, handler) {
+    this.callbacks_.on(event, handler);
+  }
+
+  /** @override */
+  off(event, handler) {
+    this.callbacks_.off(event, handler);
+  }
+
+  /** @override */
+  getActiveFlows() {
+    return Promise.resolve(this.flowController_.getActiveFlows());
+  }
+
+  /** @override */
+  getDiagnostics() {
+    return Promise.resolve(this.diagnostics_.export());
+  }
+
+  /** @override */
+  getPerformanceTimings() {
+    return Promise.resolve(this.performanceTimings_.getDurations());
+  }
+
   /** @override */
   createButton(optionsOrCallback, callback) {
     // This is a minor duplication to allow this code to be sync.
@@ -1176,7 +1699,57 @@ export class ConfiguredRuntime {
 }
 
 /**
- * @param {!Runtime} runtime
+ * @param {*} value
+ * @return {boolean}
+ */
+function isValidStorageBackendsConfig(value) {
+  if (!isObject(value)) {
+    return false;
+  }
+  for (const chainName in value) {
+    const chain = value[chainName];
+    if (
+      (chainName != 'session' && chainName != 'local') ||
+      !Array.isArray(chain) ||
+      !chain.every(isValidStorageBackend)
+    ) {
+      return false;
+    }
+  }
+  return true;
+}
+
+/**
+ * @param {*} value
+ * @return {boolean}
+ */
+function isValidEntitlementsCachePolicy(value) {
+  if (!isObject(value)) {
+    return false;
+  }
+  for (const key in value) {
+    const property = value[key];
+    switch (key) {
+      case 'mode':
+        if (!isEnumValue(EntitlementsCacheMode, property)) {
+          return false;
+        }
+        break;
+      case 'negativeTtlSeconds':
+      case 'offlineGracePeriodSeconds':
+        if (typeof property != 'number' || !(property >= 0)) {
+          return false;
+        }
+        break;
+      default:
+        return false;
+    }
+  }
+  return true;
+}
+
+/**
+ * @param {!Runtime|!ConfiguredRuntime} runtime
  * @return {!Subscriptions}
  */
 function createPublicRuntime(runtime) {
@@ -1186,6 +1759,9 @@ function createPublicRuntime(runtime) {
     start: runtime.start.bind(runtime),
     reset: runtime.reset.bind(runtime),
     clear: runtime.clear.bind(runtime),
+    navigate: runtime.navigate.bind(runtime),
+    destroy: runtime.destroy.bind(runtime),
+    createRuntime: runtime.createRuntime.bind(runtime),
     getEntitlements: runtime.getEntitlements.bind(runtime),
     linkAccount: runtime.linkAccount.bind(runtime),
     showLoginPrompt: runtime.showLoginPrompt.bind(runtime),
@@ -1213,6 +1789,11 @@ function createPublicRuntime(runtime) {
     setOnContributionResponse: runtime.setOnContributionResponse.bind(runtime),
     setOnFlowStarted: runtime.setOnFlowStarted.bind(runtime),
     setOnFlowCanceled: runtime.setOnFlowCanceled.bind(runtime),
+    on: runtime.on.bind(runtime),
+    off: runtime.off.bind(runtime),
+    getActiveFlows: runtime.getActiveFlows.bind(runtime),
+    getDiagnostics: runtime.getDiagnostics.bind(runtime),
+    getPerformanceTimings: runtime.getPerformanceTimings.bind(runtime),
     saveSubscription: runtime.saveSubscription.bind(runtime),
     createButton: runtime.createButton.bind(runtime),
     attachButton: runtime.attachButton.bind(runtime),
@@ -1227,6 +1808,31 @@ function createPublicRuntime(runtime) {
   });
 }
 
+/**
+ * Exposes a runtime created for another publication of the page. It's
+ * initialized for its publication, the markup of the page doesn't describe
+ * its articles, and only the page's runtime creates runtimes.
+ * @param {!ConfiguredRuntime} runtime
+ * @param {function():!Promise} destroy Destroys the runtime, and removes it
+ *     from the runtimes of the page's runtime.
+ * @return {!Subscriptions}
+ */
+function createPublicCreatedRuntime(runtime, destroy) {
+  return Object.assign(createPublicRuntime(runtime), {
+    init: () => {
+      throw new Error('Created runtimes are already initialized');
+    },
+    navigate: () =>
+      Promise.reject(
+        new Error('Created runtimes can not navigate, create a new one')
+      ),
+    createRuntime: () => {
+      throw new Error('Runtimes can only be created by the page runtime');
+    },
+    destroy,
+  });
+}
+
 /**
  * @protected
  */
@@ -0,0 +1,302 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {
+  CookieStorageBackend,
+  IndexedDbStorageBackend,
+  MemoryStorageBackend,
+  WebStorageBackend,
+  createStorageBackend,
+  isValidStorageBackend,
+} from './storage-backends';
+import {tick} from '../../test/tick';
+
+/**
+ * Minimal emulation of `document.cookie`.
+ */
+class FakeCookieDocument {
+  constructor() {
+    this.jar = {};
+    this.written = [];
+    this.maxLength = Infinity;
+  }
+
+  get cookie() {
+    return Object.keys(this.jar)
+      .map((name) => `${name}=${this.jar[name]}`)
+      .join('; ');
+  }
+
+  set cookie(value) {
+    this.written.push(value);
+    if (value.length > this.maxLength) {
+      return;
+    }
+    const pair = value.split(';')[0];
+    const index = pair.indexOf('=');
+    const name = pair.substring(0, index);
+    if (/max-age=0(;|$)/.test(value)) {
+      delete this.jar[name];
+    } else {
+      this.jar[name] = pair.substring(index + 1);
+    }
+  }
+}
+
+describes.realWin('storage backends', {}, (env) => {
+  let win;
+
+  beforeEach(() => {
+    win = env.win;
+  });
+
+  describe('WebStorageBackend', () => {
+    it('should read and write session storage', () => {
+      const backend = new WebStorageBackend(win, /* persistent */ false);
+
+      backend.setItem('test:a', 'one');
+
+      expect(win.sessionStorage.getItem('test:a')).to.equal('one');
+      expect(backend.getItem('test:a')).to.equal('one');
+      backend.removeItem('test:a');
+      expect(win.sessionStorage.getItem('test:a')).to.be.null;
+    });
+
+    it('should read and write local storage', () => {
+      const backend = new WebStorageBackend(win, /* persistent */ true);
+
+      backend.setItem('test:a', 'one');
+
+      expect(win.localStorage.getItem('test:a')).to.equal('one');
+      backend.removeItem('test:a');
+      expect(win.localStorage.getItem('test:a')).to.be.null;
+    });
+
+    it('should remove the items with a prefix', () => {
+      const backend = new WebStorageBackend(win, /* persistent */ true);
+      backend.setItem('test:a', 'one');
+      backend.setItem('test:b', 'two');
+      backend.setItem('other:a', 'three');
+
+      backend.removeItemsWithPrefix('test:');
+
+      expect(win.localStorage.getItem('test:a')).to.be.null;
+      expect(win.localStorage.getItem('test:b')).to.be.null;
+      expect(win.localStorage.getItem('other:a')).to.equal('three');
+      backend.removeItem('other:a');
+    });
+
+    it('should throw if storage is not available', () => {
+      const backend = new WebStorageBackend(
+        {sessionStorage: null},
+        /* persistent */ false
+      );
+
+      expect(() => backend.getItem('a')).to.throw(/not available/);
+    });
+
+    it('should throw if accessing storage throws', () => {
+      const fakeWin = {};
+      Object.defineProperty(fakeWin, 'localStorage', {
+        get: () => {
+          throw new Error('SecurityError');
+        },
+      });
+      const backend = new WebStorageBackend(fakeWin, /* persistent */ true);
+
+      expect(() => backend.setItem('a', 'one')).to.throw('SecurityError');
+    });
+  });
+
+  describe('IndexedDbStorageBackend', () => {
+    it('should read, write and remove values', async () => {
+      const backend = new IndexedDbStorageBackend(win);
+
+      await backend.setItem('test:a', 'one');
+      await expect(backend.getItem('test:a')).to.eventually.equal('one');
+
+      await backend.removeItem('test:a');
+      await expect(backend.getItem('test:a')).to.eventually.be.null;
+    });
+
+    it('should close the connection', async () => {
+      const backend = new IndexedDbStorageBackend(win);
+      await backend.getItem('test:a');
+      const db = await backend.db_;
+      const closeSpy = sandbox.spy(db, 'close');
+
+      backend.close();
+      await tick();
+
+      expect(closeSpy).to.be.calledOnce;
+      await expect(backend.getItem('test:a')).to.eventually.be.null;
+    });
+
+    it('should keep the session and persistent values apart', async () => {
+      const local = new IndexedDbStorageBackend(win, /* persistent */ true);
+      const session = new IndexedDbStorageBackend(win, /* persistent */ false);
+
+      await local.setItem('test:a', 'local');
+      await session.setItem('test:a', 'session');
+
+      await expect(local.getItem('test:a')).to.eventually.equal('local');
+      await expect(session.getItem('test:a')).to.eventually.equal('session');
+      await local.removeItem('test:a');
+      await session.removeItem('test:a');
+    });
+
+    it('should clear the session values in a new session', async () => {
+      const local = new IndexedDbStorageBackend(win, /* persistent */ true);
+      let session = new IndexedDbStorageBackend(win, /* persistent */ false);
+      await local.setItem('test:a', 'local');
+      await session.setItem('test:a', 'session');
+      session.close();
+
+      // Same browser session.
+      session = new IndexedDbStorageBackend(win, /* persistent */ false);
+      await expect(session.getItem('test:a')).to.eventually.equal('session');
+      session.close();
+
+      // New browser session, without the session cookies.
+      win.document.cookie = 'swg_idb_session=; path=/; max-age=0';
+      session = new IndexedDbStorageBackend(win, /* persistent */ false);
+      await expect(session.getItem('test:a')).to.eventually.be.null;
+      await expect(local.getItem('test:a')).to.eventually.equal('local');
+      await local.removeItem('test:a');
+    });
+
+    it('should reject if IndexedDB is not available', async () => {
+      const backend = new IndexedDbStorageBackend({});
+
+      await expect(backend.getItem('a')).to.be.rejectedWith(/not available/);
+    });
+  });
+
+  describe('CookieStorageBackend', () => {
+    let doc;
+    let fakeWin;
+
+    beforeEach(() => {
+      doc = new FakeCookieDocument();
+      fakeWin = {document: doc, location: {protocol: 'https:'}};
+    });
+
+    it('should write a persistent cookie', () => {
+      const backend = new CookieStorageBackend(fakeWin, /* persistent */ true);
+
+      backend.setItem('subscribe.google.com:a', 'one two');
+
+      expect(doc.written[0]).to.equal(
+        'subscribe.google.com%3Aa=one%20two; path=/; SameSite=Lax; ' +
+          'max-age=31536000; Secure'
+      );
+      expect(backend.getItem('subscribe.google.com:a')).to.equal('one two');
+    });
+
+    it('should write a session cookie', () => {
+      fakeWin.location.protocol = 'http:';
+      const backend = new CookieStorageBackend(fakeWin, /* persistent */ false);
+
+      backend.setItem('a', 'one');
+
+      expect(doc.written[0]).to.equal('a=one; path=/; SameSite=Lax');
+    });
+
+    it('should return null for missing cookies', () => {
+      doc.jar['b'] = 'two';
+      const backend = new CookieStorageBackend(fakeWin, /* persistent */ true);
+
+      expect(backend.getItem('a')).to.be.null;
+    });
+
+    it('should remove a cookie', () => {
+      const backend = new CookieStorageBackend(fakeWin, /* persistent */ true);
+      backend.setItem('a', 'one');
+
+      backend.removeItem('a');
+
+      expect(backend.getItem('a')).to.be.null;
+    });
+
+    it('should throw if the cookie was dropped', () => {
+      doc.maxLength = 10;
+      const backend = new CookieStorageBackend(fakeWin, /* persistent */ true);
+
+      expect(() => backend.setItem('a', 'a-very-long-value')).to.throw(
+        /could not be written/
+      );
+    });
+  });
+
+  describe('MemoryStorageBackend', () => {
+    it('should read, write and remove values', () => {
+      const backend = new MemoryStorageBackend();
+
+      expect(backend.getItem('a')).to.be.null;
+      backend.setItem('a', 'one');
+      expect(backend.getItem('a')).to.equal('one');
+      backend.removeItem('a');
+      expect(backend.getItem('a')).to.be.null;
+    });
+  });
+
+  describe('createStorageBackend', () => {
+    it('should create built-in backends', () => {
+      expect(
+        createStorageBackend(win, 'sessionStorage', false)
+      ).to.be.instanceOf(WebStorageBackend);
+      expect(createStorageBackend(win, 'localStorage', true)).to.be.instanceOf(
+        WebStorageBackend
+      );
+      expect(createStorageBackend(win, 'indexedDB', true)).to.be.instanceOf(
+        IndexedDbStorageBackend
+      );
+      expect(createStorageBackend(win, 'indexedDB', false).persistent_).to.be
+        .false;
+      expect(createStorageBackend(win, 'cookie', true)).to.be.instanceOf(
+        CookieStorageBackend
+      );
+      expect(createStorageBackend(win, 'memory', true)).to.be.instanceOf(
+        MemoryStorageBackend
+      );
+    });
+
+    it('should throw on unknown types', () => {
+      expect(() => createStorageBackend(win, 'floppyDisk', true)).to.throw(
+        /Unknown storage backend/
+      );
+    });
+  });
+
+  describe('isValidStorageBackend', () => {
+    it('should accept built-in types', () => {
+      expect(isValidStorageBackend('cookie')).to.be.true;
+      expect(isValidStorageBackend('floppyDisk')).to.be.false;
+    });
+
+    it('should accept objects implementing the interface', () => {
+      expect(
+        isValidStorageBackend({
+          getItem() {},
+          setItem() {},
+          removeItem() {},
+        })
+      ).to.be.true;
+      expect(isValidStorageBackend({getItem() {}})).to.be.false;
+      expect(isValidStorageBackend(null)).to.be.false;
+    });
+  });
+});
@@ -0,0 +1,377 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {StorageBackendType} from '../api/storage-backend';
+import {isEnumValue, isFunction, isObject} from '../utils/types';
+
+const IDB_NAME = 'subscribe.google.com';
+const IDB_VERSION = 1;
+const IDB_LOCAL_STORE_NAME = 'local';
+const IDB_SESSION_STORE_NAME = 'session';
+
+/**
+ * Session cookie set once the IndexedDB session store was cleared for the
+ * current browser session.
+ */
+const IDB_SESSION_COOKIE = 'swg_idb_session';
+
+/** One year, in seconds. */
+const COOKIE_MAX_AGE = 31536000;
+
+/**
+ * Stores data in `sessionStorage` or `localStorage`. The storage object is
+ * looked up on every call, since merely accessing it can throw in sandboxed
+ * iframes and in some private browsing modes.
+ * @implements {../api/storage-backend.StorageBackend}
+ */
+export class WebStorageBackend {
+  /**
+   * @param {!Window} win
+   * @param {boolean} persistent Whether to use `localStorage`.
+   */
+  constructor(win, persistent) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {boolean} */
+    this.persistent_ = persistent;
+  }
+
+  /** @override */
+  getItem(key) {
+    return this.getStorage_().getItem(key);
+  }
+
+  /** @override */
+  setItem(key, value) {
+    this.getStorage_().setItem(key, value);
+  }
+
+  /** @override */
+  removeItem(key) {
+    this.getStorage_().removeItem(key);
+  }
+
+  /**
+   * @param {string} prefix
+   */
+  removeItemsWithPrefix(prefix) {
+    const storage = this.getStorage_();
+    const keys = [];
+    for (let i = 0; i < storage.length; i++) {
+      const key = storage.key(i);
+      if (key && key.indexOf(prefix) == 0) {
+        keys.push(key);
+      }
+    }
+    for (const key of keys) {
+      storage.removeItem(key);
+    }
+  }
+
+  /**
+   * @return {!Storage}
+   * @private
+   */
+  getStorage_() {
+    const storage = this.persistent_
+      ? this.win_.localStorage
+      : this.win_.sessionStorage;
+    if (!storage) {
+      throw new Error('Web storage is not available');
+    }
+    return storage;
+  }
+}
+
+/**
+ * Stores data in an IndexedDB object store. Session and persistent backends
+ * use separate stores. Like session cookies, the session store lasts for the
+ * browser session: it's cleared the first time it's opened in a new one.
+ * @implements {../api/storage-backend.StorageBackend}
+ */
+export class IndexedDbStorageBackend {
+  /**
+   * @param {!Window} win
+   * @param {boolean=} persistent
+   */
+  constructor(win, persistent = true) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {boolean} */
+    this.persistent_ = persistent;
+
+    /** @private @const {string} */
+    this.storeName_ = persistent
+      ? IDB_LOCAL_STORE_NAME
+      : IDB_SESSION_STORE_NAME;
+
+    /** @private {?Promise<!IDBDatabase>} */
+    this.db_ = null;
+  }
+
+  /** @override */
+  getItem(key) {
+    return this.request_('readonly', (store) => store.get(key)).then(
+      (value) => (value == null ? null : String(value))
+    );
+  }
+
+  /** @override */
+  setItem(key, value) {
+    return this.request_('readwrite', (store) => store.put(value, key));
+  }
+
+  /** @override */
+  removeItem(key) {
+    return this.request_('readwrite', (store) => store.delete(key));
+  }
+
+  /**
+   * Closes the connection to the database, if open. Later requests open it
+   * again.
+   */
+  close() {
+    if (this.db_) {
+      this.db_.then(
+        (db) => db.close(),
+        () => {}
+      );
+      this.db_ = null;
+    }
+  }
+
+  /**
+   * @return {!Promise<!IDBDatabase>}
+   * @private
+   */
+  getDb_() {
+    if (!this.db_) {
+      this.db_ = new Promise((resolve, reject) => {
+        const indexedDB = this.win_.indexedDB;
+        if (!indexedDB) {
+          throw new Error('IndexedDB is not available');
+        }
+        const request = indexedDB.open(IDB_NAME, IDB_VERSION);
+        request.onupgradeneeded = () => {
+          request.result.createObjectStore(IDB_LOCAL_STORE_NAME);
+          request.result.createObjectStore(IDB_SESSION_STORE_NAME);
+        };
+        request.onsuccess = () => resolve(request.result);
+        request.onerror = () => reject(request.error);
+        request.onblocked = () => reject(new Error('IndexedDB is blocked'));
+      });
+      if (!this.persistent_) {
+        this.db_ = this.db_.then((db) => this.startSession_(db));
+      }
+    }
+    return this.db_;
+  }
+
+  /**
+   * Clears the session store, unless it was already cleared in the current
+   * browser session.
+   * @param {!IDBDatabase} db
+   * @return {!Promise<!IDBDatabase>}
+   * @private
+   */
+  startSession_(db) {
+    const marker = new CookieStorageBackend(this.win_, /* persistent */ false);
+    try {
+      if (marker.getItem(IDB_SESSION_COOKIE)) {
+        return Promise.resolve(db);
+      }
+    } catch (e) {
+      // Without cookies, every page load starts a new session.
+    }
+    const cleared = runRequest(db, this.storeName_, 'readwrite', (store) =>
+      store.clear()
+    );
+    return cleared.then(() => {
+      try {
+        marker.setItem(IDB_SESSION_COOKIE, '1');
+      } catch (e) {
+        // Cleared again on the next page load.
+      }
+      return db;
+    });
+  }
+
+  /**
+   * @param {string} mode
+   * @param {function(!IDBObjectStore):!IDBRequest} callback
+   * @return {!Promise<*>}
+   * @private
+   */
+  request_(mode, callback) {
+    return this.getDb_().then((db) =>
+      runRequest(db, this.storeName_, mode, callback)
+    );
+  }
+}
+
+/**
+ * @param {!IDBDatabase} db
+ * @param {string} storeName
+ * @param {string} mode
+ * @param {function(!IDBObjectStore):!IDBRequest} callback
+ * @return {!Promise<*>}
+ */
+function runRequest(db, storeName, mode, callback) {
+  return new Promise((resolve, reject) => {
+    const transaction = db.transaction(storeName, mode);
+    const request = callback(transaction.objectStore(storeName));
+    request.onsuccess = () => resolve(request.result);
+    request.onerror = () => reject(request.error);
+  });
+}
+
+/**
+ * Stores data in first-party cookies. Session backends use session cookies,
+ * persistent backends use cookies that expire after a year.
+ * @implements {../api/storage-backend.StorageBackend}
+ */
+export class CookieStorageBackend {
+  /**
+   * @param {!Window} win
+   * @param {boolean} persistent
+   */
+  constructor(win, persistent) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /** @private @const {boolean} */
+    this.persistent_ = persistent;
+  }
+
+  /** @override */
+  getItem(key) {
+    const name = encodeURIComponent(key);
+    const cookies = this.win_.document.cookie.split(';');
+    for (const cookie of cookies) {
+      const index = cookie.indexOf('=');
+      if (index != -1 && cookie.substring(0, index).trim() == name) {
+        return decodeURIComponent(cookie.substring(index + 1).trim());
+      }
+    }
+    return null;
+  }
+
+  /** @override */
+  setItem(key, value) {
+    this.write_(
+      key,
+      encodeURIComponent(value),
+      this.persistent_ ? COOKIE_MAX_AGE : -1
+    );
+    // Browsers silently drop cookies that are too large or disallowed.
+    if (this.getItem(key) !== value) {
+      throw new Error('Cookie could not be written');
+    }
+  }
+
+  /** @override */
+  removeItem(key) {
+    this.write_(key, '', 0);
+  }
+
+  /**
+   * @param {string} key
+   * @param {string} encodedValue
+   * @param {number} maxAge Negative values write a session cookie.
+   * @private
+   */
+  write_(key, encodedValue, maxAge) {
+    let cookie =
+      `${encodeURIComponent(key)}=${encodedValue}; path=/; SameSite=Lax`;
+    if (maxAge >= 0) {
+      cookie += `; max-age=${maxAge}`;
+    }
+    if (this.win_.location.protocol == 'https:') {
+      cookie += '; Secure';
+    }
+    this.win_.document.cookie = cookie;
+  }
+}
+
+/**
+ * Stores data in memory for the lifetime of the page.
+ * @implements {../api/storage-backend.StorageBackend}
+ */
+export class MemoryStorageBackend {
+  constructor() {
+    /** @private @const {!Object<string, string>} */
+    this.values_ = {};
+  }
+
+  /** @override */
+  getItem(key) {
+    return key in this.values_ ? this.values_[key] : null;
+  }
+
+  /** @override */
+  setItem(key, value) {
+    this.values_[key] = value;
+  }
+
+  /** @override */
+  removeItem(key) {
+    delete this.values_[key];
+  }
+}
+
+/**
+ * Creates a built-in storage backend.
+ * @param {!Window} win
+ * @param {!StorageBackendType} type
+ * @param {boolean} persistent
+ * @return {!../api/storage-backend.StorageBackend}
+ */
+export function createStorageBackend(win, type, persistent) {
+  switch (type) {
+    case StorageBackendType.SESSION_STORAGE:
+      return new WebStorageBackend(win, /* persistent */ false);
+    case StorageBackendType.LOCAL_STORAGE:
+      return new WebStorageBackend(win, /* persistent */ true);
+    case StorageBackendType.INDEXED_DB:
+      return new IndexedDbStorageBackend(win, persistent);
+    case StorageBackendType.COOKIE:
+      return new CookieStorageBackend(win, persistent);
+    case StorageBackendType.MEMORY:
+      return new MemoryStorageBackend();
+    default:
+      throw new Error('Unknown storage backend: ' + type);
+  }
+}
+
+/**
+ * Whether the value is a built-in backend type or a publisher-supplied
+ * backend implementing the `StorageBackend` interface.
+ * @param {*} value
+ * @return {boolean}
+ */
+export function isValidStorageBackend(value) {
+  if (typeof value == 'string') {
+    return isEnumValue(StorageBackendType, value);
+  }
+  return (
+    isObject(value) &&
+    isFunction(value.getItem) &&
+    isFunction(value.setItem) &&
+    isFunction(value.removeItem)
+  );
+}
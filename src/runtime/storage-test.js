@@ -15,6 +15,7 @@
  */
 
 import {Storage} from './storage';
+import {tick} from '../../test/tick';
 
 class WebStorageStub {
   getItem(unusedKey) {}
@@ -82,6 +83,17 @@ describes.realWin('Storage', {}, (env) => {
       await expect(storage.get('a')).to.eventually.equal('one');
     });
 
+    it('should reload the value from the storage', async () => {
+      sessionStorageMock
+        .expects('getItem')
+        .withExactArgs('subscribe.google.com:a')
+        .returns('one')
+        .twice();
+
+      await expect(storage.get('a')).to.eventually.equal('one');
+      await expect(storage.reload('a')).to.eventually.equal('one');
+    });
+
     it('should return null if storage is not available', async () => {
       Object.defineProperty(win, 'sessionStorage', {value: null});
       sessionStorageMock.expects('getItem').never();
@@ -320,3 +332,433 @@ describes.realWin('Storage', {}, (env) => {
     });
   });
 });
+
+describes.realWin('Storage with backend chains', {}, (env) => {
+  let win;
+  let config;
+  let storage;
+  let publisherBackend;
+
+  beforeEach(() => {
+    win = env.win;
+    publisherBackend = {
+      values: {},
+      getItem(key) {
+        return Promise.resolve(this.values[key] || null);
+      },
+      setItem(key, value) {
+        this.values[key] = value;
+        return Promise.resolve();
+      },
+      removeItem(key) {
+        delete this.values[key];
+        return Promise.resolve();
+      },
+    };
+    config = {};
+    storage = new Storage(win, config);
+    sandbox.stub(self.console, 'log');
+  });
+
+  it('should use a publisher-supplied backend', async () => {
+    config.storageBackends = {session: [publisherBackend]};
+
+    await storage.set('a', 'one');
+
+    expect(publisherBackend.values).to.deep.equal({
+      'subscribe.google.com:a': 'one',
+    });
+  });
+
+  it('should read from a publisher-supplied backend', async () => {
+    config.storageBackends = {local: [publisherBackend]};
+    publisherBackend.values['subscribe.google.com:a'] = 'one';
+
+    await expect(storage.get('a', /* useLocalStorage */ true)).to.eventually
+      .equal('one');
+  });
+
+  it('should prefix the keys with the namespace', async () => {
+    config.storageBackends = {session: [publisherBackend]};
+    publisherBackend.values['subscribe.google.com:a'] = 'other';
+    storage = new Storage(win, config, /* crossTabSync */ null, 'pub2');
+
+    await storage.set('b', 'one');
+
+    await expect(storage.get('a')).to.eventually.be.null;
+    expect(publisherBackend.values).to.deep.equal({
+      'subscribe.google.com:a': 'other',
+      'subscribe.google.com:pub2:b': 'one',
+    });
+  });
+
+  it('should only use session backends without consent', async () => {
+    const localBackend = Object.assign({}, publisherBackend, {values: {}});
+    config.storageBackends = {
+      session: ['cookie', publisherBackend],
+      local: [localBackend],
+    };
+    let hasConsent = false;
+    const consentManager = {hasConsent: () => hasConsent, onChange() {}};
+    storage = new Storage(win, config, null, '', consentManager);
+
+    await storage.set('a', 'one', /* useLocalStorage */ true);
+    expect(publisherBackend.values).to.deep.equal({
+      'subscribe.google.com:a': 'one',
+    });
+    expect(win.document.cookie).to.not.include('subscribe.google.com');
+
+    hasConsent = true;
+    await storage.set('b', 'two', /* useLocalStorage */ true);
+    expect(localBackend.values).to.deep.equal({
+      'subscribe.google.com:b': 'two',
+    });
+  });
+
+  it('should remove the local data once consent is revoked', async () => {
+    const sessionBackend = Object.assign({}, publisherBackend, {values: {}});
+    config.storageBackends = {
+      session: [sessionBackend],
+      local: [publisherBackend, 'localStorage'],
+    };
+    let hasConsent = true;
+    let onConsentChange;
+    const consentManager = {
+      hasConsent: () => hasConsent,
+      onChange: (callback) => (onConsentChange = callback),
+    };
+    storage = new Storage(win, config, null, '', consentManager);
+    win.localStorage.setItem('subscribe.google.com:previous', 'zero');
+    await storage.set('a', 'one', /* useLocalStorage */ true);
+    await storage.set('b', 'two');
+
+    hasConsent = false;
+    onConsentChange();
+    await tick();
+
+    expect(publisherBackend.values).to.deep.equal({});
+    expect(win.localStorage.getItem('subscribe.google.com:previous')).to.be
+      .null;
+    expect(sessionBackend.values).to.deep.equal({
+      'subscribe.google.com:b': 'two',
+    });
+  });
+
+  it('should keep the local data when consent is granted', async () => {
+    config.storageBackends = {local: [publisherBackend]};
+    let hasConsent = false;
+    let onConsentChange;
+    const consentManager = {
+      hasConsent: () => hasConsent,
+      onChange: (callback) => (onConsentChange = callback),
+    };
+    storage = new Storage(win, config, null, '', consentManager);
+    publisherBackend.values['subscribe.google.com:a'] = 'one';
+
+    hasConsent = true;
+    onConsentChange();
+    await tick();
+
+    await expect(storage.get('a', /* useLocalStorage */ true)).to.eventually
+      .equal('one');
+  });
+
+  it('should read the local data again once consent is granted', async () => {
+    config.storageBackends = {local: [publisherBackend]};
+    let hasConsent = false;
+    let onConsentChange;
+    const consentManager = {
+      hasConsent: () => hasConsent,
+      onChange: (callback) => (onConsentChange = callback),
+    };
+    storage = new Storage(win, config, null, '', consentManager);
+    publisherBackend.values['subscribe.google.com:a'] = 'one';
+    await expect(storage.get('a', /* useLocalStorage */ true)).to.eventually.be
+      .null;
+
+    hasConsent = true;
+    onConsentChange();
+
+    await expect(storage.get('a', /* useLocalStorage */ true)).to.eventually
+      .equal('one');
+  });
+
+  it('should move the data written without consent once consent is granted', async () => {
+    const sessionBackend = Object.assign({}, publisherBackend, {values: {}});
+    config.storageBackends = {
+      session: [sessionBackend],
+      local: [publisherBackend],
+    };
+    let hasConsent = false;
+    let onConsentChange;
+    const consentManager = {
+      hasConsent: () => hasConsent,
+      onChange: (callback) => (onConsentChange = callback),
+    };
+    storage = new Storage(win, config, null, '', consentManager);
+    publisherBackend.values['subscribe.google.com:c'] = 'old';
+    await storage.set('a', 'one', /* useLocalStorage */ true);
+    await storage.set('b', 'two');
+    await storage.remove('c', /* useLocalStorage */ true);
+    expect(publisherBackend.values).to.deep.equal({
+      'subscribe.google.com:c': 'old',
+    });
+
+    hasConsent = true;
+    onConsentChange();
+    await tick();
+
+    expect(publisherBackend.values).to.deep.equal({
+      'subscribe.google.com:a': 'one',
+    });
+    expect(sessionBackend.values).to.deep.equal({
+      'subscribe.google.com:a': 'one',
+      'subscribe.google.com:b': 'two',
+    });
+  });
+
+  it('should pick up config set after construction', async () => {
+    storage = new Storage(win, config);
+    config.storageBackends = {session: [publisherBackend]};
+
+    await storage.set('a', 'one');
+
+    expect(publisherBackend.values['subscribe.google.com:a']).to.equal('one');
+  });
+
+  it('should fall back to the next backend when one throws', async () => {
+    const failingBackend = {
+      getItem: sandbox.stub().throws(new Error('SecurityError')),
+      setItem: sandbox.stub().throws(new Error('QuotaExceededError')),
+      removeItem: sandbox.stub(),
+    };
+    config.storageBackends = {session: [failingBackend, publisherBackend]};
+
+    await storage.set('a', 'one');
+    await storage.set('b', 'two');
+
+    expect(self.console.log).to.be.calledOnce;
+    expect(failingBackend.removeItem).to.be.calledWith(
+      'subscribe.google.com:a'
+    );
+    storage = new Storage(win, config);
+    await expect(storage.get('a')).to.eventually.equal('one');
+  });
+
+  it('should fall back to the next backend when one rejects', async () => {
+    const failingBackend = {
+      getItem: () => Promise.reject(new Error('intentional')),
+      setItem: () => Promise.reject(new Error('intentional')),
+      removeItem: () => Promise.reject(new Error('intentional')),
+    };
+    config.storageBackends = {session: [failingBackend, publisherBackend]};
+
+    await storage.set('a', 'one');
+
+    expect(publisherBackend.values['subscribe.google.com:a']).to.equal('one');
+  });
+
+  it('should read from later backends on a miss', async () => {
+    const emptyBackend = {
+      getItem: () => null,
+      setItem: () => {},
+      removeItem: () => {},
+    };
+    config.storageBackends = {session: [emptyBackend, publisherBackend]};
+    publisherBackend.values['subscribe.google.com:a'] = 'one';
+
+    await expect(storage.get('a')).to.eventually.equal('one');
+  });
+
+  it('should keep values in memory when every backend fails', async () => {
+    const failingBackend = {
+      getItem: () => {
+        throw new Error('intentional');
+      },
+      setItem: () => {
+        throw new Error('intentional');
+      },
+      removeItem: () => {},
+    };
+    config.storageBackends = {session: [failingBackend]};
+
+    await storage.set('a', 'one');
+    // Forget the cached value to force a read through the backends.
+    storage.values_ = {};
+
+    await expect(storage.get('a')).to.eventually.equal('one');
+  });
+
+  it('should remove values from every backend', async () => {
+    const otherBackend = {
+      getItem: () => null,
+      setItem: () => {},
+      removeItem: sandbox.spy(),
+    };
+    config.storageBackends = {session: [publisherBackend, otherBackend]};
+    publisherBackend.values['subscribe.google.com:a'] = 'one';
+
+    await storage.remove('a');
+
+    expect(publisherBackend.values).to.deep.equal({});
+    expect(otherBackend.removeItem).to.be.calledWith('subscribe.google.com:a');
+  });
+
+  it('should create built-in backends by type', async () => {
+    config.storageBackends = {session: ['memory'], local: ['memory']};
+
+    await storage.set('a', 'one');
+    await storage.set('b', 'two', /* useLocalStorage */ true);
+    storage.values_ = {};
+
+    await expect(storage.get('a')).to.eventually.equal('one');
+    await expect(storage.get('b', /* useLocalStorage */ true)).to.eventually
+      .equal('two');
+    await expect(storage.get('a', /* useLocalStorage */ true)).to.eventually.be
+      .null;
+  });
+
+  it('should close the IndexedDB connections once destroyed', async () => {
+    config.storageBackends = {local: ['indexedDB']};
+    await storage.set('a', 'one', /* useLocalStorage */ true);
+    const backend = storage.backends_['local'][0];
+    const closeStub = sandbox.stub(backend, 'close');
+
+    storage.destroy();
+
+    expect(closeStub).to.be.calledOnce;
+    expect(storage.backends_).to.be.empty;
+  });
+});
+
+describes.realWin('Storage with cross-tab sync', {}, (env) => {
+  let crossTabSync;
+  let storage;
+  let syncListener;
+
+  beforeEach(() => {
+    crossTabSync = {
+      onChange: sandbox.spy(),
+      broadcast: sandbox.spy(),
+    };
+    storage = new Storage(
+      env.win,
+      {storageBackends: {session: ['memory'], local: ['memory']}},
+      crossTabSync
+    );
+    syncListener = sandbox.spy();
+    storage.syncAcrossTabs(['ents', 'USER_TOKEN'], syncListener);
+  });
+
+  /**
+   * Simulates a change made in another tab and waits for it to be applied.
+   */
+  async function changeInOtherTab(change) {
+    crossTabSync.onChange.args[0][0](change);
+    await tick(10);
+  }
+
+  it('should broadcast changes of synced keys', async () => {
+    await storage.set('ents', 'raw');
+    await storage.set('USER_TOKEN', 'token', /* useLocalStorage */ true);
+    await storage.remove('ents');
+
+    expect(crossTabSync.broadcast.args).to.deep.equal([
+      [{key: 'ents', value: 'raw', useLocalStorage: false}],
+      [{key: 'USER_TOKEN', value: 'token', useLocalStorage: true}],
+      [{key: 'ents', value: null, useLocalStorage: false}],
+    ]);
+  });
+
+  it('should not broadcast changes of other keys', async () => {
+    await storage.set('toast', '1');
+    await storage.remove('toast');
+
+    expect(crossTabSync.broadcast).to.not.be.called;
+  });
+
+  it('should apply changes from other tabs', async () => {
+    await storage.set('ents', 'old');
+
+    await changeInOtherTab({key: 'ents', value: 'new', useLocalStorage: false});
+
+    await expect(storage.get('ents')).to.eventually.equal('new');
+    expect(syncListener).to.be.calledOnceWithExactly('ents');
+    // Applying a remote change doesn't echo it back.
+    expect(crossTabSync.broadcast).to.be.calledOnce;
+  });
+
+  it('should apply removals from other tabs', async () => {
+    await storage.set('USER_TOKEN', 'token', /* useLocalStorage */ true);
+
+    await changeInOtherTab({
+      key: 'USER_TOKEN',
+      value: null,
+      useLocalStorage: true,
+    });
+
+    await expect(storage.get('USER_TOKEN', /* useLocalStorage */ true)).to
+      .eventually.be.null;
+    expect(syncListener).to.be.calledOnceWithExactly('USER_TOKEN');
+  });
+
+  it('should ignore changes that are already applied', async () => {
+    await storage.set('ents', 'raw');
+
+    await changeInOtherTab({key: 'ents', value: 'raw', useLocalStorage: false});
+
+    expect(syncListener).to.not.be.called;
+  });
+
+  it('should ignore changes of other keys', async () => {
+    await changeInOtherTab({key: 'toast', value: '1', useLocalStorage: false});
+
+    await expect(storage.get('toast')).to.eventually.be.null;
+    expect(syncListener).to.not.be.called;
+  });
+
+  it('should stop applying changes from other tabs once destroyed', async () => {
+    storage.destroy();
+
+    await changeInOtherTab({key: 'ents', value: 'new', useLocalStorage: false});
+
+    await expect(storage.get('ents')).to.eventually.be.null;
+    expect(syncListener).to.not.be.called;
+  });
+
+  describe('with a namespace', () => {
+    beforeEach(() => {
+      storage = new Storage(
+        env.win,
+        {storageBackends: {session: ['memory'], local: ['memory']}},
+        crossTabSync,
+        'pub2'
+      );
+      storage.syncAcrossTabs(['ents'], syncListener);
+    });
+
+    it('should broadcast changes with the namespace', async () => {
+      await storage.set('ents', 'raw');
+
+      expect(crossTabSync.broadcast).to.be.calledWithExactly({
+        key: 'pub2:ents',
+        value: 'raw',
+        useLocalStorage: false,
+      });
+    });
+
+    it('should only apply changes of its namespace', async () => {
+      // The first listener belongs to the storage without namespace.
+      const onChange = crossTabSync.onChange.args[1][0];
+
+      onChange({key: 'ents', value: 'raw', useLocalStorage: false});
+      await tick(10);
+      await expect(storage.get('ents')).to.eventually.be.null;
+
+      onChange({key: 'pub2:ents', value: 'raw', useLocalStorage: false});
+      await tick(10);
+      await expect(storage.get('ents')).to.eventually.equal('raw');
+      expect(syncListener).to.be.calledOnceWithExactly('ents');
+    });
+  });
+});
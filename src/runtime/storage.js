@@ -14,23 +14,142 @@
  * limitations under the License.
  */
 
+import {ConsentPurpose} from '../api/subscriptions';
+import {
+  IndexedDbStorageBackend,
+  MemoryStorageBackend,
+  WebStorageBackend,
+  createStorageBackend,
+} from './storage-backends';
+import {StorageBackendType} from '../api/storage-backend';
+import {log} from '../utils/log';
+
 const PREFIX = 'subscribe.google.com';
 
+/** @const {!Array<!StorageBackendType>} */
+const DEFAULT_SESSION_BACKENDS = [StorageBackendType.SESSION_STORAGE];
+
+/** @const {!Array<!StorageBackendType>} */
+const DEFAULT_LOCAL_BACKENDS = [StorageBackendType.LOCAL_STORAGE];
+
 /**
- * This class is responsible for the storage of data in session storage. If
- * you're looking to store data in local storage, see
- * src/runtime/local-storage.LocalStorage.
+ * This class is responsible for the storage of data in session storage, or in
+ * local storage when `useLocalStorage` is set. Each of the two is backed by a
+ * chain of backends (see `storageBackends` in the runtime config). When a
+ * backend fails, for instance in Safari private mode or in a sandboxed
+ * iframe, the next backend in the chain is used. The last backend of every
+ * chain is an in-memory one, so state survives at least for the page's
+ * lifetime.
+ *
+ * Keys registered via `syncAcrossTabs` are also kept in sync with other tabs
+ * of the same origin.
+ *
+ * Until the reader consents to storage, all requests use the session
+ * backends, except cookie backends, so that nothing outlives the session.
+ * Once the reader consents, the values written in the meantime are moved to
+ * the backends they're meant for. The data stored in the local backends is
+ * removed if the reader revokes their consent.
  */
 export class Storage {
   /**
    * @param {!Window} win
+   * @param {!../api/subscriptions.Config=} config
+   * @param {?./cross-tab-sync.CrossTabSync=} crossTabSync
+   * @param {string=} namespace Isolates the keys from those of the runtimes
+   *     of other publications of the page.
+   * @param {?./consent-manager.ConsentManager=} consentManager
    */
-  constructor(win) {
+  constructor(
+    win,
+    config = {},
+    crossTabSync = null,
+    namespace = '',
+    consentManager = null
+  ) {
     /** @private @const {!Window} */
     this.win_ = win;
 
+    /** @private @const {!../api/subscriptions.Config} */
+    this.config_ = config;
+
     /** @private @const {!Object<string, !Promise<?string>>} */
     this.values_ = {};
+
+    /**
+     * Backend chains, keyed by name: "session", "local", or "restricted" for
+     * the chain used without consent. Created lazily so that they pick up
+     * the latest config.
+     * @private @const {!Object<string, !Array<!../api/storage-backend.StorageBackend>>}
+     */
+    this.backends_ = {};
+
+    /**
+     * Backends that have already failed, so failures are logged only once.
+     * @private @const {!Array<!../api/storage-backend.StorageBackend>}
+     */
+    this.failedBackends_ = [];
+
+    /** @private @const {?./cross-tab-sync.CrossTabSync} */
+    this.crossTabSync_ = crossTabSync;
+
+    /** @private @const {!Object<string, !Array<function(string)>>} */
+    this.syncListeners_ = {};
+
+    /** @private @const {string} */
+    this.keyPrefix_ = namespace ? namespace + ':' : '';
+
+    /** @private @const {?./consent-manager.ConsentManager} */
+    this.consentManager_ = consentManager;
+
+    /** @private {boolean} */
+    this.consented_ = this.hasConsent_();
+
+    /**
+     * The values written or removed without consent, to move to the session
+     * or local backends once the reader consents.
+     * @private {!Object<string, {value: ?string, useLocalStorage: boolean}>}
+     */
+    this.restrictedWrites_ = {};
+
+    if (this.consentManager_) {
+      this.consentManager_.onChange(this.onConsentChange_.bind(this));
+    }
+
+    if (this.crossTabSync_) {
+      this.crossTabSync_.onChange(this.onChangeInOtherTab_.bind(this));
+    }
+  }
+
+  /**
+   * Broadcasts changes of the given keys to other tabs, and applies changes
+   * made by other tabs locally. The callback is called with the key after a
+   * change from another tab has been applied.
+   * @param {!Array<string>} keys
+   * @param {function(string)} callback
+   */
+  syncAcrossTabs(keys, callback) {
+    for (const key of keys) {
+      this.syncListeners_[key] = this.syncListeners_[key] || [];
+      this.syncListeners_[key].push(callback);
+    }
+  }
+
+  /**
+   * Closes the connections of the backends, and stops applying the changes
+   * made by other tabs.
+   */
+  destroy() {
+    for (const chainName in this.backends_) {
+      for (const backend of this.backends_[chainName]) {
+        if (backend instanceof IndexedDbStorageBackend) {
+          backend.close();
+        }
+      }
+      delete this.backends_[chainName];
+    }
+    for (const key in this.syncListeners_) {
+      delete this.syncListeners_[key];
+    }
   }
 
   /**
@@ -40,25 +159,40 @@ export class Storage {
    */
   get(key, useLocalStorage = false) {
     if (!this.values_[key]) {
-      this.values_[key] = new Promise((resolve) => {
-        const storage = useLocalStorage
-          ? this.win_.localStorage
-          : this.win_.sessionStorage;
-        if (storage) {
-          try {
-            resolve(storage.getItem(storageKey(key)));
-          } catch (e) {
-            // Ignore error.
-            resolve(null);
-          }
-        } else {
-          resolve(null);
+      const backends = this.getBackends_(useLocalStorage);
+      // Reads fall through to the next backend on failures and on misses,
+      // since a previous write may have fallen back to a later backend.
+      const read = (index) => {
+        if (index >= backends.length) {
+          return Promise.resolve(null);
         }
-      });
+        return callBackend(backends[index], (backend) =>
+          backend.getItem(this.storageKey_(key))
+        ).then(
+          (value) => (value != null ? value : read(index + 1)),
+          (reason) => {
+            this.onBackendFailed_(backends[index], reason);
+            return read(index + 1);
+          }
+        );
+      };
+      this.values_[key] = read(0);
     }
     return this.values_[key];
   }
 
+  /**
+   * Reads the value from the backends again, instead of the value read or
+   * written last, e.g. since another tab may have changed it.
+   * @param {string} key
+   * @param {boolean=} useLocalStorage
+   * @return {!Promise<?string>}
+   */
+  reload(key, useLocalStorage = false) {
+    delete this.values_[key];
+    return this.get(key, useLocalStorage);
+  }
+
   /**
    * @param {string} key
    * @param {string} value
@@ -66,20 +200,8 @@ export class Storage {
    * @return {!Promise}
    */
   set(key, value, useLocalStorage = false) {
-    this.values_[key] = Promise.resolve(value);
-    return new Promise((resolve) => {
-      const storage = useLocalStorage
-        ? this.win_.localStorage
-        : this.win_.sessionStorage;
-      if (storage) {
-        try {
-          storage.setItem(storageKey(key), value);
-        } catch (e) {
-          // Ignore error.
-        }
-      }
-      resolve();
-    });
+    this.broadcast_(key, value, useLocalStorage);
+    return this.setLocally_(key, value, useLocalStorage);
   }
 
   /**
@@ -88,27 +210,283 @@ export class Storage {
    * @return {!Promise}
    */
   remove(key, useLocalStorage = false) {
+    this.broadcast_(key, null, useLocalStorage);
+    return this.removeLocally_(key, useLocalStorage);
+  }
+
+  /**
+   * Writes a value without notifying other tabs.
+   * @param {string} key
+   * @param {string} value
+   * @param {boolean} useLocalStorage
+   * @return {!Promise}
+   * @private
+   */
+  setLocally_(key, value, useLocalStorage) {
+    this.values_[key] = Promise.resolve(value);
+    this.trackRestrictedWrite_(key, value, useLocalStorage);
+    const backends = this.getBackends_(useLocalStorage);
+    const write = (index) => {
+      if (index >= backends.length) {
+        return Promise.resolve();
+      }
+      return callBackend(backends[index], (backend) =>
+        backend.setItem(this.storageKey_(key), value)
+      ).then(
+        () => {},
+        (reason) => {
+          this.onBackendFailed_(backends[index], reason);
+          // Don't leave a stale value behind in the failed backend, since
+          // reads would find it first.
+          callBackend(backends[index], (backend) =>
+            backend.removeItem(this.storageKey_(key))
+          ).catch(() => {});
+          return write(index + 1);
+        }
+      );
+    };
+    return write(0);
+  }
+
+  /**
+   * Removes a value without notifying other tabs.
+   * @param {string} key
+   * @param {boolean} useLocalStorage
+   * @return {!Promise}
+   * @private
+   */
+  removeLocally_(key, useLocalStorage) {
     delete this.values_[key];
-    return new Promise((resolve) => {
-      const storage = useLocalStorage
-        ? this.win_.localStorage
-        : this.win_.sessionStorage;
-      if (storage) {
-        try {
-          storage.removeItem(storageKey(key));
-        } catch (e) {
-          // Ignore error.
+    this.trackRestrictedWrite_(key, null, useLocalStorage);
+    const backends = this.getBackends_(useLocalStorage);
+    return Promise.all(
+      backends.map((backend) =>
+        callBackend(backend, (backend) =>
+          backend.removeItem(this.storageKey_(key))
+        ).catch((reason) => {
+          this.onBackendFailed_(backend, reason);
+        })
+      )
+    ).then(() => {});
+  }
+
+  /**
+   * @param {string} key
+   * @param {?string} value
+   * @param {boolean} useLocalStorage
+   * @private
+   */
+  broadcast_(key, value, useLocalStorage) {
+    if (this.crossTabSync_ && this.syncListeners_[key]) {
+      this.crossTabSync_.broadcast({
+        key: this.keyPrefix_ + key,
+        value,
+        useLocalStorage,
+      });
+    }
+  }
+
+  /**
+   * @param {!./cross-tab-sync.StorageChange} change
+   * @private
+   */
+  onChangeInOtherTab_(change) {
+    const {value, useLocalStorage} = change;
+    if (change.key.indexOf(this.keyPrefix_) != 0) {
+      // Change of another namespace.
+      return;
+    }
+    const key = change.key.substring(this.keyPrefix_.length);
+    const listeners = this.syncListeners_[key];
+    if (!listeners) {
+      return;
+    }
+    this.get(key, useLocalStorage)
+      .then((currentValue) => {
+        if (currentValue === value) {
+          // Already up to date, e.g. the change was relayed twice.
+          return false;
+        }
+        return (
+          value == null
+            ? this.removeLocally_(key, useLocalStorage)
+            : this.setLocally_(key, value, useLocalStorage)
+        ).then(() => true);
+      })
+      .then((changed) => {
+        if (changed) {
+          for (const listener of listeners) {
+            listener(key);
+          }
         }
+      });
+  }
+
+  /**
+   * @param {string} key
+   * @param {?string} value
+   * @param {boolean} useLocalStorage
+   * @private
+   */
+  trackRestrictedWrite_(key, value, useLocalStorage) {
+    if (!this.hasConsent_()) {
+      this.restrictedWrites_[key] = {value, useLocalStorage};
+    }
+  }
+
+  /**
+   * Switches the backends once the consent to storage is granted or revoked.
+   * @private
+   */
+  onConsentChange_() {
+    const consented = this.consented_;
+    this.consented_ = this.hasConsent_();
+    if (consented == this.consented_) {
+      return;
+    }
+    if (this.consented_) {
+      this.onConsentGranted_();
+    } else {
+      this.onConsentRevoked_();
+    }
+  }
+
+  /**
+   * Switches back to the session and local backends once the reader consents
+   * to storage. The values written without consent are moved to them, and
+   * the other values are read from them again.
+   * @private
+   */
+  onConsentGranted_() {
+    const writes = this.restrictedWrites_;
+    this.restrictedWrites_ = {};
+    for (const key in this.values_) {
+      delete this.values_[key];
+    }
+    for (const key in writes) {
+      const {value, useLocalStorage} = writes[key];
+      if (value == null) {
+        this.removeLocally_(key, useLocalStorage);
+      } else {
+        this.setLocally_(key, value, useLocalStorage);
       }
-      resolve();
-    });
+    }
+  }
+
+  /**
+   * Removes the data stored in the local backends once the consent to
+   * storage is revoked. The values read so far are kept in memory.
+   * @private
+   */
+  onConsentRevoked_() {
+    const config = this.config_.storageBackends || {};
+    const backends = this.getChain_(
+      'local',
+      config['local'] || DEFAULT_LOCAL_BACKENDS,
+      /* persistent */ true
+    );
+    // The web storage backends also have the values stored in the previous
+    // sessions, whose keys may not have been read yet.
+    const keys = Object.keys(this.values_);
+    for (const backend of backends) {
+      callBackend(backend, (backend) => {
+        if (backend instanceof WebStorageBackend) {
+          backend.removeItemsWithPrefix(this.storageKey_(''));
+        }
+        return Promise.all(
+          keys.map((key) => backend.removeItem(this.storageKey_(key)))
+        );
+      }).catch((reason) => {
+        this.onBackendFailed_(backend, reason);
+      });
+    }
+  }
+
+  /**
+   * @return {boolean}
+   * @private
+   */
+  hasConsent_() {
+    return (
+      !this.consentManager_ ||
+      this.consentManager_.hasConsent(ConsentPurpose.STORAGE)
+    );
+  }
+
+  /**
+   * @param {boolean} persistent
+   * @return {!Array<!../api/storage-backend.StorageBackend>}
+   * @private
+   */
+  getBackends_(persistent) {
+    const config = this.config_.storageBackends || {};
+    if (!this.hasConsent_()) {
+      const types = (config['session'] || DEFAULT_SESSION_BACKENDS).filter(
+        (typeOrBackend) => typeOrBackend != StorageBackendType.COOKIE
+      );
+      return this.getChain_('restricted', types, /* persistent */ false);
+    }
+    const chainName = persistent ? 'local' : 'session';
+    const types =
+      config[chainName] ||
+      (persistent ? DEFAULT_LOCAL_BACKENDS : DEFAULT_SESSION_BACKENDS);
+    return this.getChain_(chainName, types, persistent);
+  }
+
+  /**
+   * @param {string} chainName
+   * @param {!Array<!StorageBackendType|!../api/storage-backend.StorageBackend>} types
+   * @param {boolean} persistent
+   * @return {!Array<!../api/storage-backend.StorageBackend>}
+   * @private
+   */
+  getChain_(chainName, types, persistent) {
+    if (!this.backends_[chainName]) {
+      const backends = types.map((typeOrBackend) =>
+        typeof typeOrBackend == 'string'
+          ? createStorageBackend(this.win_, typeOrBackend, persistent)
+          : typeOrBackend
+      );
+      if (types.indexOf(StorageBackendType.MEMORY) == -1) {
+        backends.push(new MemoryStorageBackend());
+      }
+      this.backends_[chainName] = backends;
+    }
+    return this.backends_[chainName];
+  }
+
+  /**
+   * @param {string} key
+   * @return {string}
+   * @private
+   */
+  storageKey_(key) {
+    return PREFIX + ':' + this.keyPrefix_ + key;
+  }
+
+  /**
+   * @param {!../api/storage-backend.StorageBackend} backend
+   * @param {*} reason
+   * @private
+   */
+  onBackendFailed_(backend, reason) {
+    if (this.failedBackends_.indexOf(backend) != -1) {
+      return;
+    }
+    this.failedBackends_.push(backend);
+    log('[swg.js:Storage]: Storage backend failed, falling back.', reason);
   }
 }
 
 /**
- * @param {string} key
- * @return {string}
+ * Calls a backend method, converting synchronous results and exceptions to a
+ * promise.
+ * @param {!../api/storage-backend.StorageBackend} backend
+ * @param {function(!../api/storage-backend.StorageBackend):*} callback
+ * @return {!Promise<*>}
  */
-function storageKey(key) {
-  return PREFIX + ':' + key;
+function callBackend(backend, callback) {
+  return new Promise((resolve) => {
+    resolve(callback(backend));
+  });
 }
@@ -99,4 +99,19 @@ describes.realWin('WaitForSubscriptionLookupApi', {}, (env) => {
       'No account promise provided'
     );
   });
+
+  it('should close the view when aborted', () => {
+    const disconnectStub = sandbox.stub(
+      waitingApi.activityIframeView_,
+      'disconnect'
+    );
+    dialogManagerMock
+      .expects('completeView')
+      .withExactArgs(waitingApi.activityIframeView_)
+      .once();
+
+    waitingApi.abort();
+
+    expect(disconnectStub).to.be.calledOnce;
+  });
 });
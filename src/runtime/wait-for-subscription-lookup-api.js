@@ -79,4 +79,12 @@ export class WaitForSubscriptionLookupApi {
       }
     );
   }
+
+  /**
+   * Closes the loading indicator, before the account is found.
+   */
+  abort() {
+    this.dialogManager_.completeView(this.activityIframeView_);
+    this.activityIframeView_.disconnect();
+  }
 }
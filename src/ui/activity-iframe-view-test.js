@@ -19,6 +19,7 @@ import {ActivityIframeView} from './activity-iframe-view';
 import {ActivityResult} from 'web-activities/activity-ports';
 import {Dialog} from '../components/dialog';
 import {GlobalDoc} from '../model/doc';
+import {PerformanceTimings} from '../utils/performance';
 import {SkuSelectedResponse} from '../proto/api_messages';
 import {setExperimentsStringForTesting} from '../runtime/experiments';
 
@@ -30,6 +31,7 @@ describes.realWin('ActivityIframeView', {}, (env) => {
   let activityIframeView;
   let dialog;
   let deps;
+  let performanceTimings;
 
   const activityArgs = {
     'publicationId': 'pub1',
@@ -41,8 +43,10 @@ describes.realWin('ActivityIframeView', {}, (env) => {
     win = env.win;
     src = '$frontend$/offersiframe';
     dialog = new Dialog(new GlobalDoc(win), {height: '100px'});
+    performanceTimings = new PerformanceTimings(win);
     deps = {
       win: () => win,
+      performanceTimings: () => performanceTimings,
     };
     activityPorts = new ActivityPorts(deps);
     activityIframePort = new ActivityIframePort(
@@ -199,6 +203,16 @@ describes.realWin('ActivityIframeView', {}, (env) => {
       await cancelPromise;
     });
 
+    it('should disconnect the port', async () => {
+      const disconnectStub = sandbox.stub(activityIframePort, 'disconnect');
+      activityIframeView.disconnect();
+      expect(disconnectStub).to.not.be.called;
+
+      await activityIframeView.init(dialog);
+      activityIframeView.disconnect();
+      expect(disconnectStub).to.be.calledOnce;
+    });
+
     it('should cache loading indicator', async () => {
       expect(activityIframeView.hasLoadingIndicator()).to.be.false;
       const activityIframeView2 = new ActivityIframeView(
@@ -211,5 +225,11 @@ describes.realWin('ActivityIframeView', {}, (env) => {
       );
       expect(activityIframeView2.hasLoadingIndicator()).to.be.true;
     });
+
+    it('should return the timings of its runtime', () => {
+      expect(activityIframeView.getPerformanceTimings()).to.equal(
+        performanceTimings
+      );
+    });
   });
 });
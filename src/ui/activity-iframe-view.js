@@ -119,6 +119,11 @@ export class ActivityIframeView extends View {
     return this.hasLoadingIndicator_;
   }
 
+  /** @override */
+  getPerformanceTimings() {
+    return this.activityPorts_.getPerformanceTimings();
+  }
+
   /**
    * @param {!../components/activities.ActivityIframePort} port
    * @param {!../components/dialog.Dialog} dialog
@@ -213,6 +218,15 @@ export class ActivityIframeView extends View {
     });
   }
 
+  /**
+   * Disconnects the iframe's port, if connected.
+   */
+  disconnect() {
+    if (this.port_) {
+      this.port_.disconnect();
+    }
+  }
+
   /** @override */
   resized() {
     if (this.port_) {
@@ -19,6 +19,7 @@ import {
   ActivityResult,
   ActivityResultCode,
 } from 'web-activities/activity-ports';
+import {SwgError, SwgErrorCode} from './errors';
 import {acceptPortResultData} from './activity-utils';
 
 const OK = ActivityResultCode.OK;
@@ -79,9 +80,16 @@ describes.sandboxed('acceptPortResultData', {}, () => {
   it('should fail success on wrong origin', async () => {
     result(OK, 'A', OTHER_ORIGIN, VERIFIED, SECURE);
 
-    await expect(
-      acceptPortResultData(port, ORIGIN, REQUIRE_VERIFIED, REQUIRE_SECURE)
-    ).to.be.rejectedWith(/channel mismatch/);
+    const error = await acceptPortResultData(
+      port,
+      ORIGIN,
+      REQUIRE_VERIFIED,
+      REQUIRE_SECURE
+    ).catch((reason) => reason);
+    expect(error).to.be.an.instanceOf(SwgError);
+    expect(error.message).to.equal('channel mismatch');
+    expect(error.code).to.equal(SwgErrorCode.ORIGIN_MISMATCH);
+    expect(error.retryable).to.be.false;
   });
 
   it('should fail success on not verified', async () => {
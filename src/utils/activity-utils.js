@@ -14,6 +14,8 @@
  * limitations under the License.
  */
 
+import {SwgError, SwgErrorCode} from './errors';
+
 /**
  * @param {!../components/activities.ActivityPortDef} port
  * @param {string} requireOrigin
@@ -33,7 +35,7 @@ export function acceptPortResultData(
       (requireOriginVerified && !result.originVerified) ||
       (requireSecureChannel && !result.secureChannel)
     ) {
-      throw new Error('channel mismatch');
+      throw new SwgError(SwgErrorCode.ORIGIN_MISMATCH, 'channel mismatch');
     }
     return result.data;
   });
@@ -14,7 +14,15 @@
  * limitations under the License.
  */
 
-import {ErrorUtils, createCancelError, isCancelError} from './errors';
+import {
+  ErrorUtils,
+  SwgError,
+  SwgErrorCode,
+  createCancelError,
+  isCancelError,
+  isSwgError,
+  toRequestError,
+} from './errors';
 
 describe('errors', () => {
   describe('isCancelError', () => {
@@ -48,6 +56,78 @@ describe('errors', () => {
     });
   });
 
+  describe('SwgError', () => {
+    it('creates error', () => {
+      const cause = new Error('cause');
+      const error = new SwgError(
+        SwgErrorCode.NETWORK,
+        'custom message',
+        true,
+        cause
+      );
+      expect(error).to.be.an.instanceOf(Error);
+      expect(error.name).to.equal('SwgError');
+      expect(error.message).to.equal('custom message');
+      expect(error.code).to.equal('network');
+      expect(error.retryable).to.be.true;
+      expect(error.cause).to.equal(cause);
+    });
+
+    it('is not retryable by default', () => {
+      const error = new SwgError(SwgErrorCode.POPUP_BLOCKED, 'blocked');
+      expect(error.retryable).to.be.false;
+      expect(error.cause).to.be.undefined;
+    });
+  });
+
+  describe('isSwgError', () => {
+    it('should check the type and code', () => {
+      const error = new SwgError(SwgErrorCode.INVALID_CONFIG, 'invalid');
+      expect(isSwgError(error)).to.be.true;
+      expect(isSwgError(error, SwgErrorCode.INVALID_CONFIG)).to.be.true;
+      expect(isSwgError(error, SwgErrorCode.NETWORK)).to.be.false;
+      expect(isSwgError(new Error('invalid'))).to.be.false;
+      expect(isSwgError(null)).to.be.false;
+    });
+  });
+
+  describe('toRequestError', () => {
+    const CODE = SwgErrorCode.ENTITLEMENTS_SERVER_ERROR;
+
+    it('converts network failures', () => {
+      const reason = new Error('Network failure');
+      reason.retriable = true;
+      const error = toRequestError(reason, CODE);
+      expect(error.code).to.equal(SwgErrorCode.NETWORK);
+      expect(error.message).to.equal('Network failure');
+      expect(error.retryable).to.be.true;
+      expect(error.cause).to.equal(reason);
+    });
+
+    it('converts HTTP errors', () => {
+      const reason = new Error('HTTP error 403');
+      reason.retriable = false;
+      reason.response = {status: 403};
+      const error = toRequestError(reason, CODE);
+      expect(error.code).to.equal(CODE);
+      expect(error.retryable).to.be.false;
+    });
+
+    it('converts other values', () => {
+      const error = toRequestError('offline', CODE);
+      expect(error.code).to.equal(SwgErrorCode.NETWORK);
+      expect(error.message).to.equal('offline');
+      expect(error.retryable).to.be.false;
+    });
+
+    it('returns abort errors and SwgErrors as is', () => {
+      const abortError = new DOMException('cancel', 'AbortError');
+      const swgError = new SwgError(SwgErrorCode.NETWORK, 'network');
+      expect(toRequestError(abortError, CODE)).to.equal(abortError);
+      expect(toRequestError(swgError, CODE)).to.equal(swgError);
+    });
+  });
+
   describe('ErrorUtils', () => {
     describe('throwAsync', () => {
       it('throws error after a timeout', () => {
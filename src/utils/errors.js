@@ -40,6 +40,83 @@ export function createCancelError(win, message) {
   return createAbortError(win, message);
 }
 
+/**
+ * The codes of the errors reported to publishers. Unlike the messages, the
+ * codes are stable and can be relied on.
+ * @enum {string}
+ */
+export const SwgErrorCode = {
+  // A request failed, e.g. because the user is offline.
+  NETWORK: 'network',
+  // The payment was declined, or failed otherwise.
+  PAYMENT_DECLINED: 'payment-declined',
+  // The browser blocked the popup of a flow.
+  POPUP_BLOCKED: 'popup-blocked',
+  // A result came from an unexpected origin, or through an insecure channel.
+  ORIGIN_MISMATCH: 'origin-mismatch',
+  // The publication's client config couldn't be fetched, or the server
+  // reported an error in it.
+  INVALID_CONFIG: 'invalid-config',
+  // The entitlements server failed, or reported an error.
+  ENTITLEMENTS_SERVER_ERROR: 'entitlements-server-error',
+  // The account creation that follows a purchase failed.
+  ACCOUNT_CREATION_FAILED: 'account-creation-failed',
+};
+
+/**
+ * An error reported to publishers, with a stable code.
+ */
+export class SwgError extends Error {
+  /**
+   * @param {!SwgErrorCode} code
+   * @param {string} message
+   * @param {boolean=} retryable Whether trying again may succeed.
+   * @param {*=} cause The error this error was created from.
+   */
+  constructor(code, message, retryable = false, cause = undefined) {
+    super(message);
+    this.name = 'SwgError';
+    // Transpiled subclasses of Error don't get the message from super().
+    this.message = message;
+
+    /** @const {!SwgErrorCode} */
+    this.code = code;
+
+    /** @const {boolean} */
+    this.retryable = retryable;
+
+    /** @const {*} */
+    this.cause = cause;
+  }
+}
+
+/**
+ * Whether the specified error is a SwgError, optionally with the given code.
+ * @param {*} error
+ * @param {!SwgErrorCode=} code
+ * @return {boolean}
+ */
+export function isSwgError(error, code) {
+  return error instanceof SwgError && (!code || error.code == code);
+}
+
+/**
+ * Converts the failure of a request to a SwgError. HTTP errors get the given
+ * code, and other failures are network errors. Both are retryable if the
+ * request is. AbortErrors and SwgErrors are returned as is.
+ * @param {*} reason
+ * @param {!SwgErrorCode} httpErrorCode
+ * @return {*}
+ */
+export function toRequestError(reason, httpErrorCode) {
+  if (isSwgError(reason) || isCancelError(reason)) {
+    return reason;
+  }
+  const message = String((reason && reason.message) || reason);
+  const code = reason && reason.response ? httpErrorCode : SwgErrorCode.NETWORK;
+  return new SwgError(code, message, !!(reason && reason.retriable), reason);
+}
+
 /**
  * A set of error utilities combined in a class to allow easy stubbing in tests.
  */
@@ -15,6 +15,7 @@
  */
 
 import {JwtHelper} from './jwt';
+import {base64UrlEncodeFromBytes, utf8EncodeSync} from './bytes';
 
 describe('JwtHelper', () => {
   // Generated from https://jwt.io/#debugger
@@ -80,4 +81,95 @@ describe('JwtHelper', () => {
       expect(token.sig).to.not.equal(tokenWebSafe);
     });
   });
+
+  describe('verify', () => {
+    const RSA_PARAMS = {
+      name: 'RSASSA-PKCS1-v1_5',
+      modulusLength: 2048,
+      publicExponent: new Uint8Array([1, 0, 1]),
+      hash: {name: 'SHA-256'},
+    };
+    const EC_PARAMS = {name: 'ECDSA', namedCurve: 'P-256'};
+    const EC_SIGN_PARAMS = {name: 'ECDSA', hash: {name: 'SHA-256'}};
+
+    let subtle;
+
+    beforeEach(() => {
+      subtle = self.crypto.subtle;
+    });
+
+    function encode(obj) {
+      return base64UrlEncodeFromBytes(utf8EncodeSync(JSON.stringify(obj)));
+    }
+
+    async function createKeys(params) {
+      const keyPair = await subtle.generateKey(params, true, [
+        'sign',
+        'verify',
+      ]);
+      const jwk = await subtle.exportKey('jwk', keyPair.publicKey);
+      return {privateKey: keyPair.privateKey, jwk};
+    }
+
+    async function sign(alg, signParams, privateKey, payload = {'sub': '1'}) {
+      const verifiable = `${encode({'alg': alg})}.${encode(payload)}`;
+      const sig = await subtle.sign(
+        signParams,
+        privateKey,
+        utf8EncodeSync(verifiable)
+      );
+      return `${verifiable}.${base64UrlEncodeFromBytes(new Uint8Array(sig))}`;
+    }
+
+    it('should verify RS256 signatures', async () => {
+      const {privateKey, jwk} = await createKeys(RSA_PARAMS);
+      const token = await sign('RS256', RSA_PARAMS, privateKey);
+
+      await expect(helper.verify(token, jwk, subtle)).to.eventually.be.true;
+    });
+
+    it('should verify ES256 signatures', async () => {
+      const {privateKey, jwk} = await createKeys(EC_PARAMS);
+      const token = await sign('ES256', EC_SIGN_PARAMS, privateKey);
+
+      await expect(helper.verify(token, jwk, subtle)).to.eventually.be.true;
+    });
+
+    it('should reject tampered payloads', async () => {
+      const {privateKey, jwk} = await createKeys(EC_PARAMS);
+      const token = await sign('ES256', EC_SIGN_PARAMS, privateKey);
+      const parts = token.split('.');
+      const forged = `${parts[0]}.${encode({'sub': '2'})}.${parts[2]}`;
+
+      await expect(helper.verify(forged, jwk, subtle)).to.eventually.be.false;
+    });
+
+    it('should reject signatures from other keys', async () => {
+      const {privateKey} = await createKeys(EC_PARAMS);
+      const {jwk} = await createKeys(EC_PARAMS);
+      const token = await sign('ES256', EC_SIGN_PARAMS, privateKey);
+
+      await expect(helper.verify(token, jwk, subtle)).to.eventually.be.false;
+    });
+
+    it('should reject unsupported algorithms', async () => {
+      const {jwk} = await createKeys(EC_PARAMS);
+
+      await expect(helper.verify(TOKEN, jwk, subtle)).to.eventually.be.false;
+    });
+
+    it('should reject keys for another algorithm', async () => {
+      const {privateKey, jwk} = await createKeys(RSA_PARAMS);
+      const token = await sign('RS256', RSA_PARAMS, privateKey);
+      jwk['alg'] = 'RS512';
+
+      await expect(helper.verify(token, jwk, subtle)).to.eventually.be.false;
+    });
+
+    it('should reject malformed tokens', async () => {
+      const {jwk} = await createKeys(EC_PARAMS);
+
+      await expect(helper.verify('ABC', jwk, subtle)).to.eventually.be.false;
+    });
+  });
 });
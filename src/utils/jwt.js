@@ -14,9 +14,28 @@
  * limitations under the License.
  */
 
-import {base64UrlDecodeToBytes, utf8DecodeSync} from './bytes';
+import {
+  base64UrlDecodeToBytes,
+  utf8DecodeSync,
+  utf8EncodeSync,
+} from './bytes';
 import {tryParseJson} from './json';
 
+/**
+ * WebCrypto parameters for the supported JWS algorithms, keyed by `alg`.
+ * @const {!Object<string, {importParams: !Object, verifyParams: !Object}>}
+ */
+const SIGNATURE_ALGORITHMS = {
+  'RS256': {
+    importParams: {name: 'RSASSA-PKCS1-v1_5', hash: {name: 'SHA-256'}},
+    verifyParams: {name: 'RSASSA-PKCS1-v1_5'},
+  },
+  'ES256': {
+    importParams: {name: 'ECDSA', namedCurve: 'P-256'},
+    verifyParams: {name: 'ECDSA', hash: {name: 'SHA-256'}},
+  },
+};
+
 /**
  * @typedef {{
  *   header: (?JsonObject|undefined),
@@ -42,6 +61,51 @@ export class JwtHelper {
     return this.decodeInternal_(encodedToken).payload;
   }
 
+  /**
+   * Decodes JWT token and returns its header.
+   * @param {string} encodedToken
+   * @return {?JsonObject|undefined}
+   */
+  decodeHeader(encodedToken) {
+    return this.decodeInternal_(encodedToken).header;
+  }
+
+  /**
+   * Verifies the token's signature against a JSON Web Key. Only RS256 and
+   * ES256 are supported. JWS encodes ES256 signatures as raw `r || s`, which
+   * is also the format WebCrypto expects, so no conversion is needed.
+   * Resolves to false for malformed tokens, unsupported algorithms and keys
+   * that don't match the token's algorithm.
+   * @param {string} encodedToken
+   * @param {!JsonObject} jwk
+   * @param {!webCrypto.SubtleCrypto} subtle
+   * @return {!Promise<boolean>}
+   */
+  verify(encodedToken, jwk, subtle) {
+    return new Promise((resolve) => {
+      const token = this.decodeInternal_(encodedToken);
+      const alg = token.header && token.header['alg'];
+      const algorithm = SIGNATURE_ALGORITHMS[alg];
+      // Don't let the token pick a weaker algorithm than the key is for.
+      if (!algorithm || (jwk['alg'] && jwk['alg'] !== alg)) {
+        resolve(false);
+        return;
+      }
+      resolve(
+        subtle
+          .importKey('jwk', jwk, algorithm.importParams, false, ['verify'])
+          .then((key) =>
+            subtle.verify(
+              algorithm.verifyParams,
+              key,
+              base64UrlDecodeToBytes(token.sig),
+              utf8EncodeSync(token.verifiable)
+            )
+          )
+      );
+    }).catch(() => false);
+  }
+
   /**
    * @param {string} encodedToken
    * @return {!JwtTokenInternalDef}
@@ -0,0 +1,139 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {
+  PerformanceTiming,
+  PerformanceTimings,
+  startTiming,
+} from './performance';
+
+describes.realWin('performance', {}, (env) => {
+  let win;
+  let nowStub;
+  let timings;
+
+  beforeEach(() => {
+    win = env.win;
+    nowStub = sandbox.stub(win.performance, 'now').returns(100);
+    win.performance.clearMeasures();
+    timings = new PerformanceTimings(win);
+  });
+
+  it('should measure the timings', () => {
+    const endTiming = timings.start(PerformanceTiming.ENTITLEMENTS);
+    nowStub.returns(350);
+
+    endTiming();
+
+    expect(timings.getDurations()).to.deep.equal({
+      [PerformanceTiming.ENTITLEMENTS]: 250,
+    });
+    const measures = win.performance.getEntriesByName('swg:entitlements');
+    expect(measures).to.have.length(1);
+    expect(measures[0].entryType).to.equal('measure');
+    expect(win.performance.getEntriesByType('mark')).to.be.empty;
+  });
+
+  it('should only end the timings once', () => {
+    const endTiming = timings.start(PerformanceTiming.IFRAME_READY);
+    nowStub.returns(200);
+    endTiming();
+    nowStub.returns(500);
+
+    endTiming();
+
+    expect(timings.getDurations()).to.deep.equal({
+      [PerformanceTiming.IFRAME_READY]: 100,
+    });
+    const measures = win.performance.getEntriesByName('swg:iframeReady');
+    expect(measures).to.have.length(1);
+  });
+
+  it('should time resolved operations', async () => {
+    const promise = timings.timeAsync(PerformanceTiming.CLIENT_CONFIG, () => {
+      nowStub.returns(150);
+      return Promise.resolve('config');
+    });
+
+    expect(await promise).to.equal('config');
+    expect(timings.getDurations()).to.deep.equal({
+      [PerformanceTiming.CLIENT_CONFIG]: 50,
+    });
+  });
+
+  it('should time rejected operations', async () => {
+    const promise = timings.timeAsync(PerformanceTiming.ENTITLEMENTS, () =>
+      Promise.reject(new Error('broken'))
+    );
+
+    await expect(promise).to.be.rejectedWith('broken');
+    expect(timings.getDurations()).to.have.property(
+      PerformanceTiming.ENTITLEMENTS
+    );
+  });
+
+  it('should keep the timings of each runtime apart', () => {
+    const otherTimings = new PerformanceTimings(win);
+    const endTiming = timings.start(PerformanceTiming.ENTITLEMENTS);
+    const endOtherTiming = otherTimings.start(PerformanceTiming.ENTITLEMENTS);
+    nowStub.returns(300);
+    endTiming();
+    nowStub.returns(400);
+
+    endOtherTiming();
+
+    expect(timings.getDurations()).to.deep.equal({
+      [PerformanceTiming.ENTITLEMENTS]: 200,
+    });
+    expect(otherTimings.getDurations()).to.deep.equal({
+      [PerformanceTiming.ENTITLEMENTS]: 300,
+    });
+  });
+
+  it('should record the timings in the runtime given at the end', () => {
+    const endTiming = startTiming(win, PerformanceTiming.DIALOG_FIRST_RESIZE);
+    nowStub.returns(180);
+
+    endTiming(timings);
+
+    expect(timings.getDurations()).to.deep.equal({
+      [PerformanceTiming.DIALOG_FIRST_RESIZE]: 80,
+    });
+  });
+
+  it('should only measure the timings without a runtime', () => {
+    const endTiming = startTiming(win, PerformanceTiming.DIALOG_FIRST_RESIZE);
+
+    endTiming(null);
+
+    expect(timings.getDurations()).to.deep.equal({});
+    const measures = win.performance.getEntriesByName('swg:dialogFirstResize');
+    expect(measures).to.have.length(1);
+  });
+
+  it('should time without the User Timing API', () => {
+    sandbox.stub(Date, 'now').returns(1000);
+    timings = new PerformanceTimings({});
+    const endTiming = timings.start(PerformanceTiming.ENTITLEMENTS);
+    Date.now.returns(1200);
+
+    endTiming();
+
+    expect(timings.getDurations()).to.deep.equal({
+      [PerformanceTiming.ENTITLEMENTS]: 200,
+    });
+  });
+});
@@ -0,0 +1,163 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/**
+ * The operations of SwG that are timed. Each timing is added to the
+ * performance timeline as a "swg:<timing>" measure, so that
+ * `PerformanceObserver`s see it.
+ * @enum {string}
+ */
+export const PerformanceTiming = {
+  // Fetching the entitlements.
+  ENTITLEMENTS: 'entitlements',
+  // Fetching the client config.
+  CLIENT_CONFIG: 'clientConfig',
+  // Opening an iframe, until it's ready.
+  IFRAME_READY: 'iframeReady',
+  // Opening a dialog, until it's first resized to its view.
+  DIALOG_FIRST_RESIZE: 'dialogFirstResize',
+  // Starting the payment flow, until the payment sheet is requested. Flows
+  // aborted before that aren't timed.
+  PAY_START: 'payStart',
+};
+
+/** @const {string} */
+const MEASURE_PREFIX = 'swg:';
+
+/**
+ * Makes the names of the start marks unique, since the same operation can
+ * be timed several times at once.
+ * @type {number}
+ */
+let lastMarkId = 0;
+
+/**
+ * The timings of a runtime. Several runtimes can run in the same window, e.g.
+ * the ones of `createRuntime`, so each one keeps its own durations. The
+ * durations are logged with the client events as labels of the analytics
+ * context.
+ */
+export class PerformanceTimings {
+  /**
+   * @param {!Window} win
+   */
+  constructor(win) {
+    /** @private @const {!Window} */
+    this.win_ = win;
+
+    /**
+     * The latest duration of each timing, in milliseconds. Kept apart from
+     * the performance timeline, which pages can clear.
+     * @private @const {!Object<string, number>}
+     */
+    this.durations_ = {};
+  }
+
+  /**
+   * Starts timing an operation.
+   * @param {!PerformanceTiming} timing
+   * @return {function()} Ends the timing. Only the first call counts.
+   */
+  start(timing) {
+    const endTiming = startTiming(this.win_, timing);
+    return () => endTiming(this);
+  }
+
+  /**
+   * Times an asynchronous operation, until its promise settles.
+   * @param {!PerformanceTiming} timing
+   * @param {function():!Promise<T>} operation
+   * @return {!Promise<T>} The promise of the operation.
+   * @template T
+   */
+  timeAsync(timing, operation) {
+    const endTiming = this.start(timing);
+    const promise = operation();
+    promise.then(endTiming, endTiming);
+    return promise;
+  }
+
+  /**
+   * @param {!PerformanceTiming} timing
+   * @param {number} duration In milliseconds.
+   */
+  record(timing, duration) {
+    this.durations_[timing] = duration;
+  }
+
+  /**
+   * @return {!Object<string, number>} The latest duration of each timing that
+   *     ended, in milliseconds, keyed by `PerformanceTiming`.
+   */
+  getDurations() {
+    return Object.assign({}, this.durations_);
+  }
+}
+
+/**
+ * Starts timing an operation before knowing which runtime it belongs to, e.g.
+ * opening the dialog that the runtimes of a page share.
+ * @param {!Window} win
+ * @param {!PerformanceTiming} timing
+ * @return {function(?PerformanceTimings)} Ends the timing, and records its
+ *     duration in the given timings, if any. Only the first call counts.
+ */
+export function startTiming(win, timing) {
+  const startTime = now(win);
+  const startMark = `${MEASURE_PREFIX}${timing}:start:${++lastMarkId}`;
+  withPerformance(win, (performance) => performance.mark(startMark));
+  let ended = false;
+  return (timings) => {
+    if (ended) {
+      return;
+    }
+    ended = true;
+    if (timings) {
+      timings.record(timing, now(win) - startTime);
+    }
+    withPerformance(win, (performance) => {
+      performance.measure(MEASURE_PREFIX + timing, startMark);
+      performance.clearMarks(startMark);
+    });
+  };
+}
+
+/**
+ * @param {!Window} win
+ * @return {number}
+ */
+function now(win) {
+  const performance = win.performance;
+  return performance && performance.now ? performance.now() : Date.now();
+}
+
+/**
+ * Calls the User Timing API, if supported. Errors are ignored, e.g. when the
+ * page cleared the start mark.
+ * @param {!Window} win
+ * @param {function(!Performance)} callback
+ */
+function withPerformance(win, callback) {
+  const performance = win.performance;
+  if (!performance || !performance.mark || !performance.measure) {
+    return;
+  }
+  try {
+    callback(performance);
+  } catch (e) {
+    // Timings are best effort.
+  }
+}
@@ -56,4 +56,19 @@ describes.realWin('preconnect', {}, (env) => {
     );
     expect(elements.length).to.equal(1);
   });
+
+  it('should remove its links on destroy', () => {
+    const otherLink = doc.createElement('link');
+    doc.head.appendChild(otherLink);
+    preconnect.preconnect('one');
+    preconnect.prefetch('two');
+
+    preconnect.destroy();
+
+    const elements = doc.head.querySelectorAll(
+      'link[href=one], link[href=two]'
+    );
+    expect(elements.length).to.equal(0);
+    expect(otherLink.parentNode).to.equal(doc.head);
+  });
 });
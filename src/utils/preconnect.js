@@ -14,7 +14,7 @@
  * limitations under the License.
  */
 
-import {createElement} from './dom';
+import {createElement, removeElement} from './dom';
 
 export class Preconnect {
   /**
@@ -23,6 +23,9 @@ export class Preconnect {
   constructor(doc) {
     /** @private @const {!Document} */
     this.doc_ = doc;
+
+    /** @private @const {!Array<!Element>} */
+    this.links_ = [];
   }
 
   /**
@@ -70,5 +73,14 @@ export class Preconnect {
       linkEl.setAttribute('as', as);
     }
     this.doc_.head.appendChild(linkEl);
+    this.links_.push(linkEl);
+  }
+
+  /**
+   * Removes the links added by this instance.
+   */
+  destroy() {
+    this.links_.forEach(removeElement);
+    this.links_.length = 0;
   }
 }
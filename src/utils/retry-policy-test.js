@@ -0,0 +1,205 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import {RetryPolicy, getRetryAfterMs} from './retry-policy';
+
+function retriableError(retryAfter) {
+  const err = new Error('HTTP error 503');
+  err.retriable = true;
+  err.response = {
+    status: 503,
+    headers: {get: (name) => (name == 'Retry-After' ? retryAfter : null)},
+  };
+  return err;
+}
+
+describes.realWin('RetryPolicy', {}, () => {
+  let clock;
+  let retries;
+
+  beforeEach(() => {
+    clock = sandbox.useFakeTimers();
+    retries = [];
+  });
+
+  function createPolicy(options) {
+    return new RetryPolicy(
+      Object.assign(
+        {jitter: 0, onRetry: (retry) => retries.push(retry)},
+        options
+      )
+    );
+  }
+
+  it('should resolve with the first successful attempt', async () => {
+    const attempt = sandbox.stub().resolves('ok');
+
+    const result = await createPolicy().run(self, attempt);
+
+    expect(result).to.equal('ok');
+    expect(attempt).to.be.calledOnce;
+    expect(retries).to.be.empty;
+  });
+
+  it('should retry retriable errors with exponential backoff', async () => {
+    const attempt = sandbox.stub();
+    attempt.onCall(0).rejects(retriableError());
+    attempt.onCall(1).rejects(retriableError());
+    attempt.resolves('ok');
+
+    const promise = createPolicy({maxAttempts: 3, initialDelayMs: 100}).run(
+      self,
+      attempt
+    );
+    await clock.tickAsync(100);
+    expect(attempt).to.be.calledTwice;
+    await clock.tickAsync(200);
+
+    expect(await promise).to.equal('ok');
+    expect(attempt).to.be.calledThrice;
+    const delays = retries.map((retry) => [retry.attempt, retry.delayMs]);
+    expect(delays).to.deep.equal([
+      [1, 100],
+      [2, 200],
+    ]);
+  });
+
+  it('should cap delays', async () => {
+    const attempt = sandbox.stub();
+    attempt.onCall(0).rejects(retriableError());
+    attempt.onCall(1).rejects(retriableError());
+    attempt.resolves('ok');
+
+    const promise = createPolicy({initialDelayMs: 300, maxDelayMs: 400}).run(
+      self,
+      attempt
+    );
+    await clock.tickAsync(700);
+
+    expect(await promise).to.equal('ok');
+    expect(retries[1].delayMs).to.equal(400);
+  });
+
+  it('should randomize delays with jitter', async () => {
+    sandbox.stub(Math, 'random').returns(1);
+    const attempt = sandbox.stub();
+    attempt.onCall(0).rejects(retriableError());
+    attempt.resolves('ok');
+
+    const promise = createPolicy({initialDelayMs: 100, jitter: 0.5}).run(
+      self,
+      attempt
+    );
+    await clock.tickAsync(50);
+
+    expect(await promise).to.equal('ok');
+    expect(retries[0].delayMs).to.equal(50);
+  });
+
+  it('should give up after the last attempt', async () => {
+    const error = retriableError();
+    const attempt = sandbox.stub().rejects(error);
+
+    const promise = createPolicy({maxAttempts: 2, initialDelayMs: 10}).run(
+      self,
+      attempt
+    );
+    await clock.tickAsync(10);
+
+    await expect(promise).to.be.rejectedWith(error);
+    expect(attempt).to.be.calledTwice;
+  });
+
+  it('should not retry errors that are not retriable', async () => {
+    const error = new Error('HTTP error 404');
+    error.retriable = false;
+    const attempt = sandbox.stub().rejects(error);
+
+    await expect(createPolicy().run(self, attempt)).to.be.rejectedWith(error);
+    expect(attempt).to.be.calledOnce;
+    expect(retries).to.be.empty;
+  });
+
+  it('should wait for Retry-After', async () => {
+    const attempt = sandbox.stub();
+    attempt.onCall(0).rejects(retriableError('2'));
+    attempt.resolves('ok');
+
+    const promise = createPolicy({initialDelayMs: 100}).run(self, attempt);
+    await clock.tickAsync(1999);
+    expect(attempt).to.be.calledOnce;
+    await clock.tickAsync(1);
+
+    expect(await promise).to.equal('ok');
+    expect(retries[0].delayMs).to.equal(2000);
+  });
+
+  it('should give up if Retry-After exceeds the max delay', async () => {
+    const error = retriableError('60');
+    const attempt = sandbox.stub().rejects(error);
+
+    await expect(
+      createPolicy({maxDelayMs: 10000}).run(self, attempt)
+    ).to.be.rejectedWith(error);
+    expect(attempt).to.be.calledOnce;
+  });
+
+  it('should time out and abort attempts', async () => {
+    const signals = [];
+    const attempt = sandbox.stub().callsFake((signal) => {
+      signals.push(signal);
+      return signals.length == 1 ? new Promise(() => {}) : Promise.resolve(1);
+    });
+
+    const promise = createPolicy({timeoutMs: 1000, initialDelayMs: 10}).run(
+      self,
+      attempt
+    );
+    await clock.tickAsync(1000);
+    expect(signals[0].aborted).to.be.true;
+    expect(retries[0].error.message).to.match(/timed out after 1000ms/);
+    await clock.tickAsync(10);
+
+    expect(await promise).to.equal(1);
+    expect(signals[1].aborted).to.be.false;
+  });
+
+  it('should not pass a signal without a timeout', async () => {
+    const attempt = sandbox.stub().resolves('ok');
+
+    await createPolicy().run(self, attempt);
+
+    expect(attempt).to.be.calledWithExactly(null);
+  });
+
+  describe('getRetryAfterMs', () => {
+    it('should parse seconds', () => {
+      expect(getRetryAfterMs(retriableError('120').response)).to.equal(120000);
+    });
+
+    it('should parse HTTP dates', () => {
+      const date = new Date(Date.now() + 5000).toUTCString();
+
+      expect(getRetryAfterMs(retriableError(date).response)).to.equal(5000);
+    });
+
+    it('should ignore missing and invalid values', () => {
+      expect(getRetryAfterMs(null)).to.be.null;
+      expect(getRetryAfterMs(retriableError().response)).to.be.null;
+      expect(getRetryAfterMs(retriableError('soon').response)).to.be.null;
+    });
+  });
+});
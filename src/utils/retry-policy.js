@@ -0,0 +1,224 @@
+/**
+ * Copyright 2022 The Subscribe with Google Authors. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS-IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+const DEFAULT_MAX_ATTEMPTS = 3;
+const DEFAULT_INITIAL_DELAY_MS = 500;
+const DEFAULT_MAX_DELAY_MS = 10000;
+const DEFAULT_BACKOFF_FACTOR = 2;
+const DEFAULT_JITTER = 0.5;
+
+/**
+ * Options of a RetryPolicy.
+ * - maxAttempts: Number of attempts, including the first one. Defaults to 3.
+ * - initialDelayMs: Delay before the first retry. Defaults to 500.
+ * - maxDelayMs: Longest delay between attempts. A `Retry-After` longer than
+ *   this makes the request fail instead of waiting. Defaults to 10000.
+ * - backoffFactor: Multiplier applied to the delay after each retry. Defaults
+ *   to 2.
+ * - jitter: Fraction of each delay that is randomized, between 0 and 1.
+ *   Defaults to 0.5.
+ * - timeoutMs: Timeout of each attempt. Attempts that time out are aborted
+ *   and retried. Defaults to no timeout.
+ * - onRetry: Called before each retry.
+ *
+ * @typedef {{
+ *   maxAttempts: (number|undefined),
+ *   initialDelayMs: (number|undefined),
+ *   maxDelayMs: (number|undefined),
+ *   backoffFactor: (number|undefined),
+ *   jitter: (number|undefined),
+ *   timeoutMs: (number|undefined),
+ *   onRetry: (function(!RetryInfo)|undefined),
+ * }}
+ */
+export let RetryPolicyOptions;
+
+/**
+ * Describes a retry about to happen.
+ * - attempt: The number of the attempt that failed, starting at 1.
+ * - delayMs: How long until the next attempt.
+ * - error: The error the attempt failed with.
+ *
+ * @typedef {{
+ *   attempt: number,
+ *   delayMs: number,
+ *   error: !Error,
+ * }}
+ */
+export let RetryInfo;
+
+/**
+ * Retries failed requests with exponential backoff and jitter.
+ *
+ * Only errors flagged as `retriable` are retried: network failures, timeouts,
+ * and the HTTP statuses accepted by `isRetriable` in `xhr.js`. When a failed
+ * response has a `Retry-After` header, the next attempt waits at least that
+ * long.
+ */
+export class RetryPolicy {
+  /**
+   * @param {!RetryPolicyOptions=} options
+   */
+  constructor(options = {}) {
+    /** @private @const {number} */
+    this.maxAttempts_ = withDefault(options.maxAttempts, DEFAULT_MAX_ATTEMPTS);
+
+    /** @private @const {number} */
+    this.initialDelayMs_ = withDefault(
+      options.initialDelayMs,
+      DEFAULT_INITIAL_DELAY_MS
+    );
+
+    /** @private @const {number} */
+    this.maxDelayMs_ = withDefault(options.maxDelayMs, DEFAULT_MAX_DELAY_MS);
+
+    /** @private @const {number} */
+    this.backoffFactor_ = withDefault(
+      options.backoffFactor,
+      DEFAULT_BACKOFF_FACTOR
+    );
+
+    /** @private @const {number} */
+    this.jitter_ = withDefault(options.jitter, DEFAULT_JITTER);
+
+    /** @private @const {number} */
+    this.timeoutMs_ = withDefault(options.timeoutMs, 0);
+
+    /** @private @const {?function(!RetryInfo)} */
+    this.onRetry_ = options.onRetry || null;
+  }
+
+  /**
+   * Calls `attempt` until it succeeds, fails with an error that isn't
+   * retriable, or runs out of attempts. When the policy has a timeout, each
+   * attempt receives an AbortSignal that is aborted when the attempt times
+   * out, if the browser supports AbortController.
+   * @param {!Window} win
+   * @param {function(?AbortSignal):!Promise<T>} attempt
+   * @return {!Promise<T>}
+   * @template T
+   */
+  run(win, attempt) {
+    const tryAttempt = (attemptNumber) =>
+      this.runAttempt_(win, attempt).catch((error) => {
+        const delayMs = this.getRetryDelayMs_(error, attemptNumber);
+        if (delayMs == null) {
+          throw error;
+        }
+        if (this.onRetry_) {
+          this.onRetry_({attempt: attemptNumber, delayMs, error});
+        }
+        return new Promise((resolve) => {
+          win.setTimeout(resolve, delayMs);
+        }).then(() => tryAttempt(attemptNumber + 1));
+      });
+    return tryAttempt(1);
+  }
+
+  /**
+   * @param {!Window} win
+   * @param {function(?AbortSignal):!Promise<T>} attempt
+   * @return {!Promise<T>}
+   * @template T
+   * @private
+   */
+  runAttempt_(win, attempt) {
+    if (!this.timeoutMs_) {
+      return attempt(null);
+    }
+    const controller = win.AbortController ? new win.AbortController() : null;
+    let timeoutId;
+    const timeout = new Promise((unusedResolve, reject) => {
+      timeoutId = win.setTimeout(() => {
+        if (controller) {
+          controller.abort();
+        }
+        const err = new Error(`Request timed out after ${this.timeoutMs_}ms`);
+        err.retriable = true;
+        reject(err);
+      }, this.timeoutMs_);
+    });
+    const result = attempt(controller && controller.signal);
+    return Promise.race([result, timeout]).then(
+      (value) => {
+        win.clearTimeout(timeoutId);
+        return value;
+      },
+      (error) => {
+        win.clearTimeout(timeoutId);
+        throw error;
+      }
+    );
+  }
+
+  /**
+   * Returns how long to wait before retrying after the given failed attempt,
+   * or null if the request shouldn't be retried.
+   * @param {*} error
+   * @param {number} attemptNumber
+   * @return {?number}
+   * @private
+   */
+  getRetryDelayMs_(error, attemptNumber) {
+    if (attemptNumber >= this.maxAttempts_ || !error || !error.retriable) {
+      return null;
+    }
+    const backoffMs = Math.min(
+      this.initialDelayMs_ * Math.pow(this.backoffFactor_, attemptNumber - 1),
+      this.maxDelayMs_
+    );
+    const delayMs = Math.round(backoffMs * (1 - this.jitter_ * Math.random()));
+    const retryAfterMs = getRetryAfterMs(error.response);
+    if (retryAfterMs == null) {
+      return delayMs;
+    }
+    if (retryAfterMs > this.maxDelayMs_) {
+      return null;
+    }
+    return Math.max(delayMs, retryAfterMs);
+  }
+}
+
+/**
+ * Parses the `Retry-After` header of a response, which is either a number of
+ * seconds or an HTTP date. Returns null if the header is missing or invalid.
+ * @param {?./xhr.FetchResponse|?Response|undefined} response
+ * @return {?number}
+ */
+export function getRetryAfterMs(response) {
+  const value =
+    response && response.headers && response.headers.get('Retry-After');
+  if (!value) {
+    return null;
+  }
+  if (/^\s*\d+\s*$/.test(value)) {
+    return parseInt(value, 10) * 1000;
+  }
+  const date = Date.parse(value);
+  if (isNaN(date)) {
+    return null;
+  }
+  return Math.max(date - Date.now(), 0);
+}
+
+/**
+ * @param {number|undefined} value
+ * @param {number} defaultValue
+ * @return {number}
+ */
+function withDefault(value, defaultValue) {
+  return value == null ? defaultValue : value;
+}
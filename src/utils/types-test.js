@@ -58,6 +58,24 @@ describes.realWin('Types', {}, () => {
     });
   });
 
+  describe('getEnumName', () => {
+    /** @enum {number} */
+    const enumObj = {
+      X: 0,
+      Y: 1,
+    };
+
+    it('should return the names of enum values', () => {
+      expect(types.getEnumName(enumObj, 0)).to.equal('X');
+      expect(types.getEnumName(enumObj, 1)).to.equal('Y');
+    });
+
+    it('should return the values that are not in the enum', () => {
+      expect(types.getEnumName(enumObj, 2)).to.equal(2);
+      expect(types.getEnumName(enumObj, '1')).to.equal('1');
+    });
+  });
+
   describe('isObject', () => {
     it('identifies objects', () => {
       const values = [{}, {x: 1}];
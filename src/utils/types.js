@@ -41,6 +41,19 @@ export function isEnumValue(enumObj, s) {
   return false;
 }
 
+/**
+ * Returns the name of a value of `enumObj`, e.g. for logging.
+ *
+ * @param {!Object<T>} enumObj
+ * @param {*} value
+ * @return {*} The name of the value, or the value if it isn't in the enum.
+ * @template T
+ */
+export function getEnumName(enumObj, value) {
+  const name = Object.keys(enumObj).find((key) => enumObj[key] === value);
+  return name || value;
+}
+
 /**
  * True if the value is a function.
  * @param {*} value
@@ -307,6 +307,8 @@ describe('serializeProtoMessageForUrl', () => {
       'othertxid',
       true,
       'subscriptions',
+      9.99,
+      'USD',
     ];
     const analyticsRequestArray = [
       'AnalyticsRequest',
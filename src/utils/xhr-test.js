@@ -153,6 +153,42 @@ describes.realWin('test', {}, () => {
             const request = await xhrCreated;
             expect(request.method).to.equal('POST');
           });
+
+          it('should flag network failures as retriable', async () => {
+            const promise = xhr.fetch('/abc');
+            const request = await xhrCreated;
+            request.error();
+
+            const err = await promise.then(
+              () => null,
+              (reason) => reason
+            );
+            expect(err.message).to.match(/XHR Failed fetching/);
+            expect(err.retriable).to.be.true;
+          });
+
+          it('should abort the request when the signal aborts', async () => {
+            const controller = new AbortController();
+            const promise = xhr.fetch('/abc', {signal: controller.signal});
+            const request = await xhrCreated;
+            controller.abort();
+
+            const err = await promise.then(
+              () => null,
+              (reason) => reason
+            );
+            expect(request.aborted).to.be.true;
+            expect(err.retriable).to.be.false;
+          });
+
+          it('should not send the request if the signal is aborted', async () => {
+            const controller = new AbortController();
+            controller.abort();
+
+            await expect(
+              xhr.fetch('/abc', {signal: controller.signal})
+            ).to.be.rejectedWith(/XHR Failed fetching/);
+          });
         });
       }
 
@@ -197,6 +233,30 @@ describes.realWin('test', {}, () => {
             }
           });
 
+          it('should flag retriable statuses', async () => {
+            for (const status of [408, 429, 500, 503]) {
+              mockXhr.status = status;
+              const err = await assertSuccess(
+                createResponseInstance('', mockXhr)
+              ).then(
+                () => null,
+                (reason) => reason
+              );
+              expect(err.retriable).to.be.true;
+            }
+          });
+
+          it('should not flag client errors as retriable', async () => {
+            mockXhr.status = 404;
+            const err = await assertSuccess(
+              createResponseInstance('', mockXhr)
+            ).then(
+              () => null,
+              (reason) => reason
+            );
+            expect(err.retriable).to.be.false;
+          });
+
           it('should not resolve after rejecting promise', async () => {
             mockXhr.status = 500;
             mockXhr.responseText = '{"a": "hello"}';
@@ -30,7 +30,8 @@ import {utf8EncodeSync} from './bytes';
  *   credentials: (string|undefined),
  *   headers: (!Object|undefined),
  *   method: (string|undefined),
- *   responseType: (string)
+ *   responseType: (string),
+ *   signal: (?AbortSignal|undefined)
  * }}
  */
 export let FetchInitDef;
@@ -101,10 +102,14 @@ export class Xhr {
          * publisher towards the real problem.
          */
         const targetOrigin = parseUrl(input).origin;
-        throw new Error(
+        const err = new Error(
           `XHR Failed fetching (${targetOrigin}/...): (Note: a CORS error above may indicate that this publisher or domain is not configured in Publisher Center. The CORS error happens becasue 4xx responses do not set CORS headers.)`,
           reason && reason.message
         );
+        // Network failures are usually transient. Aborted requests are not
+        // retried: they were aborted on purpose.
+        err.retriable = !(init.signal && init.signal.aborted);
+        throw err;
       })
       .then((response) => assertSuccess(response));
   }
@@ -204,6 +209,14 @@ export function fetchPolyfill(input, init) {
       reject(new Error('Request aborted'));
     };
 
+    if (init.signal) {
+      if (init.signal.aborted) {
+        reject(new Error('Request aborted'));
+        return;
+      }
+      init.signal.addEventListener('abort', () => xhr.abort());
+    }
+
     if (init.method == 'POST') {
       xhr.send(init.body);
     } else {
@@ -229,11 +242,16 @@ function createXhrRequest(method, url) {
 }
 
 /**
- * If 415 or in the 5xx range.
+ * If 408, 415, 429 or in the 5xx range.
  * @param {number} status
  */
 function isRetriable(status) {
-  return status == 415 || (status >= 500 && status < 600);
+  return (
+    status == 408 ||
+    status == 415 ||
+    status == 429 ||
+    (status >= 500 && status < 600)
+  );
 }
 
 /**